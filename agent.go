@@ -0,0 +1,165 @@
+//go:build !minimal
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/jackcvr/tcpw/tcpw"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"time"
+)
+
+// probeSchemes are schemes of endpoints checked for remote clients, by the agent and the job API of 'serve':
+// network probes only, never local paths, scripts or commands.
+var probeSchemes = []string{"tcp", "udp", "tls", "http", "https"}
+
+// CheckProbeScheme reports an error unless the endpoint requested by a remote client is of probeSchemes.
+func CheckProbeScheme(value string) error {
+	if scheme := tcpw.Scheme(value); !slices.Contains(probeSchemes, scheme) {
+		return fmt.Errorf("'%s' endpoints are not checked for remote clients, expected %s", scheme, tcpw.QuoteList(probeSchemes))
+	}
+	return nil
+}
+
+// ParseProbeEndpoint is ParseEndpoint of endpoints requested by remote clients, which must be of probeSchemes.
+// The scheme is checked first, so nothing local, e.g. a script, is touched for other endpoints.
+func ParseProbeEndpoint(value string) (string, error) {
+	if err := CheckProbeScheme(value); err != nil {
+		return "", err
+	}
+	return ParseEndpoint(value)
+}
+
+// Authorized reports whether the request has the token as a bearer token, which must be set.
+func Authorized(r *http.Request, token string) bool {
+	return token != "" && subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte("Bearer "+token)) == 1
+}
+
+// AgentRequest is a connection attempt requested from the agent by tcpw running with '-agent'.
+type AgentRequest struct {
+	Endpoint  string `json:"endpoint"`
+	TimeoutMs int64  `json:"timeout_ms,omitempty"`
+}
+
+// AgentServer makes connection attempts on behalf of tcpw running with '-agent', from the network of the agent:
+//
+//	POST /v1/dial - a single connection attempt, e.g. {"endpoint": "db:5432", "timeout_ms": 1000}
+//
+// Requests must have the '-agent-token' as a bearer token, if it is set, which is required unless the agent listens on localhost.
+// Only endpoints of probeSchemes are checked.
+type AgentServer struct {
+	app App
+	mux *http.ServeMux
+}
+
+func NewAgentServer(app App) *AgentServer {
+	s := &AgentServer{app: app, mux: http.NewServeMux()}
+	s.mux.HandleFunc("POST /v1/dial", s.dial)
+	return s
+}
+
+func (s *AgentServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+func (s *AgentServer) dial(w http.ResponseWriter, r *http.Request) {
+	if s.app.agentToken != "" && !Authorized(r, s.app.agentToken) {
+		writeJSONError(w, http.StatusUnauthorized, errors.New("invalid agent token"))
+		return
+	}
+	var req AgentRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, grpcMaxMessage)).Decode(&req); err != nil {
+		writeJSONError(w, http.StatusBadRequest, err)
+		return
+	}
+	addr, err := ParseProbeEndpoint(req.Endpoint)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, fmt.Errorf("invalid endpoint %s: %w", req.Endpoint, err))
+		return
+	}
+	ctx := r.Context()
+	var d net.Dialer
+	if req.TimeoutMs > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(req.TimeoutMs)*time.Millisecond)
+		defer cancel()
+		d.Timeout = time.Duration(req.TimeoutMs) * time.Millisecond
+	}
+	if s.app.dialTimeout > 0 {
+		d.Timeout = s.app.dialTimeout
+	}
+	start := time.Now()
+	err = s.app.Dial(ctx, d, addr)
+	if err != nil {
+		s.app.Debug("attempt of %s for %s failed: %v", addr, r.RemoteAddr, err)
+	} else {
+		s.app.Debug("attempt of %s for %s succeeded", addr, r.RemoteAddr)
+	}
+	writeJSON(w, http.StatusOK, NewAttemptResult(err, time.Since(start)))
+}
+
+// RunAgent serves connection attempts to tcpw running with '-agent' on the address of 'agent' subcommand until interrupted.
+func (app App) RunAgent() error {
+	l, err := net.Listen("tcp", app.agentListen)
+	if err != nil {
+		app.Error("%v", err)
+		return err
+	}
+	srv := &http.Server{Handler: NewAgentServer(app), ReadHeaderTimeout: 5 * time.Second}
+	go func() {
+		_ = srv.Serve(l)
+	}()
+	app.Info("agent listening on %s", l.Addr())
+	interrupts, stop := Interrupts()
+	defer stop()
+	<-interrupts
+	return srv.Close()
+}
+
+// DialAgent makes a single connection attempt from the agent of '-agent'.
+// Errors of the agent itself, e.g. an endpoint it does not support, are fatal, unless the agent is not reachable.
+func (app App) DialAgent(ctx context.Context, addr string) error {
+	req := AgentRequest{Endpoint: addr}
+	if deadline, ok := ctx.Deadline(); ok {
+		req.TimeoutMs = max(time.Until(deadline).Milliseconds(), 1)
+	}
+	body, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	r, err := http.NewRequestWithContext(ctx, http.MethodPost, "http://"+app.agent+"/v1/dial", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	r.Header.Set("Content-Type", "application/json")
+	if app.agentToken != "" {
+		r.Header.Set("Authorization", "Bearer "+app.agentToken)
+	}
+	resp, err := http.DefaultClient.Do(r)
+	if err != nil {
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		// not an error of the endpoint, e.g. the agent is restarting
+		return &AttemptError{Msg: fmt.Sprintf("agent %s: %v", app.agent, err)}
+	}
+	defer resp.Body.Close()
+	var result AttemptResult // or the error of a rejected request
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("agent %s: %s: %w", app.agent, resp.Status, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return &AttemptError{Msg: fmt.Sprintf("agent %s: %s", app.agent, result.Error), Fatal: true}
+	}
+	app.Trace("%s: attempt from agent %s in %.3fms", addr, app.agent, result.LatencyMs)
+	return result.Err(fmt.Sprintf("agent %s: ", app.agent))
+}
@@ -0,0 +1,100 @@
+//go:build !minimal
+
+package main
+
+import (
+	"context"
+	"errors"
+	"github.com/jackcvr/tcpw/tcpw"
+	"net"
+	"net/http/httptest"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestAgent(t *testing.T) {
+	agentApp := newApp()
+	agentApp.agentToken = "secret"
+	srv := httptest.NewServer(NewAgentServer(agentApp))
+	defer srv.Close()
+	agent := strings.TrimPrefix(srv.URL, "http://")
+	l := serve("127.0.0.1:0")
+	defer l.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	t.Run("Test attempts", func(t *testing.T) {
+		app := newApp()
+		if err := app.Parse("tcpw", []string{"-a", "db=remote.invalid:5432", "-agent", agent, "-agent-token", "secret"}); err != nil {
+			t.Fatal(err)
+		}
+		// hosts are resolved by the agent
+		if app.endpoints[0] != "remote.invalid:5432" || app.labels["remote.invalid:5432"] != "db" {
+			t.Fatalf("Unexpected endpoints: %v", app.endpoints)
+		}
+		if err := app.Dial(ctx, net.Dialer{}, l.Addr().String()); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		err := app.Dial(ctx, net.Dialer{}, getFreeTCPAddr().String())
+		if !errors.Is(err, syscall.ECONNREFUSED) || IsFatal(err) || !strings.HasPrefix(err.Error(), "agent "+agent+": dial tcp") {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		for _, addr := range []string{"foo://bar", "path:///etc/shadow", "script:///tmp/check.star"} {
+			err = app.Dial(ctx, net.Dialer{}, addr)
+			if !IsFatal(err) || err.Error() != "agent "+agent+": invalid endpoint "+addr+": '"+tcpw.Scheme(addr)+"' endpoints are not checked "+
+				"for remote clients, expected 'tcp', 'udp', 'tls', 'http' or 'https'" {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+		}
+	})
+
+	t.Run("Test rejected", func(t *testing.T) {
+		app := newApp()
+		app.agent = agent
+		if err := app.Dial(ctx, net.Dialer{}, l.Addr().String()); !IsFatal(err) || err.Error() != "agent "+agent+": invalid agent token" {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		app.agent = getFreeTCPAddr().String()
+		err := app.Dial(ctx, net.Dialer{}, l.Addr().String())
+		if IsFatal(err) || errors.Is(err, syscall.ECONNREFUSED) || !strings.HasPrefix(err.Error(), "agent "+app.agent+": dial tcp") {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	})
+
+	t.Run("Test subcommand", func(t *testing.T) {
+		for args, want := range map[string]string{
+			"agent":                      DefaultAgentListen,
+			"agent -v localhost:9000":    "localhost:9000",
+			"agent -agent-token s :9000": ":9000",
+		} {
+			var app App
+			if err := app.ParseArgs(append([]string{"tcpw"}, strings.Fields(args)...)); err != nil {
+				t.Fatal(err)
+			}
+			if err := app.Check(); err != nil || app.agentListen != want {
+				t.Fatalf("Unexpected address of %s: %s, %v", args, app.agentListen, err)
+			}
+		}
+		var app App
+		if err := app.ParseArgs([]string{"tcpw", "agent", "-a", "localhost:5432"}); err != nil {
+			t.Fatal(err)
+		}
+		if err := app.Check(); err == nil || err.Error() != "'agent' can not be combined with endpoints or a command" {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		for args, want := range map[string]string{
+			"agent :9000":                          "'agent' listening on :9000 beyond localhost requires '-agent-token'",
+			"agent -check-cmd true 127.0.0.1:9000": "'agent' can not be combined with '-check-cmd' or '-wasm-check', which would run them for endpoints of remote clients",
+		} {
+			var app App
+			if err := app.ParseArgs(append([]string{"tcpw"}, strings.Fields(args)...)); err != nil {
+				t.Fatal(err)
+			}
+			if err := app.Check(); err == nil || err.Error() != want {
+				t.Fatalf("Unexpected error of %s: %v", args, err)
+			}
+		}
+	})
+}
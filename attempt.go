@@ -0,0 +1,50 @@
+package main
+
+import (
+	"errors"
+	"syscall"
+	"time"
+)
+
+// AttemptResult is the result of a connection attempt made elsewhere: by the agent of '-agent', or in the run recorded with '-record'.
+type AttemptResult struct {
+	Error     string  `json:"error,omitempty"`
+	Fatal     bool    `json:"fatal,omitempty"`   // the error won't go away by retrying, e.g. an unknown host
+	Refused   bool    `json:"refused,omitempty"` // the endpoint refused the connection
+	LatencyMs float64 `json:"latency_ms"`
+}
+
+// NewAttemptResult returns the result of the attempt which took the latency and failed with err, unless it is nil.
+func NewAttemptResult(err error, latency time.Duration) AttemptResult {
+	result := AttemptResult{LatencyMs: float64(latency) / float64(time.Millisecond)}
+	if err != nil {
+		result.Error = err.Error()
+		result.Fatal = IsFatal(err)
+		result.Refused = errors.Is(err, syscall.ECONNREFUSED)
+	}
+	return result
+}
+
+// Err returns the error of the attempt with the prefix, e.g. 'agent host:port: ', or nil if it succeeded.
+func (r AttemptResult) Err(prefix string) error {
+	if r.Error == "" {
+		return nil
+	}
+	return &AttemptError{Msg: prefix + r.Error, Fatal: r.Fatal, Refused: r.Refused}
+}
+
+// AttemptError is the error of a connection attempt made elsewhere, see AttemptResult, which keeps whether it is fatal or refused.
+type AttemptError struct {
+	Msg     string
+	Fatal   bool
+	Refused bool
+}
+
+func (e *AttemptError) Error() string {
+	return e.Msg
+}
+
+// Is reports connection refused errors of the endpoint as syscall.ECONNREFUSED, so they have the exit code of '-exit-refused'.
+func (e *AttemptError) Is(target error) bool {
+	return e.Refused && target == syscall.ECONNREFUSED
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+)
+
+// CheckCmd runs '-check-cmd' shell command for the endpoint instead of connecting to it,
+// with placeholders of CmdData, e.g. '{{.Addr}}', replaced by those of the endpoint.
+// The endpoint is available if the command exits with zero, otherwise its last line of output is the error.
+func (app App) CheckCmd(ctx context.Context, addr string) error {
+	data := NewCmdData([]EndpointResult{{Addr: addr, Label: app.labels[addr]}}, 0)
+	data.Env = app.env.Map()
+	script, err := ExecuteTemplate(app.checkCmd, data)
+	if err != nil {
+		return err
+	}
+	cmd := exec.CommandContext(ctx, Shell(), "-c", script)
+	cmd.Dir = app.workdir
+	cmd.Env = append(os.Environ(), app.env...)
+	cmd.Env = append(cmd.Env, "TCPW_ENDPOINT="+addr)
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	if err = RunProcess(cmd); err != nil {
+		lines := bytes.Split(bytes.TrimSpace(out.Bytes()), []byte("\n"))
+		if last := lines[len(lines)-1]; len(last) > 0 {
+			return fmt.Errorf("%w: %s", err, last)
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"context"
+	"net"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCheckCmd(t *testing.T) {
+	dir := t.TempDir()
+	var app App
+	if err := app.Parse("tcpw", []string{"-check-cmd", "test -e {{.Host}}-{{.Port}} || { echo \"no $TCPW_ENDPOINT\"; exit 1; }", "-workdir", dir, "-a", "db=127.0.0.1:5432"}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	err := app.Dial(ctx, net.Dialer{}, app.endpoints[0])
+	if err == nil || !strings.HasSuffix(err.Error(), "exit status 1: no 127.0.0.1:5432") {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err = os.WriteFile(dir+"/127.0.0.1-5432", nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err = app.Dial(ctx, net.Dialer{}, app.endpoints[0]); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	app.checkCmd = "{{.Missing"
+	if err = app.Check(); err == nil {
+		t.Fatal("Unexpected success")
+	}
+}
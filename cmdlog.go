@@ -0,0 +1,74 @@
+package main
+
+import (
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// CmdLog is a file receiving a copy of the command output.
+// The first write error is passed to onError and the rest of the output is dropped,
+// so that a full disk does not break the pipes of the command.
+type CmdLog struct {
+	mu         sync.Mutex
+	file       *os.File
+	timestamps bool
+	onError    func(error)
+	failed     bool
+}
+
+func OpenCmdLog(path string, timestamps bool, onError func(error)) (*CmdLog, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &CmdLog{file: f, timestamps: timestamps, onError: onError}, nil
+}
+
+func (l *CmdLog) Close() error {
+	return l.file.Close()
+}
+
+// Writer returns a writer for a single output stream,
+// which is written to the log line by line when timestamps are enabled.
+func (l *CmdLog) Writer() io.Writer {
+	return &cmdLogWriter{log: l, bol: true}
+}
+
+type cmdLogWriter struct {
+	log *CmdLog
+	bol bool
+}
+
+// Write always succeeds, as io.MultiWriter would stop copying the output to the terminal otherwise.
+func (w *cmdLogWriter) Write(p []byte) (int, error) {
+	w.log.mu.Lock()
+	defer w.log.mu.Unlock()
+	if w.log.failed {
+		return len(p), nil
+	}
+	if !w.log.timestamps {
+		w.log.write(p)
+		return len(p), nil
+	}
+	var buf []byte
+	for _, b := range p {
+		if w.bol {
+			buf = time.Now().AppendFormat(buf, "2006/01/02 15:04:05.000000 ")
+		}
+		buf = append(buf, b)
+		w.bol = b == '\n'
+	}
+	w.log.write(buf)
+	return len(p), nil
+}
+
+func (l *CmdLog) write(p []byte) {
+	if _, err := l.file.Write(p); err != nil {
+		l.failed = true
+		if l.onError != nil {
+			l.onError(err)
+		}
+	}
+}
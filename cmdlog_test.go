@@ -0,0 +1,72 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"regexp"
+	"testing"
+)
+
+func TestCmdLog(t *testing.T) {
+	t.Run("Test command output is copied", func(t *testing.T) {
+		app := newApp()
+		app.endpoints = []string{startListener("").String()}
+		app.cmdLogPath = t.TempDir() + "/cmd.log"
+		app.shell = "echo out; echo err >&2"
+		if err := app.Run(); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		data, err := os.ReadFile(app.cmdLogPath)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if s := string(data); s != "out\nerr\n" && s != "err\nout\n" {
+			t.Fatalf("Wrong log content: %q", s)
+		}
+	})
+
+	t.Run("Test timestamps", func(t *testing.T) {
+		path := t.TempDir() + "/cmd.log"
+		l, err := OpenCmdLog(path, true, nil)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		w := l.Writer()
+		for _, s := range []string{"first ", "line\nsecond", " line\n"} {
+			if _, err = w.Write([]byte(s)); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+		}
+		if err = l.Close(); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		ts := `\d{4}/\d\d/\d\d \d\d:\d\d:\d\d\.\d{6} `
+		if !regexp.MustCompile("^" + ts + "first line\n" + ts + "second line\n$").Match(data) {
+			t.Fatalf("Wrong log content: %q", data)
+		}
+	})
+
+	t.Run("Test write errors are reported once", func(t *testing.T) {
+		var errs []error
+		l, err := OpenCmdLog(t.TempDir()+"/cmd.log", false, func(err error) { errs = append(errs, err) })
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if err = l.Close(); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		w := l.Writer()
+		for _, s := range []string{"first\n", "second\n"} {
+			if n, err := w.Write([]byte(s)); n != len(s) || err != nil {
+				t.Fatalf("Wrong write result: %d, %v", n, err)
+			}
+		}
+		if len(errs) != 1 || !errors.Is(errs[0], os.ErrClosed) {
+			t.Fatalf("Wrong reported errors: %v", errs)
+		}
+	})
+}
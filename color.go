@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+)
+
+const (
+	StatusUp       = "up"
+	StatusDown     = "down"
+	StatusRetrying = "retrying"
+)
+
+const (
+	colorReset  = "\033[0m"
+	colorRed    = "\033[31m"
+	colorGreen  = "\033[32m"
+	colorYellow = "\033[33m"
+)
+
+var statusColors = map[string]string{
+	StatusUp:       colorGreen,
+	StatusDown:     colorRed,
+	StatusRetrying: colorYellow,
+}
+
+// Colored reports whether log lines are colored according to '-color':
+// with 'auto' only if log lines are printed to stderr, which is a terminal, and NO_COLOR is not set.
+func (app App) Colored() bool {
+	switch app.color {
+	case "always":
+		return true
+	case "never":
+		return false
+	}
+	return app.logFile == nil && os.Getenv("NO_COLOR") == "" && os.Getenv("TERM") != "dumb" && IsTerminal(os.Stderr)
+}
+
+// Colorize prepends the status, if any, to the message as an aligned tag in its color.
+// Without the status, errors and warnings are colored red and yellow.
+func Colorize(level slog.Level, status, msg string) string {
+	if status != "" {
+		return fmt.Sprintf("%s%-8s%s %s", statusColors[status], status, colorReset, msg)
+	}
+	switch {
+	case level >= slog.LevelError:
+		return colorRed + msg + colorReset
+	case level >= slog.LevelWarn:
+		return colorYellow + msg + colorReset
+	}
+	return msg
+}
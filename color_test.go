@@ -0,0 +1,38 @@
+package main
+
+import (
+	"log/slog"
+	"testing"
+)
+
+func TestColorize(t *testing.T) {
+	t.Run("Test status tag is aligned and colored", func(t *testing.T) {
+		if got := Colorize(slog.LevelInfo, StatusUp, "connected"); got != "\033[32mup      \033[0m connected" {
+			t.Fatalf("Unexpected line: %q", got)
+		}
+		if got := Colorize(slog.LevelDebug, StatusRetrying, "failed"); got != "\033[33mretrying\033[0m failed" {
+			t.Fatalf("Unexpected line: %q", got)
+		}
+	})
+
+	t.Run("Test errors are colored without status", func(t *testing.T) {
+		if got := Colorize(slog.LevelError, "", "timeout error"); got != "\033[31mtimeout error\033[0m" {
+			t.Fatalf("Unexpected line: %q", got)
+		}
+		if got := Colorize(slog.LevelInfo, "", "message"); got != "message" {
+			t.Fatalf("Unexpected line: %q", got)
+		}
+	})
+
+	t.Run("Test -color always and never", func(t *testing.T) {
+		app := newApp()
+		app.color = "always"
+		if !app.Colored() {
+			t.Fatal("Output is not colored")
+		}
+		app.color = "never"
+		if app.Colored() {
+			t.Fatal("Output is colored")
+		}
+	})
+}
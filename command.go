@@ -0,0 +1,241 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/jackcvr/tcpw/tcpw"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"text/template"
+	"time"
+)
+
+type Env []string
+
+func (env *Env) String() string {
+	return strings.Join(*env, ", ")
+}
+
+func (env *Env) Set(value string) error {
+	if k, _, ok := strings.Cut(value, "="); !ok || k == "" {
+		return errors.New("must be in the form 'KEY=VALUE'")
+	}
+	*env = append(*env, value)
+	return nil
+}
+
+type Strings []string
+
+func (s *Strings) String() string {
+	return strings.Join(*s, ", ")
+}
+
+func (s *Strings) Set(value string) error {
+	*s = append(*s, value)
+	return nil
+}
+
+func (app App) ShouldExec(err error) bool {
+	var intErr *InterruptError
+	if errors.As(err, &intErr) {
+		return false // tcpw is asked to stop
+	}
+	return (app.on == "s" && err == nil) || (app.on == "f" && err != nil) || app.on == "any"
+}
+
+// Args returns the command line to execute, or nil if there is none.
+func (app App) Args() []string {
+	if app.shell != "" {
+		return []string{Shell(), "-c", app.shell}
+	}
+	if len(app.command) > 0 {
+		return app.command
+	}
+	return nil
+}
+
+// Commands returns command lines to execute in sequence: '-run' shell commands followed by the command.
+func (app App) Commands() [][]string {
+	var commands [][]string
+	for _, run := range app.runs {
+		commands = append(commands, []string{Shell(), "-c", run})
+	}
+	if args := app.Args(); args != nil {
+		commands = append(commands, args)
+	}
+	return commands
+}
+
+// CmdData holds values for template placeholders in command arguments, e.g. '{{.Addr}}'.
+// With multiple results values are joined with ','.
+type CmdData struct {
+	Host      string
+	Port      string
+	Addr      string
+	Label     string
+	LatencyMs int64
+	Env       map[string]string // variables of '-env' and '-env-file'
+}
+
+func NewCmdData(results []EndpointResult, elapsed time.Duration) CmdData {
+	var hosts, ports, addrs, labels []string
+	for _, r := range results {
+		host, port := tcpw.HostPort(r.Addr)
+		hosts = append(hosts, host)
+		ports = append(ports, port)
+		addrs = append(addrs, r.Addr)
+		labels = append(labels, r.Name())
+	}
+	return CmdData{
+		Host:      strings.Join(hosts, ","),
+		Port:      strings.Join(ports, ","),
+		Addr:      strings.Join(addrs, ","),
+		Label:     strings.Join(labels, ","),
+		LatencyMs: elapsed.Milliseconds(),
+	}
+}
+
+// ExecuteTemplate replaces template placeholders of CmdData in s.
+func ExecuteTemplate(s string, data CmdData) (string, error) {
+	if !strings.Contains(s, "{{") {
+		return s, nil
+	}
+	tmpl, err := template.New("arg").Parse(s)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	if err = tmpl.Execute(&b, data); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// Expand replaces template placeholders in arg.
+// In '-each' mode '{}' is replaced with the endpoint as well.
+// The arg is kept as is if it is not a template of CmdData, e.g. '{{.Names}}' of 'docker ps --format'.
+func (app App) Expand(arg string, data CmdData) string {
+	if app.each {
+		arg = strings.ReplaceAll(arg, "{}", data.Addr)
+	}
+	if expanded, err := ExecuteTemplate(arg, data); err == nil {
+		return expanded
+	}
+	return arg
+}
+
+// IsScript reports whether the command line is a shell script, e.g. of '-c' or '-run'.
+func IsScript(args []string) bool {
+	return len(args) == 3 && args[0] == Shell() && args[1] == "-c"
+}
+
+// Cmd returns the command to execute for the command line, or nil if it is empty.
+// Placeholders are not replaced in shell scripts, which get the endpoints from the environment.
+func (app App) Cmd(args []string, results []EndpointResult, elapsed time.Duration) *exec.Cmd {
+	if len(args) == 0 {
+		return nil
+	}
+	expanded := args
+	if !IsScript(args) {
+		data := NewCmdData(results, elapsed)
+		data.Env = app.env.Map()
+		expanded = make([]string, len(args))
+		for i, arg := range args {
+			expanded[i] = app.Expand(arg, data)
+		}
+	}
+	cmd := exec.Command(expanded[0], expanded[1:]...)
+	cmd.Dir = app.workdir
+	cmd.Env = append(os.Environ(), app.env...)
+	if results != nil {
+		cmd.Env = append(cmd.Env, ResultEnv(results, elapsed)...)
+	}
+	app.user.Apply(cmd)
+	if !app.noStdin {
+		cmd.Stdin = os.Stdin
+	}
+	SetProcessGroup(cmd)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	// a copy is written through a pipe, so the output of the command is no longer a terminal, if it was
+	if app.cmdLog != nil {
+		cmd.Stdout = io.MultiWriter(os.Stdout, app.cmdLog.Writer())
+		cmd.Stderr = io.MultiWriter(os.Stderr, app.cmdLog.Writer())
+	}
+	return cmd
+}
+
+// LaunchError is returned if the command could not be started.
+type LaunchError struct {
+	Err error
+}
+
+func (e *LaunchError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *LaunchError) Unwrap() error {
+	return e.Err
+}
+
+// Exec runs the commands, if any, for the given results, stopping at the first failure.
+func (app App) Exec(results []EndpointResult, elapsed time.Duration) error {
+	for _, args := range app.Commands() {
+		if err := app.ExecArgs(args, results, elapsed); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ExecArgs runs the command line. Failed command is retried up to '-cmd-retries' times.
+func (app App) ExecArgs(args []string, results []EndpointResult, elapsed time.Duration) error {
+	for attempt := 1; ; attempt++ {
+		cmd, exited, err := app.Start(args, results, elapsed)
+		if err == nil {
+			err = app.Wait(cmd, exited)
+		}
+		var intErr *InterruptError
+		if err == nil || attempt > app.cmdRetries || errors.As(err, &intErr) {
+			return err
+		}
+		app.Warn("command failed: %v, retrying in %v (%d/%d)...", err, app.cmdRetryInterval, attempt, app.cmdRetries)
+		time.Sleep(app.cmdRetryInterval)
+	}
+}
+
+// ResultEnv returns environment variables describing the results of the wait.
+func ResultEnv(results []EndpointResult, elapsed time.Duration) []string {
+	result := "success"
+	var failed []string
+	var env []string
+	for _, r := range results {
+		if !r.Ready {
+			result = "failure"
+			failed = append(failed, r.Name())
+		} else {
+			env = append(env, fmt.Sprintf("TCPW_%s_ADDR=%s", EnvName(r.Name()), r.Addr))
+		}
+	}
+	return append(env,
+		"TCPW_RESULT="+result,
+		"TCPW_FAILED_ENDPOINTS="+strings.Join(failed, ","),
+		fmt.Sprintf("TCPW_WAIT_DURATION_MS=%d", elapsed.Milliseconds()),
+	)
+}
+
+// EnvName converts s to a valid environment variable name part,
+// e.g. 'db.local:5432' to 'DB_LOCAL_5432'.
+func EnvName(s string) string {
+	return strings.Map(func(r rune) rune {
+		if (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		if r >= 'a' && r <= 'z' {
+			return r - 'a' + 'A'
+		}
+		return '_'
+	}, s)
+}
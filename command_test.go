@@ -0,0 +1,163 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEnvSet(t *testing.T) {
+	var env Env
+	if err := env.Set("KEY=a=b"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := env.Set("KEY"); err == nil {
+		t.Fatal("Unexpected success")
+	}
+	if err := env.Set("=VALUE"); err == nil {
+		t.Fatal("Unexpected success")
+	}
+	if len(env) != 1 || env[0] != "KEY=a=b" {
+		t.Fatalf("Wrong env: %v", env)
+	}
+}
+
+func TestEnvFile(t *testing.T) {
+	var env Env
+	content := "# database\nexport DB_HOST=db\nDB_PORT = 5432 # default\nGREETING=\"hello\\nworld\"\nRAW='a\\n #b'\n\n"
+	if err := (EnvFile{&env}).Load(strings.NewReader(content)); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	want := []string{"DB_HOST=db", "DB_PORT=5432", "GREETING=hello\nworld", "RAW=a\\n #b"}
+	if !slices.Equal(env, want) {
+		t.Fatalf("Wrong env: %q", env)
+	}
+	if err := (EnvFile{&env}).Load(strings.NewReader("DB_HOST=db\nnot a variable\n")); err == nil || err.Error() != "line 2: 'KEY=VALUE' expected" {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	app := newApp()
+	app.env = Env{"DB_HOST=db", "DB_PORT=5432", "DB_PORT=5433"}
+	app.command = []string{"psql", "-h", "{{.Env.DB_HOST}}", "-p", "{{.Env.DB_PORT}}"}
+	cmd := app.Cmd(app.Args(), nil, 0)
+	if !slices.Equal(cmd.Args[1:], []string{"-h", "db", "-p", "5433"}) {
+		t.Fatalf("Wrong args: %q", cmd.Args)
+	}
+}
+
+func TestResultEnv(t *testing.T) {
+	results := []EndpointResult{
+		{Addr: "127.0.0.1:5432", Ready: true},
+		{Addr: "127.0.0.1:6379", Err: errors.New("timeout")},
+		{Addr: "10.3.2.1:5432", Label: "db", Ready: true},
+		{Addr: "10.3.2.2:6379", Label: "cache", Err: errors.New("timeout")},
+	}
+	env := ResultEnv(results, 1500*time.Millisecond)
+	expected := []string{
+		"TCPW_127_0_0_1_5432_ADDR=127.0.0.1:5432",
+		"TCPW_DB_ADDR=10.3.2.1:5432",
+		"TCPW_RESULT=failure",
+		"TCPW_FAILED_ENDPOINTS=127.0.0.1:6379,cache",
+		"TCPW_WAIT_DURATION_MS=1500",
+	}
+	if !slices.Equal(env, expected) {
+		t.Fatalf("Wrong env: %v", env)
+	}
+}
+
+func TestAppExpand(t *testing.T) {
+	data := NewCmdData([]EndpointResult{{Addr: "127.0.0.1:5432"}}, 42*time.Millisecond)
+
+	t.Run("Test template placeholders", func(t *testing.T) {
+		app := newApp()
+		arg := app.Expand("{{.Host}} {{.Port}} {{.Addr}} up after {{.LatencyMs}}ms {}", data)
+		if arg != "127.0.0.1 5432 127.0.0.1:5432 up after 42ms {}" {
+			t.Fatalf("Wrong expansion: %s", arg)
+		}
+	})
+
+	t.Run("Test '{}' placeholder (-each)", func(t *testing.T) {
+		app := newApp()
+		app.each = true
+		if arg := app.Expand("{}", data); arg != "127.0.0.1:5432" {
+			t.Fatalf("Wrong expansion: %s", arg)
+		}
+	})
+
+	t.Run("Test aggregate data", func(t *testing.T) {
+		data := NewCmdData([]EndpointResult{{Addr: "127.0.0.1:5432"}, {Addr: "127.0.0.1:6379"}}, 0)
+		if data.Addr != "127.0.0.1:5432,127.0.0.1:6379" || data.Port != "5432,6379" {
+			t.Fatalf("Wrong data: %+v", data)
+		}
+	})
+
+	t.Run("Test unknown placeholders are kept", func(t *testing.T) {
+		app := newApp()
+		for _, s := range []string{"{{.Names}}", "{{.Missing", "{{json .}} {{.Addr}}"} {
+			if arg := app.Expand(s, data); arg != s {
+				t.Fatalf("Wrong expansion of %s: %s", s, arg)
+			}
+		}
+	})
+}
+
+func TestAppCmd(t *testing.T) {
+	t.Run("Test stdin passthrough", func(t *testing.T) {
+		app := newApp()
+		app.command = []string{"cat"}
+		cmd := app.Cmd(app.Args(), nil, 0)
+		if cmd.Stdin != os.Stdin {
+			t.Fatal("Stdin is not passed to the command")
+		}
+	})
+
+	t.Run("Test output is teed only with '-cmd-log'", func(t *testing.T) {
+		app := newApp()
+		app.command = []string{"echo"}
+		if cmd := app.Cmd(app.Args(), nil, 0); cmd.Stdout != os.Stdout || cmd.Stderr != os.Stderr {
+			t.Fatal("Output of the command is not passed as is")
+		}
+		cmdLog, err := OpenCmdLog(filepath.Join(t.TempDir(), "cmd.log"), false, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer cmdLog.Close()
+		app.cmdLog = cmdLog
+		if cmd := app.Cmd(app.Args(), nil, 0); cmd.Stdout == os.Stdout || cmd.Stderr == os.Stderr {
+			t.Fatal("Output of the command is not copied to '-cmd-log'")
+		}
+	})
+
+	t.Run("Test no stdin (-no-stdin)", func(t *testing.T) {
+		app := newApp()
+		app.noStdin = true
+		app.command = []string{"cat"}
+		cmd := app.Cmd(app.Args(), nil, 0)
+		if cmd.Stdin != nil {
+			t.Fatal("Stdin is passed to the command")
+		}
+	})
+
+	t.Run("Test docker format is passed as is", func(t *testing.T) {
+		app := newApp()
+		app.command = []string{"docker", "ps", "--format", "{{.Names}}", "-f", "name={{.Host}}"}
+		cmd := app.Cmd(app.Args(), []EndpointResult{{Addr: "db:5432", Ready: true}}, 0)
+		if !slices.Equal(cmd.Args[1:], []string{"ps", "--format", "{{.Names}}", "-f", "name=db"}) {
+			t.Fatalf("Wrong args: %q", cmd.Args)
+		}
+	})
+
+	t.Run("Test shell scripts are not templated", func(t *testing.T) {
+		app := newApp()
+		app.shell = "echo '{{.Addr}}' {}"
+		app.each = true
+		cmd := app.Cmd(app.Args(), []EndpointResult{{Addr: "db:5432", Ready: true}}, 0)
+		if cmd.Args[2] != app.shell {
+			t.Fatalf("Wrong script: %q", cmd.Args[2])
+		}
+	})
+}
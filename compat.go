@@ -0,0 +1,195 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Subcommands of tcpw. Without any of them tcpw waits, like with 'wait'.
+var Subcommands = []string{"wait", "watch", "ping", "serve", "agent", "compose", "listen", "service", "history", "version", "wait-for-it"}
+
+// ParseArgs parses the command line, including the program name,
+// choosing the compatibility mode by the program name or the first argument, or a subcommand:
+//
+//	wait      - wait for endpoints, then execute the command, as without subcommand
+//	watch     - live dashboard of endpoints, also as 'tui'
+//	ping      - statistics of connection attempts, as with '-stats'
+//	serve     - keep probing endpoints, serving their state on '-status-listen' and APIs on '-grpc' and '-http'
+//	agent     - make connection attempts for tcpw running with '-agent' on the address given as argument, ':7070' by default
+//	compose   - wait for services of a Compose file
+//
+// Endpoints of watch, ping and serve may be given as arguments as well.
+// Subcommands 'listen', 'service', 'history' and 'version' are run by main.
+func (app *App) ParseArgs(args []string) error {
+	name, args := args[0], args[1:]
+	if strings.TrimSuffix(filepath.Base(name), ".sh") == "wait-for-it" {
+		return app.ParseWaitForIt(name, args)
+	}
+	if len(args) == 0 {
+		return app.Parse(name, args)
+	}
+	switch args[0] {
+	case "wait-for-it":
+		return app.ParseWaitForIt(name+" wait-for-it", args[1:])
+	case "wait":
+		return app.Parse(name+" wait", args[1:])
+	case "compose":
+		app.compose = &Compose{}
+		return app.Parse(name+" compose", args[1:])
+	case "agent":
+		return app.ParseAgent(name+" agent", args[1:])
+	case "watch", "tui", "ping", "serve":
+	default:
+		return app.Parse(name, args)
+	}
+	if err := app.Parse(name+" "+args[0], args[1:]); err != nil {
+		return err
+	}
+	switch args[0] {
+	case "watch", "tui":
+		app.tui = true
+	case "ping":
+		app.stats = true
+	case "serve":
+		app.serve = true
+	}
+	return app.EndpointArgs()
+}
+
+// DefaultAgentListen is the address 'agent' subcommand listens on by default, which is reachable from localhost only.
+const DefaultAgentListen = "127.0.0.1:7070"
+
+// ParseAgent parses the command line of 'agent' subcommand, whose only argument is the address to listen on.
+func (app *App) ParseAgent(name string, args []string) error {
+	if err := app.Parse(name, args); err != nil {
+		return err
+	}
+	app.agentListen = DefaultAgentListen
+	switch len(app.command) {
+	case 0:
+	case 1:
+		app.agentListen = app.command[0]
+	default:
+		err := fmt.Errorf("only the address to listen on is expected, got %q", app.command)
+		app.Error("%v", err)
+		return err
+	}
+	app.command = nil
+	return nil
+}
+
+// EndpointArgs adds arguments left after flags to endpoints, for subcommands which execute no command.
+func (app *App) EndpointArgs() error {
+	endpoints := LabeledEndpoints{&app.endpoints, app.labels, app.hosts, app.endpointOptions}
+	for _, arg := range app.command {
+		if err := endpoints.Set(arg); err != nil {
+			app.Error("invalid endpoint %s: %v", arg, err)
+			return err
+		}
+	}
+	app.command = nil
+	return nil
+}
+
+// CutHostPort returns the endpoint given in netcat style as two arguments before '--', e.g. 'db 5432 -- command args',
+// and the command after it. The rest are arguments left after flags of the whole command line args.
+func CutHostPort(args, rest []string) (endpoint string, command []string, ok bool) {
+	if len(rest) < 3 || rest[2] != "--" {
+		return "", nil, false
+	}
+	if i := len(args) - len(rest); i > 0 && args[i-1] == "--" {
+		return "", nil, false // the command itself
+	}
+	if port, err := strconv.ParseUint(rest[1], 10, 16); err != nil || port == 0 {
+		return "", nil, false
+	}
+	return net.JoinHostPort(rest[0], rest[1]), rest[3:], true
+}
+
+// ParseWaitForIt parses the command line of wait-for-it.sh:
+//
+//	wait-for-it.sh host:port [-s] [-t timeout] [-- command args]
+func (app *App) ParseWaitForIt(name string, args []string) error {
+	if err := app.Parse(name, nil); err != nil {
+		return err
+	}
+	app.usage = func() {
+		app.Print("Usage: %s host:port [-s] [-t timeout] [-- command args]\n"+
+			"  -h HOST | --host=HOST       Host or IP under test\n"+
+			"  -p PORT | --port=PORT       TCP port under test\n"+
+			"                              Alternatively, you specify the host and port as host:port\n"+
+			"  -s | --strict               Only execute subcommand if the test succeeds\n"+
+			"  -q | --quiet                Don't output any status messages\n"+
+			"  -t TIMEOUT | --timeout=TIMEOUT\n"+
+			"                              Timeout in seconds, zero for no timeout (default 15)\n"+
+			"  -- COMMAND ARGS             Execute command with args after the test finishes", name)
+	}
+	app.timeout = 15 * time.Second
+	app.on = "any"
+	app.timeoutCompat = true
+
+	var host, port string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		key, value, hasValue := strings.Cut(arg, "=")
+		next := func() (string, error) {
+			if hasValue {
+				return value, nil
+			}
+			if i+1 >= len(args) {
+				return "", fmt.Errorf("missing value for %s", key)
+			}
+			i++
+			return args[i], nil
+		}
+		var err error
+		switch key {
+		case "--":
+			app.command = args[i+1:]
+			i = len(args)
+		case "-h", "--host":
+			host, err = next()
+		case "-p", "--port":
+			port, err = next()
+		case "-s", "--strict":
+			app.on = "s"
+		case "-q", "--quiet":
+			app.logLevel.Level = LevelQuiet
+			app.SetLogger()
+		case "-t", "--timeout":
+			if value, err = next(); err == nil {
+				var seconds int
+				if seconds, err = strconv.Atoi(value); err == nil {
+					app.timeout = time.Duration(seconds) * time.Second
+				}
+			}
+		case "--help":
+			app.usage()
+			return flag.ErrHelp
+		default:
+			if strings.HasPrefix(arg, "-") || !strings.Contains(arg, ":") {
+				err = fmt.Errorf("unknown argument: %s", arg)
+			} else {
+				err = app.endpoints.Set(arg)
+			}
+		}
+		if err != nil {
+			app.Error("%v", err)
+			app.usage()
+			return err
+		}
+	}
+	if host != "" || port != "" {
+		if err := app.endpoints.Set(host + ":" + port); err != nil {
+			app.Error("%v", err)
+			app.usage()
+			return err
+		}
+	}
+	return nil
+}
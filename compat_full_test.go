@@ -0,0 +1,34 @@
+//go:build !minimal
+
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestServeSubcommand(t *testing.T) {
+	t.Run("Test serve requires -status-listen", func(t *testing.T) {
+		var app App
+		if err := app.ParseArgs([]string{"tcpw", "serve", "127.0.0.1:5432"}); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if err := app.Check(); err == nil || !strings.Contains(err.Error(), "-status-listen") {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	})
+
+	t.Run("Test serve APIs require -api-token", func(t *testing.T) {
+		var app App
+		if err := app.ParseArgs([]string{"tcpw", "serve", "-grpc", ":7777"}); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if err := app.Check(); err == nil || err.Error() != "'-grpc' and '-http' require '-api-token'" {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		app.apiToken = "secret"
+		if err := app.Check(); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	})
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSubcommands(t *testing.T) {
+	t.Run("Test wait and legacy invocation", func(t *testing.T) {
+		for _, args := range [][]string{
+			{"tcpw", "wait", "-a", "127.0.0.1:5432", "echo", "up"},
+			{"tcpw", "-a", "127.0.0.1:5432", "echo", "up"},
+		} {
+			var app App
+			if err := app.ParseArgs(args); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if len(app.endpoints) != 1 || strings.Join(app.command, " ") != "echo up" || app.stats || app.tui || app.serve {
+				t.Fatalf("Wrong arguments parsed of %q: %+v", args, app)
+			}
+		}
+	})
+
+	t.Run("Test endpoints as arguments", func(t *testing.T) {
+		var app App
+		if err := app.ParseArgs([]string{"tcpw", "ping", "-count", "3", "127.0.0.1:5432", "cache=127.0.0.1:6379"}); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if !app.stats || app.count != 3 || len(app.endpoints) != 2 || app.Label("127.0.0.1:6379") != "cache" || app.command != nil {
+			t.Fatalf("Wrong arguments parsed: %+v", app)
+		}
+		if err := app.Check(); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		app = App{logLevel: LogLevel{LevelQuiet}}
+		if err := app.ParseArgs([]string{"tcpw", "watch", "localhost:99999"}); err == nil {
+			t.Fatal("Unexpected success")
+		}
+	})
+}
+
+func TestParseWaitForIt(t *testing.T) {
+	t.Run("Test host:port with command", func(t *testing.T) {
+		var app App
+		args := []string{"wait-for-it.sh", "localhost:1234", "--timeout=30", "--strict", "--", "echo", "-n", "up"}
+		if err := app.ParseArgs(args); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if len(app.endpoints) != 1 || app.timeout != 30*time.Second || app.on != "s" || !app.timeoutCompat {
+			t.Fatalf("Wrong arguments parsed: %+v", app)
+		}
+		if strings.Join(app.command, " ") != "echo -n up" {
+			t.Fatalf("Wrong command parsed: %v", app.command)
+		}
+	})
+
+	t.Run("Test host and port flags", func(t *testing.T) {
+		var app App
+		args := []string{"tcpw", "wait-for-it", "-h", "localhost", "-p", "1234", "-q"}
+		if err := app.ParseArgs(args); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if len(app.endpoints) != 1 || app.timeout != 15*time.Second || app.on != "any" || app.logLevel.Level != LevelQuiet {
+			t.Fatalf("Wrong arguments parsed: %+v", app)
+		}
+	})
+
+	t.Run("Test error: unknown argument", func(t *testing.T) {
+		app := App{logLevel: LogLevel{LevelQuiet}}
+		if err := app.ParseWaitForIt("wait-for-it", []string{"--nope"}); err == nil {
+			t.Fatal("Unexpected success")
+		}
+	})
+}
+
+func TestCutHostPort(t *testing.T) {
+	t.Run("Test host and port before command", func(t *testing.T) {
+		var app App
+		if err := app.ParseArgs([]string{"tcpw", "-t", "5s", "127.0.0.1", "5432", "--", "echo", "up"}); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if strings.Join(app.endpoints, " ") != "127.0.0.1:5432" || strings.Join(app.command, " ") != "echo up" {
+			t.Fatalf("Wrong arguments parsed: %v, %v", app.endpoints, app.command)
+		}
+	})
+
+	t.Run("Test command with numeric argument", func(t *testing.T) {
+		for _, args := range [][]string{
+			{"-a", "127.0.0.1:5432", "--", "sleep", "5", "--", "x"},
+			{"-a", "127.0.0.1:5432", "sleep", "5"},
+			{"-a", "127.0.0.1:5432", "sleep", "5s", "--", "x"},
+		} {
+			var app App
+			if err := app.Parse("tcpw", args); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if len(app.endpoints) != 1 || app.command[0] != "sleep" {
+				t.Fatalf("Wrong arguments parsed: %v, %v", app.endpoints, app.command)
+			}
+		}
+	})
+}
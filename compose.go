@@ -0,0 +1,430 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"slices"
+	"strings"
+)
+
+// composeFiles are names of the compose file looked up in the current directory, like 'docker compose' does.
+var composeFiles = []string{"compose.yaml", "compose.yml", "docker-compose.yaml", "docker-compose.yml"}
+
+// Compose is the state of 'tcpw compose' subcommand, which awaits services of a compose file.
+type Compose struct {
+	file      string
+	published bool
+	deps      bool
+}
+
+func (c *Compose) Flags(fs *flag.FlagSet) {
+	fs.StringVar(&c.file, "f", "", "Compose file (default: compose.yaml or docker-compose.yml in the current directory)")
+	fs.BoolVar(&c.published, "published", false, "Await published ports on localhost, e.g. from the host, "+
+		"instead of ports of containers by service names, e.g. from another container (default false)")
+	fs.BoolVar(&c.deps, "deps", false, "Await only services the given ones depend on, e.g. in the entrypoint of the service itself (default false)")
+}
+
+// Endpoints returns endpoints of the services and services they depend on, dependencies first,
+// with labels of services having a single endpoint. Services without ports are skipped.
+func (c *Compose) Endpoints(names []string) (Endpoints, Labels, error) {
+	path := c.file
+	if path == "" {
+		for _, name := range composeFiles {
+			if _, err := os.Stat(name); err == nil {
+				path = name
+				break
+			}
+		}
+		if path == "" {
+			return nil, nil, errors.New("no compose file in the current directory, use '-f'")
+		}
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+	services, err := ParseCompose(f)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	var order []string
+	visited := map[string]bool{}
+	var visit func(name string) error
+	visit = func(name string) error {
+		if visited[name] {
+			return nil
+		}
+		visited[name] = true
+		s, ok := services[name]
+		if !ok {
+			return fmt.Errorf("%s: no such service: %s", path, name)
+		}
+		for _, dep := range s.DependsOn {
+			if err := visit(dep); err != nil {
+				return err
+			}
+		}
+		order = append(order, name)
+		return nil
+	}
+	for _, name := range names {
+		if err = visit(name); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	var endpoints Endpoints
+	labels := Labels{}
+	for _, name := range order {
+		if c.deps && slices.Contains(names, name) {
+			continue
+		}
+		addrs := services[name].Addrs(name, c.published)
+		for _, addr := range addrs {
+			if !slices.Contains(endpoints, addr) {
+				endpoints = append(endpoints, addr)
+			}
+		}
+		if len(addrs) == 1 {
+			labels[addrs[0]] = name
+		}
+	}
+	return endpoints, labels, nil
+}
+
+// ComposePort is a port of a compose service.
+type ComposePort struct {
+	Target    string
+	Published string // empty if the port is not published
+	HostIP    string
+}
+
+// ComposeService is a service of a compose file, as far as waiting for it is concerned.
+type ComposeService struct {
+	Ports     []ComposePort // of 'ports' and 'expose'
+	DependsOn []string
+}
+
+// Addrs returns addresses of TCP ports of the service: published ports on localhost,
+// or ports of the container by the service name.
+func (s ComposeService) Addrs(name string, published bool) []string {
+	var addrs []string
+	for _, p := range s.Ports {
+		switch {
+		case !published:
+			addrs = append(addrs, net.JoinHostPort(name, p.Target))
+		case p.Published != "":
+			host := p.HostIP
+			if host == "" || net.ParseIP(host).IsUnspecified() {
+				host = "127.0.0.1"
+			}
+			addrs = append(addrs, net.JoinHostPort(host, p.Published))
+		}
+	}
+	return slices.Compact(addrs)
+}
+
+// ParseCompose returns services of a compose file with their TCP ports and dependencies.
+func ParseCompose(r io.Reader) (map[string]ComposeService, error) {
+	tree, err := ParseYAMLTree(r)
+	if err != nil {
+		return nil, err
+	}
+	root, _ := tree.(map[string]any)
+	entries, ok := root["services"].(map[string]any)
+	if !ok {
+		return nil, errors.New("no services")
+	}
+	services := map[string]ComposeService{}
+	for name, entry := range entries {
+		m, _ := entry.(map[string]any)
+		var s ComposeService
+		for _, item := range asList(m["ports"]) {
+			p, err := parseComposePort(item)
+			if err != nil {
+				return nil, fmt.Errorf("service %s: %w", name, err)
+			}
+			s.Ports = append(s.Ports, p...)
+		}
+		for _, item := range asList(m["expose"]) {
+			if port, proto, _ := strings.Cut(fmt.Sprint(item), "/"); proto == "" || proto == "tcp" {
+				s.Ports = append(s.Ports, ComposePort{Target: port})
+			}
+		}
+		switch deps := m["depends_on"].(type) {
+		case []any:
+			for _, dep := range deps {
+				s.DependsOn = append(s.DependsOn, fmt.Sprint(dep))
+			}
+		case map[string]any:
+			for dep := range deps {
+				s.DependsOn = append(s.DependsOn, dep)
+			}
+			slices.Sort(s.DependsOn)
+		}
+		services[name] = s
+	}
+	return services, nil
+}
+
+// parseComposePort parses a port in short syntax, e.g. '127.0.0.1:8080:80' or '5432/udp',
+// or in long syntax with 'target', 'published', 'host_ip' and 'protocol' keys.
+// UDP ports are skipped, ranges are expanded.
+func parseComposePort(item any) ([]ComposePort, error) {
+	if m, ok := item.(map[string]any); ok {
+		if proto, _ := m["protocol"].(string); proto != "" && proto != "tcp" {
+			return nil, nil
+		}
+		target, _ := m["target"].(string)
+		if target == "" {
+			return nil, errors.New("port without 'target'")
+		}
+		published, _ := m["published"].(string)
+		hostIP, _ := m["host_ip"].(string)
+		return []ComposePort{{Target: target, Published: published, HostIP: hostIP}}, nil
+	}
+	spec, proto, _ := strings.Cut(fmt.Sprint(item), "/")
+	if proto != "" && proto != "tcp" {
+		return nil, nil
+	}
+	var hostIP, published, target string
+	if i := strings.LastIndex(spec, ":"); i < 0 {
+		target = spec
+	} else {
+		target = spec[i+1:]
+		published = spec[:i]
+		if j := strings.LastIndex(published, ":"); j >= 0 {
+			hostIP, published = strings.Trim(published[:j], "[]"), published[j+1:]
+		}
+	}
+	targets, err := portRange(target)
+	if err != nil {
+		return nil, err
+	}
+	publishedPorts := make([]string, len(targets))
+	if published != "" {
+		if p, err := portRange(published); err != nil {
+			return nil, err
+		} else if len(p) == len(targets) {
+			publishedPorts = p
+		}
+		// a range published on a single port is not known in advance
+	}
+	ports := make([]ComposePort, len(targets))
+	for i, t := range targets {
+		ports[i] = ComposePort{Target: t, Published: publishedPorts[i], HostIP: hostIP}
+	}
+	return ports, nil
+}
+
+// portRange expands a port or a range of ports, e.g. '8000-8002'.
+func portRange(spec string) ([]string, error) {
+	from, to, isRange := strings.Cut(spec, "-")
+	var first, last int
+	if _, err := fmt.Sscan(from, &first); err != nil || first <= 0 || first > 65535 {
+		return nil, fmt.Errorf("invalid port: %s", spec)
+	}
+	last = first
+	if isRange {
+		if _, err := fmt.Sscan(to, &last); err != nil || last < first || last > 65535 {
+			return nil, fmt.Errorf("invalid port range: %s", spec)
+		}
+	}
+	var ports []string
+	for p := first; p <= last; p++ {
+		ports = append(ports, fmt.Sprint(p))
+	}
+	return ports, nil
+}
+
+func asList(v any) []any {
+	list, _ := v.([]any)
+	return list
+}
+
+type yamlLine struct {
+	n      int
+	indent int
+	text   string
+}
+
+// ParseYAMLTree parses YAML block mappings and sequences into maps of type map[string]any and lists of type []any
+// with string scalars. Flow lists and mappings are only supported on a single line, block scalars are skipped
+// and anchors are not supported.
+func ParseYAMLTree(r io.Reader) (any, error) {
+	var lines []yamlLine
+	scanner := bufio.NewScanner(r)
+	blockIndent := -1 // of the line starting a block scalar being skipped
+	for n := 1; scanner.Scan(); n++ {
+		line := stripComment(scanner.Text())
+		trimmed := strings.TrimSpace(line)
+		indent := len(line) - len(strings.TrimLeft(line, " "))
+		if blockIndent >= 0 && (trimmed == "" || indent > blockIndent) {
+			continue
+		}
+		blockIndent = -1
+		if trimmed == "" || trimmed == "---" {
+			continue
+		}
+		if strings.HasSuffix(trimmed, "|") || strings.HasSuffix(trimmed, ">") ||
+			strings.HasSuffix(trimmed, "|-") || strings.HasSuffix(trimmed, ">-") {
+			blockIndent = indent
+		}
+		lines = append(lines, yamlLine{n, indent, trimmed})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(lines) == 0 {
+		return nil, nil
+	}
+	p := yamlParser{lines: lines}
+	v, err := p.node(lines[0].indent)
+	if err != nil {
+		return nil, err
+	}
+	if p.i < len(lines) {
+		return nil, fmt.Errorf("line %d: unexpected indentation", lines[p.i].n)
+	}
+	return v, nil
+}
+
+type yamlParser struct {
+	lines []yamlLine
+	i     int
+}
+
+func isSequenceItem(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ")
+}
+
+// node parses a mapping or a sequence at the indentation.
+func (p *yamlParser) node(indent int) (any, error) {
+	if isSequenceItem(p.lines[p.i].text) {
+		return p.sequence(indent)
+	}
+	return p.mapping(indent)
+}
+
+func (p *yamlParser) sequence(indent int) (any, error) {
+	var list []any
+	for p.i < len(p.lines) && p.lines[p.i].indent == indent && isSequenceItem(p.lines[p.i].text) {
+		line := p.lines[p.i]
+		item := strings.TrimSpace(strings.TrimPrefix(line.text, "-"))
+		if item == "" {
+			p.i++
+			v, err := p.child(indent, false)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, v)
+			continue
+		}
+		if _, _, ok := cutMapping(item); ok && !strings.HasPrefix(item, "{") {
+			// a mapping starting on the line of the item continues at the indentation of its first key
+			p.lines[p.i] = yamlLine{line.n, indent + len(line.text) - len(item), item}
+			v, err := p.mapping(p.lines[p.i].indent)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, v)
+			continue
+		}
+		v, err := flowValue(item)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line.n, err)
+		}
+		list = append(list, v)
+		p.i++
+	}
+	return list, nil
+}
+
+func (p *yamlParser) mapping(indent int) (any, error) {
+	m := map[string]any{}
+	for p.i < len(p.lines) && p.lines[p.i].indent == indent && !isSequenceItem(p.lines[p.i].text) {
+		line := p.lines[p.i]
+		key, value, ok := cutMapping(line.text)
+		if !ok {
+			k, v, found := strings.Cut(line.text, ":")
+			if key, ok = unquote(strings.TrimSpace(k)); !found || !ok {
+				return nil, fmt.Errorf("line %d: 'key: value' expected", line.n)
+			}
+			value = strings.TrimSpace(v)
+		}
+		p.i++
+		if value == "" {
+			v, err := p.child(indent, true)
+			if err != nil {
+				return nil, err
+			}
+			m[key] = v
+			continue
+		}
+		if strings.HasPrefix(value, "|") || strings.HasPrefix(value, ">") {
+			m[key] = "" // block scalars are skipped
+			continue
+		}
+		v, err := flowValue(value)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line.n, err)
+		}
+		m[key] = v
+	}
+	return m, nil
+}
+
+// child parses the value on the lines after a key or a sequence item, nil if there is none.
+// Sequences may be at the indentation of the key.
+func (p *yamlParser) child(indent int, sameIndentSequence bool) (any, error) {
+	if p.i >= len(p.lines) {
+		return nil, nil
+	}
+	next := p.lines[p.i]
+	if next.indent > indent || (sameIndentSequence && next.indent == indent && isSequenceItem(next.text)) {
+		return p.node(next.indent)
+	}
+	return nil, nil
+}
+
+// flowValue parses a scalar, a flow list '[a, b]' or a flow mapping '{a: b, c: d}' of scalars.
+func flowValue(v string) (any, error) {
+	if strings.HasPrefix(v, "{") {
+		if !strings.HasSuffix(v, "}") {
+			return nil, errors.New("mappings must be on a single line")
+		}
+		m := map[string]any{}
+		for _, pair := range strings.Split(v[1:len(v)-1], ",") {
+			if pair = strings.TrimSpace(pair); pair == "" {
+				continue
+			}
+			key, value, _ := strings.Cut(pair, ":")
+			k, ok1 := unquote(strings.TrimSpace(key))
+			val, ok2 := unquote(strings.TrimSpace(value))
+			if !ok1 || !ok2 {
+				return nil, fmt.Errorf("invalid mapping %s", v)
+			}
+			m[k] = val
+		}
+		return m, nil
+	}
+	items, err := parseValue(v)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(v, "[") {
+		return items[0], nil
+	}
+	list := make([]any, len(items))
+	for i, item := range items {
+		list[i] = item
+	}
+	return list, nil
+}
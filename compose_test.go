@@ -0,0 +1,111 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const composeFile = `
+services:
+  web:
+    image: web
+    command: >
+      ./server
+        --port 8080
+    ports:
+      - "8080:8080"
+      - 9090/udp
+    depends_on:
+      api:
+        condition: service_started
+  api:
+    build: .
+    ports:
+    - target: 3000
+      published: "13000"
+      host_ip: 127.0.0.2
+    depends_on: [db, cache]
+  db:
+    image: postgres # with comment
+    ports: ["127.0.0.1:15432:5432"]
+    environment:
+      - POSTGRES_PASSWORD=secret
+  cache:
+    image: redis
+    expose:
+      - "6379"
+      - 6380
+  worker:
+    image: worker
+    depends_on:
+      - db
+`
+
+func TestParseCompose(t *testing.T) {
+	services, err := ParseCompose(strings.NewReader(composeFile))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(services) != 5 {
+		t.Fatalf("Wrong services: %+v", services)
+	}
+	for name, want := range map[string]string{
+		"web":    "web:8080 | 127.0.0.1:8080",
+		"api":    "api:3000 | 127.0.0.2:13000",
+		"db":     "db:5432 | 127.0.0.1:15432",
+		"cache":  "cache:6379 cache:6380 | ",
+		"worker": " | ",
+	} {
+		s := services[name]
+		got := strings.Join(s.Addrs(name, false), " ") + " | " + strings.Join(s.Addrs(name, true), " ")
+		if got != want {
+			t.Fatalf("Wrong addresses of %s: %q", name, got)
+		}
+	}
+	if deps := strings.Join(services["api"].DependsOn, " "); deps != "db cache" {
+		t.Fatalf("Wrong dependencies: %s", deps)
+	}
+	if ports, err := parseComposePort("8000-8001:9000-9001"); err != nil || len(ports) != 2 || ports[1] != (ComposePort{Target: "9001", Published: "8001"}) {
+		t.Fatalf("Wrong ports: %+v, %v", ports, err)
+	}
+}
+
+func TestComposeSubcommand(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "compose.yml")
+	if err := os.WriteFile(path, []byte(composeFile), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("Test service with dependencies", func(t *testing.T) {
+		var app App
+		if err := app.ParseArgs([]string{"tcpw", "compose", "-f", path, "-t", "1m", "web", "--", "echo", "up"}); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if labels := strings.Join(app.Labels(), " "); labels != "db cache:6379 cache:6380 api web" {
+			t.Fatalf("Wrong endpoints: %s", labels)
+		}
+		if strings.Join(app.command, " ") != "echo up" {
+			t.Fatalf("Wrong command: %v", app.command)
+		}
+	})
+
+	t.Run("Test dependencies only on published ports", func(t *testing.T) {
+		var app App
+		if err := app.ParseArgs([]string{"tcpw", "compose", "-f", path, "-deps", "-published", "api", "worker"}); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if endpoints := strings.Join(app.endpoints, " "); endpoints != "127.0.0.1:15432" {
+			t.Fatalf("Wrong endpoints: %s", endpoints)
+		}
+	})
+
+	t.Run("Test error: unknown service", func(t *testing.T) {
+		app := App{logLevel: LogLevel{LevelQuiet}}
+		err := app.ParseArgs([]string{"tcpw", "compose", "-f", path, "queue"})
+		if err == nil || !strings.HasSuffix(err.Error(), "no such service: queue") {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	})
+}
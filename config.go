@@ -0,0 +1,435 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/jackcvr/tcpw/tcpw"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// ConfigValue is a value of a flag given in a '-config' file or an environment variable.
+// Lists are given as repeated values.
+type ConfigValue struct {
+	Key    string
+	Value  string
+	Source string // e.g. 'line 3' or 'TCPW_TIMEOUT'
+}
+
+// configAliases are keys of '-config' file, which are not names of flags.
+var configAliases = map[string]string{
+	"endpoints": "a",
+	"endpoint":  "a",
+	"timeout":   "t",
+	"interval":  "i",
+}
+
+// LoadConfig reads the '-config' file: TOML if it has '.toml' extension, YAML otherwise.
+// Only a subset of the formats is supported: scalars and lists of them as values of flags,
+// and lists of tables with 'address' and optional 'label', 'protocol' and options keys as endpoints, see endpointKeys.
+// Environment variables are expanded in values, except for shell commands, see ExpandVars.
+func LoadConfig(path string) ([]ConfigValue, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var values []ConfigValue
+	if strings.EqualFold(filepath.Ext(path), ".toml") {
+		values, err = ParseTOML(f)
+	} else {
+		values, err = ParseYAML(f)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	for i, v := range values {
+		if unexpandedKeys[v.Key] {
+			continue
+		}
+		if values[i].Value, err = ExpandVars(v.Value); err != nil {
+			return nil, fmt.Errorf("%s: %s: %w", path, v.Source, err)
+		}
+	}
+	return values, nil
+}
+
+// ApplyConfig sets flags, which are not set on the command line, to the values from a config.
+// The 'command' key sets the command, unless it is given on the command line.
+func ApplyConfig(fs *flag.FlagSet, values []ConfigValue, command *[]string) error {
+	set := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+	var configCommand []string
+	for _, v := range values {
+		name := v.Key
+		if alias, ok := configAliases[name]; ok {
+			name = alias
+		}
+		if name == "command" {
+			configCommand = append(configCommand, v.Value)
+			continue
+		}
+		if fs.Lookup(name) == nil {
+			return fmt.Errorf("%s: unknown key '%s'", v.Source, v.Key)
+		}
+		if set[name] || name == "config" {
+			continue
+		}
+		if err := fs.Set(name, v.Value); err != nil {
+			return fmt.Errorf("%s: invalid value %q for '%s': %w", v.Source, v.Value, v.Key, err)
+		}
+	}
+	if len(*command) == 0 {
+		*command = configCommand
+	}
+	return nil
+}
+
+// hookEnv are environment variables passed to hooks and the command, which tcpw started by them
+// must not take for its own flags.
+var hookEnv = []string{
+	"TCPW_ENDPOINT", "TCPW_ATTEMPT", "TCPW_ENDPOINT_STATE", "TCPW_ERROR", "TCPW_ELAPSED_MS", "TCPW_LATENCY_MS",
+	"TCPW_STATUS", "TCPW_FAILED", "TCPW_RESULT", "TCPW_FAILED_ENDPOINTS", "TCPW_WAIT_DURATION_MS",
+}
+
+// EnvConfig returns values of flags given in environment variables named after them with 'TCPW_' prefix,
+// e.g. TCPW_LOG_LEVEL for '-log-level', TCPW_TIMEOUT and TCPW_INTERVAL for '-t' and '-i',
+// and TCPW_ENDPOINTS with endpoints separated by commas or spaces.
+func EnvConfig(fs *flag.FlagSet, environ []string) []ConfigValue {
+	names := map[string]string{}
+	fs.VisitAll(func(f *flag.Flag) {
+		names["TCPW_"+EnvName(f.Name)] = f.Name
+	})
+	for alias, name := range configAliases {
+		names["TCPW_"+EnvName(alias)] = name
+	}
+	for _, name := range hookEnv {
+		delete(names, name)
+	}
+	var values []ConfigValue
+	for _, kv := range environ {
+		key, value, _ := strings.Cut(kv, "=")
+		name, ok := names[key]
+		if !ok || value == "" {
+			continue
+		}
+		if key == "TCPW_ENDPOINTS" {
+			for _, endpoint := range strings.FieldsFunc(value, func(r rune) bool { return r == ',' || unicode.IsSpace(r) }) {
+				values = append(values, ConfigValue{name, endpoint, key})
+			}
+			continue
+		}
+		values = append(values, ConfigValue{name, value, key})
+	}
+	return values
+}
+
+// endpointKeys are keys of tables describing endpoints. Options of the endpoint, e.g. 'timeout' and 'interval',
+// override the keys of the wait, see ParseEndpointOptions.
+var endpointKeys = []string{"address", "label", "protocol", "timeout", "interval", "status"}
+
+// endpointValue converts a table describing an endpoint to the value of '-a' flag with options of the endpoint,
+// e.g. 'db=10.3.2.1:5432 timeout=5s'. The 'protocol' is the scheme of the address, e.g. 'udp' for 'udp://host:port'.
+func endpointValue(table map[string]string) (string, error) {
+	for key := range table {
+		if !slices.Contains(endpointKeys, key) {
+			return "", fmt.Errorf("per-endpoint '%s' is not supported, only %s", key, tcpw.QuoteList(endpointKeys))
+		}
+	}
+	addr, ok := table["address"]
+	if !ok {
+		return "", errors.New("endpoint without 'address'")
+	}
+	if protocol, ok := table["protocol"]; ok {
+		if strings.Contains(addr, "://") {
+			return "", fmt.Errorf("'protocol' of address %s, which has a scheme already", addr)
+		}
+		addr = protocol + "://" + addr
+	}
+	if label, ok := table["label"]; ok {
+		addr = label + "=" + addr
+	}
+	for _, key := range endpointKeys[3:] {
+		if option, ok := table[key]; ok {
+			addr += " " + key + "=" + strings.ReplaceAll(option, " ", "")
+		}
+	}
+	return addr, nil
+}
+
+// ParseYAML parses a YAML config: 'key: value' pairs, where value is a scalar, a flow list '[a, b]',
+// or a block list of scalars or, for endpoints, of mappings.
+func ParseYAML(r io.Reader) ([]ConfigValue, error) {
+	var values []ConfigValue
+	var key string              // of the block list being parsed
+	var table map[string]string // endpoint mapping being parsed
+	var tableLine, tableIndent int
+	flush := func() error {
+		if table == nil {
+			return nil
+		}
+		value, err := endpointValue(table)
+		if err != nil {
+			return fmt.Errorf("line %d: %w", tableLine, err)
+		}
+		values = append(values, ConfigValue{key, value, fmt.Sprintf("line %d", tableLine)})
+		table = nil
+		return nil
+	}
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := stripComment(scanner.Text())
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || trimmed == "---" {
+			continue
+		}
+		indent := len(line) - len(strings.TrimLeft(line, " "))
+		switch {
+		case indent == 0:
+			if err := flush(); err != nil {
+				return nil, err
+			}
+			k, v, ok := strings.Cut(trimmed, ":")
+			if !ok {
+				return nil, fmt.Errorf("line %d: 'key: value' expected", n)
+			}
+			key = strings.TrimSpace(k)
+			if v = strings.TrimSpace(v); v == "" {
+				continue
+			}
+			items, err := parseValue(v)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", n, err)
+			}
+			for _, item := range items {
+				values = append(values, ConfigValue{key, item, fmt.Sprintf("line %d", n)})
+			}
+			key = ""
+		case key == "":
+			return nil, fmt.Errorf("line %d: unexpected indentation", n)
+		case strings.HasPrefix(trimmed, "- ") || trimmed == "-":
+			if err := flush(); err != nil {
+				return nil, err
+			}
+			item := strings.TrimSpace(strings.TrimPrefix(trimmed, "-"))
+			if k, v, ok := cutMapping(item); ok {
+				table, tableLine, tableIndent = map[string]string{}, n, indent+2
+				if table[k], ok = unquote(v); !ok {
+					return nil, fmt.Errorf("line %d: invalid string %s", n, v)
+				}
+				continue
+			}
+			value, ok := unquote(item)
+			if !ok {
+				return nil, fmt.Errorf("line %d: invalid string %s", n, item)
+			}
+			values = append(values, ConfigValue{key, value, fmt.Sprintf("line %d", n)})
+		case table != nil && indent == tableIndent:
+			k, v, ok := cutMapping(trimmed)
+			if !ok {
+				return nil, fmt.Errorf("line %d: 'key: value' expected", n)
+			}
+			if table[k], ok = unquote(v); !ok {
+				return nil, fmt.Errorf("line %d: invalid string %s", n, v)
+			}
+		default:
+			return nil, fmt.Errorf("line %d: unexpected indentation", n)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+// cutMapping splits 'key: value' of a YAML mapping, unless it is a scalar like 'host:port' or a URL.
+func cutMapping(s string) (key, value string, ok bool) {
+	key, value, ok = strings.Cut(s, ": ")
+	if !ok {
+		key, ok = strings.CutSuffix(s, ":")
+	}
+	if !ok || strings.ContainsAny(key, " \"'") {
+		return "", "", false
+	}
+	return key, strings.TrimSpace(value), true
+}
+
+// ParseTOML parses a TOML config: 'key = value' pairs, where value is a scalar or an array,
+// and '[[endpoints]]' tables.
+func ParseTOML(r io.Reader) ([]ConfigValue, error) {
+	var values []ConfigValue
+	var table map[string]string
+	var tableKey string
+	var tableLine int
+	flush := func() error {
+		if table == nil {
+			return nil
+		}
+		value, err := endpointValue(table)
+		if err != nil {
+			return fmt.Errorf("line %d: %w", tableLine, err)
+		}
+		values = append(values, ConfigValue{tableKey, value, fmt.Sprintf("line %d", tableLine)})
+		table = nil
+		return nil
+	}
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(stripComment(scanner.Text()))
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "[[") && strings.HasSuffix(line, "]]") {
+			if err := flush(); err != nil {
+				return nil, err
+			}
+			table, tableKey, tableLine = map[string]string{}, strings.TrimSpace(line[2:len(line)-2]), n
+			continue
+		}
+		k, v, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("line %d: 'key = value' expected", n)
+		}
+		k, v = strings.TrimSpace(k), strings.TrimSpace(v)
+		if err := checkLiteralStrings(v); err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+		items, err := parseValue(v)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+		if table != nil {
+			if len(items) != 1 {
+				return nil, fmt.Errorf("line %d: single value expected", n)
+			}
+			table[k] = items[0]
+			continue
+		}
+		for _, item := range items {
+			values = append(values, ConfigValue{k, item, fmt.Sprintf("line %d", n)})
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+// checkLiteralStrings reports quotes escaped YAML-style, by doubling them, in TOML literal strings, which have no escapes.
+func checkLiteralStrings(v string) error {
+	for i := 0; i < len(v); i++ {
+		if v[i] != '"' && v[i] != '\'' {
+			continue
+		}
+		end := closingQuote(v[i:])
+		if end < 0 {
+			return nil // reported by parseValue
+		}
+		if v[i] == '\'' && strings.Contains(v[i+1:i+end], "''") {
+			return fmt.Errorf("invalid string %s: literal strings can not contain quotes, use \"...\" instead", v[i:i+end+1])
+		}
+		i += end
+	}
+	return nil
+}
+
+// parseValue parses a scalar or a flow list '[a, "b", 'c']'.
+func parseValue(v string) ([]string, error) {
+	if !strings.HasPrefix(v, "[") {
+		value, ok := unquote(v)
+		if !ok {
+			return nil, fmt.Errorf("invalid string %s", v)
+		}
+		return []string{value}, nil
+	}
+	if !strings.HasSuffix(v, "]") {
+		return nil, errors.New("lists must be on a single line")
+	}
+	var items []string
+	for rest := strings.TrimSpace(v[1 : len(v)-1]); rest != ""; {
+		item := rest
+		if rest[0] == '"' || rest[0] == '\'' {
+			end := closingQuote(rest)
+			if end < 0 {
+				return nil, fmt.Errorf("invalid string %s", rest)
+			}
+			item = rest[:end+1]
+		} else if i := strings.Index(rest, ","); i >= 0 {
+			item = rest[:i]
+		}
+		value, ok := unquote(strings.TrimSpace(item))
+		if !ok {
+			return nil, fmt.Errorf("invalid string %s", item)
+		}
+		items = append(items, value)
+		rest = strings.TrimSpace(rest[len(item):])
+		rest = strings.TrimSpace(strings.TrimPrefix(rest, ","))
+	}
+	return items, nil
+}
+
+// unquote returns the value of a double quoted string with escapes, a single quoted literal string,
+// or a plain scalar as is.
+func unquote(s string) (string, bool) {
+	switch {
+	case strings.HasPrefix(s, "\""):
+		v, err := strconv.Unquote(s)
+		return v, err == nil
+	case strings.HasPrefix(s, "'"):
+		if len(s) < 2 || !strings.HasSuffix(s, "'") {
+			return "", false
+		}
+		return strings.ReplaceAll(s[1:len(s)-1], "''", "'"), true
+	}
+	return s, true
+}
+
+// closingQuote returns the index of the quote closing the string at the start of s, or -1.
+func closingQuote(s string) int {
+	quote := s[0]
+	for i := 1; i < len(s); i++ {
+		switch {
+		case quote == '"' && s[i] == '\\':
+			i++
+		case s[i] == quote && quote == '\'' && i+1 < len(s) && s[i+1] == '\'':
+			i++
+		case s[i] == quote:
+			return i
+		}
+	}
+	return -1
+}
+
+// stripComment removes a '#' comment, which is not inside a quoted string.
+func stripComment(line string) string {
+	for i := 0; i < len(line); i++ {
+		switch line[i] {
+		case '"', '\'':
+			end := closingQuote(line[i:])
+			if end < 0 {
+				return line
+			}
+			i += end
+		case '#':
+			if i == 0 || line[i-1] == ' ' || line[i-1] == '\t' {
+				return strings.TrimRight(line[:i], " \t")
+			}
+		}
+	}
+	return line
+}
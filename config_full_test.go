@@ -0,0 +1,44 @@
+//go:build !minimal
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestConfigFormats parses both formats of configs with an HTTP endpoint, which the minimal build rejects.
+func TestConfigFormats(t *testing.T) {
+	for name, config := range map[string]string{"tcpw.yaml": yamlConfig, "tcpw.toml": tomlConfig} {
+		t.Run("Test "+name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), name)
+			if err := os.WriteFile(path, []byte(config), 0644); err != nil {
+				t.Fatal(err)
+			}
+			var app App
+			if err := app.Parse("tcpw", []string{"-config", path}); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if app.timeout != 30*time.Second || app.interval != 500*time.Millisecond || !app.supervise {
+				t.Fatalf("Wrong flags: %v, %v, %v", app.timeout, app.interval, app.supervise)
+			}
+			if strings.Join(app.env, ",") != "MODE=prod,GREETING=hello # world" {
+				t.Fatalf("Wrong env: %q", app.env)
+			}
+			if strings.Join(app.Labels(), " ") != "127.0.0.1:6379 db http://127.0.0.1:8080/health?full=1 udp://127.0.0.1:53" {
+				t.Fatalf("Wrong endpoints: %v", app.Labels())
+			}
+			db, health := app.endpointOptions["127.0.0.1:5432"], app.endpointOptions["http://127.0.0.1:8080/health?full=1"]
+			if db.Timeout != 10*time.Second || db.Interval != 200*time.Millisecond || !slices.Equal(health.Status, []int{200, 204}) {
+				t.Fatalf("Wrong endpoint options: %+v", app.endpointOptions)
+			}
+			if strings.Join(app.command, " ") != "./server --port 8080" {
+				t.Fatalf("Wrong command: %q", app.command)
+			}
+		})
+	}
+}
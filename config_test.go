@@ -0,0 +1,157 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const yamlConfig = `# dependencies of the app
+timeout: 30s
+interval: 500ms
+supervise: true
+env: [MODE=prod, "GREETING=hello # world"]
+endpoints:
+  - 127.0.0.1:6379
+  - address: 127.0.0.1:5432
+    label: db
+    timeout: 10s
+    interval: 200ms
+  - address: 'http://127.0.0.1:8080/health?full=1'
+    status: 200, 204
+  - address: 127.0.0.1:53
+    protocol: udp
+command:
+  - ./server
+  - --port
+  - "8080"
+`
+
+const tomlConfig = `# dependencies of the app
+timeout = "30s"
+interval = "500ms"
+supervise = true
+env = ["MODE=prod", "GREETING=hello # world"]
+endpoints = ["127.0.0.1:6379"]
+command = ["./server", "--port", "8080"]
+
+[[endpoints]]
+address = "127.0.0.1:5432"
+label = "db"
+timeout = "10s"
+interval = "200ms"
+
+[[endpoints]]
+address = 'http://127.0.0.1:8080/health?full=1'
+status = "200,204"
+
+[[endpoints]]
+address = "127.0.0.1:53"
+protocol = "udp"
+`
+
+func TestConfig(t *testing.T) {
+	t.Run("Test command line overrides config", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "tcpw.yaml")
+		if err := os.WriteFile(path, []byte(yamlConfig), 0644); err != nil {
+			t.Fatal(err)
+		}
+		var app App
+		if err := app.Parse("tcpw", []string{"-t", "5s", "-config", path, "-a", "127.0.0.1:1", "echo"}); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if app.timeout != 5*time.Second || app.interval != 500*time.Millisecond {
+			t.Fatalf("Wrong flags: %v, %v", app.timeout, app.interval)
+		}
+		if strings.Join(app.endpoints, " ") != "127.0.0.1:1" || strings.Join(app.command, " ") != "echo" {
+			t.Fatalf("Wrong endpoints or command: %v, %v", app.endpoints, app.command)
+		}
+	})
+
+	t.Run("Test environment variables", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "tcpw.yaml")
+		if err := os.WriteFile(path, []byte(yamlConfig), 0644); err != nil {
+			t.Fatal(err)
+		}
+		t.Setenv("TCPW_CONFIG", path)
+		t.Setenv("TCPW_TIMEOUT", "20s")
+		t.Setenv("TCPW_INTERVAL", "2s")
+		t.Setenv("TCPW_LOG_LEVEL", "warn")
+		t.Setenv("TCPW_ENDPOINTS", "db=127.0.0.1:5432, 127.0.0.1:6379")
+		var app App
+		if err := app.Parse("tcpw", []string{"-i", "100ms"}); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if app.timeout != 20*time.Second || app.interval != 100*time.Millisecond || app.logLevel.String() != "warn" || !app.supervise {
+			t.Fatalf("Wrong flags: %v, %v, %v, %v", app.timeout, app.interval, app.logLevel.String(), app.supervise)
+		}
+		if strings.Join(app.Labels(), " ") != "db 127.0.0.1:6379" {
+			t.Fatalf("Wrong endpoints: %v", app.Labels())
+		}
+
+		t.Setenv("TCPW_TIMEOUT", "soon")
+		if err := app.Parse("tcpw", nil); err == nil || !strings.HasPrefix(err.Error(), "TCPW_TIMEOUT: invalid value") {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	})
+
+	t.Run("Test environment of hooks", func(t *testing.T) {
+		for _, name := range hookEnv {
+			t.Setenv(name, "invalid")
+		}
+		t.Setenv("TCPW_ENDPOINTS", "127.0.0.1:5432")
+		var app App
+		if err := app.Parse("tcpw", nil); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if strings.Join(app.Labels(), " ") != "127.0.0.1:5432" {
+			t.Fatalf("Wrong endpoints: %v", app.Labels())
+		}
+	})
+
+	for _, tt := range []struct {
+		config string
+		err    string
+	}{
+		{"timeout: soon\n", "line 1: invalid value \"soon\" for 'timeout'"},
+		{"colour: never\n", "line 1: unknown key 'colour'"},
+		{"endpoints:\n  - address: 127.0.0.1:5432\n    retries: 5\n", "line 2: per-endpoint 'retries' is not supported, only 'address', 'label', 'protocol', 'timeout', 'interval' or 'status'"},
+		{"endpoints:\n  - address: 127.0.0.1:5432\n    timeout: soon\n", "line 2: invalid value \"127.0.0.1:5432 timeout=soon\" for 'endpoints': invalid value \"soon\" of option 'timeout', expected a non-negative duration"},
+		{"endpoints:\n  - address: tcp://127.0.0.1:5432\n    protocol: udp\n", "line 2: 'protocol' of address tcp://127.0.0.1:5432, which has a scheme already"},
+		{"  timeout: 5s\n", "line 1: unexpected indentation"},
+		{"env: [\"A=1\n", "line 1: lists must be on a single line"},
+	} {
+		t.Run("Test invalid config "+strings.Fields(tt.config)[0], func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "tcpw.yaml")
+			if err := os.WriteFile(path, []byte(tt.config), 0644); err != nil {
+				t.Fatal(err)
+			}
+			app := newApp()
+			if err := app.Parse("tcpw", []string{"-q", "-config", path}); err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+		})
+	}
+
+	t.Run("Test quotes in literal strings", func(t *testing.T) {
+		dir := t.TempDir()
+		yaml, toml := filepath.Join(dir, "tcpw.yaml"), filepath.Join(dir, "tcpw.toml")
+		if err := os.WriteFile(yaml, []byte("endpoints: 127.0.0.1:1\nc: 'echo ''up'''\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(toml, []byte("endpoints = '127.0.0.1:1'\nc = 'echo ''up'''\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		app := newApp()
+		if err := app.Parse("tcpw", []string{"-q", "-config", yaml}); err != nil || app.shell != "echo 'up'" {
+			t.Fatalf("Unexpected result: %q, %v", app.shell, err)
+		}
+		app = newApp()
+		err := app.Parse("tcpw", []string{"-q", "-config", toml})
+		if err == nil || !strings.HasSuffix(err.Error(), `line 2: invalid string 'echo ''up''': literal strings can not contain quotes, use "..." instead`) {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	})
+}
@@ -0,0 +1,105 @@
+//go:build !minimal
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+)
+
+func init() {
+	discoverers["consul"] = Discoverer{
+		Parse: func(name string) error {
+			_, err := ConsulURL(name)
+			return err
+		},
+		Discover: DiscoverConsul,
+	}
+}
+
+type consulEntry struct {
+	Node struct {
+		Address string
+	}
+	Service struct {
+		Address string
+		Port    int
+	}
+}
+
+// ConsulURL returns the URL of the health API of the local Consul agent, or of CONSUL_HTTP_ADDR,
+// for the service in the form 'NAME[?tag=TAG&...]'. Only instances passing health checks are returned,
+// unless 'passing=false' is given.
+func ConsulURL(service string) (string, error) {
+	name, rawQuery, _ := strings.Cut(service, "?")
+	query, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return "", err
+	}
+	if name == "" {
+		return "", errors.New("service name expected, e.g. 'consul://db?tag=primary'")
+	}
+	switch passing := query.Get("passing"); passing {
+	case "":
+		query.Set("passing", "true")
+	case "false", "0":
+		query.Del("passing")
+	}
+	addr := os.Getenv("CONSUL_HTTP_ADDR")
+	if addr == "" {
+		addr = defaultConsulAddr
+	}
+	if !strings.Contains(addr, "://") {
+		addr = "http://" + addr
+	}
+	u := strings.TrimSuffix(addr, "/") + "/v1/health/service/" + url.PathEscape(name)
+	if encoded := query.Encode(); encoded != "" {
+		u += "?" + encoded
+	}
+	return u, nil
+}
+
+// DiscoverConsul returns addresses of instances of the service registered in Consul, see ConsulURL.
+// CONSUL_HTTP_TOKEN is sent as the ACL token, if it is set.
+func DiscoverConsul(ctx context.Context, service string) ([]string, error) {
+	u, err := ConsulURL(service)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	if token := os.Getenv("CONSUL_HTTP_TOKEN"); token != "" {
+		req.Header.Set("X-Consul-Token", token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("consul returned %s", resp.Status)
+	}
+	var entries []consulEntry
+	if err = json.NewDecoder(resp.Body).Decode(&entries); err != nil {
+		return nil, fmt.Errorf("invalid consul response: %w", err)
+	}
+	addrs := make([]string, len(entries))
+	for i, e := range entries {
+		host := e.Service.Address
+		if host == "" {
+			host = e.Node.Address
+		}
+		addrs[i] = net.JoinHostPort(host, strconv.Itoa(e.Service.Port))
+	}
+	return addrs, nil
+}
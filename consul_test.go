@@ -0,0 +1,45 @@
+//go:build !minimal
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestDiscoverConsul(t *testing.T) {
+	up := startListener("")
+	var query string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.RequestURI()
+		if r.Header.Get("X-Consul-Token") != "secret" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		fmt.Fprintf(w, `[{"Node": {"Address": "127.0.0.1"}, "Service": {"Address": "", "Port": %d}},`+
+			`{"Node": {"Address": "10.0.0.1"}, "Service": {"Address": "127.0.0.1", "Port": %d}}]`, up.Port, getFreeTCPAddr().Port)
+	}))
+	defer srv.Close()
+	t.Setenv("CONSUL_HTTP_ADDR", srv.Listener.Addr().String())
+	t.Setenv("CONSUL_HTTP_TOKEN", "secret")
+
+	app := newApp()
+	if err := app.Parse("tcpw", []string{"-consul", "db?tag=primary", "-require", "1"}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := app.Dial(context.Background(), net.Dialer{}, app.endpoints[0]); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if query != "/v1/health/service/db?passing=true&tag=primary" {
+		t.Fatalf("Wrong query: %s", query)
+	}
+
+	t.Setenv("CONSUL_HTTP_TOKEN", "")
+	if err := app.Dial(context.Background(), net.Dialer{}, app.endpoints[0]); err == nil || err.Error() != "consul returned 403 Forbidden" {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+var csvHeader = []string{"timestamp", "endpoint", "result", "latency_ms", "error"}
+
+// CSVLog is a CSV file receiving a row per connection attempt.
+type CSVLog struct {
+	mu   sync.Mutex
+	file *os.File
+	w    *csv.Writer
+}
+
+// OpenCSVLog opens the file to append rows to. The header is written to a new or empty file.
+func OpenCSVLog(path string) (*CSVLog, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	l := &CSVLog{file: f, w: csv.NewWriter(f)}
+	if info, err := f.Stat(); err == nil && info.Size() == 0 {
+		if err = l.Write(csvHeader); err != nil {
+			_ = f.Close()
+			return nil, err
+		}
+	}
+	return l, nil
+}
+
+func (l *CSVLog) Close() error {
+	return l.file.Close()
+}
+
+func (l *CSVLog) Write(row []string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	_ = l.w.Write(row)
+	l.w.Flush()
+	return l.w.Error()
+}
+
+// Record appends the attempt event to '-csv' file, if any.
+func (app App) Record(e Event) {
+	if app.csvLog == nil {
+		return
+	}
+	result := "success"
+	if e.Error != "" {
+		result = "failure"
+	}
+	row := []string{time.Now().Format(time.RFC3339Nano), e.Endpoint, result, fmt.Sprintf("%.3f", e.LatencyMs), e.Error}
+	if err := app.csvLog.Write(row); err != nil {
+		app.Error("failed to write CSV row: %v", err)
+	}
+}
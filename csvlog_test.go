@@ -0,0 +1,47 @@
+package main
+
+import (
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestAppRecord(t *testing.T) {
+	t.Run("Test attempts are appended to CSV file (-csv)", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "attempts.csv")
+		app := newApp()
+		app.endpoints = []string{startListener("").String(), getFreeTCPAddr().String()}
+		app.timeout = 250 * time.Millisecond
+		app.csvPath = path
+		for i := 0; i < 2; i++ {
+			_ = app.Run()
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		rows, err := csv.NewReader(f).ReadAll()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(rows) < 4 || rows[0][0] != "timestamp" || rows[1][0] == "timestamp" {
+			t.Fatalf("Unexpected rows: %v", rows)
+		}
+		results := map[string]int{}
+		for _, row := range rows[1:] {
+			if row[0] == "timestamp" {
+				t.Fatal("Header is repeated")
+			}
+			results[row[2]]++
+			if (row[2] == "failure") != (row[4] != "") {
+				t.Fatalf("Unexpected row: %v", row)
+			}
+		}
+		if results["success"] < 1 || results["failure"] < 2 {
+			t.Fatalf("Unexpected results: %v", results)
+		}
+	})
+}
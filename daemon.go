@@ -0,0 +1,104 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// daemonEnv marks the process started in the background by '-daemon', which runs as usual,
+// or the leader of its session with daemonSession value, which only starts it, see Daemonize.
+const daemonEnv = "TCPW_DAEMONIZED"
+
+const daemonSession = "session"
+
+// DaemonPaths returns the default '-pidfile' and '-log-file' of '-daemon':
+// in /var/run and /var/log for root, in the temporary directory otherwise.
+func DaemonPaths() (pidFile, logFile string) {
+	if os.Geteuid() == 0 {
+		return "/var/run/tcpw.pid", "/var/log/tcpw.log"
+	}
+	return filepath.Join(os.TempDir(), "tcpw.pid"), filepath.Join(os.TempDir(), "tcpw.log")
+}
+
+// IsDaemon reports whether tcpw is the process started in the background by '-daemon'.
+func IsDaemon() bool {
+	return os.Getenv(daemonEnv) == "1"
+}
+
+// IsSessionLeader reports whether tcpw is the leader of the session of '-daemon', which starts the daemon and exits.
+func IsSessionLeader() bool {
+	return os.Getenv(daemonEnv) == daemonSession
+}
+
+// Daemonize starts tcpw with the same arguments again in the background, detached from the terminal, and returns its PID.
+// Like the double fork of daemon(3), tcpw is started as a leader of a new session first, which starts the daemon and exits,
+// so the daemon is not a session leader and never acquires a controlling terminal by opening one.
+// Stdin, stdout and stderr of the daemon are redirected to the null device.
+func Daemonize() (int, error) {
+	if IsSessionLeader() {
+		// stdout is the pipe to tcpw waiting for the PID of the daemon or the error
+		cmd, err := startDaemon("1", nil)
+		if err != nil {
+			fmt.Println(err)
+			return 0, err
+		}
+		fmt.Println(cmd.Process.Pid)
+		return cmd.Process.Pid, cmd.Process.Release()
+	}
+	r, w, err := os.Pipe()
+	if err != nil {
+		return 0, err
+	}
+	defer r.Close()
+	leader, err := startDaemon(daemonSession, w)
+	w.Close()
+	if err != nil {
+		return 0, err
+	}
+	out, _ := io.ReadAll(r)
+	// the leader exits once the daemon is started, its exit code tells nothing more than its output
+	_ = leader.Wait()
+	line := strings.TrimSpace(string(out))
+	if pid, err := strconv.Atoi(line); err == nil {
+		return pid, nil
+	}
+	if line == "" {
+		return 0, errors.New("the daemon was not started")
+	}
+	return 0, errors.New(line)
+}
+
+// startDaemon starts tcpw with the same arguments and daemonEnv of the stage: in a new session with stdout to w,
+// if it is given, or the daemon itself, with stdin, stdout and stderr redirected to the null device.
+// The PID of the daemon is that of the returned command.
+func startDaemon(stage string, w *os.File) (*exec.Cmd, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+	null, err := os.OpenFile(os.DevNull, os.O_RDWR, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer null.Close()
+	cmd := exec.Command(exe)
+	cmd.Args = os.Args
+	cmd.Env = append(os.Environ(), daemonEnv+"="+stage)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = null, null, null
+	if w != nil {
+		cmd.Stdout = w
+		if err = Detach(cmd); err != nil {
+			return nil, err
+		}
+	}
+	if err = cmd.Start(); err != nil {
+		return nil, err
+	}
+	return cmd, nil
+}
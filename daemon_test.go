@@ -0,0 +1,49 @@
+package main
+
+import "testing"
+
+func TestDaemon(t *testing.T) {
+	t.Run("Test defaults", func(t *testing.T) {
+		pidFile, logFile := DaemonPaths()
+		app := newApp()
+		if err := app.Parse("tcpw", []string{"-daemon", "-supervise", "-a", "localhost:5432", "--", "true"}); err != nil {
+			t.Fatal(err)
+		}
+		if app.pidFile != pidFile || app.logFilePath != logFile {
+			t.Fatalf("Unexpected files: %s, %s", app.pidFile, app.logFilePath)
+		}
+		app = newApp()
+		if err := app.Parse("tcpw", []string{"-daemon", "-pidfile", "/run/db.pid", "-log-file", "db.log", "-a", "localhost:5432"}); err != nil {
+			t.Fatal(err)
+		}
+		if app.pidFile != "/run/db.pid" || app.logFilePath != "db.log" {
+			t.Fatalf("Unexpected files: %s, %s", app.pidFile, app.logFilePath)
+		}
+		app = newApp()
+		if err := app.Parse("tcpw", []string{"-daemon", "-syslog", "-a", "localhost:5432"}); err != nil {
+			t.Fatal(err)
+		}
+		if app.pidFile != pidFile || app.logFilePath != "" {
+			t.Fatalf("Unexpected files: %s, %s", app.pidFile, app.logFilePath)
+		}
+	})
+
+	t.Run("Test stages", func(t *testing.T) {
+		for value, want := range map[string][2]bool{"": {false, false}, daemonSession: {false, true}, "1": {true, false}} {
+			t.Setenv(daemonEnv, value)
+			if IsDaemon() != want[0] || IsSessionLeader() != want[1] {
+				t.Fatalf("Wrong stage of %q: %v, %v", value, IsDaemon(), IsSessionLeader())
+			}
+		}
+	})
+
+	t.Run("Test dashboard", func(t *testing.T) {
+		var app App
+		if err := app.ParseArgs([]string{"tcpw", "watch", "-daemon", "localhost:5432"}); err != nil {
+			t.Fatal(err)
+		}
+		if err := app.Check(); err == nil || err.Error() != "'-daemon' can not be combined with 'watch', which needs a terminal" {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	})
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/jackcvr/tcpw/tcpw"
+	"slices"
+	"strings"
+)
+
+// discoverySchemes are schemes of endpoints resolved to addresses by Discover.
+var discoverySchemes = []string{"dns", "srv", "consul", "k8s", "docker"}
+
+// appSchemes are schemes of endpoints checked by App itself, which tcpw.ParseEndpoint does not know.
+var appSchemes = append([]string{"script"}, discoverySchemes...)
+
+// optionalSchemes are schemes whose checkers, discovery backends or interpreters are left out of builds with 'minimal' tag.
+var optionalSchemes = []string{"http", "https", "tls", "consul", "k8s", "docker", "script"}
+
+// IsSupported reports whether endpoints of the scheme are checked by this build of tcpw.
+func IsSupported(scheme string) bool {
+	if _, ok := tcpw.Lookup(scheme); ok {
+		return true
+	}
+	if _, ok := discoverers[scheme]; ok {
+		return true
+	}
+	return scheme == "script" && hasScripts
+}
+
+// ParseEndpoint validates the endpoint and returns its canonical form, see tcpw.ParseEndpoint,
+// which is the value as is for discovered endpoints 'dns://host:port', 'srv://NAME', 'consul://SERVICE',
+// 'k8s://NAMESPACE/SERVICE' and 'docker://CONTAINER', and for check scripts 'script://PATH', which are parsed to report syntax errors early.
+func ParseEndpoint(value string) (string, error) {
+	scheme, rest, _ := strings.Cut(value, "://")
+	if slices.Contains(optionalSchemes, scheme) && !IsSupported(scheme) {
+		return "", fmt.Errorf("'%s' endpoints are not supported by the minimal build of tcpw", scheme)
+	}
+	if d, ok := discoverers[scheme]; ok {
+		if err := d.Parse(rest); err != nil {
+			return "", err
+		}
+		return value, nil
+	}
+	if scheme == "script" {
+		if _, err := LoadScript(rest); err != nil {
+			return "", err
+		}
+		return value, nil
+	}
+	addr, err := tcpw.ParseEndpoint(value)
+	if errors.Is(err, tcpw.ErrUnsupportedScheme) {
+		schemes := append(tcpw.Schemes(), slices.DeleteFunc(slices.Clone(appSchemes), func(scheme string) bool {
+			return !IsSupported(scheme)
+		})...)
+		return "", fmt.Errorf("unsupported scheme '%s', expected %s", scheme, tcpw.QuoteList(schemes))
+	}
+	return addr, err
+}
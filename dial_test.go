@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseEndpoint(t *testing.T) {
+	for value, want := range map[string]string{
+		"127.0.0.1:5432":         "127.0.0.1:5432",
+		"tcp://127.0.0.1:5432":   "127.0.0.1:5432",
+		"db:postgresql":          "db:5432",
+		"[::ffff:10.3.2.1]:5432": "10.3.2.1:5432",
+		"udp://127.0.0.1:53":     "udp://127.0.0.1:53",
+		"udp://dns.invalid:53":   "udp://dns.invalid:53",
+		"unix:///run/app.sock":   "unix:///run/app.sock",
+	} {
+		if got, err := ParseEndpoint(value); err != nil || got != want {
+			t.Fatalf("Unexpected endpoint of %s: %q, %v", value, got, err)
+		}
+	}
+	for _, value := range []string{"udp://localhost:99999", "unix://", "ftp://localhost:21"} {
+		if got, err := ParseEndpoint(value); err == nil {
+			t.Fatalf("Unexpected success of %s: %q", value, got)
+		}
+	}
+}
+
+func TestDialSchemes(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	app := newApp()
+
+	t.Run("Test udp", func(t *testing.T) {
+		conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		addr := conn.LocalAddr().String()
+		if err = app.Dial(ctx, net.Dialer{}, "udp://"+addr); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		conn.Close()
+		if err = app.Dial(ctx, net.Dialer{}, "udp://"+addr); err == nil || !strings.Contains(err.Error(), "connection refused") {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	})
+
+	t.Run("Test unix", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "app.sock")
+		l, err := net.Listen("unix", path)
+		if err != nil {
+			t.Skipf("unix sockets are not supported: %v", err)
+		}
+		defer l.Close()
+		if err = app.Dial(ctx, net.Dialer{}, "unix://"+path); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if err = app.Dial(ctx, net.Dialer{}, "unix://"+path+".missing"); err == nil {
+			t.Fatal("Unexpected success")
+		}
+	})
+
+	t.Run("Test path", func(t *testing.T) {
+		dir := t.TempDir()
+		var app App
+		if err := app.Parse("tcpw", []string{"-path", dir + "/app.pid", "-path", dir + "/app.sock"}); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		pid, sock := app.endpoints[0], app.endpoints[1]
+		if pid != "path://"+dir+"/app.pid" {
+			t.Fatalf("Wrong endpoint: %s", pid)
+		}
+		if err := app.Dial(ctx, net.Dialer{}, pid); !errors.Is(err, fs.ErrNotExist) {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if err := os.WriteFile(dir+"/app.pid", []byte("1\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := app.Dial(ctx, net.Dialer{}, pid); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		l, err := net.Listen("unix", dir+"/app.sock")
+		if err != nil {
+			t.Skipf("unix sockets are not supported: %v", err)
+		}
+		l.(*net.UnixListener).SetUnlinkOnClose(false)
+		if err = app.Dial(ctx, net.Dialer{}, sock); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		l.Close()
+		if err = app.Dial(ctx, net.Dialer{}, sock); err == nil || !strings.Contains(err.Error(), "connection refused") {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	})
+}
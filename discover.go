@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/jackcvr/tcpw/tcpw"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// lookupSRV and lookupHost resolve DNS records, replaced in tests.
+var (
+	lookupSRV  = net.DefaultResolver.LookupSRV
+	lookupHost = net.DefaultResolver.LookupHost
+)
+
+// Defaults of discovery backends, kept in builds with 'minimal' tag for the usage.
+const (
+	defaultConsulAddr = "127.0.0.1:8500"
+	defaultDockerHost = "unix:///var/run/docker.sock"
+)
+
+// Discoverer resolves names of endpoints of a discovery scheme, e.g. 'db' of 'consul://db', to addresses.
+type Discoverer struct {
+	Parse    func(name string) error // reports syntax errors of the name early
+	Discover func(ctx context.Context, name string) ([]string, error)
+}
+
+// discoverers are discovery backends by scheme. Those querying registries over HTTP register themselves,
+// unless tcpw is built with 'minimal' tag.
+var discoverers = map[string]Discoverer{
+	"dns": {
+		Parse: func(name string) error {
+			_, _, err := net.SplitHostPort(name)
+			return err
+		},
+		Discover: DiscoverDNS,
+	},
+	"srv": {
+		Parse: func(name string) error {
+			if name == "" {
+				return errors.New("DNS name expected, e.g. 'srv://_postgres._tcp.example.com'")
+			}
+			return nil
+		},
+		Discover: DiscoverSRV,
+	},
+}
+
+// SchemeEndpoints is the value of a flag adding endpoints of a discovery scheme, e.g. '-srv NAME' for 'srv://NAME'.
+type SchemeEndpoints struct {
+	LabeledEndpoints
+	scheme string
+}
+
+func (se SchemeEndpoints) Set(value string) error {
+	value, err := ExpandVars(value)
+	if err != nil {
+		return err
+	}
+	label, name, ok := strings.Cut(value, "=")
+	if !ok || !labelRe.MatchString(label) {
+		return se.LabeledEndpoints.add(se.scheme + "://" + value)
+	}
+	return se.LabeledEndpoints.add(label + "=" + se.scheme + "://" + name)
+}
+
+// Discover returns addresses published for the endpoint of a discovery scheme,
+// or ok false if the endpoint is not discovered.
+func (app App) Discover(ctx context.Context, addr string) (addrs []string, ok bool, err error) {
+	scheme, name, _ := strings.Cut(addr, "://")
+	d, ok := discoverers[scheme]
+	if !ok {
+		return nil, false, nil
+	}
+	if scheme == "dns" {
+		if replaced := app.hosts.Replace(name); replaced != name {
+			return []string{replaced}, true, nil
+		}
+	}
+	addrs, err = d.Discover(ctx, name)
+	return addrs, true, err
+}
+
+// DiscoveryError is a failed lookup of addresses of a discovery scheme, which is retried until the timeout,
+// since records may be published later, unlike DNS errors of 'host:port' endpoints.
+type DiscoveryError struct {
+	Err error
+}
+
+func (e *DiscoveryError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *DiscoveryError) Unwrap() error {
+	return e.Err
+}
+
+// DiscoverSRV resolves SRV records of the name, e.g. '_postgres._tcp.example.com', to 'host:port' addresses.
+func DiscoverSRV(ctx context.Context, name string) ([]string, error) {
+	_, records, err := lookupSRV(ctx, "", "", name)
+	if err != nil {
+		return nil, &DiscoveryError{err}
+	}
+	addrs := make([]string, len(records))
+	for i, r := range records {
+		addrs[i] = net.JoinHostPort(strings.TrimSuffix(r.Target, "."), strconv.Itoa(int(r.Port)))
+	}
+	return addrs, nil
+}
+
+// DiscoverDNS resolves all addresses of the host of 'host:port', e.g. pods of a headless Kubernetes service.
+// A host which is not found has no instances yet, e.g. until the first pod of the service is ready.
+func DiscoverDNS(ctx context.Context, hostport string) ([]string, error) {
+	host, port, err := net.SplitHostPort(hostport)
+	if err != nil {
+		return nil, err
+	}
+	ips, err := lookupHost(ctx, host)
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, &DiscoveryError{err}
+	}
+	addrs := make([]string, len(ips))
+	for i, ip := range ips {
+		addrs[i] = net.JoinHostPort(ip, port)
+	}
+	return addrs, nil
+}
+
+// DialDiscovered connects to the discovered instances of the endpoint concurrently and returns an error,
+// unless at least '-expect-replicas' of them are discovered and '-require' of them, all if it is zero, are available.
+// Addresses are discovered again on every attempt, so the endpoint follows changes of the registry.
+func (app App) DialDiscovered(ctx context.Context, d net.Dialer, addr string, addrs []string) error {
+	if len(addrs) < app.expectReplicas {
+		return fmt.Errorf("%s discovered, %d expected", tcpw.Plural(len(addrs), "instance"), app.expectReplicas)
+	}
+	required := app.require
+	if required == 0 {
+		required = max(len(addrs), 1)
+	}
+	if len(addrs) < required {
+		return fmt.Errorf("%s discovered, %d required", tcpw.Plural(len(addrs), "instance"), required)
+	}
+	errs := make([]error, len(addrs))
+	var wg sync.WaitGroup
+	for i, a := range addrs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if errs[i] = app.dial(ctx, d, a); errs[i] != nil {
+				errs[i] = fmt.Errorf("%s: %w", a, errs[i])
+			}
+		}()
+	}
+	wg.Wait()
+	available := len(addrs)
+	for _, err := range errs {
+		if err != nil {
+			available--
+		}
+	}
+	if available < required {
+		return fmt.Errorf("%d of %s available, %d required: %w", available, tcpw.Plural(len(addrs), "instance"), required, errors.Join(errs...))
+	}
+	app.Debug("%d of %s of %s available", available, tcpw.Plural(len(addrs), "instance"), app.Label(addr))
+	return nil
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// stubSRV makes SRV records of every name point to the addresses.
+func stubSRV(t *testing.T, addrs ...string) {
+	lookup := lookupSRV
+	t.Cleanup(func() {
+		lookupSRV = lookup
+	})
+	lookupSRV = func(ctx context.Context, service, proto, name string) (string, []*net.SRV, error) {
+		if len(addrs) == 0 {
+			return "", nil, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+		}
+		var records []*net.SRV
+		for _, addr := range addrs {
+			host, port, _ := net.SplitHostPort(addr)
+			p, _ := strconv.Atoi(port)
+			records = append(records, &net.SRV{Target: host + ".", Port: uint16(p)})
+		}
+		return name, records, nil
+	}
+}
+
+func TestDiscoverSRV(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	up := startListener("").String()
+	down := getFreeTCPAddr().String()
+
+	t.Run("Test all instances required", func(t *testing.T) {
+		stubSRV(t, up, down)
+		app := newApp()
+		if err := app.Parse("tcpw", []string{"-srv", "db=_postgres._tcp.example.com"}); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if app.endpoints[0] != "srv://_postgres._tcp.example.com" || app.Label(app.endpoints[0]) != "db" {
+			t.Fatalf("Wrong endpoints: %v", app.Labels())
+		}
+		err := app.Dial(ctx, net.Dialer{}, app.endpoints[0])
+		if err == nil || !strings.HasPrefix(err.Error(), "1 of 2 instances available, 2 required: "+down+": ") {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	})
+
+	t.Run("Test some instances required (-require)", func(t *testing.T) {
+		stubSRV(t, startListener("").String(), down)
+		app := newApp()
+		app.require = 1
+		if err := app.Dial(ctx, net.Dialer{}, "srv://_postgres._tcp.example.com"); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	})
+
+	t.Run("Test no records", func(t *testing.T) {
+		stubSRV(t)
+		app := newApp()
+		var dnsErr *net.DNSError
+		if err := app.Dial(ctx, net.Dialer{}, "srv://_postgres._tcp.example.com"); !errors.As(err, &dnsErr) || IsFatal(err) {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	})
+
+	t.Run("Test records published later", func(t *testing.T) {
+		stubSRV(t)
+		var lookups atomic.Int32
+		port := startListener("").Port
+		lookupSRV = func(ctx context.Context, service, proto, name string) (string, []*net.SRV, error) {
+			if lookups.Add(1) < 3 {
+				return "", nil, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+			}
+			return name, []*net.SRV{{Target: "localhost.", Port: uint16(port)}}, nil
+		}
+		app := newApp()
+		app.timeout = 2 * time.Second
+		app.interval = 10 * time.Millisecond
+		app.endpoints = []string{"srv://_postgres._tcp.example.com"}
+		if err := app.Run(); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if n := lookups.Load(); n < 3 {
+			t.Fatalf("Wrong number of lookups: %d", n)
+		}
+	})
+
+	t.Run("Test no records in time", func(t *testing.T) {
+		stubSRV(t)
+		app := newApp()
+		app.timeout = 100 * time.Millisecond
+		app.interval = 10 * time.Millisecond
+		app.endpoints = []string{"srv://_postgres._tcp.example.com"}
+		if code := app.ExitCode(app.Run()); code != ExitDNS {
+			t.Fatalf("Wrong exit code: %d", code)
+		}
+	})
+}
+
+func TestDiscoverDNS(t *testing.T) {
+	var ips []string
+	lookup := lookupHost
+	lookupHost = func(ctx context.Context, host string) ([]string, error) {
+		if len(ips) == 0 {
+			return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+		}
+		return ips, nil
+	}
+	defer func() {
+		lookupHost = lookup
+	}()
+	l := serve("127.0.0.1:0")
+	defer l.Close()
+	_, port, _ := net.SplitHostPort(l.Addr().String())
+
+	app := newApp()
+	if err := app.Parse("tcpw", []string{"-all-ips", "db-headless:" + port, "-expect-replicas", "2"}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := app.Dial(context.Background(), net.Dialer{}, app.endpoints[0]); err == nil || err.Error() != "0 instances discovered, 2 expected" || IsFatal(err) {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	ips = []string{"127.0.0.1"}
+	if err := app.Dial(context.Background(), net.Dialer{}, app.endpoints[0]); err == nil || err.Error() != "1 instance discovered, 2 expected" {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	ips = []string{"127.0.0.1", "127.0.0.2"}
+	if err := app.Dial(context.Background(), net.Dialer{}, app.endpoints[0]); err == nil || !strings.HasPrefix(err.Error(), "1 of 2 instances available, 2 required: 127.0.0.2:"+port) {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	ips = []string{"127.0.0.1", "127.0.0.1"}
+	if err := app.Dial(context.Background(), net.Dialer{}, app.endpoints[0]); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// pods become ready while tcpw waits for them
+	var lookups atomic.Int32
+	lookupHost = func(ctx context.Context, host string) ([]string, error) {
+		switch lookups.Add(1) {
+		case 1, 2:
+			return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+		case 3:
+			return []string{"127.0.0.1"}, nil
+		}
+		return []string{"127.0.0.1", "127.0.0.1"}, nil
+	}
+	app.timeout = 2 * time.Second
+	app.interval = 10 * time.Millisecond
+	if err := app.Run(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if n := lookups.Load(); n != 4 {
+		t.Fatalf("Wrong number of lookups: %d", n)
+	}
+}
@@ -0,0 +1,148 @@
+//go:build !minimal
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+func init() {
+	discoverers["docker"] = Discoverer{
+		Parse: func(name string) error {
+			_, _, err := ParseDockerContainer(name)
+			return err
+		},
+		Discover: DiscoverDocker,
+	}
+}
+
+type dockerPortBinding struct {
+	HostIp   string
+	HostPort string
+}
+
+type dockerContainer struct {
+	State struct {
+		Running bool
+	}
+	Config struct {
+		ExposedPorts map[string]struct{}
+	}
+	NetworkSettings struct {
+		Ports    map[string][]dockerPortBinding
+		Networks map[string]struct {
+			IPAddress string
+		}
+	}
+}
+
+// ParseDockerContainer parses the container in the form 'NAME[:PORT]'.
+func ParseDockerContainer(container string) (name, port string, err error) {
+	name, port, _ = strings.Cut(container, ":")
+	if name == "" {
+		return "", "", errors.New("container expected in the form 'name[:port]', e.g. 'docker://db:5432'")
+	}
+	if port != "" {
+		if n, err := strconv.ParseUint(port, 10, 16); err != nil || n == 0 {
+			return "", "", fmt.Errorf("invalid port: %s", port)
+		}
+	}
+	return name, port, nil
+}
+
+// DockerClient returns an HTTP client of the Docker engine at $DOCKER_HOST, the local socket by default,
+// and the base URL of its API.
+func DockerClient() (*http.Client, string, error) {
+	host := os.Getenv("DOCKER_HOST")
+	if host == "" {
+		host = defaultDockerHost
+	}
+	scheme, addr, _ := strings.Cut(host, "://")
+	switch scheme {
+	case "unix":
+		client := &http.Client{Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, "unix", addr)
+			},
+		}}
+		return client, "http://docker", nil
+	case "tcp":
+		return http.DefaultClient, "http://" + addr, nil
+	}
+	return nil, "", fmt.Errorf("unsupported DOCKER_HOST: %s", host)
+}
+
+// DiscoverDocker returns the address of the running container, see ParseDockerContainer:
+// the published port on the host if it is published, or the port on the container's network otherwise.
+// The lowest exposed port is used, unless a port is given.
+func DiscoverDocker(ctx context.Context, container string) ([]string, error) {
+	name, port, err := ParseDockerContainer(container)
+	if err != nil {
+		return nil, err
+	}
+	client, base, err := DockerClient()
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, base+"/containers/"+url.PathEscape(name)+"/json", nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return nil, fmt.Errorf("no such container: %s", name)
+	default:
+		return nil, fmt.Errorf("docker returned %s", resp.Status)
+	}
+	var c dockerContainer
+	if err = json.NewDecoder(resp.Body).Decode(&c); err != nil {
+		return nil, fmt.Errorf("invalid docker response: %w", err)
+	}
+	if !c.State.Running {
+		return nil, fmt.Errorf("container %s is not running", name)
+	}
+	if port == "" {
+		var ports []int
+		for exposed := range c.Config.ExposedPorts {
+			if n, proto, _ := strings.Cut(exposed, "/"); proto == "tcp" {
+				if p, err := strconv.Atoi(n); err == nil {
+					ports = append(ports, p)
+				}
+			}
+		}
+		if len(ports) == 0 {
+			return nil, fmt.Errorf("container %s exposes no TCP ports, the port must be given", name)
+		}
+		port = strconv.Itoa(slices.Min(ports))
+	}
+	for _, b := range c.NetworkSettings.Ports[port+"/tcp"] {
+		host := b.HostIp
+		if host == "" || net.ParseIP(host).IsUnspecified() {
+			host = "127.0.0.1"
+		}
+		return []string{net.JoinHostPort(host, b.HostPort)}, nil
+	}
+	for _, network := range c.NetworkSettings.Networks {
+		if network.IPAddress != "" {
+			return []string{net.JoinHostPort(network.IPAddress, port)}, nil
+		}
+	}
+	return nil, fmt.Errorf("container %s has no address", name)
+}
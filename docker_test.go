@@ -0,0 +1,50 @@
+//go:build !minimal
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDiscoverDocker(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "docker.sock")
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		t.Skipf("unix sockets are not supported: %v", err)
+	}
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/containers/db/json":
+			fmt.Fprint(w, `{"State": {"Running": true}, "Config": {"ExposedPorts": {"9187/tcp": {}, "5432/tcp": {}}}, `+
+				`"NetworkSettings": {"Ports": {"5432/tcp": [{"HostIp": "0.0.0.0", "HostPort": "15432"}]}, `+
+				`"Networks": {"bridge": {"IPAddress": "172.17.0.2"}}}}`)
+		case "/containers/stopped/json":
+			fmt.Fprint(w, `{"State": {"Running": false}}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	srv.Listener = l
+	srv.Start()
+	defer srv.Close()
+	t.Setenv("DOCKER_HOST", "unix://"+path)
+
+	for container, want := range map[string]string{"db": "127.0.0.1:15432", "db:9187": "172.17.0.2:9187"} {
+		addrs, err := DiscoverDocker(context.Background(), container)
+		if err != nil || strings.Join(addrs, " ") != want {
+			t.Fatalf("Wrong addresses of %s: %v, %v", container, addrs, err)
+		}
+	}
+	for container, want := range map[string]string{"cache": "no such container: cache", "stopped": "container stopped is not running"} {
+		if _, err := DiscoverDocker(context.Background(), container); err == nil || err.Error() != want {
+			t.Fatalf("Unexpected error of %s: %v", container, err)
+		}
+	}
+}
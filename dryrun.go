@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"github.com/jackcvr/tcpw/tcpw"
+	"io"
+	"net"
+	"strings"
+)
+
+// Plan prints the effective configuration without dialing.
+// Hosts of HTTP and TLS endpoints and host names of TCP and UDP ones are resolved to validate them.
+func (app App) Plan(w io.Writer) error {
+	fmt.Fprintln(w, "endpoints:")
+	for _, addr := range app.endpoints {
+		fmt.Fprint(w, "  ")
+		if label, ok := app.labels[addr]; ok {
+			fmt.Fprintf(w, "%s=", label)
+		}
+		host, _ := tcpw.HostPort(addr)
+		switch scheme := tcpw.Scheme(addr); {
+		case scheme == "http" || scheme == "https" || scheme == "tls":
+		case (scheme == "tcp" || scheme == "udp") && host != "" && net.ParseIP(host) == nil:
+		default:
+			fmt.Fprintf(w, "%s\n", addr)
+			continue
+		}
+		ips, err := net.LookupHost(host)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "%s (%s)\n", addr, strings.Join(ips, ", "))
+	}
+	if app.when != "" {
+		fmt.Fprintf(w, "when: %s\n", app.when)
+	}
+	timeout := "none"
+	if app.timeout > 0 {
+		timeout = app.timeout.String()
+	}
+	fmt.Fprintf(w, "timeout: %s\n", timeout)
+	fmt.Fprintf(w, "interval: %s\n", app.interval)
+	if commands := app.Commands(); commands != nil {
+		for _, args := range commands {
+			fmt.Fprintf(w, "command: %q\n", args)
+		}
+		fmt.Fprintf(w, "condition: %s\n", app.on)
+		if app.workdir != "" {
+			fmt.Fprintf(w, "workdir: %s\n", app.workdir)
+		}
+		for _, env := range app.env {
+			fmt.Fprintf(w, "env: %s\n", env)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAppPlan(t *testing.T) {
+	app := newApp()
+	app.timeout = 30 * time.Second
+	app.endpoints = []string{"127.0.0.1:5432", "http://127.0.0.1/health"}
+	app.command = []string{"echo", "up"}
+	var b strings.Builder
+	if err := app.Plan(&b); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := "endpoints:\n" +
+		"  127.0.0.1:5432\n" +
+		"  http://127.0.0.1/health (127.0.0.1)\n" +
+		"timeout: 30s\n" +
+		"interval: 100ms\n" +
+		"command: [\"echo\" \"up\"]\n" +
+		"condition: s\n"
+	if b.String() != expected {
+		t.Fatalf("Wrong plan:\n%s", b.String())
+	}
+}
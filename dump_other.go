@@ -0,0 +1,8 @@
+//go:build !unix
+
+package main
+
+import "os"
+
+// dumpSignals ask tcpw to write the current state to stderr, see DumpOnSignal. There are none on this platform.
+var dumpSignals []os.Signal
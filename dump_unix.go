@@ -0,0 +1,11 @@
+//go:build unix
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// dumpSignals ask tcpw to write the current state to stderr, see DumpOnSignal.
+var dumpSignals = []os.Signal{syscall.SIGUSR1, syscall.SIGQUIT}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// EnvFile is the value of '-env-file' flag: a file with environment variables for the command.
+type EnvFile struct {
+	*Env
+}
+
+func (ef EnvFile) String() string {
+	return ""
+}
+
+func (ef EnvFile) Set(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err = ef.Load(f); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
+
+// Load adds variables read from r in the form 'KEY=VALUE', optionally prefixed with 'export'.
+// Values may be quoted: double quoted ones with escapes like '\n', single quoted ones as is.
+// Empty lines and '#' comments are skipped.
+func (ef EnvFile) Load(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(stripComment(scanner.Text()))
+		if line == "" {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+		key, value, ok := strings.Cut(line, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" || strings.ContainsAny(key, " \t") {
+			return fmt.Errorf("line %d: 'KEY=VALUE' expected", n)
+		}
+		value, ok = unquote(strings.TrimSpace(value))
+		if !ok {
+			return fmt.Errorf("line %d: invalid string %s", n, value)
+		}
+		*ef.Env = append(*ef.Env, key+"="+value)
+	}
+	return scanner.Err()
+}
+
+// Map returns the variables by their names, the last value of repeated ones.
+func (env *Env) Map() map[string]string {
+	m := make(map[string]string, len(*env))
+	for _, kv := range *env {
+		k, v, _ := strings.Cut(kv, "=")
+		m[k] = v
+	}
+	return m
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	EventAttempt = "attempt"
+	EventState   = "state"
+	EventCommand = "command"
+)
+
+// Event is a line of the '-events' stream.
+// Attempt events are emitted for every connection attempt, state events - when an endpoint
+// (or all of them when Endpoint is empty) changes its state and command events - when the command starts or exits.
+type Event struct {
+	Time      time.Time `json:"time"`
+	Type      string    `json:"type"`
+	Endpoint  string    `json:"endpoint,omitempty"`
+	Attempt   int       `json:"attempt,omitempty"`
+	LatencyMs float64   `json:"latency_ms,omitempty"`
+	Error     string    `json:"error,omitempty"`
+	State     string    `json:"state,omitempty"`
+	Pid       int       `json:"pid,omitempty"`
+	ExitCode  *int      `json:"exit_code,omitempty"`
+}
+
+// Events is a stream of newline delimited JSON events.
+type Events struct {
+	mu sync.Mutex
+	w  io.WriteCloser
+}
+
+// OpenEvents opens the file to append events to, or stdout if path is '-'.
+func OpenEvents(path string) (*Events, error) {
+	if path == "-" {
+		return &Events{w: nopCloser{os.Stdout}}, nil
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &Events{w: f}, nil
+}
+
+func (ev *Events) Close() error {
+	return ev.w.Close()
+}
+
+func (ev *Events) Write(e Event) error {
+	e.Time = time.Now()
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	ev.mu.Lock()
+	defer ev.mu.Unlock()
+	_, err = ev.w.Write(append(b, '\n'))
+	return err
+}
+
+// Emit writes the event to '-events' stream, if any, updates the state served on '-status-listen'
+// and runs '-on-state-change' hook for state events.
+func (app App) Emit(e Event) {
+	app.tracker.Update(e)
+	if e.Type == EventState && app.onStateChange != "" {
+		if err := app.Hook(app.onStateChange, "TCPW_ENDPOINT="+e.Endpoint, "TCPW_ENDPOINT_STATE="+e.State, "TCPW_ERROR="+e.Error); err != nil {
+			app.Error("on-state-change hook failed: %v", err)
+		}
+	}
+	if app.events == nil {
+		return
+	}
+	if err := app.events.Write(e); err != nil {
+		app.Error("failed to write event: %v", err)
+	}
+}
+
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error {
+	return nil
+}
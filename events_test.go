@@ -0,0 +1,45 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAppEvents(t *testing.T) {
+	t.Run("Test events are appended to file (-events)", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "events.ndjson")
+		app := newApp()
+		addr := startListener("").String()
+		app.endpoints = []string{addr}
+		app.eventsPath = path
+		app.command = []string{"true"}
+		if err := app.Run(); err != nil {
+			t.Fatal(err)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+			var e Event
+			if err = json.Unmarshal([]byte(line), &e); err != nil {
+				t.Fatalf("Invalid JSON line %q: %v", line, err)
+			}
+			if e.Type != EventCommand && e.Endpoint != addr {
+				t.Fatalf("Wrong endpoint: %s", line)
+			}
+			if e.Type == EventCommand && e.State == "exited" && (e.ExitCode == nil || *e.ExitCode != 0) {
+				t.Fatalf("Wrong exit code: %s", line)
+			}
+			got = append(got, e.Type+":"+e.State)
+		}
+		want := "state:waiting attempt: state:up command:started command:exited"
+		if strings.Join(got, " ") != want {
+			t.Fatalf("Unexpected events: %v", got)
+		}
+	})
+}
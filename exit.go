@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"net"
+	"os/exec"
+	"syscall"
+)
+
+// Exit codes of tcpw. The exit code of the executed command takes precedence.
+const (
+	ExitSuccess       = 0
+	ExitFailure       = 1   // any other failure
+	ExitTimeout       = 3   // endpoints are not available in time
+	ExitDNS           = 4   // endpoint host can not be resolved
+	ExitRefused       = 5   // endpoints are not available in time and refused the last connection attempt
+	ExitInvalid       = 22  // invalid arguments
+	ExitLocked        = 75  // '-lock' file is locked by another run, like EX_TEMPFAIL of sysexits.h
+	ExitTimeoutCompat = 124 // endpoints are not available in time, with '-timeout-compat' like GNU timeout(1)
+	ExitCmdTimeout    = 124 // command is terminated on '-cmd-timeout', like GNU timeout(1)
+	ExitCannotExecute = 126 // command can not be executed
+	ExitNotFound      = 127 // command is not found
+	ExitSignal        = 128 // plus number of the signal, which terminated the command or tcpw itself
+)
+
+// ExitCode returns the exit code of tcpw for the error of Run.
+// '-exit-success' is only returned if no command was executed, otherwise it exited with zero.
+func (app App) ExitCode(err error) int {
+	var exErr *exec.ExitError
+	var intErr *InterruptError
+	var launchErr *LaunchError
+	var dnsErr *net.DNSError
+	var addrErr *net.AddrError
+	switch {
+	case err == nil && app.executed != nil && app.executed.Load():
+		return ExitSuccess // of the command
+	case err == nil:
+		return app.exitSuccess
+	case errors.As(err, &intErr):
+		if sig, ok := intErr.Signal.(syscall.Signal); ok {
+			return ExitSignal + int(sig)
+		}
+		return ExitFailure
+	case errors.As(err, &exErr):
+		if status, ok := exErr.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+			return ExitSignal + int(status.Signal())
+		}
+		return exErr.ExitCode()
+	case errors.Is(err, ErrCmdTimeout):
+		return ExitCmdTimeout
+	case errors.Is(err, ErrLocked):
+		return ExitLocked
+	case errors.As(err, &launchErr):
+		if errors.Is(err, exec.ErrNotFound) || errors.Is(err, fs.ErrNotExist) {
+			return ExitNotFound
+		}
+		return ExitCannotExecute
+	case app.scan:
+		return ExitFailure
+	case errors.As(err, &dnsErr):
+		return ExitDNS
+	case errors.As(err, &addrErr):
+		return ExitInvalid
+	case app.timeoutCompat && errors.Is(err, context.DeadlineExceeded):
+		return ExitTimeoutCompat
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return app.exitRefused
+	case errors.Is(err, context.DeadlineExceeded):
+		return app.exitTimeout
+	default:
+		return ExitFailure
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestAppExitCode(t *testing.T) {
+	app := newApp()
+	app.exitSuccess = 10
+	app.exitTimeout = 11
+	app.exitRefused = 12
+
+	t.Run("Test success", func(t *testing.T) {
+		if code := app.ExitCode(nil); code != 10 {
+			t.Fatalf("Wrong exit code: %d", code)
+		}
+	})
+
+	t.Run("Test timeout", func(t *testing.T) {
+		if code := app.ExitCode(context.DeadlineExceeded); code != 11 {
+			t.Fatalf("Wrong exit code: %d", code)
+		}
+	})
+
+	t.Run("Test refused", func(t *testing.T) {
+		app := app
+		app.timeout = 100 * time.Millisecond
+		app.endpoints = []string{getFreeTCPAddr().String()}
+		if code := app.ExitCode(app.Run()); code != 12 {
+			t.Fatalf("Wrong exit code: %d", code)
+		}
+	})
+
+	t.Run("Test command exited with zero", func(t *testing.T) {
+		app := app
+		app.executed = new(atomic.Bool)
+		app.endpoints = []string{startListener("").String()}
+		app.shell = "exit 0"
+		if code := app.ExitCode(app.Run()); code != ExitSuccess {
+			t.Fatalf("Wrong exit code: %d", code)
+		}
+	})
+
+	t.Run("Test no command executed (-on f)", func(t *testing.T) {
+		app := app
+		app.executed = new(atomic.Bool)
+		app.on = "f"
+		app.endpoints = []string{startListener("").String()}
+		app.shell = "exit 0"
+		if code := app.ExitCode(app.Run()); code != 10 {
+			t.Fatalf("Wrong exit code: %d", code)
+		}
+	})
+
+	t.Run("Test command exit code", func(t *testing.T) {
+		app := app
+		app.endpoints = []string{startListener("").String()}
+		app.shell = "exit 7"
+		if code := app.ExitCode(app.Run()); code != 7 {
+			t.Fatalf("Wrong exit code: %d", code)
+		}
+	})
+
+	t.Run("Test command not found", func(t *testing.T) {
+		app := app
+		app.endpoints = []string{startListener("").String()}
+		app.command = []string{"/no/such/command"}
+		if code := app.ExitCode(app.Run()); code != ExitNotFound {
+			t.Fatalf("Wrong exit code: %d", code)
+		}
+	})
+
+	t.Run("Test invalid address", func(t *testing.T) {
+		app := app
+		app.endpoints = []string{badAddr}
+		if code := app.ExitCode(app.Run()); code != ExitInvalid {
+			t.Fatalf("Wrong exit code: %d", code)
+		}
+	})
+
+	t.Run("Test DNS failure", func(t *testing.T) {
+		app := app
+		app.endpoints = []string{"no-such-host.invalid:80"}
+		if code := app.ExitCode(app.Run()); code != ExitDNS {
+			t.Fatalf("Wrong exit code: %d", code)
+		}
+		// the host is resolved by connection attempts, not on parsing
+		app = newApp()
+		if err := app.Parse("tcpw", []string{"-q", "-t", "1s", "-a", "no-such-host.invalid:80"}); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if code := app.ExitCode(app.Run()); code != ExitDNS {
+			t.Fatalf("Wrong exit code: %d", code)
+		}
+	})
+
+	t.Run("Test GNU timeout compatibility (-timeout-compat)", func(t *testing.T) {
+		app := app
+		app.timeoutCompat = true
+		app.timeout = 100 * time.Millisecond
+		app.endpoints = []string{getFreeTCPAddr().String()}
+		if code := app.ExitCode(app.Run()); code != ExitTimeoutCompat {
+			t.Fatalf("Wrong exit code: %d", code)
+		}
+	})
+}
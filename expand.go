@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// unexpandedKeys are keys of '-config' file with shell commands, which expand variables themselves.
+var unexpandedKeys = map[string]bool{"command": true, "c": true, "pre": true, "post": true, "on-attempt": true}
+
+// ExpandVars replaces '${VAR}' with the value of the environment variable, '${VAR:-default}' with the default
+// if the variable is unset or empty, and '${VAR-default}' if it is unset. '$$' is replaced with '$'.
+// Unset variables without default are an error.
+func ExpandVars(s string) (string, error) {
+	if !strings.Contains(s, "$") {
+		return s, nil
+	}
+	var b strings.Builder
+	for {
+		i := strings.IndexByte(s, '$')
+		if i < 0 || i == len(s)-1 {
+			b.WriteString(s)
+			return b.String(), nil
+		}
+		b.WriteString(s[:i])
+		switch s[i+1] {
+		case '$':
+			b.WriteByte('$')
+			s = s[i+2:]
+			continue
+		case '{':
+		default:
+			b.WriteByte('$')
+			s = s[i+1:]
+			continue
+		}
+		end := strings.IndexByte(s[i:], '}')
+		if end < 0 {
+			return "", fmt.Errorf("unterminated variable: %s", s[i:])
+		}
+		expr := s[i+2 : i+end]
+		s = s[i+end+1:]
+		name, def, hasDef := strings.Cut(expr, "-")
+		name, emptyIsUnset := strings.CutSuffix(name, ":")
+		if name == "" {
+			return "", fmt.Errorf("invalid variable: ${%s}", expr)
+		}
+		value, ok := os.LookupEnv(name)
+		switch {
+		case ok && (value != "" || !emptyIsUnset):
+			b.WriteString(value)
+		case hasDef:
+			b.WriteString(def)
+		case ok:
+		default:
+			return "", fmt.Errorf("variable %s is not set", name)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExpandVars(t *testing.T) {
+	t.Setenv("DB_HOST", "10.3.2.1")
+	t.Setenv("EMPTY", "")
+
+	t.Run("Test variables and defaults", func(t *testing.T) {
+		for s, want := range map[string]string{
+			"${DB_HOST}:${DB_PORT:-5432}":    "10.3.2.1:5432",
+			"${EMPTY:-localhost}:${EMPTY-1}": "localhost:",
+			"${UNSET-}$DB_HOST$$":            "$DB_HOST$",
+			"http://host/?q=$":               "http://host/?q=$",
+		} {
+			if got, err := ExpandVars(s); err != nil || got != want {
+				t.Fatalf("Wrong expansion of %s: %q, %v", s, got, err)
+			}
+		}
+		for s, want := range map[string]string{
+			"${DB_PORT}": "variable DB_PORT is not set",
+			"${DB_HOST":  "unterminated variable: ${DB_HOST",
+			"${:-5432}":  "invalid variable: ${:-5432}",
+		} {
+			if _, err := ExpandVars(s); err == nil || err.Error() != want {
+				t.Fatalf("Unexpected error of %s: %v", s, err)
+			}
+		}
+	})
+
+	t.Run("Test endpoints and config", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "tcpw.yaml")
+		content := "interval: ${INTERVAL:-2s}\nendpoints: [\"cache=${DB_HOST}:6379\"]\ncommand: [sh, -c, 'echo ${DB_HOST}']\n"
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		var app App
+		if err := app.Parse("tcpw", []string{"-config", path, "-a", "db=${DB_HOST}:${DB_PORT:-5432}"}); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if strings.Join(app.endpoints, " ") != "10.3.2.1:5432" || app.Label("10.3.2.1:5432") != "db" || app.interval.String() != "2s" {
+			t.Fatalf("Wrong flags: %v, %v", app.endpoints, app.interval)
+		}
+		if app.command[2] != "echo ${DB_HOST}" {
+			t.Fatalf("Command is expanded: %q", app.command)
+		}
+	})
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+// FormatData holds values for the '-format' template.
+// It is executed for every connection attempt (Type 'attempt', Status 'success' or 'failure')
+// and for every endpoint after the wait (Type 'summary', Status 'success', 'timeout' or 'failure').
+type FormatData struct {
+	Type     string
+	Endpoint string
+	Status   string
+	Attempt  int
+	Latency  time.Duration // of the (last) connection attempt
+	Duration time.Duration // since start of the wait, for summary only
+	Error    string
+}
+
+// Formatter writes lines in '-format'.
+type Formatter struct {
+	mu   sync.Mutex
+	tmpl *template.Template
+	w    io.Writer
+}
+
+func NewFormatter(format string) (*Formatter, error) {
+	if !strings.HasSuffix(format, "\n") {
+		format += "\n"
+	}
+	tmpl, err := template.New("format").Parse(format)
+	if err != nil {
+		return nil, err
+	}
+	return &Formatter{tmpl: tmpl, w: os.Stdout}, nil
+}
+
+func (f *Formatter) Write(data FormatData) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.tmpl.Execute(f.w, data)
+}
+
+// Format writes the data in '-format', if any.
+func (app App) Format(data FormatData) {
+	if app.formatter == nil {
+		return
+	}
+	if err := app.formatter.Write(data); err != nil {
+		app.Error("failed to format output: %v", err)
+	}
+}
+
+// AttemptStatus returns 'success' or 'failure' depending on the error of a connection attempt.
+func AttemptStatus(err error) string {
+	if err == nil {
+		return "success"
+	}
+	return "failure"
+}
+
+// FormatResults writes a summary line per endpoint in '-format', if any.
+func (app App) FormatResults(results []EndpointResult) {
+	for _, r := range results {
+		data := FormatData{Type: "summary", Endpoint: r.Name(), Status: Status(r.Err), Attempt: r.Attempts, Latency: r.Latency, Duration: r.Duration}
+		if r.Err != nil {
+			data.Error = r.Err.Error()
+		}
+		app.Format(data)
+	}
+}
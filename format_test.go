@@ -0,0 +1,45 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestAppFormat(t *testing.T) {
+	t.Run("Test attempt and summary lines (-format)", func(t *testing.T) {
+		app := newApp()
+		addr := startListener("").String()
+		app.endpoints = []string{addr}
+		app.format = "{{.Type}} {{.Endpoint}} {{.Status}} {{.Attempt}}"
+		var buf bytes.Buffer
+		formatter, err := NewFormatter(app.format)
+		if err != nil {
+			t.Fatal(err)
+		}
+		formatter.w = &buf
+		app.formatter = formatter
+		if err = app.Check(); err != nil {
+			t.Fatal(err)
+		}
+		results := app.Connect(context.Background())
+		if err = WaitErr(results); err != nil {
+			t.Fatal(err)
+		}
+		app.FormatResults(results)
+		want := "attempt " + addr + " success 1\nsummary " + addr + " success 1\n"
+		if buf.String() != want {
+			t.Fatalf("Unexpected output: %q", buf.String())
+		}
+	})
+
+	t.Run("Test error: invalid template", func(t *testing.T) {
+		app := newApp()
+		app.endpoints = []string{"localhost:1234"}
+		app.format = "{{.Endpoint"
+		if err := app.Check(); err == nil || !strings.Contains(err.Error(), "format") {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	})
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/jackcvr/tcpw/tcpw"
+	"io"
+	"net"
+	"strings"
+	"sync"
+)
+
+// Forward is a value of '-forward': the local address proxied to the endpoint of the label, or to the only endpoint if there is none.
+type Forward struct {
+	Label string
+	Local string
+}
+
+// Forwards is the value of '-forward' flag in the form '[LABEL=]host:port'.
+type Forwards []Forward
+
+func (f *Forwards) String() string {
+	values := make([]string, len(*f))
+	for i, fwd := range *f {
+		values[i] = fwd.Local
+		if fwd.Label != "" {
+			values[i] = fwd.Label + "=" + fwd.Local
+		}
+	}
+	return strings.Join(values, ", ")
+}
+
+func (f *Forwards) Set(value string) error {
+	var fwd Forward
+	if label, local, ok := strings.Cut(value, "="); ok {
+		fwd = Forward{Label: label, Local: local}
+	} else {
+		fwd.Local = value
+	}
+	if _, _, err := net.SplitHostPort(fwd.Local); err != nil {
+		return err
+	}
+	*f = append(*f, fwd)
+	return nil
+}
+
+// ForwardTarget returns the network and the address to forward connections to the endpoint to:
+// its host and port of TCP, TLS and HTTP(S) endpoints, whose TLS is not terminated, or the path of unix sockets.
+func ForwardTarget(addr string) (network, address string, err error) {
+	switch tcpw.Scheme(addr) {
+	case "tcp", "tls", "http", "https":
+		return "tcp", net.JoinHostPort(tcpw.HostPort(addr)), nil
+	case "unix":
+		return "unix", strings.TrimPrefix(addr, "unix://"), nil
+	}
+	return "", "", fmt.Errorf("%s can not be forwarded, only TCP, TLS and HTTP(S) endpoints and unix sockets can", addr)
+}
+
+// forwardEndpoint returns the endpoint of the '-forward'.
+func (app App) forwardEndpoint(fwd Forward) (string, error) {
+	if fwd.Label == "" {
+		if len(app.endpoints) != 1 {
+			return "", fmt.Errorf("'-forward %s' without a label requires a single endpoint", fwd.Local)
+		}
+		return app.endpoints[0], nil
+	}
+	for _, addr := range app.endpoints {
+		if app.labels[addr] == fwd.Label {
+			return addr, nil
+		}
+	}
+	return "", fmt.Errorf("'-forward %s=%s' of unknown label '%s'", fwd.Label, fwd.Local, fwd.Label)
+}
+
+// CheckForwards validates '-forward' values against endpoints.
+func (app App) CheckForwards() error {
+	for _, fwd := range app.forwards {
+		addr, err := app.forwardEndpoint(fwd)
+		if err != nil {
+			return err
+		}
+		if _, _, err = ForwardTarget(addr); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// StartForwards listens on local addresses of '-forward' and proxies connections to their endpoints
+// until the returned function is called, which closes listeners and connections.
+func (app App) StartForwards() (func(), error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	var listeners []net.Listener
+	stop := func() {
+		cancel()
+		for _, l := range listeners {
+			_ = l.Close()
+		}
+		wg.Wait()
+	}
+	for _, fwd := range app.forwards {
+		addr, err := app.forwardEndpoint(fwd)
+		if err != nil {
+			stop()
+			return nil, err
+		}
+		network, address, _ := ForwardTarget(addr)
+		l, err := net.Listen("tcp", fwd.Local)
+		if err != nil {
+			stop()
+			return nil, err
+		}
+		listeners = append(listeners, l)
+		app.Info("forwarding %s to %s", l.Addr(), app.Label(addr))
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				conn, err := l.Accept()
+				if err != nil {
+					return
+				}
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					app.proxy(ctx, conn, network, address)
+				}()
+			}
+		}()
+	}
+	return stop, nil
+}
+
+// proxy copies data between the accepted connection and a new one to the address until either is closed or ctx is done.
+func (app App) proxy(ctx context.Context, conn net.Conn, network, address string) {
+	defer conn.Close()
+	var d net.Dialer
+	if app.dialTimeout > 0 {
+		d.Timeout = app.dialTimeout
+	}
+	upstream, err := app.hosts.Dial(d)(ctx, network, address)
+	if err != nil {
+		app.Error("failed to forward %s: %v", conn.RemoteAddr(), err)
+		return
+	}
+	defer upstream.Close()
+	app.Debug("forwarding %s to %s", conn.RemoteAddr(), upstream.RemoteAddr())
+	stop := context.AfterFunc(ctx, func() {
+		_ = conn.Close()
+		_ = upstream.Close()
+	})
+	defer stop()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		copyHalf(upstream, conn)
+	}()
+	copyHalf(conn, upstream)
+	<-done
+}
+
+// copyHalf copies src to dst, then closes the write side of dst, or all of it if it can not be half-closed.
+func copyHalf(dst, src net.Conn) {
+	_, _ = io.Copy(dst, src)
+	if cw, ok := dst.(interface{ CloseWrite() error }); ok {
+		_ = cw.CloseWrite()
+		return
+	}
+	_ = dst.Close()
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"io"
+	"net"
+	"strings"
+	"testing"
+)
+
+// serveEcho writes back whatever it reads until the connection is half-closed.
+func serveEcho(t *testing.T) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				_, _ = io.Copy(conn, conn)
+			}()
+		}
+	}()
+	return l.Addr().String()
+}
+
+func TestForward(t *testing.T) {
+	t.Run("Test proxy", func(t *testing.T) {
+		local := getFreeTCPAddr().String()
+		app := newApp()
+		if err := app.Parse("tcpw", []string{"-a", "echo=" + serveEcho(t), "-forward", "echo=" + local, "--", "true"}); err != nil {
+			t.Fatal(err)
+		}
+		if err := app.Check(); err != nil {
+			t.Fatal(err)
+		}
+		stop, err := app.StartForwards()
+		if err != nil {
+			t.Fatal(err)
+		}
+		conn, err := net.Dial("tcp", local)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		_, _ = conn.Write([]byte("hello"))
+		_ = conn.(*net.TCPConn).CloseWrite()
+		if b, err := io.ReadAll(conn); err != nil || string(b) != "hello" {
+			t.Fatalf("Unexpected reply: %q, %v", b, err)
+		}
+		stop()
+		if _, err := net.Dial("tcp", local); err == nil {
+			t.Fatal("Forwarding is not stopped")
+		}
+	})
+
+	t.Run("Test check", func(t *testing.T) {
+		for args, want := range map[string]string{
+			"-a localhost:1 -forward :2":                     "'-forward' requires a command and can not be combined with '-each'",
+			"-a localhost:1 -a localhost:2 -forward :3 true": "'-forward :3' without a label requires a single endpoint",
+			"-a db=localhost:1 -forward cache=:3 true":       "'-forward cache=:3' of unknown label 'cache'",
+			"-a udp://localhost:53 -forward :3 true":         "udp://localhost:53 can not be forwarded, only TCP, TLS and HTTP(S) endpoints and unix sockets can",
+			"-a db=localhost:1 -forward db=:3 -each echo {}": "'-forward' requires a command and can not be combined with '-each'",
+		} {
+			app := newApp()
+			if err := app.Parse("tcpw", strings.Fields(args)); err != nil {
+				t.Fatal(err)
+			}
+			if err := app.Check(); err == nil || err.Error() != want {
+				t.Fatalf("Unexpected error of %s: %v", args, err)
+			}
+		}
+	})
+}
@@ -0,0 +1,12 @@
+//go:build !minimal
+
+package main
+
+// hasScripts reports whether Starlark scripts of 'script://' endpoints and WebAssembly modules of '-wasm-check' are run.
+const hasScripts = true
+
+// CheckBuild reports options of features which are left out of the build, see minimal.go.
+// The full build supports all of them.
+func (app App) CheckBuild() error {
+	return nil
+}
@@ -1,5 +1,3 @@
 module github.com/jackcvr/tcpw
 
-go 1.23.0
-
-require golang.org/x/sync v0.8.0
+go 1.24.0
@@ -0,0 +1,317 @@
+//go:build !minimal
+
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// gRPC status codes reported by the API, see https://grpc.github.io/grpc/core/md_doc_statuscodes.html
+const (
+	grpcOK                = 0
+	grpcInvalidArgument   = 3
+	grpcNotFound          = 5
+	grpcResourceExhausted = 8
+	grpcUnimplemented     = 12
+	grpcInternal          = 13
+	grpcUnauthenticated   = 16
+)
+
+// grpcMaxMessage is the largest request accepted, like the default of gRPC servers.
+const grpcMaxMessage = 4 << 20
+
+// GRPCError is an error reported to a gRPC client with its status code.
+type GRPCError struct {
+	Code int
+	Err  error
+}
+
+func (e GRPCError) Error() string {
+	return e.Err.Error()
+}
+
+func (e GRPCError) Unwrap() error {
+	return e.Err
+}
+
+// GRPCServer serves the 'tcpw.v1.Waiter' service of tcpw.proto: SubmitWait, StreamStatus and Cancel.
+// Messages are encoded without generated code, the service is small enough.
+// Calls must have the '-api-token' as a bearer token in 'authorization' metadata.
+type GRPCServer struct {
+	jobs *WaitJobs
+}
+
+func NewGRPCServer(jobs *WaitJobs) *GRPCServer {
+	return &GRPCServer{jobs: jobs}
+}
+
+func (s *GRPCServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || !strings.HasPrefix(r.Header.Get("Content-Type"), "application/grpc") {
+		http.Error(w, "gRPC requests expected", http.StatusUnsupportedMediaType)
+		return
+	}
+	w.Header().Set("Content-Type", "application/grpc")
+	var err error
+	if Authorized(r, s.jobs.app.apiToken) {
+		err = s.call(w, r)
+	} else {
+		err = GRPCError{grpcUnauthenticated, errors.New("invalid API token")}
+	}
+	code := grpcOK
+	if err != nil {
+		var grpcErr GRPCError
+		code = grpcInternal
+		if errors.As(err, &grpcErr) {
+			code = grpcErr.Code
+		}
+		w.Header().Set(http.TrailerPrefix+"Grpc-Message", grpcPercentEncode(err.Error()))
+	}
+	w.Header().Set(http.TrailerPrefix+"Grpc-Status", strconv.Itoa(code))
+}
+
+// call runs the method of the request path, writing its replies.
+func (s *GRPCServer) call(w http.ResponseWriter, r *http.Request) error {
+	req, err := ReadGRPCMessage(r.Body)
+	if err != nil {
+		return GRPCError{grpcInvalidArgument, err}
+	}
+	switch r.URL.Path {
+	case "/tcpw.v1.Waiter/SubmitWait":
+		wr, err := DecodeWaitRequest(req)
+		if err != nil {
+			return GRPCError{grpcInvalidArgument, err}
+		}
+		job, err := s.jobs.Submit(wr)
+		if errors.Is(err, ErrTooManyJobs) {
+			return GRPCError{grpcResourceExhausted, err}
+		}
+		if err != nil {
+			return GRPCError{grpcInvalidArgument, err}
+		}
+		status, _ := job.Status()
+		return WriteGRPCMessage(w, protoString(nil, 1, status.ID))
+	case "/tcpw.v1.Waiter/StreamStatus":
+		id, err := DecodeID(req)
+		if err != nil {
+			return GRPCError{grpcInvalidArgument, err}
+		}
+		job, err := s.jobs.Get(id)
+		if err != nil {
+			return GRPCError{grpcNotFound, fmt.Errorf("%w '%s'", err, id)}
+		}
+		rc := http.NewResponseController(w)
+		for {
+			status, changed := job.Status()
+			if err = WriteGRPCMessage(w, EncodeJobStatus(status)); err != nil {
+				return err
+			}
+			if err = rc.Flush(); err != nil {
+				return err
+			}
+			if status.Done() {
+				return nil
+			}
+			select {
+			case <-changed:
+			case <-r.Context().Done():
+				return r.Context().Err()
+			}
+		}
+	case "/tcpw.v1.Waiter/Cancel":
+		id, err := DecodeID(req)
+		if err != nil {
+			return GRPCError{grpcInvalidArgument, err}
+		}
+		job, err := s.jobs.Cancel(id)
+		if err != nil {
+			return GRPCError{grpcNotFound, fmt.Errorf("%w '%s'", err, id)}
+		}
+		status, _ := job.Status()
+		return WriteGRPCMessage(w, EncodeJobStatus(status))
+	}
+	return GRPCError{grpcUnimplemented, fmt.Errorf("unknown method %s", r.URL.Path)}
+}
+
+// ServeGRPC serves the gRPC API on '-grpc' address over HTTP/2 without TLS until the returned function is called.
+func (app App) ServeGRPC(jobs *WaitJobs) (func(), error) {
+	l, err := net.Listen("tcp", app.grpcListen)
+	if err != nil {
+		return nil, err
+	}
+	var protocols http.Protocols
+	protocols.SetUnencryptedHTTP2(true)
+	srv := &http.Server{Handler: NewGRPCServer(jobs), Protocols: &protocols, ReadHeaderTimeout: 5 * time.Second}
+	go func() {
+		_ = srv.Serve(l)
+	}()
+	app.Info("serving gRPC API on %s", l.Addr())
+	return func() {
+		_ = srv.Close()
+	}, nil
+}
+
+// DecodeWaitRequest decodes the WaitRequest message: endpoints = 1, timeout_ms = 2, interval_ms = 3.
+func DecodeWaitRequest(b []byte) (WaitRequest, error) {
+	var req WaitRequest
+	err := protoFields(b, func(num int, v uint64, data []byte) error {
+		switch num {
+		case 1:
+			req.Endpoints = append(req.Endpoints, string(data))
+		case 2:
+			req.Timeout = time.Duration(v) * time.Millisecond
+		case 3:
+			req.Interval = time.Duration(v) * time.Millisecond
+		}
+		return nil
+	})
+	if err == nil && len(req.Endpoints) == 0 {
+		err = errors.New("no endpoints provided")
+	}
+	return req, err
+}
+
+// DecodeID decodes the id = 1 of StatusRequest and CancelRequest messages.
+func DecodeID(b []byte) (string, error) {
+	var id string
+	err := protoFields(b, func(num int, _ uint64, data []byte) error {
+		if num == 1 {
+			id = string(data)
+		}
+		return nil
+	})
+	return id, err
+}
+
+// EncodeJobStatus encodes the WaitStatus message:
+// id = 1, status = 2, endpoints = 3 of EndpointStatus (endpoint = 1, state = 2, attempts = 3, error = 4),
+// duration_ms = 4 and error = 5.
+func EncodeJobStatus(s JobStatus) []byte {
+	b := protoString(nil, 1, s.ID)
+	b = protoString(b, 2, s.Status)
+	for _, ep := range s.Endpoints {
+		var m []byte
+		m = protoString(m, 1, ep.Endpoint)
+		m = protoString(m, 2, ep.State)
+		m = protoVarint(m, 3, uint64(ep.Attempts))
+		m = protoString(m, 4, ep.Error)
+		b = protoBytes(b, 3, m)
+	}
+	b = protoVarint(b, 4, uint64(s.DurationMs))
+	return protoString(b, 5, s.Error)
+}
+
+// ReadGRPCMessage reads a length-prefixed gRPC message, which must not be compressed.
+func ReadGRPCMessage(r io.Reader) ([]byte, error) {
+	var prefix [5]byte
+	if _, err := io.ReadFull(r, prefix[:]); err != nil {
+		return nil, fmt.Errorf("reading message: %w", err)
+	}
+	if prefix[0] != 0 {
+		return nil, errors.New("compressed messages are not supported")
+	}
+	size := binary.BigEndian.Uint32(prefix[1:])
+	if size > grpcMaxMessage {
+		return nil, fmt.Errorf("message of %d bytes is too large", size)
+	}
+	msg := make([]byte, size)
+	if _, err := io.ReadFull(r, msg); err != nil {
+		return nil, fmt.Errorf("reading message: %w", err)
+	}
+	return msg, nil
+}
+
+// WriteGRPCMessage writes the message prefixed with its length.
+func WriteGRPCMessage(w io.Writer, msg []byte) error {
+	frame := make([]byte, 5, 5+len(msg))
+	binary.BigEndian.PutUint32(frame[1:], uint32(len(msg)))
+	_, err := w.Write(append(frame, msg...))
+	return err
+}
+
+// grpcPercentEncode encodes the status message for the 'grpc-message' trailer.
+func grpcPercentEncode(s string) string {
+	var b strings.Builder
+	for _, c := range []byte(s) {
+		if c < ' ' || c > '~' || c == '%' {
+			fmt.Fprintf(&b, "%%%02X", c)
+		} else {
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// protoVarint appends the field of varint wire type, omitted if zero like proto3 does.
+func protoVarint(b []byte, num int, v uint64) []byte {
+	if v == 0 {
+		return b
+	}
+	b = binary.AppendUvarint(b, uint64(num)<<3)
+	return binary.AppendUvarint(b, v)
+}
+
+// protoBytes appends the field of length-delimited wire type: a string, bytes or an embedded message.
+func protoBytes(b []byte, num int, data []byte) []byte {
+	b = binary.AppendUvarint(b, uint64(num)<<3|2)
+	b = binary.AppendUvarint(b, uint64(len(data)))
+	return append(b, data...)
+}
+
+// protoString appends the string field, omitted if empty like proto3 does.
+func protoString(b []byte, num int, s string) []byte {
+	if s == "" {
+		return b
+	}
+	return protoBytes(b, num, []byte(s))
+}
+
+// protoFields calls f with every field of the message: its number and either the value of varint
+// and fixed wire types or the data of length-delimited ones.
+func protoFields(b []byte, f func(num int, v uint64, data []byte) error) error {
+	for len(b) > 0 {
+		key, n := binary.Uvarint(b)
+		if n <= 0 {
+			return errors.New("malformed message")
+		}
+		b = b[n:]
+		var v uint64
+		var data []byte
+		switch key & 7 {
+		case 0:
+			if v, n = binary.Uvarint(b); n <= 0 {
+				return errors.New("malformed message")
+			}
+			b = b[n:]
+		case 1:
+			if len(b) < 8 {
+				return errors.New("malformed message")
+			}
+			v, b = binary.LittleEndian.Uint64(b), b[8:]
+		case 2:
+			size, n := binary.Uvarint(b)
+			if n <= 0 || uint64(len(b)-n) < size {
+				return errors.New("malformed message")
+			}
+			data, b = b[n:n+int(size)], b[n+int(size):]
+		case 5:
+			if len(b) < 4 {
+				return errors.New("malformed message")
+			}
+			v, b = uint64(binary.LittleEndian.Uint32(b)), b[4:]
+		default:
+			return fmt.Errorf("unsupported wire type %d", key&7)
+		}
+		if err := f(int(key>>3), v, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,173 @@
+//go:build !minimal
+
+package main
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// grpcCall calls the method of the gRPC API and returns reply messages and the status code.
+func grpcCall(t *testing.T, addr, token, method string, msg []byte) ([][]byte, string) {
+	var protocols http.Protocols
+	protocols.SetUnencryptedHTTP2(true)
+	client := http.Client{Transport: &http.Transport{Protocols: &protocols}}
+	var body bytes.Buffer
+	if err := WriteGRPCMessage(&body, msg); err != nil {
+		t.Fatal(err)
+	}
+	req, err := http.NewRequest(http.MethodPost, "http://"+addr+"/tcpw.v1.Waiter/"+method, &body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/grpc")
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var replies [][]byte
+	for {
+		reply, err := ReadGRPCMessage(resp.Body)
+		if err != nil {
+			break
+		}
+		replies = append(replies, reply)
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	return replies, resp.Trailer.Get("Grpc-Status")
+}
+
+// decodeJobStatus decodes the WaitStatus message.
+func decodeJobStatus(t *testing.T, b []byte) JobStatus {
+	var s JobStatus
+	err := protoFields(b, func(num int, v uint64, data []byte) error {
+		switch num {
+		case 1:
+			s.ID = string(data)
+		case 2:
+			s.Status = string(data)
+		case 3:
+			ep := &EndpointState{}
+			s.Endpoints = append(s.Endpoints, ep)
+			return protoFields(data, func(num int, v uint64, data []byte) error {
+				switch num {
+				case 1:
+					ep.Endpoint = string(data)
+				case 2:
+					ep.State = string(data)
+				case 3:
+					ep.Attempts = int(v)
+				case 4:
+					ep.Error = string(data)
+				}
+				return nil
+			})
+		case 4:
+			s.DurationMs = int64(v)
+		case 5:
+			s.Error = string(data)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestGRPCServer(t *testing.T) {
+	app := newApp()
+	app.apiToken = "secret"
+	l := serve("127.0.0.1:0")
+	defer l.Close()
+	up, down := l.Addr().String(), getFreeTCPAddr().String()
+	app.grpcListen = getFreeTCPAddr().String()
+	jobs := NewWaitJobs(app)
+	closeGRPC, err := app.ServeGRPC(jobs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closeGRPC()
+
+	submit := func(timeout time.Duration, endpoints ...string) string {
+		var msg []byte
+		for _, e := range endpoints {
+			msg = protoString(msg, 1, e)
+		}
+		msg = protoVarint(msg, 2, uint64(timeout.Milliseconds()))
+		msg = protoVarint(msg, 3, 50)
+		replies, code := grpcCall(t, app.grpcListen, app.apiToken, "SubmitWait", msg)
+		if code != "0" || len(replies) != 1 {
+			t.Fatalf("Unexpected reply: %v, %s", replies, code)
+		}
+		id, err := DecodeID(replies[0])
+		if err != nil || id == "" {
+			t.Fatalf("Unexpected id: %s, %v", id, err)
+		}
+		return id
+	}
+
+	t.Run("Test SubmitWait and StreamStatus", func(t *testing.T) {
+		id := submit(300*time.Millisecond, up, down)
+		replies, code := grpcCall(t, app.grpcListen, app.apiToken, "StreamStatus", protoString(nil, 1, id))
+		if code != "0" || len(replies) < 2 {
+			t.Fatalf("Unexpected replies: %d, %s", len(replies), code)
+		}
+		s := decodeJobStatus(t, replies[len(replies)-1])
+		if s.ID != id || s.Status != "timeout" || s.DurationMs < 300 || s.Error == "" || len(s.Endpoints) != 2 {
+			t.Fatalf("Unexpected status: %+v", s)
+		}
+		if s.Endpoints[0].State != "up" || s.Endpoints[1].State != "timeout" || s.Endpoints[1].Attempts < 2 || s.Endpoints[1].Error == "" {
+			t.Fatalf("Unexpected endpoints: %+v, %+v", s.Endpoints[0], s.Endpoints[1])
+		}
+	})
+
+	t.Run("Test Cancel", func(t *testing.T) {
+		id := submit(0, down)
+		replies, code := grpcCall(t, app.grpcListen, app.apiToken, "Cancel", protoString(nil, 1, id))
+		if code != "0" || len(replies) != 1 {
+			t.Fatalf("Unexpected replies: %d, %s", len(replies), code)
+		}
+		if s := decodeJobStatus(t, replies[0]); s.Status != "canceled" {
+			t.Fatalf("Unexpected status: %+v", s)
+		}
+	})
+
+	t.Run("Test errors", func(t *testing.T) {
+		for method, want := range map[string]string{"SubmitWait": "3", "StreamStatus": "5", "Cancel": "5", "Unknown": "12"} {
+			if _, code := grpcCall(t, app.grpcListen, app.apiToken, method, protoString(nil, 1, "unknown")); code != want {
+				t.Fatalf("Unexpected status of %s: %s", method, code)
+			}
+		}
+		if _, code := grpcCall(t, app.grpcListen, "wrong", "SubmitWait", protoString(nil, 1, up)); code != "16" {
+			t.Fatalf("Unexpected status of unauthenticated call: %s", code)
+		}
+	})
+
+	t.Run("Test limits", func(t *testing.T) {
+		for msg, want := range map[string]string{
+			string(protoString(nil, 1, "path:///etc/shadow")):                                       "3",
+			string(protoVarint(protoString(nil, 1, up), 2, uint64(2*maxJobTimeout.Milliseconds()))): "3",
+		} {
+			if _, code := grpcCall(t, app.grpcListen, app.apiToken, "SubmitWait", []byte(msg)); code != want {
+				t.Fatalf("Unexpected status of %q: %s", msg, code)
+			}
+		}
+		jobs.mu.Lock()
+		jobs.active += maxActiveJobs
+		jobs.mu.Unlock()
+		defer func() {
+			jobs.mu.Lock()
+			jobs.active -= maxActiveJobs
+			jobs.mu.Unlock()
+		}()
+		if _, code := grpcCall(t, app.grpcListen, app.apiToken, "SubmitWait", protoString(nil, 1, up)); code != "8" {
+			t.Fatalf("Unexpected status of too many waits: %s", code)
+		}
+	})
+}
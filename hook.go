@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// Shell returns the shell used to run shell commands.
+func Shell() string {
+	if sh := os.Getenv("SHELL"); sh != "" {
+		return sh
+	}
+	return "sh"
+}
+
+// Hook runs the shell script with the command's working directory and environment.
+func (app App) Hook(script string, env ...string) error {
+	cmd := exec.Command(Shell(), "-c", script)
+	cmd.Dir = app.workdir
+	cmd.Env = append(os.Environ(), app.env...)
+	cmd.Env = append(cmd.Env, env...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return RunProcess(cmd)
+}
+
+// FinishHook runs '-on-finish' hook with the results of the wait.
+func (app App) FinishHook(results []EndpointResult, err error, elapsed time.Duration) {
+	if app.onFinish == "" {
+		return
+	}
+	var failed []string
+	for _, r := range results {
+		if !r.Ready {
+			failed = append(failed, r.Name())
+		}
+	}
+	err = app.Hook(app.onFinish,
+		"TCPW_STATUS="+Status(err),
+		"TCPW_FAILED="+strings.Join(failed, ","),
+		fmt.Sprintf("TCPW_ELAPSED_MS=%d", elapsed.Milliseconds()))
+	if err != nil {
+		app.Error("on-finish hook failed: %v", err)
+	}
+}
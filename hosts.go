@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"github.com/jackcvr/tcpw/tcpw"
+	"io"
+	"net"
+	"os"
+	"strings"
+)
+
+// Hosts maps host names to addresses given with '-hosts-file', which override DNS for endpoints.
+type Hosts map[string]string
+
+func (h Hosts) String() string {
+	return ""
+}
+
+func (h Hosts) Set(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err = h.Load(f); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
+
+// Load adds entries in the format of /etc/hosts read from r: an IP address followed by host names.
+// The first entry of a name wins.
+func (h Hosts) Load(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) < 2 || net.ParseIP(fields[0]) == nil {
+			return fmt.Errorf("line %d: 'IP host...' expected", n)
+		}
+		for _, name := range fields[1:] {
+			name = strings.ToLower(name)
+			if _, ok := h[name]; !ok {
+				h[name] = fields[0]
+			}
+		}
+	}
+	return scanner.Err()
+}
+
+// Replace returns 'host:port' with the host replaced by its address, if it is overridden.
+func (h Hosts) Replace(hostport string) string {
+	host, port, err := net.SplitHostPort(hostport)
+	if err != nil {
+		return hostport
+	}
+	if ip, ok := h[strings.ToLower(host)]; ok {
+		return net.JoinHostPort(ip, port)
+	}
+	return hostport
+}
+
+// Dial returns a function dialing addresses with overridden hosts replaced, see Replace.
+func (h Hosts) Dial(d net.Dialer) tcpw.DialFunc {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return d.DialContext(ctx, network, h.Replace(addr))
+	}
+}
+
+// ReplaceEndpoint replaces an overridden host of a TCP or UDP endpoint, so its address is shown in output.
+func (h Hosts) ReplaceEndpoint(endpoint string) string {
+	scheme, rest, ok := strings.Cut(endpoint, "://")
+	switch {
+	case !ok:
+		return h.Replace(endpoint)
+	case scheme == "tcp" || scheme == "udp":
+		return scheme + "://" + h.Replace(rest)
+	}
+	return endpoint
+}
+
+// FlagArgs returns values of the flag of the command line, e.g. '-hosts-file' or '-plugin',
+// which are loaded before parsing it, so they apply to endpoints given before the flag.
+func FlagArgs(fs *flag.FlagSet, args []string, flagName string) []string {
+	var paths []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" || !strings.HasPrefix(arg, "-") {
+			break
+		}
+		name, value, hasValue := strings.Cut(strings.TrimPrefix(arg[1:], "-"), "=")
+		f := fs.Lookup(name)
+		if f == nil || hasValue {
+			if name == flagName {
+				paths = append(paths, value)
+			}
+			continue
+		}
+		if b, ok := f.Value.(interface{ IsBoolFlag() bool }); ok && b.IsBoolFlag() {
+			continue
+		}
+		if i++; i < len(args) && name == flagName {
+			paths = append(paths, args[i])
+		}
+	}
+	return paths
+}
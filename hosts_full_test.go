@@ -0,0 +1,47 @@
+//go:build !minimal
+
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// TestHostsOfURLs applies hosts files to TLS and HTTP endpoints, which the minimal build rejects.
+func TestHostsOfURLs(t *testing.T) {
+	path := writeHosts(t)
+
+	t.Run("Test host of TLS endpoint is kept to verify the certificate", func(t *testing.T) {
+		var app App
+		if err := app.Parse("tcpw", []string{"-hosts-file", path, "-a", "tls://api.example.com:443"}); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if endpoints := strings.Join(app.endpoints, " "); endpoints != "tls://api.example.com:443" {
+			t.Fatalf("Wrong endpoints: %s", endpoints)
+		}
+	})
+
+	t.Run("Test dial with overridden host", func(t *testing.T) {
+		var host string
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			host = r.Host
+		}))
+		defer srv.Close()
+		_, port, _ := net.SplitHostPort(srv.Listener.Addr().String())
+		app := newApp()
+		app.hosts = Hosts{}
+		if err := app.hosts.Set(path); err != nil {
+			t.Fatal(err)
+		}
+		if err := app.Dial(context.Background(), net.Dialer{}, "http://api.example.com:"+port+"/health"); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if host != "api.example.com:"+port {
+			t.Fatalf("Wrong host: %s", host)
+		}
+	})
+}
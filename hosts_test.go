@@ -0,0 +1,39 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeHosts writes a hosts file of the tests and returns its path.
+func writeHosts(t *testing.T) string {
+	path := filepath.Join(t.TempDir(), "hosts")
+	content := "# staging\n127.0.0.1 db.example.com API.example.com\n10.0.0.1 db.example.com\n\n::1 cache.example.com # ipv6\n"
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestHosts(t *testing.T) {
+	path := writeHosts(t)
+
+	t.Run("Test endpoints before and after the flag", func(t *testing.T) {
+		var app App
+		args := []string{"-v", "-a", "db.example.com:5432", "-t", "5s", "-hosts-file", path, "-a", "udp://cache.example.com:53"}
+		if err := app.Parse("tcpw", args); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if endpoints := strings.Join(app.endpoints, " "); endpoints != "127.0.0.1:5432 udp://[::1]:53" {
+			t.Fatalf("Wrong endpoints: %s", endpoints)
+		}
+	})
+
+	t.Run("Test invalid file", func(t *testing.T) {
+		if err := (Hosts{}).Load(strings.NewReader("127.0.0.1 db\ndb.example.com\n")); err == nil || err.Error() != "line 2: 'IP host...' expected" {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	})
+}
@@ -0,0 +1,93 @@
+//go:build !minimal
+
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"github.com/jackcvr/tcpw/tcpw"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// IsHTTP reports whether the endpoint is an HTTP(S) URL.
+func IsHTTP(addr string) bool {
+	return strings.HasPrefix(addr, "http://") || strings.HasPrefix(addr, "https://")
+}
+
+// DialHTTP sends a GET request to the URL and checks that the response status is 2xx, see tcpw.DialHTTP,
+// or one of those of 'status' option of the endpoint.
+func (app App) DialHTTP(ctx context.Context, dial tcpw.DialFunc, url string) error {
+	resp, err := tcpw.DialHTTP(ctx, dial, url)
+	if logger := app.Logger(); resp != nil && logger.Enabled(ctx, LevelWire) {
+		logger.Log(ctx, LevelWire, fmt.Sprintf("< %s %s", resp.Proto, resp.Status))
+		for key, values := range resp.Header {
+			logger.Log(ctx, LevelWire, fmt.Sprintf("< %s: %s", key, strings.Join(values, ", ")))
+		}
+	}
+	if status := app.endpointOptions[url].Status; resp != nil && status != nil {
+		if !slices.Contains(status, resp.StatusCode) {
+			expected := make([]string, len(status))
+			for i, code := range status {
+				expected[i] = strconv.Itoa(code)
+			}
+			return fmt.Errorf("%s returned %s, expected %s", url, resp.Status, strings.Join(expected, " or "))
+		}
+		return nil
+	}
+	return err
+}
+
+// DialTLS connects to the address and performs a TLS handshake, verifying its certificate,
+// e.g. for dial() of check scripts.
+func DialTLS(ctx context.Context, dial tcpw.DialFunc, addr string) (net.Conn, error) {
+	host, _, _ := net.SplitHostPort(addr)
+	conn, err := dial(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	tlsConn := tls.Client(conn, &tls.Config{ServerName: host})
+	if err = tlsConn.HandshakeContext(ctx); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	return tlsConn, nil
+}
+
+// GetURL sends a GET request to the URL, e.g. of '-ready', and checks that the response status is not an error.
+func GetURL(url string) error {
+	resp, err := http.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("%s returned %s", url, resp.Status)
+	}
+	return nil
+}
+
+// PostJSON sends the JSON body to the URL and checks that the response status is not an error.
+func PostJSON(method, url, body string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, method, url, strings.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("%s request returned %s", method, resp.Status)
+	}
+	return nil
+}
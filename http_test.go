@@ -0,0 +1,120 @@
+//go:build !minimal
+
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDialHTTP(t *testing.T) {
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	t.Run("Test success after retries", func(t *testing.T) {
+		app := newApp()
+		app.endpoints = []string{srv.URL}
+		if err := app.Run(); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if n := requests.Load(); n != 3 {
+			t.Fatalf("Wrong number of requests: %d", n)
+		}
+	})
+
+	t.Run("Test dockerize flags", func(t *testing.T) {
+		var app App
+		args := []string{"-wait", "tcp://localhost:1234", "-wait", srv.URL + "/health", "-wait-retry-interval", "2s", "-timeout", "10s"}
+		if err := app.Parse("tcpw", args); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if len(app.endpoints) != 2 || app.endpoints[1] != srv.URL+"/health" {
+			t.Fatalf("Wrong endpoints parsed: %v", app.endpoints)
+		}
+		if app.interval != 2*time.Second || app.timeout != 10*time.Second {
+			t.Fatalf("Wrong durations parsed: %v, %v", app.interval, app.timeout)
+		}
+	})
+
+	t.Run("Test status option", func(t *testing.T) {
+		unavailable := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}))
+		defer unavailable.Close()
+		var app App
+		if err := app.Parse("tcpw", []string{"-a", unavailable.URL + " status=200,503"}); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if err := app.Dial(context.Background(), net.Dialer{}, unavailable.URL); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		app.endpointOptions[unavailable.URL] = EndpointOptions{Status: []int{200, 204}}
+		want := unavailable.URL + " returned 503 Service Unavailable, expected 200 or 204"
+		if err := app.Dial(context.Background(), net.Dialer{}, unavailable.URL); err == nil || err.Error() != want {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	})
+
+	t.Run("Test slow response", func(t *testing.T) {
+		slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			select {
+			case <-time.After(time.Second):
+			case <-r.Context().Done():
+			}
+		}))
+		defer slow.Close()
+		app := newApp()
+		app.dialTimeout = 50 * time.Millisecond
+		d := net.Dialer{Timeout: app.dialTimeout}
+		start := time.Now()
+		if err := app.Dial(context.Background(), d, slow.URL); !errors.Is(err, context.DeadlineExceeded) || time.Since(start) > 500*time.Millisecond {
+			t.Fatalf("Unexpected error after %v: %v", time.Since(start), err)
+		}
+	})
+}
+
+func TestParseEndpointURLs(t *testing.T) {
+	for value, want := range map[string]string{
+		"tls://localhost:443":      "tls://localhost:443",
+		"https://localhost/health": "https://localhost/health",
+	} {
+		if got, err := ParseEndpoint(value); err != nil || got != want {
+			t.Fatalf("Unexpected endpoint of %s: %q, %v", value, got, err)
+		}
+	}
+}
+
+func TestDialTLS(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	app := newApp()
+
+	t.Run("Test tls", func(t *testing.T) {
+		srv := httptest.NewUnstartedServer(http.NotFoundHandler())
+		srv.Config.ErrorLog = log.New(io.Discard, "", 0)
+		srv.StartTLS()
+		defer srv.Close()
+		// the certificate of the test server is not trusted
+		err := app.Dial(ctx, net.Dialer{}, "tls://"+srv.Listener.Addr().String())
+		if err == nil || !strings.Contains(err.Error(), "certificate") {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if err = app.Dial(ctx, net.Dialer{}, "tls://"+startListener("").String()); err == nil {
+			t.Fatal("Unexpected success without handshake")
+		}
+	})
+}
@@ -0,0 +1,48 @@
+// Package errgroup runs goroutines of a task, collecting the first error and canceling the rest on it,
+// like golang.org/x/sync/errgroup does, so tcpw has no dependencies.
+package errgroup
+
+import (
+	"context"
+	"sync"
+)
+
+// Group is a collection of goroutines of the same task. The zero Group is valid and does not cancel on error.
+type Group struct {
+	cancel func(error)
+	wg     sync.WaitGroup
+	once   sync.Once
+	err    error
+}
+
+// WithContext returns a new Group and a context derived from ctx, which is canceled
+// once a function passed to Go returns an error or Wait returns, whichever occurs first.
+func WithContext(ctx context.Context) (*Group, context.Context) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	return &Group{cancel: cancel}, ctx
+}
+
+// Go runs the function in a new goroutine. The first error it returns cancels the group and is returned by Wait.
+func (g *Group) Go(f func() error) {
+	g.wg.Add(1)
+	go func() {
+		defer g.wg.Done()
+		if err := f(); err != nil {
+			g.once.Do(func() {
+				g.err = err
+				if g.cancel != nil {
+					g.cancel(err)
+				}
+			})
+		}
+	}()
+}
+
+// Wait waits for all functions passed to Go to return, then returns the first error of them, if any.
+func (g *Group) Wait() error {
+	g.wg.Wait()
+	if g.cancel != nil {
+		g.cancel(g.err)
+	}
+	return g.err
+}
@@ -0,0 +1,46 @@
+package errgroup
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestGroup(t *testing.T) {
+	t.Run("Test first error", func(t *testing.T) {
+		errFirst := errors.New("first")
+		g, ctx := WithContext(context.Background())
+		g.Go(func() error { return errFirst })
+		g.Go(func() error {
+			<-ctx.Done()
+			return errors.New("canceled")
+		})
+		if err := g.Wait(); err != errFirst {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if cause := context.Cause(ctx); cause != errFirst {
+			t.Fatalf("Unexpected cause: %v", cause)
+		}
+	})
+
+	t.Run("Test no error", func(t *testing.T) {
+		g, ctx := WithContext(context.Background())
+		for range 10 {
+			g.Go(func() error { return nil })
+		}
+		if err := g.Wait(); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if ctx.Err() == nil {
+			t.Fatal("Context is not canceled after Wait")
+		}
+	})
+
+	t.Run("Test zero group", func(t *testing.T) {
+		var g Group
+		g.Go(func() error { return errors.New("failed") })
+		if err := g.Wait(); err == nil || err.Error() != "failed" {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	})
+}
@@ -0,0 +1,564 @@
+package starlark
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// maxSteps is how many statements a run may execute, so a script looping forever fails even without a timeout.
+const maxSteps = 1 << 20
+
+// maxAlloc is how many bytes of strings and lists a run may create, so a script growing them, e.g. with 'x' * n,
+// fails before it takes the memory of the host program.
+const maxAlloc = 64 << 20
+
+// valueSize is the size of an element of a list or a tuple.
+const valueSize = 16
+
+// Program is a parsed script.
+type Program struct {
+	file  string
+	stmts []stmt
+}
+
+// Parse parses the script, reporting syntax errors with the file name and the line.
+func Parse(file string, src []byte) (*Program, error) {
+	stmts, err := parse(file, string(src))
+	if err != nil {
+		return nil, err
+	}
+	return &Program{file: file, stmts: stmts}, nil
+}
+
+// Thread is a run of a program.
+type Thread struct {
+	ctx      context.Context
+	file     string
+	universe map[string]Value
+	steps    int
+	alloc    int         // bytes of strings and lists created, see maxAlloc
+	stack    []*function // of calls, so recursion is reported
+}
+
+// Context returns the context of the run, which builtins doing I/O must respect.
+func (th *Thread) Context() context.Context {
+	return th.ctx
+}
+
+// allocate accounts for a string or a list of n units of the size, which the run is about to create.
+func (th *Thread) allocate(n, size int) error {
+	if n > 0 && size > 0 && n > (maxAlloc-th.alloc)/size {
+		return fmt.Errorf("too much memory allocated, more than %d bytes", maxAlloc)
+	}
+	th.alloc += n * size
+	return nil
+}
+
+// Run executes the program until it ends, fails or ctx is done.
+// Besides built-in functions, the script may use the predeclared values, e.g. builtins of the host program.
+// print() of the script calls print.
+func (p *Program) Run(ctx context.Context, predeclared map[string]Value, print func(msg string)) error {
+	th := &Thread{ctx: ctx, file: p.file, universe: universe(print)}
+	for name, v := range predeclared {
+		th.universe[name] = v
+	}
+	globals := map[string]Value{}
+	_, _, err := th.exec(p.stmts, &env{locals: globals, globals: globals})
+	return err
+}
+
+type env struct {
+	locals, globals map[string]Value
+}
+
+func (th *Thread) lookup(e *env, name string) (Value, bool) {
+	if v, ok := e.locals[name]; ok {
+		return v, true
+	}
+	if v, ok := e.globals[name]; ok {
+		return v, true
+	}
+	v, ok := th.universe[name]
+	return v, ok
+}
+
+// wrap adds the position to the error, unless it has one.
+func (th *Thread) wrap(line int, err error) error {
+	if err == nil {
+		return nil
+	}
+	var e *Error
+	if errors.As(err, &e) {
+		return err
+	}
+	return &Error{File: th.file, Line: line, Err: err}
+}
+
+type flow int
+
+const (
+	flowNext flow = iota
+	flowBreak
+	flowContinue
+	flowReturn
+)
+
+func (th *Thread) exec(stmts []stmt, e *env) (flow, Value, error) {
+	for _, s := range stmts {
+		line := stmtLine(s)
+		if th.steps++; th.steps > maxSteps {
+			return flowNext, nil, th.wrap(line, fmt.Errorf("too many steps, more than %d", maxSteps))
+		}
+		if err := th.ctx.Err(); err != nil {
+			return flowNext, nil, th.wrap(line, err)
+		}
+		switch s := s.(type) {
+		case *exprStmt:
+			if _, err := th.eval(s.x, e); err != nil {
+				return flowNext, nil, err
+			}
+		case *assignStmt:
+			v, err := th.eval(s.rhs, e)
+			if err != nil {
+				return flowNext, nil, err
+			}
+			if s.op != "=" {
+				old, err := th.eval(s.lhs, e)
+				if err != nil {
+					return flowNext, nil, err
+				}
+				if v, err = th.binary(strings.TrimSuffix(s.op, "="), old, v); err != nil {
+					return flowNext, nil, th.wrap(s.line, err)
+				}
+			}
+			if err = th.assign(s.line, s.lhs, v, e); err != nil {
+				return flowNext, nil, err
+			}
+		case *ifStmt:
+			cond, err := th.eval(s.cond, e)
+			if err != nil {
+				return flowNext, nil, err
+			}
+			body := s.els
+			if Truth(cond) {
+				body = s.then
+			}
+			if f, v, err := th.exec(body, e); f != flowNext || err != nil {
+				return f, v, err
+			}
+		case *forStmt:
+			x, err := th.eval(s.x, e)
+			if err != nil {
+				return flowNext, nil, err
+			}
+			f, ret := flowNext, Value(nil)
+			iterErr := forEach(x, func(elem Value) bool {
+				if err = th.assign(s.line, s.vars, elem, e); err != nil {
+					return false
+				}
+				var bodyFlow flow
+				bodyFlow, ret, err = th.exec(s.body, e)
+				switch {
+				case err != nil:
+					return false
+				case bodyFlow == flowBreak:
+					return false
+				case bodyFlow == flowReturn:
+					f = flowReturn
+					return false
+				}
+				return true
+			})
+			if iterErr != nil {
+				return flowNext, nil, th.wrap(s.line, iterErr)
+			}
+			if err != nil || f == flowReturn {
+				return f, ret, err
+			}
+		case *defStmt:
+			e.locals[s.name] = &function{def: s, globals: e.globals}
+		case *returnStmt:
+			var v Value
+			if s.x != nil {
+				var err error
+				if v, err = th.eval(s.x, e); err != nil {
+					return flowNext, nil, err
+				}
+			}
+			return flowReturn, v, nil
+		case *branchStmt:
+			switch s.kw {
+			case "break":
+				return flowBreak, nil, nil
+			case "continue":
+				return flowContinue, nil, nil
+			}
+		}
+	}
+	return flowNext, nil, nil
+}
+
+func stmtLine(s stmt) int {
+	switch s := s.(type) {
+	case *exprStmt:
+		return s.line
+	case *assignStmt:
+		return s.line
+	case *ifStmt:
+		return s.line
+	case *forStmt:
+		return s.line
+	case *defStmt:
+		return s.line
+	case *returnStmt:
+		return s.line
+	case *branchStmt:
+		return s.line
+	}
+	return 0
+}
+
+func (th *Thread) assign(line int, target expr, v Value, e *env) error {
+	switch t := target.(type) {
+	case *identExpr:
+		e.locals[t.name] = v
+		return nil
+	case *indexExpr:
+		x, err := th.eval(t.x, e)
+		if err != nil {
+			return err
+		}
+		i, err := th.eval(t.index, e)
+		if err != nil {
+			return err
+		}
+		l, ok := x.(*List)
+		if !ok {
+			return th.wrap(line, fmt.Errorf("%s does not support item assignment", TypeName(x)))
+		}
+		n, ok := i.(int)
+		if !ok {
+			return th.wrap(line, fmt.Errorf("list index must be int, not %s", TypeName(i)))
+		}
+		if n < 0 {
+			n += len(l.Elems)
+		}
+		if n < 0 || n >= len(l.Elems) {
+			return th.wrap(line, fmt.Errorf("index %d out of range: list of length %d", i, len(l.Elems)))
+		}
+		l.Elems[n] = v
+		return nil
+	}
+	var targets []expr
+	switch t := target.(type) {
+	case *tupleExpr:
+		targets = t.elems
+	case *listExpr:
+		targets = t.elems
+	}
+	var values []Value
+	if err := forEach(v, func(elem Value) bool {
+		values = append(values, elem)
+		return true
+	}); err != nil {
+		return th.wrap(line, fmt.Errorf("can not unpack %s", TypeName(v)))
+	}
+	if len(values) != len(targets) {
+		return th.wrap(line, fmt.Errorf("can not unpack %d values into %d variables", len(values), len(targets)))
+	}
+	for i, t := range targets {
+		if err := th.assign(line, t, values[i], e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (th *Thread) eval(x expr, e *env) (Value, error) {
+	switch x := x.(type) {
+	case *literalExpr:
+		return x.val, nil
+	case *identExpr:
+		v, ok := th.lookup(e, x.name)
+		if !ok {
+			return nil, th.wrap(x.line, fmt.Errorf("undefined: %s", x.name))
+		}
+		return v, nil
+	case *listExpr:
+		elems, err := th.evalAll(x.elems, e)
+		return &List{Elems: elems}, err
+	case *tupleExpr:
+		elems, err := th.evalAll(x.elems, e)
+		return Tuple(elems), err
+	case *unaryExpr:
+		v, err := th.eval(x.x, e)
+		if err != nil {
+			return nil, err
+		}
+		switch x.op {
+		case "not":
+			return !Truth(v), nil
+		case "-":
+			switch n := v.(type) {
+			case int:
+				return -n, nil
+			case float64:
+				return -n, nil
+			}
+		case "+":
+			if _, ok := toFloat(v); ok {
+				return v, nil
+			}
+		}
+		return nil, th.wrap(x.line, fmt.Errorf("unsupported operation: %s%s", x.op, TypeName(v)))
+	case *binaryExpr:
+		a, err := th.eval(x.x, e)
+		if err != nil {
+			return nil, err
+		}
+		switch x.op {
+		case "and":
+			if !Truth(a) {
+				return a, nil
+			}
+			return th.eval(x.y, e)
+		case "or":
+			if Truth(a) {
+				return a, nil
+			}
+			return th.eval(x.y, e)
+		}
+		b, err := th.eval(x.y, e)
+		if err != nil {
+			return nil, err
+		}
+		v, err := th.binary(x.op, a, b)
+		return v, th.wrap(x.line, err)
+	case *condExpr:
+		cond, err := th.eval(x.cond, e)
+		if err != nil {
+			return nil, err
+		}
+		if Truth(cond) {
+			return th.eval(x.t, e)
+		}
+		return th.eval(x.f, e)
+	case *callExpr:
+		fn, err := th.eval(x.fn, e)
+		if err != nil {
+			return nil, err
+		}
+		args, err := th.evalAll(x.args, e)
+		if err != nil {
+			return nil, err
+		}
+		v, err := th.call(fn, args)
+		return v, th.wrap(x.line, err)
+	case *indexExpr:
+		v, err := th.eval(x.x, e)
+		if err != nil {
+			return nil, err
+		}
+		i, err := th.eval(x.index, e)
+		if err != nil {
+			return nil, err
+		}
+		v, err = index(v, i)
+		return v, th.wrap(x.line, err)
+	case *sliceExpr:
+		v, err := th.eval(x.x, e)
+		if err != nil {
+			return nil, err
+		}
+		var lo, hi Value
+		if x.lo != nil {
+			if lo, err = th.eval(x.lo, e); err != nil {
+				return nil, err
+			}
+		}
+		if x.hi != nil {
+			if hi, err = th.eval(x.hi, e); err != nil {
+				return nil, err
+			}
+		}
+		v, err = slice(v, lo, hi)
+		return v, th.wrap(x.line, err)
+	case *dotExpr:
+		v, err := th.eval(x.x, e)
+		if err != nil {
+			return nil, err
+		}
+		v, err = attr(v, x.name)
+		return v, th.wrap(x.line, err)
+	}
+	panic(fmt.Sprintf("unexpected expression %T", x))
+}
+
+func (th *Thread) evalAll(exprs []expr, e *env) ([]Value, error) {
+	values := make([]Value, len(exprs))
+	for i, x := range exprs {
+		v, err := th.eval(x, e)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = v
+	}
+	return values, nil
+}
+
+func (th *Thread) call(fn Value, args []Value) (Value, error) {
+	switch fn := fn.(type) {
+	case *Builtin:
+		return fn.Fn(th, args)
+	case *function:
+		if len(args) != len(fn.def.params) {
+			return nil, fmt.Errorf("%s: got %d arguments, want %d", fn.def.name, len(args), len(fn.def.params))
+		}
+		if slices.Contains(th.stack, fn) {
+			return nil, fmt.Errorf("function %s called recursively", fn.def.name)
+		}
+		th.stack = append(th.stack, fn)
+		defer func() {
+			th.stack = th.stack[:len(th.stack)-1]
+		}()
+		locals := map[string]Value{}
+		for i, param := range fn.def.params {
+			locals[param] = args[i]
+		}
+		_, v, err := th.exec(fn.def.body, &env{locals: locals, globals: fn.globals})
+		return v, err
+	}
+	return nil, fmt.Errorf("%s is not callable", TypeName(fn))
+}
+
+// universe returns built-in functions: len, str, repr, int, float, bool, type, range, ord, chr, print and fail.
+func universe(print func(msg string)) map[string]Value {
+	builtins := map[string]func(th *Thread, args []Value) (Value, error){
+		"len": func(th *Thread, args []Value) (Value, error) {
+			var v Value
+			if err := UnpackArgs("len", args, 1, &v); err != nil {
+				return nil, err
+			}
+			return length(v)
+		},
+		"str": func(th *Thread, args []Value) (Value, error) {
+			var v Value
+			err := UnpackArgs("str", args, 1, &v)
+			return Str(v), err
+		},
+		"repr": func(th *Thread, args []Value) (Value, error) {
+			var v Value
+			err := UnpackArgs("repr", args, 1, &v)
+			return repr(v), err
+		},
+		"int": func(th *Thread, args []Value) (Value, error) {
+			var v Value
+			base := 10
+			if err := UnpackArgs("int", args, 1, &v, &base); err != nil {
+				return nil, err
+			}
+			switch v := v.(type) {
+			case int:
+				return v, nil
+			case float64:
+				return int(v), nil
+			case bool:
+				if v {
+					return 1, nil
+				}
+				return 0, nil
+			case string:
+				n, err := strconv.ParseInt(strings.TrimSpace(v), base, 64)
+				if err != nil {
+					return nil, fmt.Errorf("int: invalid literal %s", repr(v))
+				}
+				return int(n), nil
+			}
+			return nil, fmt.Errorf("int: got %s, want int, float, bool or string", TypeName(v))
+		},
+		"float": func(th *Thread, args []Value) (Value, error) {
+			var v Value
+			if err := UnpackArgs("float", args, 1, &v); err != nil {
+				return nil, err
+			}
+			if f, ok := toFloat(v); ok {
+				return f, nil
+			}
+			if s, ok := v.(string); ok {
+				f, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+				if err != nil {
+					return nil, fmt.Errorf("float: invalid literal %s", repr(v))
+				}
+				return f, nil
+			}
+			return nil, fmt.Errorf("float: got %s, want int, float or string", TypeName(v))
+		},
+		"bool": func(th *Thread, args []Value) (Value, error) {
+			var v Value
+			err := UnpackArgs("bool", args, 0, &v)
+			return Truth(v), err
+		},
+		"type": func(th *Thread, args []Value) (Value, error) {
+			var v Value
+			err := UnpackArgs("type", args, 1, &v)
+			return TypeName(v), err
+		},
+		"range": func(th *Thread, args []Value) (Value, error) {
+			r := rangeValue{step: 1}
+			if err := UnpackArgs("range", args, 1, &r.start, &r.stop, &r.step); err != nil {
+				return nil, err
+			}
+			if len(args) == 1 {
+				r.start, r.stop = 0, r.start
+			}
+			if r.step == 0 {
+				return nil, errors.New("range: step is zero")
+			}
+			return r, nil
+		},
+		"ord": func(th *Thread, args []Value) (Value, error) {
+			var s string
+			if err := UnpackArgs("ord", args, 1, &s); err != nil {
+				return nil, err
+			}
+			if len(s) != 1 {
+				return nil, fmt.Errorf("ord: got string of length %d, want 1", len(s))
+			}
+			return int(s[0]), nil
+		},
+		"chr": func(th *Thread, args []Value) (Value, error) {
+			var n int
+			if err := UnpackArgs("chr", args, 1, &n); err != nil {
+				return nil, err
+			}
+			if n < 0 || n > 255 {
+				return nil, fmt.Errorf("chr: %d out of byte range", n)
+			}
+			return string([]byte{byte(n)}), nil
+		},
+		"print": func(th *Thread, args []Value) (Value, error) {
+			print(joinStr(args))
+			return nil, nil
+		},
+		"fail": func(th *Thread, args []Value) (Value, error) {
+			return nil, errors.New(joinStr(args))
+		},
+	}
+	u := make(map[string]Value, len(builtins))
+	for name, fn := range builtins {
+		u[name] = &Builtin{Name: name, Fn: fn}
+	}
+	return u
+}
+
+func joinStr(values []Value) string {
+	s := make([]string, len(values))
+	for i, v := range values {
+		s[i] = Str(v)
+	}
+	return strings.Join(s, " ")
+}
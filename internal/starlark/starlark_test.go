@@ -0,0 +1,108 @@
+package starlark
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func run(src string, predeclared map[string]Value) (string, error) {
+	p, err := Parse("test.star", []byte(src))
+	if err != nil {
+		return "", err
+	}
+	var out []string
+	err = p.Run(context.Background(), predeclared, func(msg string) {
+		out = append(out, msg)
+	})
+	return strings.Join(out, "\n"), err
+}
+
+func TestRun(t *testing.T) {
+	for _, tt := range []struct {
+		src, out string
+	}{
+		{`print(1 + 2 * 3, 7 // 2, -7 // 2, 7 % 3, -7 % 3, 1 / 2, 0.5 * 4, 'yes' if 1 > 2 else 'no')`, "7 3 -4 1 2 0.5 2.0 no"},
+		{`print("a" + "b" * 2, "%s=%d (%r) %x%%" % ("n", 42, "q", 255))`, "abb n=42 (\"q\") ff%"},
+		{`print(len("\x00\x01"), ord("\n"), chr(65), b"\xff" == "\xff", repr('it\'s'))`, "2 10 A True \"it's\""},
+		{"s = ' Hello World '\nprint(s.strip().lower().split(' '), s.find('W'), '-'.join(['a', 'b']), s.startswith(' H'))",
+			`["hello", "world"] 7 a-b True`},
+		{`print(int("42") + int("ff", 16), float("1.5"), str([1, (2,), None]), bool([]), type(1.0))`, "297 1.5 [1, (2,), None] False float"},
+		{"x = [1, 2]\nx.append(3)\nx[0] = 0\na, b = 'ab'[0], x[-1]\nprint(x, x[1:], a, b, 3 in x, 'z' not in 'abc')", "[0, 2, 3] [2, 3] a 3 True True"},
+		{"total = 0\nfor i in range(10):\n    if i % 2:\n        continue\n    elif i > 6:\n        break\n    total += i\nprint(total)", "12"},
+		{"def greet(name):\n    if not name:\n        return None\n    return 'hi ' + name\n\nprint(greet('bob'), greet(''))", "hi bob None"},
+		{"# comment\n\nx = (1 +\n     2)  # continued\nif x == 3: print('three')\nfor a, b in [(1, 2), (3, 4)]:\n\tprint(a + b)", "three\n3\n7"},
+		{"print(0 or 'default', 1 and 2, None or [] or 'last')", "default 2 last"},
+	} {
+		out, err := run(tt.src, nil)
+		if err != nil || out != tt.out {
+			t.Fatalf("Unexpected output of %q: %q, %v", tt.src, out, err)
+		}
+	}
+}
+
+func TestErrors(t *testing.T) {
+	for src, want := range map[string]string{
+		"x = 1\nif x\n":                      "test.star:2: expected ':', got newline",
+		"x = 'abc":                           "test.star:1: unterminated string",
+		"def f():\n  def g():\n    pass":     "test.star:2: nested functions are not supported",
+		"break":                              "test.star:1: 'break' not in loop",
+		"return 1":                           "test.star:1: 'return' outside function",
+		"x = {}":                             "test.star:1: dicts are not supported",
+		"f(x=1)":                             "test.star:1: keyword arguments are not supported",
+		"if True:\n    x = 1\n  y = 2":       "test.star:3: unindent does not match any outer indentation level",
+		"1 = x":                              "test.star:1: invalid assignment target",
+		"print(y)":                           "test.star:1: undefined: y",
+		"x = 1\nx + 'a'":                     "test.star:2: unsupported operation: int + string",
+		"[1][2]":                             "test.star:1: index 2 out of range: list of length 1",
+		"1 // 0":                             "test.star:1: integer division by zero",
+		"def f():\n    f()\nf()":             "test.star:2: function f called recursively",
+		"len(1, 2)":                          "test.star:1: len: got 2 arguments, want 1",
+		"'a'.startswith(1)":                  "test.star:1: startswith: got int for argument 1, want string",
+		"x = 1\nx.foo":                       "test.star:2: int has no attribute 'foo'",
+		"fail('bad', 42)":                    "test.star:1: bad 42",
+		"for x in 1:\n  pass":                "test.star:1: int is not iterable",
+		"for i in range(100000000):\n  pass": "test.star:2: too many steps, more than 1048576",
+		"x = 'x' * 100000000":                "test.star:1: too much memory allocated, more than 67108864 bytes",
+		"x = 'x' * 1000000\nfor i in range(10):\n  x = x + x": "test.star:3: too much memory allocated, more than 67108864 bytes",
+		"x = [0] * 4194304\nx.append(1)":                      "test.star:2: too much memory allocated, more than 67108864 bytes",
+		"x = ('x' * 1000).replace('x', 'y' * 100000)":         "test.star:1: too much memory allocated, more than 67108864 bytes",
+		"x = ['x' * 1000000] * 100\ny = '-'.join(x)":          "test.star:2: too much memory allocated, more than 67108864 bytes",
+	} {
+		if _, err := run(src, nil); err == nil || err.Error() != want {
+			t.Fatalf("Unexpected error of %q: %v", src, err)
+		}
+	}
+}
+
+func TestPredeclared(t *testing.T) {
+	called := 0
+	predeclared := map[string]Value{
+		"double": &Builtin{Name: "double", Fn: func(th *Thread, args []Value) (Value, error) {
+			called++
+			var n int
+			err := UnpackArgs("double", args, 1, &n)
+			return n * 2, err
+		}},
+		"wait": &Builtin{Name: "wait", Fn: func(th *Thread, args []Value) (Value, error) {
+			<-th.Context().Done()
+			return nil, th.Context().Err()
+		}},
+	}
+	if out, err := run("print(double(21))", predeclared); err != nil || out != "42" || called != 1 {
+		t.Fatalf("Unexpected output: %q, %v", out, err)
+	}
+	p, err := Parse("test.star", []byte("x = 1\nwait()"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err = p.Run(ctx, predeclared, nil)
+	var e *Error
+	if !errors.Is(err, context.DeadlineExceeded) || !errors.As(err, &e) || e.Line != 2 {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,884 @@
+// Package starlark interprets the subset of Starlark used by check scripts of '-script':
+// top-level functions (def), if/elif/else, for loops over lists, tuples and range,
+// assignments, and expressions of None, bools, ints, floats, strings, lists and tuples.
+// Dicts, comprehensions, lambdas, keyword arguments and load are not supported.
+// Strings are byte strings, so "\x00" escapes build binary messages.
+// A run is limited in statements it executes and in memory of strings and lists it creates.
+package starlark
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Error is a syntax or runtime error of a script, with the line it occurred on.
+type Error struct {
+	File string
+	Line int
+	Err  error
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s:%d: %v", e.File, e.Line, e.Err)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenNewline
+	tokenIndent
+	tokenDedent
+	tokenName // names and keywords
+	tokenLiteral
+	tokenOp // operators and punctuation
+)
+
+type token struct {
+	kind tokenKind
+	text string // of names and operators
+	val  Value  // of literals
+	line int
+}
+
+func (t token) String() string {
+	switch t.kind {
+	case tokenEOF:
+		return "end of file"
+	case tokenNewline:
+		return "newline"
+	case tokenIndent:
+		return "indent"
+	case tokenDedent:
+		return "unindent"
+	case tokenLiteral:
+		return repr(t.val)
+	}
+	return "'" + t.text + "'"
+}
+
+var keywords = map[string]bool{
+	"and": true, "break": true, "continue": true, "def": true, "elif": true, "else": true, "for": true, "if": true,
+	"in": true, "not": true, "or": true, "pass": true, "return": true, "None": true, "True": true, "False": true,
+	// reserved by Starlark, but not supported
+	"lambda": true, "load": true, "while": true,
+}
+
+// operators, longest first
+var operators = []string{
+	"//=", "==", "!=", "<=", ">=", "//", "+=", "-=", "*=", "/=", "%=",
+	"+", "-", "*", "/", "%", "<", ">", "=", "(", ")", "[", "]", "{", "}", ",", ":", ".", ";",
+}
+
+// scanner splits the source into tokens, with indentation of blocks turned into indent and dedent tokens.
+type scanner struct {
+	file    string
+	src     string
+	pos     int
+	line    int
+	depth   int // of brackets, in which newlines and indentation are ignored
+	indents []int
+	tokens  []token
+}
+
+func scan(file, src string) ([]token, error) {
+	s := &scanner{file: file, src: src, line: 1, indents: []int{0}}
+	if err := s.run(); err != nil {
+		return nil, err
+	}
+	return s.tokens, nil
+}
+
+func (s *scanner) errorf(format string, args ...any) error {
+	return &Error{File: s.file, Line: s.line, Err: fmt.Errorf(format, args...)}
+}
+
+func (s *scanner) emit(kind tokenKind, text string, val Value) {
+	s.tokens = append(s.tokens, token{kind: kind, text: text, val: val, line: s.line})
+}
+
+func (s *scanner) run() error {
+	lineStart := true
+	for {
+		if lineStart && s.depth == 0 {
+			if err := s.indent(); err != nil {
+				return err
+			}
+			lineStart = false
+		}
+		if s.pos >= len(s.src) {
+			break
+		}
+		c := s.src[s.pos]
+		switch {
+		case c == '\n':
+			if s.depth == 0 {
+				s.emit(tokenNewline, "", nil)
+				lineStart = true
+			}
+			s.pos++
+			s.line++
+		case c == ' ' || c == '\t' || c == '\r':
+			s.pos++
+		case c == '\\' && strings.HasPrefix(s.src[s.pos:], "\\\n"):
+			s.pos += 2
+			s.line++
+		case c == '#':
+			for s.pos < len(s.src) && s.src[s.pos] != '\n' {
+				s.pos++
+			}
+		case c == '"' || c == '\'':
+			if err := s.string(); err != nil {
+				return err
+			}
+		case isDigit(c) || c == '.' && s.pos+1 < len(s.src) && isDigit(s.src[s.pos+1]):
+			if err := s.number(); err != nil {
+				return err
+			}
+		case isLetter(c):
+			start := s.pos
+			for s.pos < len(s.src) && (isLetter(s.src[s.pos]) || isDigit(s.src[s.pos])) {
+				s.pos++
+			}
+			name := s.src[start:s.pos]
+			// bytes literals are strings, which are byte strings already
+			if name == "b" && s.pos < len(s.src) && (s.src[s.pos] == '"' || s.src[s.pos] == '\'') {
+				if err := s.string(); err != nil {
+					return err
+				}
+				continue
+			}
+			s.emit(tokenName, name, nil)
+		default:
+			op := ""
+			for _, o := range operators {
+				if strings.HasPrefix(s.src[s.pos:], o) {
+					op = o
+					break
+				}
+			}
+			switch op {
+			case "":
+				return s.errorf("unexpected character %q", c)
+			case "(", "[", "{":
+				s.depth++
+			case ")", "]", "}":
+				if s.depth == 0 {
+					return s.errorf("unexpected '%s'", op)
+				}
+				s.depth--
+			}
+			s.emit(tokenOp, op, nil)
+			s.pos += len(op)
+		}
+	}
+	if s.depth > 0 {
+		return s.errorf("unexpected end of file in brackets")
+	}
+	if n := len(s.tokens); n > 0 && s.tokens[n-1].kind != tokenNewline {
+		s.emit(tokenNewline, "", nil)
+	}
+	for len(s.indents) > 1 {
+		s.indents = s.indents[:len(s.indents)-1]
+		s.emit(tokenDedent, "", nil)
+	}
+	s.emit(tokenEOF, "", nil)
+	return nil
+}
+
+// indent skips blank lines and emits indent or dedent tokens for the indentation of the next one.
+func (s *scanner) indent() error {
+	for {
+		col, i := 0, s.pos
+		for ; i < len(s.src) && (s.src[i] == ' ' || s.src[i] == '\t'); i++ {
+			if s.src[i] == '\t' {
+				col += 8 - col%8
+			} else {
+				col++
+			}
+		}
+		if i < len(s.src) && (s.src[i] == '#' || s.src[i] == '\r' || s.src[i] == '\n') {
+			for i < len(s.src) && s.src[i] != '\n' {
+				i++
+			}
+			if i < len(s.src) {
+				s.pos = i + 1
+				s.line++
+				continue
+			}
+		}
+		s.pos = i
+		if i >= len(s.src) {
+			return nil
+		}
+		if top := s.indents[len(s.indents)-1]; col > top {
+			s.indents = append(s.indents, col)
+			s.emit(tokenIndent, "", nil)
+			return nil
+		}
+		for col < s.indents[len(s.indents)-1] {
+			s.indents = s.indents[:len(s.indents)-1]
+			s.emit(tokenDedent, "", nil)
+		}
+		if col != s.indents[len(s.indents)-1] {
+			return s.errorf("unindent does not match any outer indentation level")
+		}
+		return nil
+	}
+}
+
+func (s *scanner) number() error {
+	start := s.pos
+	isFloat := false
+	for s.pos < len(s.src) {
+		c := s.src[s.pos]
+		if c == '.' || (c == 'e' || c == 'E') && !strings.HasPrefix(s.src[start:], "0x") {
+			isFloat = true
+			if (c == 'e' || c == 'E') && s.pos+1 < len(s.src) && (s.src[s.pos+1] == '+' || s.src[s.pos+1] == '-') {
+				s.pos++
+			}
+		} else if !isLetter(c) && !isDigit(c) {
+			break
+		}
+		s.pos++
+	}
+	text := s.src[start:s.pos]
+	if isFloat {
+		f, err := strconv.ParseFloat(text, 64)
+		if err != nil {
+			return s.errorf("invalid float literal %s", text)
+		}
+		s.emit(tokenLiteral, "", f)
+		return nil
+	}
+	if len(text) > 1 && text[0] == '0' && isDigit(text[1]) {
+		return s.errorf("invalid int literal %s, use 0o prefix for octal", text)
+	}
+	i, err := strconv.ParseInt(text, 0, 64)
+	if err != nil {
+		return s.errorf("invalid int literal %s", text)
+	}
+	s.emit(tokenLiteral, "", int(i))
+	return nil
+}
+
+func (s *scanner) string() error {
+	quote := s.src[s.pos : s.pos+1]
+	if strings.HasPrefix(s.src[s.pos:], strings.Repeat(quote, 3)) {
+		quote = strings.Repeat(quote, 3)
+	}
+	line := s.line
+	s.pos += len(quote)
+	var b strings.Builder
+	for {
+		if s.pos >= len(s.src) {
+			s.line = line
+			return s.errorf("unterminated string")
+		}
+		if strings.HasPrefix(s.src[s.pos:], quote) {
+			s.pos += len(quote)
+			break
+		}
+		c := s.src[s.pos]
+		switch {
+		case c == '\n' && len(quote) == 1:
+			s.line = line
+			return s.errorf("unterminated string")
+		case c == '\n':
+			s.line++
+		case c == '\\':
+			if s.pos+1 >= len(s.src) {
+				s.line = line
+				return s.errorf("unterminated string")
+			}
+			s.pos++
+			switch e := s.src[s.pos]; e {
+			case 'n':
+				c = '\n'
+			case 't':
+				c = '\t'
+			case 'r':
+				c = '\r'
+			case '0':
+				c = 0
+			case '\\', '\'', '"':
+				c = e
+			case '\n':
+				s.line++
+				s.pos++
+				continue
+			case 'x':
+				if s.pos+2 >= len(s.src) {
+					return s.errorf("invalid escape sequence \\x")
+				}
+				n, err := strconv.ParseUint(s.src[s.pos+1:s.pos+3], 16, 8)
+				if err != nil {
+					return s.errorf("invalid escape sequence \\x%s", s.src[s.pos+1:s.pos+3])
+				}
+				c = byte(n)
+				s.pos += 2
+			default:
+				return s.errorf("invalid escape sequence \\%c", e)
+			}
+		}
+		b.WriteByte(c)
+		s.pos++
+	}
+	// the token is on the line the string starts on
+	s.tokens = append(s.tokens, token{kind: tokenLiteral, val: b.String(), line: line})
+	return nil
+}
+
+func isLetter(c byte) bool {
+	return c == '_' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
+
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
+
+type (
+	stmt interface{}
+
+	exprStmt struct {
+		line int
+		x    expr
+	}
+	assignStmt struct {
+		line     int
+		op       string // '=' or augmented, e.g. '+='
+		lhs, rhs expr
+	}
+	ifStmt struct {
+		line      int
+		cond      expr
+		then, els []stmt
+	}
+	forStmt struct {
+		line int
+		vars expr
+		x    expr
+		body []stmt
+	}
+	defStmt struct {
+		line   int
+		name   string
+		params []string
+		body   []stmt
+	}
+	returnStmt struct {
+		line int
+		x    expr // nil for None
+	}
+	branchStmt struct {
+		line int
+		kw   string // 'pass', 'break' or 'continue'
+	}
+)
+
+type (
+	expr interface{}
+
+	identExpr struct {
+		line int
+		name string
+	}
+	literalExpr struct {
+		val Value
+	}
+	listExpr struct {
+		elems []expr
+	}
+	tupleExpr struct {
+		elems []expr
+	}
+	unaryExpr struct {
+		line int
+		op   string
+		x    expr
+	}
+	binaryExpr struct {
+		line int
+		op   string
+		x, y expr
+	}
+	condExpr struct {
+		cond, t, f expr
+	}
+	callExpr struct {
+		line int
+		fn   expr
+		args []expr
+	}
+	indexExpr struct {
+		line     int
+		x, index expr
+	}
+	sliceExpr struct {
+		line      int
+		x, lo, hi expr // lo and hi are nil if omitted
+	}
+	dotExpr struct {
+		line int
+		x    expr
+		name string
+	}
+)
+
+// parser builds statements of tokens, panicking with *Error on syntax errors.
+type parser struct {
+	file  string
+	toks  []token
+	pos   int
+	inDef bool
+	loops int // depth of loops, in which break and continue are allowed
+}
+
+func parse(file, src string) (stmts []stmt, err error) {
+	toks, err := scan(file, src)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{file: file, toks: toks}
+	defer func() {
+		if r := recover(); r != nil {
+			e, ok := r.(*Error)
+			if !ok {
+				panic(r)
+			}
+			err = e
+		}
+	}()
+	for p.peek().kind != tokenEOF {
+		stmts = append(stmts, p.stmt()...)
+	}
+	return stmts, nil
+}
+
+func (p *parser) peek() token {
+	return p.toks[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.toks[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) fail(format string, args ...any) {
+	panic(&Error{File: p.file, Line: p.peek().line, Err: fmt.Errorf(format, args...)})
+}
+
+func (p *parser) unexpected() {
+	p.fail("unexpected %s", p.peek())
+}
+
+func (p *parser) isOp(op string) bool {
+	t := p.peek()
+	return t.kind == tokenOp && t.text == op
+}
+
+func (p *parser) isKeyword(kw string) bool {
+	t := p.peek()
+	return t.kind == tokenName && t.text == kw
+}
+
+func (p *parser) expectOp(op string) {
+	if !p.isOp(op) {
+		p.fail("expected '%s', got %s", op, p.peek())
+	}
+	p.next()
+}
+
+func (p *parser) expectKeyword(kw string) {
+	if !p.isKeyword(kw) {
+		p.fail("expected '%s', got %s", kw, p.peek())
+	}
+	p.next()
+}
+
+func (p *parser) expect(kind tokenKind) {
+	if p.peek().kind != kind {
+		p.unexpected()
+	}
+	p.next()
+}
+
+func (p *parser) name() string {
+	t := p.peek()
+	if t.kind != tokenName || keywords[t.text] {
+		p.fail("expected name, got %s", t)
+	}
+	p.next()
+	return t.text
+}
+
+func (p *parser) stmt() []stmt {
+	switch {
+	case p.isKeyword("def"):
+		return []stmt{p.def()}
+	case p.isKeyword("if"):
+		return []stmt{p.ifStmt()}
+	case p.isKeyword("for"):
+		return []stmt{p.forStmt()}
+	}
+	return p.simpleStmts()
+}
+
+// simpleStmts parses statements separated by semicolons up to the end of the line.
+func (p *parser) simpleStmts() []stmt {
+	stmts := []stmt{p.smallStmt()}
+	for p.isOp(";") {
+		p.next()
+		if p.peek().kind == tokenNewline {
+			break
+		}
+		stmts = append(stmts, p.smallStmt())
+	}
+	p.expect(tokenNewline)
+	return stmts
+}
+
+func (p *parser) smallStmt() stmt {
+	t := p.peek()
+	if t.kind == tokenName {
+		switch t.text {
+		case "return":
+			if !p.inDef {
+				p.fail("'return' outside function")
+			}
+			p.next()
+			var x expr
+			if p.startsExpr() {
+				x = p.exprList()
+			}
+			return &returnStmt{line: t.line, x: x}
+		case "break", "continue":
+			if p.loops == 0 {
+				p.fail("'%s' not in loop", t.text)
+			}
+			fallthrough
+		case "pass":
+			p.next()
+			return &branchStmt{line: t.line, kw: t.text}
+		}
+	}
+	x := p.exprList()
+	if op := p.peek(); op.kind == tokenOp && strings.HasSuffix(op.text, "=") && op.text != "==" && op.text != "!=" &&
+		op.text != "<=" && op.text != ">=" {
+		p.next()
+		p.checkTarget(x, op.text != "=")
+		return &assignStmt{line: op.line, op: op.text, lhs: x, rhs: p.exprList()}
+	}
+	return &exprStmt{line: t.line, x: x}
+}
+
+func (p *parser) checkTarget(x expr, augmented bool) {
+	switch x := x.(type) {
+	case *identExpr, *indexExpr:
+		return
+	case *tupleExpr:
+		if !augmented {
+			for _, e := range x.elems {
+				p.checkTarget(e, false)
+			}
+			return
+		}
+	case *listExpr:
+		if !augmented {
+			for _, e := range x.elems {
+				p.checkTarget(e, false)
+			}
+			return
+		}
+	}
+	p.fail("invalid assignment target")
+}
+
+// suite parses the block of a compound statement, either indented or on the same line.
+func (p *parser) suite() []stmt {
+	p.expectOp(":")
+	if p.peek().kind != tokenNewline {
+		return p.simpleStmts()
+	}
+	p.next()
+	p.expect(tokenIndent)
+	var body []stmt
+	for p.peek().kind != tokenDedent && p.peek().kind != tokenEOF {
+		body = append(body, p.stmt()...)
+	}
+	p.expect(tokenDedent)
+	return body
+}
+
+func (p *parser) def() stmt {
+	line := p.next().line
+	if p.inDef {
+		p.fail("nested functions are not supported")
+	}
+	d := &defStmt{line: line, name: p.name()}
+	p.expectOp("(")
+	for !p.isOp(")") {
+		d.params = append(d.params, p.name())
+		if !p.isOp(")") {
+			p.expectOp(",")
+		}
+	}
+	p.next()
+	loops := p.loops
+	p.inDef, p.loops = true, 0
+	d.body = p.suite()
+	p.inDef, p.loops = false, loops
+	return d
+}
+
+// ifStmt parses if and elif statements.
+func (p *parser) ifStmt() stmt {
+	s := &ifStmt{line: p.next().line, cond: p.test()}
+	s.then = p.suite()
+	switch {
+	case p.isKeyword("elif"):
+		s.els = []stmt{p.ifStmt()}
+	case p.isKeyword("else"):
+		p.next()
+		s.els = p.suite()
+	}
+	return s
+}
+
+func (p *parser) forStmt() stmt {
+	s := &forStmt{line: p.next().line}
+	// targets are parsed as primary expressions, so 'in' is not taken for the operator
+	vars := []expr{p.postfix()}
+	for p.isOp(",") {
+		p.next()
+		vars = append(vars, p.postfix())
+	}
+	s.vars = vars[0]
+	if len(vars) > 1 {
+		s.vars = &tupleExpr{elems: vars}
+	}
+	p.checkTarget(s.vars, false)
+	p.expectKeyword("in")
+	s.x = p.exprList()
+	p.loops++
+	s.body = p.suite()
+	p.loops--
+	return s
+}
+
+func (p *parser) startsExpr() bool {
+	t := p.peek()
+	switch t.kind {
+	case tokenLiteral:
+		return true
+	case tokenName:
+		return !keywords[t.text] || t.text == "not" || t.text == "None" || t.text == "True" || t.text == "False"
+	case tokenOp:
+		return t.text == "(" || t.text == "[" || t.text == "-" || t.text == "+"
+	}
+	return false
+}
+
+// exprList parses expressions separated by commas, a tuple if there is a comma.
+func (p *parser) exprList() expr {
+	x := p.test()
+	if !p.isOp(",") {
+		return x
+	}
+	elems := []expr{x}
+	for p.isOp(",") {
+		p.next()
+		if !p.startsExpr() {
+			break
+		}
+		elems = append(elems, p.test())
+	}
+	return &tupleExpr{elems: elems}
+}
+
+// test parses an expression, including a conditional one.
+func (p *parser) test() expr {
+	x := p.or()
+	if p.isKeyword("if") {
+		p.next()
+		cond := p.or()
+		p.expectKeyword("else")
+		return &condExpr{cond: cond, t: x, f: p.test()}
+	}
+	return x
+}
+
+func (p *parser) or() expr {
+	x := p.and()
+	for p.isKeyword("or") {
+		line := p.next().line
+		x = &binaryExpr{line: line, op: "or", x: x, y: p.and()}
+	}
+	return x
+}
+
+func (p *parser) and() expr {
+	x := p.not()
+	for p.isKeyword("and") {
+		line := p.next().line
+		x = &binaryExpr{line: line, op: "and", x: x, y: p.not()}
+	}
+	return x
+}
+
+func (p *parser) not() expr {
+	if p.isKeyword("not") {
+		line := p.next().line
+		return &unaryExpr{line: line, op: "not", x: p.not()}
+	}
+	return p.comparison()
+}
+
+func (p *parser) comparison() expr {
+	x := p.arith()
+	for {
+		t := p.peek()
+		op := ""
+		switch {
+		case t.kind == tokenOp && (t.text == "==" || t.text == "!=" || t.text == "<" || t.text == ">" || t.text == "<=" || t.text == ">="):
+			op = t.text
+		case p.isKeyword("in"):
+			op = "in"
+		case p.isKeyword("not") && p.toks[p.pos+1].kind == tokenName && p.toks[p.pos+1].text == "in":
+			p.next()
+			op = "not in"
+		default:
+			return x
+		}
+		p.next()
+		x = &binaryExpr{line: t.line, op: op, x: x, y: p.arith()}
+	}
+}
+
+func (p *parser) arith() expr {
+	x := p.term()
+	for p.isOp("+") || p.isOp("-") {
+		t := p.next()
+		x = &binaryExpr{line: t.line, op: t.text, x: x, y: p.term()}
+	}
+	return x
+}
+
+func (p *parser) term() expr {
+	x := p.unary()
+	for p.isOp("*") || p.isOp("/") || p.isOp("//") || p.isOp("%") {
+		t := p.next()
+		x = &binaryExpr{line: t.line, op: t.text, x: x, y: p.unary()}
+	}
+	return x
+}
+
+func (p *parser) unary() expr {
+	if p.isOp("-") || p.isOp("+") {
+		t := p.next()
+		return &unaryExpr{line: t.line, op: t.text, x: p.unary()}
+	}
+	return p.postfix()
+}
+
+func (p *parser) postfix() expr {
+	x := p.primary()
+	for {
+		t := p.peek()
+		switch {
+		case p.isOp("("):
+			p.next()
+			call := &callExpr{line: t.line, fn: x}
+			for !p.isOp(")") {
+				call.args = append(call.args, p.test())
+				if p.isOp("=") {
+					p.fail("keyword arguments are not supported")
+				}
+				if !p.isOp(")") {
+					p.expectOp(",")
+				}
+			}
+			p.next()
+			x = call
+		case p.isOp("["):
+			p.next()
+			var lo expr
+			if !p.isOp(":") {
+				lo = p.test()
+			}
+			if p.isOp(":") {
+				p.next()
+				s := &sliceExpr{line: t.line, x: x, lo: lo}
+				if !p.isOp("]") {
+					s.hi = p.test()
+				}
+				x = s
+			} else {
+				x = &indexExpr{line: t.line, x: x, index: lo}
+			}
+			p.expectOp("]")
+		case p.isOp("."):
+			p.next()
+			x = &dotExpr{line: t.line, x: x, name: p.name()}
+		default:
+			return x
+		}
+	}
+}
+
+func (p *parser) primary() expr {
+	t := p.peek()
+	switch t.kind {
+	case tokenLiteral:
+		p.next()
+		return &literalExpr{val: t.val}
+	case tokenName:
+		switch t.text {
+		case "None":
+			p.next()
+			return &literalExpr{val: nil}
+		case "True", "False":
+			p.next()
+			return &literalExpr{val: t.text == "True"}
+		}
+		return &identExpr{line: t.line, name: p.name()}
+	case tokenOp:
+		switch t.text {
+		case "(":
+			p.next()
+			if p.isOp(")") {
+				p.next()
+				return &tupleExpr{}
+			}
+			x := p.exprList()
+			p.expectOp(")")
+			return x
+		case "[":
+			p.next()
+			l := &listExpr{}
+			for !p.isOp("]") {
+				l.elems = append(l.elems, p.test())
+				if p.isKeyword("for") {
+					p.fail("comprehensions are not supported")
+				}
+				if !p.isOp("]") {
+					p.expectOp(",")
+				}
+			}
+			p.next()
+			return l
+		case "{":
+			p.fail("dicts are not supported")
+		}
+	}
+	p.unexpected()
+	return nil
+}
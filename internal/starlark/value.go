@@ -0,0 +1,745 @@
+package starlark
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Value is a value of a script: nil for None, bool, int, float64, string, *List, Tuple,
+// a function defined by the script, a *Builtin, or a value of the host program implementing HasAttrs.
+type Value any
+
+// List is a mutable list.
+type List struct {
+	Elems []Value
+}
+
+// Tuple is an immutable list.
+type Tuple []Value
+
+// Builtin is a function of the host program.
+type Builtin struct {
+	Name string
+	Fn   func(th *Thread, args []Value) (Value, error)
+}
+
+// HasAttrs is a value of the host program with attributes, e.g. methods, which must be a pointer.
+type HasAttrs interface {
+	Type() string
+	Attr(name string) Value // nil if there is no such attribute
+}
+
+type function struct {
+	def     *defStmt
+	globals map[string]Value
+}
+
+// rangeValue is a lazy sequence of ints.
+type rangeValue struct {
+	start, stop, step int
+}
+
+func (r rangeValue) len() int {
+	if r.step > 0 && r.start < r.stop {
+		return (r.stop - r.start + r.step - 1) / r.step
+	}
+	if r.step < 0 && r.start > r.stop {
+		return (r.start - r.stop - r.step - 1) / -r.step
+	}
+	return 0
+}
+
+// TypeName returns the type of the value, e.g. 'int' or 'NoneType'.
+func TypeName(v Value) string {
+	switch v := v.(type) {
+	case nil:
+		return "NoneType"
+	case bool:
+		return "bool"
+	case int:
+		return "int"
+	case float64:
+		return "float"
+	case string:
+		return "string"
+	case *List:
+		return "list"
+	case Tuple:
+		return "tuple"
+	case *function:
+		return "function"
+	case *Builtin:
+		return "builtin_function_or_method"
+	case rangeValue:
+		return "range"
+	case HasAttrs:
+		return v.Type()
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+// Truth reports whether the value is true in conditions.
+func Truth(v Value) bool {
+	switch v := v.(type) {
+	case nil:
+		return false
+	case bool:
+		return v
+	case int:
+		return v != 0
+	case float64:
+		return v != 0
+	case string:
+		return v != ""
+	case *List:
+		return len(v.Elems) > 0
+	case Tuple:
+		return len(v) > 0
+	case rangeValue:
+		return v.len() > 0
+	}
+	return true
+}
+
+// Str returns the string of the value, as str() does: strings as is, other values as literals, e.g. '[1, "a"]'.
+func Str(v Value) string {
+	if s, ok := v.(string); ok {
+		return s
+	}
+	return repr(v)
+}
+
+func repr(v Value) string {
+	switch v := v.(type) {
+	case nil:
+		return "None"
+	case bool:
+		if v {
+			return "True"
+		}
+		return "False"
+	case int:
+		return strconv.Itoa(v)
+	case float64:
+		s := strconv.FormatFloat(v, 'g', -1, 64)
+		if !strings.ContainsAny(s, ".eIN") {
+			s += ".0"
+		}
+		return s
+	case string:
+		return strconv.Quote(v)
+	case *List:
+		return "[" + joinRepr(v.Elems) + "]"
+	case Tuple:
+		if len(v) == 1 {
+			return "(" + repr(v[0]) + ",)"
+		}
+		return "(" + joinRepr(v) + ")"
+	case *function:
+		return "<function " + v.def.name + ">"
+	case *Builtin:
+		return "<built-in function " + v.Name + ">"
+	case rangeValue:
+		if v.step == 1 {
+			return fmt.Sprintf("range(%d, %d)", v.start, v.stop)
+		}
+		return fmt.Sprintf("range(%d, %d, %d)", v.start, v.stop, v.step)
+	}
+	return "<" + TypeName(v) + ">"
+}
+
+func joinRepr(elems []Value) string {
+	s := make([]string, len(elems))
+	for i, e := range elems {
+		s[i] = repr(e)
+	}
+	return strings.Join(s, ", ")
+}
+
+func toFloat(v Value) (float64, bool) {
+	switch v := v.(type) {
+	case int:
+		return float64(v), true
+	case float64:
+		return v, true
+	}
+	return 0, false
+}
+
+func equal(a, b Value) bool {
+	if x, ok := toFloat(a); ok {
+		y, ok := toFloat(b)
+		return ok && x == y
+	}
+	switch a := a.(type) {
+	case *List:
+		b, ok := b.(*List)
+		return ok && equalElems(a.Elems, b.Elems)
+	case Tuple:
+		b, ok := b.(Tuple)
+		return ok && equalElems(a, b)
+	}
+	if _, ok := b.(Tuple); ok {
+		return false
+	}
+	return a == b
+}
+
+func equalElems(a, b []Value) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !equal(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func compare(op string, a, b Value) (bool, error) {
+	switch op {
+	case "==":
+		return equal(a, b), nil
+	case "!=":
+		return !equal(a, b), nil
+	}
+	var c int
+	x, okX := toFloat(a)
+	y, okY := toFloat(b)
+	sa, okA := a.(string)
+	sb, okB := b.(string)
+	switch {
+	case okX && okY:
+		c = cmpFloat(x, y)
+	case okA && okB:
+		c = strings.Compare(sa, sb)
+	default:
+		return false, fmt.Errorf("unsupported comparison: %s %s %s", TypeName(a), op, TypeName(b))
+	}
+	switch op {
+	case "<":
+		return c < 0, nil
+	case ">":
+		return c > 0, nil
+	case "<=":
+		return c <= 0, nil
+	}
+	return c >= 0, nil
+}
+
+func cmpFloat(x, y float64) int {
+	switch {
+	case x < y:
+		return -1
+	case x > y:
+		return 1
+	}
+	return 0
+}
+
+func (th *Thread) binary(op string, a, b Value) (Value, error) {
+	switch op {
+	case "==", "!=", "<", ">", "<=", ">=":
+		return compare(op, a, b)
+	case "in", "not in":
+		ok, err := contains(b, a)
+		return ok == (op == "in"), err
+	}
+	ia, intA := a.(int)
+	ib, intB := b.(int)
+	fa, numA := toFloat(a)
+	fb, numB := toFloat(b)
+	switch op {
+	case "+":
+		switch {
+		case intA && intB:
+			return ia + ib, nil
+		case numA && numB:
+			return fa + fb, nil
+		}
+		switch a := a.(type) {
+		case string:
+			if b, ok := b.(string); ok {
+				if err := th.allocate(len(a)+len(b), 1); err != nil {
+					return nil, err
+				}
+				return a + b, nil
+			}
+		case *List:
+			if b, ok := b.(*List); ok {
+				if err := th.allocate(len(a.Elems)+len(b.Elems), valueSize); err != nil {
+					return nil, err
+				}
+				return &List{Elems: append(append([]Value{}, a.Elems...), b.Elems...)}, nil
+			}
+		case Tuple:
+			if b, ok := b.(Tuple); ok {
+				if err := th.allocate(len(a)+len(b), valueSize); err != nil {
+					return nil, err
+				}
+				return append(append(Tuple{}, a...), b...), nil
+			}
+		}
+	case "-":
+		switch {
+		case intA && intB:
+			return ia - ib, nil
+		case numA && numB:
+			return fa - fb, nil
+		}
+	case "*":
+		switch {
+		case intA && intB:
+			return ia * ib, nil
+		case numA && numB:
+			return fa * fb, nil
+		case intB:
+			return th.repeat(a, ib)
+		case intA:
+			return th.repeat(b, ia)
+		}
+	case "/":
+		if numA && numB {
+			if fb == 0 {
+				return nil, errors.New("floating-point division by zero")
+			}
+			return fa / fb, nil
+		}
+	case "//":
+		switch {
+		case intA && intB:
+			if ib == 0 {
+				return nil, errors.New("integer division by zero")
+			}
+			q := ia / ib
+			if ia%ib != 0 && (ia < 0) != (ib < 0) {
+				q--
+			}
+			return q, nil
+		case numA && numB:
+			if fb == 0 {
+				return nil, errors.New("floating-point division by zero")
+			}
+			return math.Floor(fa / fb), nil
+		}
+	case "%":
+		switch {
+		case intA && intB:
+			if ib == 0 {
+				return nil, errors.New("integer modulo by zero")
+			}
+			r := ia % ib
+			if r != 0 && (r < 0) != (ib < 0) {
+				r += ib
+			}
+			return r, nil
+		case numA && numB:
+			if fb == 0 {
+				return nil, errors.New("floating-point modulo by zero")
+			}
+			r := math.Mod(fa, fb)
+			if r != 0 && (r < 0) != (fb < 0) {
+				r += fb
+			}
+			return r, nil
+		}
+		if s, ok := a.(string); ok {
+			return format(s, b)
+		}
+	}
+	return nil, fmt.Errorf("unsupported operation: %s %s %s", TypeName(a), op, TypeName(b))
+}
+
+func (th *Thread) repeat(v Value, n int) (Value, error) {
+	n = max(n, 0)
+	switch v := v.(type) {
+	case string:
+		if err := th.allocate(n, len(v)); err != nil {
+			return nil, err
+		}
+		return strings.Repeat(v, n), nil
+	case *List:
+		if err := th.allocate(n, len(v.Elems)*valueSize); err != nil {
+			return nil, err
+		}
+		elems := make([]Value, 0, len(v.Elems)*n)
+		for range n {
+			elems = append(elems, v.Elems...)
+		}
+		return &List{Elems: elems}, nil
+	}
+	return nil, fmt.Errorf("unsupported operation: %s * int", TypeName(v))
+}
+
+func contains(container, v Value) (bool, error) {
+	switch c := container.(type) {
+	case string:
+		s, ok := v.(string)
+		if !ok {
+			return false, fmt.Errorf("'in <string>' requires string as left operand, not %s", TypeName(v))
+		}
+		return strings.Contains(c, s), nil
+	case *List, Tuple, rangeValue:
+		found := false
+		err := forEach(c, func(e Value) bool {
+			found = equal(e, v)
+			return !found
+		})
+		return found, err
+	}
+	return false, fmt.Errorf("unsupported operation: %s in %s", TypeName(v), TypeName(container))
+}
+
+// format formats the string with % directives: %s, %r, %d, %x and %%.
+func format(s string, args Value) (string, error) {
+	values := []Value{args}
+	if t, ok := args.(Tuple); ok {
+		values = t
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '%' {
+			b.WriteByte(s[i])
+			continue
+		}
+		i++
+		if i == len(s) {
+			return "", errors.New("incomplete format")
+		}
+		if s[i] == '%' {
+			b.WriteByte('%')
+			continue
+		}
+		if len(values) == 0 {
+			return "", errors.New("not enough arguments for format string")
+		}
+		v := values[0]
+		values = values[1:]
+		switch s[i] {
+		case 's':
+			b.WriteString(Str(v))
+		case 'r':
+			b.WriteString(repr(v))
+		case 'd', 'x':
+			n, ok := v.(int)
+			if f, isFloat := v.(float64); isFloat {
+				n, ok = int(f), true
+			}
+			if !ok {
+				return "", fmt.Errorf("%%%c format requires int, not %s", s[i], TypeName(v))
+			}
+			base := 10
+			if s[i] == 'x' {
+				base = 16
+			}
+			b.WriteString(strconv.FormatInt(int64(n), base))
+		default:
+			return "", fmt.Errorf("unsupported format character '%c'", s[i])
+		}
+	}
+	if len(values) > 0 {
+		return "", errors.New("too many arguments for format string")
+	}
+	return b.String(), nil
+}
+
+// forEach calls f with elements of the iterable value until it returns false.
+func forEach(v Value, f func(Value) bool) error {
+	switch v := v.(type) {
+	case *List:
+		// a snapshot, so the loop may change the list
+		for _, e := range append([]Value{}, v.Elems...) {
+			if !f(e) {
+				return nil
+			}
+		}
+	case Tuple:
+		for _, e := range v {
+			if !f(e) {
+				return nil
+			}
+		}
+	case rangeValue:
+		for i, n := v.start, v.len(); n > 0; i, n = i+v.step, n-1 {
+			if !f(i) {
+				return nil
+			}
+		}
+	default:
+		return fmt.Errorf("%s is not iterable", TypeName(v))
+	}
+	return nil
+}
+
+func length(v Value) (int, error) {
+	switch v := v.(type) {
+	case string:
+		return len(v), nil
+	case *List:
+		return len(v.Elems), nil
+	case Tuple:
+		return len(v), nil
+	case rangeValue:
+		return v.len(), nil
+	}
+	return 0, fmt.Errorf("%s has no len()", TypeName(v))
+}
+
+func index(x, i Value) (Value, error) {
+	n, ok := i.(int)
+	if !ok {
+		return nil, fmt.Errorf("%s index must be int, not %s", TypeName(x), TypeName(i))
+	}
+	size, err := length(x)
+	if err != nil {
+		return nil, fmt.Errorf("%s is not indexable", TypeName(x))
+	}
+	if n < 0 {
+		n += size
+	}
+	if n < 0 || n >= size {
+		return nil, fmt.Errorf("index %d out of range: %s of length %d", i, TypeName(x), size)
+	}
+	switch x := x.(type) {
+	case string:
+		return x[n : n+1], nil
+	case *List:
+		return x.Elems[n], nil
+	case Tuple:
+		return x[n], nil
+	case rangeValue:
+		return x.start + n*x.step, nil
+	}
+	return nil, fmt.Errorf("%s is not indexable", TypeName(x))
+}
+
+func slice(x, lo, hi Value) (Value, error) {
+	size, err := length(x)
+	if err != nil {
+		return nil, fmt.Errorf("%s can not be sliced", TypeName(x))
+	}
+	bound := func(v Value, def int) (int, error) {
+		if v == nil {
+			return def, nil
+		}
+		n, ok := v.(int)
+		if !ok {
+			return 0, fmt.Errorf("slice index must be int, not %s", TypeName(v))
+		}
+		if n < 0 {
+			n += size
+		}
+		return min(max(n, 0), size), nil
+	}
+	i, err := bound(lo, 0)
+	if err != nil {
+		return nil, err
+	}
+	j, err := bound(hi, size)
+	if err != nil {
+		return nil, err
+	}
+	j = max(i, j)
+	switch x := x.(type) {
+	case string:
+		return x[i:j], nil
+	case *List:
+		return &List{Elems: append([]Value{}, x.Elems[i:j]...)}, nil
+	case Tuple:
+		return append(Tuple{}, x[i:j]...), nil
+	}
+	return nil, fmt.Errorf("%s can not be sliced", TypeName(x))
+}
+
+// attr returns the attribute of the value: a method of strings and lists, or an attribute of host values.
+func attr(v Value, name string) (Value, error) {
+	var fn func(th *Thread, args []Value) (Value, error)
+	switch v := v.(type) {
+	case string:
+		if m, ok := stringMethods[name]; ok {
+			fn = m(v)
+		}
+	case *List:
+		if name == "append" {
+			fn = func(th *Thread, args []Value) (Value, error) {
+				var e Value
+				if err := UnpackArgs("append", args, 1, &e); err != nil {
+					return nil, err
+				}
+				if err := th.allocate(1, valueSize); err != nil {
+					return nil, err
+				}
+				v.Elems = append(v.Elems, e)
+				return nil, nil
+			}
+		}
+	case HasAttrs:
+		if a := v.Attr(name); a != nil {
+			return a, nil
+		}
+	}
+	if fn == nil {
+		return nil, fmt.Errorf("%s has no attribute '%s'", TypeName(v), name)
+	}
+	return &Builtin{Name: name, Fn: fn}, nil
+}
+
+type method func(th *Thread, args []Value) (Value, error)
+
+// stringMethods return methods of the string; missing ones are nil.
+var stringMethods = map[string]func(s string) method{
+	"startswith": stringPredicate("startswith", strings.HasPrefix),
+	"endswith":   stringPredicate("endswith", strings.HasSuffix),
+	"lower":      stringFunc("lower", strings.ToLower),
+	"upper":      stringFunc("upper", strings.ToUpper),
+	"strip":      stringTrim("strip", strings.Trim),
+	"lstrip":     stringTrim("lstrip", strings.TrimLeft),
+	"rstrip":     stringTrim("rstrip", strings.TrimRight),
+	"find": func(s string) method {
+		return func(th *Thread, args []Value) (Value, error) {
+			var sub string
+			err := UnpackArgs("find", args, 1, &sub)
+			return strings.Index(s, sub), err
+		}
+	},
+	"replace": func(s string) method {
+		return func(th *Thread, args []Value) (Value, error) {
+			var old, new string
+			if err := UnpackArgs("replace", args, 2, &old, &new); err != nil {
+				return nil, err
+			}
+			// an empty old string is replaced before every rune and at the end
+			n := utf8.RuneCountInString(s) + 1
+			if old != "" {
+				n = strings.Count(s, old)
+			}
+			if err := th.allocate(n, len(new)); err != nil {
+				return nil, err
+			}
+			return strings.ReplaceAll(s, old, new), nil
+		}
+	},
+	"split": func(s string) method {
+		return func(th *Thread, args []Value) (Value, error) {
+			var sep Value
+			n := -1
+			if err := UnpackArgs("split", args, 0, &sep, &n); err != nil {
+				return nil, err
+			}
+			var parts []string
+			switch sep := sep.(type) {
+			case nil:
+				parts = strings.Fields(s)
+			case string:
+				if sep == "" {
+					return nil, errors.New("split: empty separator")
+				}
+				if n >= 0 {
+					n++
+				}
+				parts = strings.SplitN(s, sep, n)
+			default:
+				return nil, fmt.Errorf("split: got %s separator, want string", TypeName(sep))
+			}
+			l := &List{}
+			for _, p := range parts {
+				l.Elems = append(l.Elems, p)
+			}
+			return l, nil
+		}
+	},
+	"join": func(s string) method {
+		return func(th *Thread, args []Value) (Value, error) {
+			var iterable Value
+			if err := UnpackArgs("join", args, 1, &iterable); err != nil {
+				return nil, err
+			}
+			var parts []string
+			var err error
+			iterErr := forEach(iterable, func(v Value) bool {
+				p, ok := v.(string)
+				if !ok {
+					err = fmt.Errorf("join: got %s element, want string", TypeName(v))
+					return false
+				}
+				if err = th.allocate(len(p)+len(s), 1); err != nil {
+					return false
+				}
+				parts = append(parts, p)
+				return true
+			})
+			if err = errors.Join(iterErr, err); err != nil {
+				return nil, err
+			}
+			return strings.Join(parts, s), nil
+		}
+	},
+}
+
+func stringPredicate(name string, f func(s, arg string) bool) func(s string) method {
+	return func(s string) method {
+		return func(th *Thread, args []Value) (Value, error) {
+			var arg string
+			err := UnpackArgs(name, args, 1, &arg)
+			return f(s, arg), err
+		}
+	}
+}
+
+func stringFunc(name string, f func(s string) string) func(s string) method {
+	return func(s string) method {
+		return func(th *Thread, args []Value) (Value, error) {
+			err := UnpackArgs(name, args, 0)
+			return f(s), err
+		}
+	}
+}
+
+func stringTrim(name string, f func(s, cutset string) string) func(s string) method {
+	return func(s string) method {
+		return func(th *Thread, args []Value) (Value, error) {
+			cutset := " \t\r\n\v\f"
+			err := UnpackArgs(name, args, 0, &cutset)
+			return f(s, cutset), err
+		}
+	}
+}
+
+// UnpackArgs checks the arguments of the function and stores them into dst,
+// pointers to string, int, float64 (ints are accepted too), bool or Value.
+// Arguments after the first min ones are optional, their pointers are left as is if they are missing.
+func UnpackArgs(fn string, args []Value, min int, dst ...any) error {
+	if len(args) < min || len(args) > len(dst) {
+		if min == len(dst) {
+			return fmt.Errorf("%s: got %d arguments, want %d", fn, len(args), min)
+		}
+		return fmt.Errorf("%s: got %d arguments, want %d to %d", fn, len(args), min, len(dst))
+	}
+	for i, arg := range args {
+		ok := true
+		switch p := dst[i].(type) {
+		case *string:
+			*p, ok = arg.(string)
+		case *int:
+			*p, ok = arg.(int)
+		case *float64:
+			*p, ok = toFloat(arg)
+		case *bool:
+			*p, ok = arg.(bool)
+		case *Value:
+			*p = arg
+		default:
+			panic(fmt.Sprintf("unsupported destination %T", p))
+		}
+		if !ok {
+			want := map[string]string{"*string": "string", "*int": "int", "*float64": "float", "*bool": "bool"}[fmt.Sprintf("%T", dst[i])]
+			return fmt.Errorf("%s: got %s for argument %d, want %s", fn, TypeName(arg), i+1, want)
+		}
+	}
+	return nil
+}
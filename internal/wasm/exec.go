@@ -0,0 +1,893 @@
+package wasm
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"math/bits"
+)
+
+// DefaultMaxSteps is how many instructions a call of an export may run, unless Instantiate is given another limit.
+const DefaultMaxSteps = 1 << 24
+
+// Limits of running modules.
+const (
+	maxCallDepth = 1000
+	maxStack     = 1 << 20 // values
+)
+
+// Trap is the error of a module which trapped, e.g. on 'unreachable' or an out of bounds memory access.
+type Trap struct {
+	Reason string
+}
+
+func (t *Trap) Error() string {
+	return "wasm trap: " + t.Reason
+}
+
+func trap(format string, args ...any) {
+	panic(&Trap{Reason: fmt.Sprintf(format, args...)})
+}
+
+// HostFunc is a function of the host imported by modules. Values of arguments and results are
+// those of the FuncType as bits, e.g. uint64(uint32(x)) of i32 and math.Float64bits(x) of f64.
+// An error of the function aborts the call of the module with it.
+type HostFunc struct {
+	Type FuncType
+	Fn   func(inst *Instance, args []uint64) ([]uint64, error)
+}
+
+// Imports are functions of the host by names of modules and their own, e.g. imports["env"]["dial"].
+type Imports map[string]map[string]HostFunc
+
+// hostError aborts execution with the error of a host function.
+type hostError struct {
+	err error
+}
+
+// Instance is an instantiated module. It is not safe for concurrent use.
+type Instance struct {
+	module   *Module
+	host     []HostFunc // of imported functions
+	memory   []byte
+	maxMem   uint32 // in pages
+	globals  []uint64
+	table    []int64 // function indices, -1 for null references
+	dropped  []bool  // data segments dropped by 'data.drop'
+	ctx      context.Context
+	stack    []uint64
+	depth    int
+	steps    int
+	maxSteps int
+}
+
+// Instantiate resolves imports of the module, initializes its memory, table and globals, and runs its start function.
+// The start function and every call of an export trap after maxSteps instructions, or DefaultMaxSteps if it is zero.
+func Instantiate(ctx context.Context, m *Module, imports Imports, maxSteps int) (*Instance, error) {
+	if maxSteps <= 0 {
+		maxSteps = DefaultMaxSteps
+	}
+	inst := &Instance{module: m, ctx: ctx, dropped: make([]bool, len(m.datas)), maxSteps: maxSteps}
+	for _, imp := range m.imports {
+		f, ok := imports[imp.module][imp.name]
+		if !ok {
+			return nil, fmt.Errorf("unknown import %s.%s", imp.module, imp.name)
+		}
+		if !f.Type.Equal(m.types[imp.typ]) {
+			return nil, fmt.Errorf("import %s.%s is %v, not %v", imp.module, imp.name, f.Type, m.types[imp.typ])
+		}
+		inst.host = append(inst.host, f)
+	}
+	err := inst.protect(func() {
+		for _, g := range m.globals {
+			inst.globals = append(inst.globals, inst.eval(g.init))
+		}
+		if m.memory != nil {
+			inst.memory = make([]byte, int(m.memory.min)*pageSize)
+			inst.maxMem = maxPages
+			if m.memory.hasMax {
+				inst.maxMem = min(m.memory.max, maxPages)
+			}
+		}
+		if m.table != nil {
+			inst.table = make([]int64, m.table.min)
+			for i := range inst.table {
+				inst.table[i] = -1
+			}
+		}
+		for _, e := range m.elems {
+			if !e.active {
+				continue
+			}
+			offset := uint64(uint32(inst.eval(e.offset)))
+			if offset+uint64(len(e.funcs)) > uint64(len(inst.table)) {
+				trap("out of bounds table access")
+			}
+			copy(inst.table[offset:], e.funcs)
+		}
+		for i, d := range m.datas {
+			if !d.active {
+				continue
+			}
+			offset := uint64(uint32(inst.eval(d.offset)))
+			if offset+uint64(len(d.init)) > uint64(len(inst.memory)) {
+				trap("out of bounds memory access")
+			}
+			copy(inst.memory[offset:], d.init)
+			inst.dropped[i] = true
+		}
+		if m.start != nil {
+			inst.call(*m.start)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return inst, nil
+}
+
+func (inst *Instance) eval(e constExpr) uint64 {
+	switch e.op {
+	case 0x23:
+		if int(e.val) >= len(inst.globals) {
+			trap("unknown global %d", e.val)
+		}
+		return inst.globals[e.val]
+	case 0xd0:
+		return math.MaxUint64
+	}
+	return e.val
+}
+
+// protect runs the function and returns the error it panicked with: a Trap, an error of a host function,
+// or a Trap of a runtime error of invalid code, e.g. popping an empty stack, which validation is to rule out.
+func (inst *Instance) protect(fn func()) (err error) {
+	defer func() {
+		switch e := recover().(type) {
+		case nil:
+		case *Trap:
+			err = e
+		case hostError:
+			err = e.err
+		case error:
+			err = &Trap{Reason: "invalid code: " + e.Error()}
+		default:
+			panic(e)
+		}
+		inst.stack, inst.depth = inst.stack[:0], 0
+	}()
+	fn()
+	return nil
+}
+
+// Memory returns the memory of the instance, to pass data to and from host functions. It is nil if the module has none.
+// The slice is valid until the module grows its memory.
+func (inst *Instance) Memory() []byte {
+	return inst.memory
+}
+
+// Context returns the context of the running call, see Call.
+func (inst *Instance) Context() context.Context {
+	return inst.ctx
+}
+
+// Call calls the exported function with the arguments and returns its results, see HostFunc for their values.
+// It traps once ctx is done.
+func (inst *Instance) Call(ctx context.Context, name string, args ...uint64) (results []uint64, err error) {
+	exp, ok := inst.module.exports[name]
+	if !ok || exp.kind != kindFunc {
+		return nil, fmt.Errorf("no exported function %s", name)
+	}
+	ft := inst.module.types[inst.module.funcType(exp.index)]
+	if len(args) != len(ft.Params) {
+		return nil, fmt.Errorf("%s takes %d arguments, not %d", name, len(ft.Params), len(args))
+	}
+	inst.ctx, inst.steps = ctx, 0
+	err = inst.protect(func() {
+		inst.stack = append(inst.stack, args...)
+		inst.call(exp.index)
+		results = append(results, inst.stack[len(inst.stack)-len(ft.Results):]...)
+	})
+	return results, err
+}
+
+func (inst *Instance) push(v uint64) {
+	inst.stack = append(inst.stack, v)
+}
+
+func (inst *Instance) pop() uint64 {
+	v := inst.stack[len(inst.stack)-1]
+	inst.stack = inst.stack[:len(inst.stack)-1]
+	return v
+}
+
+// call calls the function with arguments on the stack, which are replaced by its results.
+func (inst *Instance) call(index uint32) {
+	m := inst.module
+	ft := m.types[m.funcType(index)]
+	if inst.depth++; inst.depth > maxCallDepth {
+		trap("call stack exhausted")
+	}
+	defer func() { inst.depth-- }()
+	base := len(inst.stack) - len(ft.Params)
+	if int(index) < len(inst.host) {
+		results, err := inst.host[index].Fn(inst, append([]uint64(nil), inst.stack[base:]...))
+		if err != nil {
+			panic(hostError{err})
+		}
+		if len(results) != len(ft.Results) {
+			panic(hostError{fmt.Errorf("host function returned %d results, not %d", len(results), len(ft.Results))})
+		}
+		inst.stack = append(inst.stack[:base], results...)
+		return
+	}
+	c := &m.codes[int(index)-len(inst.host)]
+	locals := make([]uint64, len(ft.Params)+len(c.locals))
+	copy(locals, inst.stack[base:])
+	inst.stack = inst.stack[:base]
+	inst.exec(c.body, locals, len(ft.Results))
+}
+
+// label is a target of branches: the continuation of a block, or the start of a loop.
+type label struct {
+	pc     int // to continue at
+	arity  int // values passed by branches
+	height int // of the stack at the start of the block
+	loop   bool
+}
+
+// exec runs the body of a function, leaving its results on the stack.
+func (inst *Instance) exec(body []instr, locals []uint64, results int) {
+	base := len(inst.stack)
+	// branches to the label of the function body return from it
+	labels := []label{{pc: len(body) - 1, arity: results, height: base}}
+	mem := func(offset uint32, size uint64) uint64 {
+		ea := uint64(uint32(inst.pop())) + uint64(offset)
+		if ea+size > uint64(len(inst.memory)) {
+			trap("out of bounds memory access")
+		}
+		return ea
+	}
+	// branch jumps to the label of the depth, keeping values it passes on the stack
+	branch := func(depth uint32) int {
+		l := labels[len(labels)-1-int(depth)]
+		if l.height+l.arity != len(inst.stack) {
+			copy(inst.stack[l.height:], inst.stack[len(inst.stack)-l.arity:])
+			inst.stack = inst.stack[:l.height+l.arity]
+		}
+		if l.loop {
+			labels = labels[:len(labels)-int(depth)]
+		} else {
+			labels = labels[:len(labels)-1-int(depth)]
+		}
+		return l.pc
+	}
+	for pc := 0; ; {
+		if inst.steps++; inst.steps&0x3fff == 0 {
+			if err := inst.ctx.Err(); err != nil {
+				panic(hostError{err})
+			}
+			if inst.steps > inst.maxSteps {
+				trap("too many steps, more than %d", inst.maxSteps)
+			}
+			if len(inst.stack) > maxStack {
+				trap("value stack exhausted")
+			}
+		}
+		in := &body[pc]
+		pc++
+		switch in.op {
+		case 0x00:
+			trap("unreachable")
+		case 0x01:
+		case 0x02:
+			params, arity := int(in.v>>32), int(uint32(in.v))
+			labels = append(labels, label{pc: int(in.a) + 1, arity: arity, height: len(inst.stack) - params})
+		case 0x03:
+			params := int(in.v >> 32)
+			labels = append(labels, label{pc: pc, arity: params, height: len(inst.stack) - params, loop: true})
+		case 0x04:
+			params, arity := int(in.v>>32), int(uint32(in.v))
+			cond := uint32(inst.pop())
+			switch {
+			case cond != 0:
+				labels = append(labels, label{pc: int(in.a) + 1, arity: arity, height: len(inst.stack) - params})
+			case in.b != 0:
+				labels = append(labels, label{pc: int(in.a) + 1, arity: arity, height: len(inst.stack) - params})
+				pc = int(in.b) + 1
+			default:
+				pc = int(in.a) + 1
+			}
+		case 0x05:
+			// the end of the 'then' branch
+			labels = labels[:len(labels)-1]
+			pc = int(in.a) + 1
+		case 0x0b:
+			if pc == len(body) {
+				inst.ret(base, results)
+				return
+			}
+			labels = labels[:len(labels)-1]
+		case 0x0c:
+			pc = branch(in.a)
+		case 0x0d:
+			if uint32(inst.pop()) != 0 {
+				pc = branch(in.a)
+			}
+		case 0x0e:
+			i := uint32(inst.pop())
+			depth := in.a
+			if int(i) < len(in.table) {
+				depth = in.table[i]
+			}
+			pc = branch(depth)
+		case 0x0f:
+			inst.ret(base, results)
+			return
+		case 0x10:
+			inst.call(in.a)
+		case 0x11:
+			i := uint32(inst.pop())
+			if int(i) >= len(inst.table) {
+				trap("undefined element %d", i)
+			}
+			f := inst.table[i]
+			if f < 0 {
+				trap("uninitialized element %d", i)
+			}
+			if !inst.module.types[inst.module.funcType(uint32(f))].Equal(inst.module.types[in.a]) {
+				trap("indirect call type mismatch")
+			}
+			inst.call(uint32(f))
+		case 0x1a:
+			inst.pop()
+		case 0x1b:
+			cond := uint32(inst.pop())
+			y, x := inst.pop(), inst.pop()
+			if cond != 0 {
+				inst.push(x)
+			} else {
+				inst.push(y)
+			}
+		case 0x20:
+			inst.push(locals[in.a])
+		case 0x21:
+			locals[in.a] = inst.pop()
+		case 0x22:
+			locals[in.a] = inst.stack[len(inst.stack)-1]
+		case 0x23:
+			inst.push(inst.globals[in.a])
+		case 0x24:
+			inst.globals[in.a] = inst.pop()
+
+		// memory
+		case 0x28:
+			ea := mem(in.a, 4)
+			inst.push(uint64(binary.LittleEndian.Uint32(inst.memory[ea:])))
+		case 0x29:
+			ea := mem(in.a, 8)
+			inst.push(binary.LittleEndian.Uint64(inst.memory[ea:]))
+		case 0x2a:
+			ea := mem(in.a, 4)
+			inst.push(uint64(binary.LittleEndian.Uint32(inst.memory[ea:])))
+		case 0x2b:
+			ea := mem(in.a, 8)
+			inst.push(binary.LittleEndian.Uint64(inst.memory[ea:]))
+		case 0x2c:
+			inst.push(uint64(uint32(int32(int8(inst.memory[mem(in.a, 1)])))))
+		case 0x2d:
+			inst.push(uint64(inst.memory[mem(in.a, 1)]))
+		case 0x2e:
+			ea := mem(in.a, 2)
+			inst.push(uint64(uint32(int32(int16(binary.LittleEndian.Uint16(inst.memory[ea:]))))))
+		case 0x2f:
+			ea := mem(in.a, 2)
+			inst.push(uint64(binary.LittleEndian.Uint16(inst.memory[ea:])))
+		case 0x30:
+			inst.push(uint64(int64(int8(inst.memory[mem(in.a, 1)]))))
+		case 0x31:
+			inst.push(uint64(inst.memory[mem(in.a, 1)]))
+		case 0x32:
+			ea := mem(in.a, 2)
+			inst.push(uint64(int64(int16(binary.LittleEndian.Uint16(inst.memory[ea:])))))
+		case 0x33:
+			ea := mem(in.a, 2)
+			inst.push(uint64(binary.LittleEndian.Uint16(inst.memory[ea:])))
+		case 0x34:
+			ea := mem(in.a, 4)
+			inst.push(uint64(int64(int32(binary.LittleEndian.Uint32(inst.memory[ea:])))))
+		case 0x35:
+			ea := mem(in.a, 4)
+			inst.push(uint64(binary.LittleEndian.Uint32(inst.memory[ea:])))
+		case 0x36, 0x38, 0x3e:
+			v := inst.pop()
+			binary.LittleEndian.PutUint32(inst.memory[mem(in.a, 4):], uint32(v))
+		case 0x37, 0x39:
+			v := inst.pop()
+			binary.LittleEndian.PutUint64(inst.memory[mem(in.a, 8):], v)
+		case 0x3a, 0x3c:
+			v := inst.pop()
+			inst.memory[mem(in.a, 1)] = byte(v)
+		case 0x3b, 0x3d:
+			v := inst.pop()
+			binary.LittleEndian.PutUint16(inst.memory[mem(in.a, 2):], uint16(v))
+		case 0x3f:
+			inst.push(uint64(len(inst.memory) / pageSize))
+		case 0x40:
+			n := uint32(inst.pop())
+			pages := uint32(len(inst.memory) / pageSize)
+			if inst.module.memory == nil || uint64(pages)+uint64(n) > uint64(inst.maxMem) {
+				inst.push(uint64(math.MaxUint32))
+				break
+			}
+			inst.memory = append(inst.memory, make([]byte, int(n)*pageSize)...)
+			inst.push(uint64(pages))
+
+		// constants
+		case 0x41, 0x42, 0x43, 0x44:
+			inst.push(in.v)
+
+		case 0xfc08:
+			n, src, dst := uint64(uint32(inst.pop())), uint64(uint32(inst.pop())), uint64(uint32(inst.pop()))
+			var data []byte
+			if !inst.dropped[in.a] {
+				data = inst.module.datas[in.a].init
+			}
+			if src+n > uint64(len(data)) || dst+n > uint64(len(inst.memory)) {
+				trap("out of bounds memory access")
+			}
+			copy(inst.memory[dst:], data[src:src+n])
+		case 0xfc09:
+			inst.dropped[in.a] = true
+		case 0xfc0a:
+			n, src, dst := uint64(uint32(inst.pop())), uint64(uint32(inst.pop())), uint64(uint32(inst.pop()))
+			if src+n > uint64(len(inst.memory)) || dst+n > uint64(len(inst.memory)) {
+				trap("out of bounds memory access")
+			}
+			copy(inst.memory[dst:dst+n], inst.memory[src:src+n])
+		case 0xfc0b:
+			n, v, dst := uint64(uint32(inst.pop())), byte(inst.pop()), uint64(uint32(inst.pop()))
+			if dst+n > uint64(len(inst.memory)) {
+				trap("out of bounds memory access")
+			}
+			for i := range inst.memory[dst : dst+n] {
+				inst.memory[dst+uint64(i)] = v
+			}
+
+		default:
+			inst.numeric(in.op)
+		}
+	}
+}
+
+// ret leaves the results of the function on the stack at its base.
+func (inst *Instance) ret(base, results int) {
+	copy(inst.stack[base:], inst.stack[len(inst.stack)-results:])
+	inst.stack = inst.stack[:base+results]
+}
+
+// numeric runs a numeric instruction, which pops its operands and pushes its result.
+func (inst *Instance) numeric(op uint16) {
+	if op >= 0x46 && op <= 0x4f || op >= 0x51 && op <= 0x66 || op >= 0x6a && op <= 0x78 || op >= 0x7c && op <= 0x8a || op >= 0x92 && op <= 0x98 || op >= 0xa0 && op <= 0xa6 {
+		y, x := inst.pop(), inst.pop()
+		inst.push(binaryOp(op, x, y))
+		return
+	}
+	inst.push(unaryOp(op, inst.pop()))
+}
+
+func b2u(b bool) uint64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func binaryOp(op uint16, x, y uint64) uint64 {
+	xi, yi := uint32(x), uint32(y)
+	switch op {
+	// i32 comparisons
+	case 0x46:
+		return b2u(xi == yi)
+	case 0x47:
+		return b2u(xi != yi)
+	case 0x48:
+		return b2u(int32(xi) < int32(yi))
+	case 0x49:
+		return b2u(xi < yi)
+	case 0x4a:
+		return b2u(int32(xi) > int32(yi))
+	case 0x4b:
+		return b2u(xi > yi)
+	case 0x4c:
+		return b2u(int32(xi) <= int32(yi))
+	case 0x4d:
+		return b2u(xi <= yi)
+	case 0x4e:
+		return b2u(int32(xi) >= int32(yi))
+	case 0x4f:
+		return b2u(xi >= yi)
+	// i64 comparisons
+	case 0x51:
+		return b2u(x == y)
+	case 0x52:
+		return b2u(x != y)
+	case 0x53:
+		return b2u(int64(x) < int64(y))
+	case 0x54:
+		return b2u(x < y)
+	case 0x55:
+		return b2u(int64(x) > int64(y))
+	case 0x56:
+		return b2u(x > y)
+	case 0x57:
+		return b2u(int64(x) <= int64(y))
+	case 0x58:
+		return b2u(x <= y)
+	case 0x59:
+		return b2u(int64(x) >= int64(y))
+	case 0x5a:
+		return b2u(x >= y)
+	// float comparisons
+	case 0x5b:
+		return b2u(f32(x) == f32(y))
+	case 0x5c:
+		return b2u(f32(x) != f32(y))
+	case 0x5d:
+		return b2u(f32(x) < f32(y))
+	case 0x5e:
+		return b2u(f32(x) > f32(y))
+	case 0x5f:
+		return b2u(f32(x) <= f32(y))
+	case 0x60:
+		return b2u(f32(x) >= f32(y))
+	case 0x61:
+		return b2u(f64(x) == f64(y))
+	case 0x62:
+		return b2u(f64(x) != f64(y))
+	case 0x63:
+		return b2u(f64(x) < f64(y))
+	case 0x64:
+		return b2u(f64(x) > f64(y))
+	case 0x65:
+		return b2u(f64(x) <= f64(y))
+	case 0x66:
+		return b2u(f64(x) >= f64(y))
+	// i32 arithmetic
+	case 0x6a:
+		return uint64(xi + yi)
+	case 0x6b:
+		return uint64(xi - yi)
+	case 0x6c:
+		return uint64(xi * yi)
+	case 0x6d:
+		if yi == 0 {
+			trap("integer divide by zero")
+		}
+		if int32(xi) == math.MinInt32 && int32(yi) == -1 {
+			trap("integer overflow")
+		}
+		return uint64(uint32(int32(xi) / int32(yi)))
+	case 0x6e:
+		if yi == 0 {
+			trap("integer divide by zero")
+		}
+		return uint64(xi / yi)
+	case 0x6f:
+		if yi == 0 {
+			trap("integer divide by zero")
+		}
+		if int32(yi) == -1 {
+			return 0
+		}
+		return uint64(uint32(int32(xi) % int32(yi)))
+	case 0x70:
+		if yi == 0 {
+			trap("integer divide by zero")
+		}
+		return uint64(xi % yi)
+	case 0x71:
+		return uint64(xi & yi)
+	case 0x72:
+		return uint64(xi | yi)
+	case 0x73:
+		return uint64(xi ^ yi)
+	case 0x74:
+		return uint64(xi << (yi & 31))
+	case 0x75:
+		return uint64(uint32(int32(xi) >> (yi & 31)))
+	case 0x76:
+		return uint64(xi >> (yi & 31))
+	case 0x77:
+		return uint64(bits.RotateLeft32(xi, int(yi&31)))
+	case 0x78:
+		return uint64(bits.RotateLeft32(xi, -int(yi&31)))
+	// i64 arithmetic
+	case 0x7c:
+		return x + y
+	case 0x7d:
+		return x - y
+	case 0x7e:
+		return x * y
+	case 0x7f:
+		if y == 0 {
+			trap("integer divide by zero")
+		}
+		if int64(x) == math.MinInt64 && int64(y) == -1 {
+			trap("integer overflow")
+		}
+		return uint64(int64(x) / int64(y))
+	case 0x80:
+		if y == 0 {
+			trap("integer divide by zero")
+		}
+		return x / y
+	case 0x81:
+		if y == 0 {
+			trap("integer divide by zero")
+		}
+		if int64(y) == -1 {
+			return 0
+		}
+		return uint64(int64(x) % int64(y))
+	case 0x82:
+		if y == 0 {
+			trap("integer divide by zero")
+		}
+		return x % y
+	case 0x83:
+		return x & y
+	case 0x84:
+		return x | y
+	case 0x85:
+		return x ^ y
+	case 0x86:
+		return x << (y & 63)
+	case 0x87:
+		return uint64(int64(x) >> (y & 63))
+	case 0x88:
+		return x >> (y & 63)
+	case 0x89:
+		return bits.RotateLeft64(x, int(y&63))
+	case 0x8a:
+		return bits.RotateLeft64(x, -int(y&63))
+	// f32 arithmetic
+	case 0x92:
+		return u32f(f32(x) + f32(y))
+	case 0x93:
+		return u32f(f32(x) - f32(y))
+	case 0x94:
+		return u32f(f32(x) * f32(y))
+	case 0x95:
+		return u32f(f32(x) / f32(y))
+	case 0x96:
+		return u32f(float32(fmin(float64(f32(x)), float64(f32(y)))))
+	case 0x97:
+		return u32f(float32(fmax(float64(f32(x)), float64(f32(y)))))
+	case 0x98:
+		return x&0x7fffffff | y&0x80000000
+	// f64 arithmetic
+	case 0xa0:
+		return u64f(f64(x) + f64(y))
+	case 0xa1:
+		return u64f(f64(x) - f64(y))
+	case 0xa2:
+		return u64f(f64(x) * f64(y))
+	case 0xa3:
+		return u64f(f64(x) / f64(y))
+	case 0xa4:
+		return u64f(fmin(f64(x), f64(y)))
+	case 0xa5:
+		return u64f(fmax(f64(x), f64(y)))
+	case 0xa6:
+		return x&(1<<63-1) | y&(1<<63)
+	}
+	trap("unsupported instruction 0x%02x", op)
+	return 0
+}
+
+// fmin and fmax are min and max of WebAssembly, which propagate NaN and order -0 before +0.
+func fmin(x, y float64) float64 {
+	if math.IsNaN(x) || math.IsNaN(y) {
+		return math.NaN()
+	}
+	return math.Min(x, y)
+}
+
+func fmax(x, y float64) float64 {
+	if math.IsNaN(x) || math.IsNaN(y) {
+		return math.NaN()
+	}
+	return math.Max(x, y)
+}
+
+func unaryOp(op uint16, x uint64) uint64 {
+	xi := uint32(x)
+	switch op {
+	case 0x45:
+		return b2u(xi == 0)
+	case 0x50:
+		return b2u(x == 0)
+	case 0x67:
+		return uint64(bits.LeadingZeros32(xi))
+	case 0x68:
+		return uint64(bits.TrailingZeros32(xi))
+	case 0x69:
+		return uint64(bits.OnesCount32(xi))
+	case 0x79:
+		return uint64(bits.LeadingZeros64(x))
+	case 0x7a:
+		return uint64(bits.TrailingZeros64(x))
+	case 0x7b:
+		return uint64(bits.OnesCount64(x))
+	// f32
+	case 0x8b:
+		return x & 0x7fffffff
+	case 0x8c:
+		return uint64(xi ^ 0x80000000)
+	case 0x8d:
+		return u32f(float32(math.Ceil(float64(f32(x)))))
+	case 0x8e:
+		return u32f(float32(math.Floor(float64(f32(x)))))
+	case 0x8f:
+		return u32f(float32(math.Trunc(float64(f32(x)))))
+	case 0x90:
+		return u32f(float32(math.RoundToEven(float64(f32(x)))))
+	case 0x91:
+		return u32f(float32(math.Sqrt(float64(f32(x)))))
+	// f64
+	case 0x99:
+		return x & (1<<63 - 1)
+	case 0x9a:
+		return x ^ 1<<63
+	case 0x9b:
+		return u64f(math.Ceil(f64(x)))
+	case 0x9c:
+		return u64f(math.Floor(f64(x)))
+	case 0x9d:
+		return u64f(math.Trunc(f64(x)))
+	case 0x9e:
+		return u64f(math.RoundToEven(f64(x)))
+	case 0x9f:
+		return u64f(math.Sqrt(f64(x)))
+	// conversions
+	case 0xa7:
+		return uint64(xi)
+	case 0xa8:
+		return uint64(uint32(int32(truncate(float64(f32(x)), math.MinInt32, math.MaxInt32, false))))
+	case 0xa9:
+		return uint64(uint32(truncate(float64(f32(x)), 0, math.MaxUint32, false)))
+	case 0xaa:
+		return uint64(uint32(int32(truncate(f64(x), math.MinInt32, math.MaxInt32, false))))
+	case 0xab:
+		return uint64(uint32(truncate(f64(x), 0, math.MaxUint32, false)))
+	case 0xac:
+		return uint64(int64(int32(xi)))
+	case 0xad:
+		return uint64(xi)
+	case 0xae:
+		return truncateI64(float64(f32(x)), false)
+	case 0xaf:
+		return truncateU64(float64(f32(x)), false)
+	case 0xb0:
+		return truncateI64(f64(x), false)
+	case 0xb1:
+		return truncateU64(f64(x), false)
+	case 0xb2:
+		return u32f(float32(int32(xi)))
+	case 0xb3:
+		return u32f(float32(xi))
+	case 0xb4:
+		return u32f(float32(int64(x)))
+	case 0xb5:
+		return u32f(float32(x))
+	case 0xb6:
+		return u32f(float32(f64(x)))
+	case 0xb7:
+		return u64f(float64(int32(xi)))
+	case 0xb8:
+		return u64f(float64(xi))
+	case 0xb9:
+		return u64f(float64(int64(x)))
+	case 0xba:
+		return u64f(float64(x))
+	case 0xbb:
+		return u64f(float64(f32(x)))
+	case 0xbc, 0xbe:
+		return uint64(xi)
+	case 0xbd, 0xbf:
+		return x
+	// sign extension
+	case 0xc0:
+		return uint64(uint32(int32(int8(xi))))
+	case 0xc1:
+		return uint64(uint32(int32(int16(xi))))
+	case 0xc2:
+		return uint64(int64(int8(x)))
+	case 0xc3:
+		return uint64(int64(int16(x)))
+	case 0xc4:
+		return uint64(int64(int32(x)))
+	// non-trapping conversions
+	case 0xfc00:
+		return uint64(uint32(int32(truncate(float64(f32(x)), math.MinInt32, math.MaxInt32, true))))
+	case 0xfc01:
+		return uint64(uint32(truncate(float64(f32(x)), 0, math.MaxUint32, true)))
+	case 0xfc02:
+		return uint64(uint32(int32(truncate(f64(x), math.MinInt32, math.MaxInt32, true))))
+	case 0xfc03:
+		return uint64(uint32(truncate(f64(x), 0, math.MaxUint32, true)))
+	case 0xfc04:
+		return truncateI64(float64(f32(x)), true)
+	case 0xfc05:
+		return truncateU64(float64(f32(x)), true)
+	case 0xfc06:
+		return truncateI64(f64(x), true)
+	case 0xfc07:
+		return truncateU64(f64(x), true)
+	}
+	trap("unsupported instruction 0x%02x", op)
+	return 0
+}
+
+// truncate converts the float to a 32 bits integer in [lo, hi], trapping if it is out of range or NaN,
+// or saturating to the bounds (and 0 for NaN) if sat is set.
+func truncate(f, lo, hi float64, sat bool) float64 {
+	if math.IsNaN(f) {
+		if sat {
+			return 0
+		}
+		trap("invalid conversion to integer")
+	}
+	t := math.Trunc(f)
+	if t < lo || t > hi {
+		if !sat {
+			trap("integer overflow")
+		}
+		return math.Max(lo, math.Min(t, hi))
+	}
+	return t
+}
+
+// truncateI64 converts the float to int64, see truncate. 2^63 is the first float out of its range.
+func truncateI64(f float64, sat bool) uint64 {
+	if math.IsNaN(f) {
+		if sat {
+			return 0
+		}
+		trap("invalid conversion to integer")
+	}
+	t := math.Trunc(f)
+	if t < math.MinInt64 || t >= 1<<63 {
+		if !sat {
+			trap("integer overflow")
+		}
+		if t < 0 {
+			return 1 << 63
+		}
+		return math.MaxInt64
+	}
+	return uint64(int64(t))
+}
+
+// truncateU64 converts the float to uint64, see truncate.
+func truncateU64(f float64, sat bool) uint64 {
+	if math.IsNaN(f) {
+		if sat {
+			return 0
+		}
+		trap("invalid conversion to integer")
+	}
+	t := math.Trunc(f)
+	if t <= -1 || t >= 1<<64 {
+		if !sat {
+			trap("integer overflow")
+		}
+		if t <= -1 {
+			return 0
+		}
+		return math.MaxUint64
+	}
+	return uint64(t)
+}
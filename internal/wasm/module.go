@@ -0,0 +1,737 @@
+// Package wasm runs WebAssembly modules with an interpreter of the binary format of WebAssembly 1.0,
+// plus sign extension, non-trapping float-to-int conversions, bulk memory and multi-value, without dependencies.
+// It is enough for small modules of checks compiled from C, Rust, Zig or AssemblyScript, not for fast ones.
+//
+// Modules are sandboxed: they have no access to the host besides functions it imports to them,
+// and run with limits of memory, call depth and steps. Code is validated while it is decoded,
+// so a module with invalid code, e.g. of mismatched types of operands, fails to load instead of running.
+package wasm
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"unicode/utf8"
+)
+
+// ValType is the type of a value.
+type ValType byte
+
+const (
+	I32       ValType = 0x7f
+	I64       ValType = 0x7e
+	F32       ValType = 0x7d
+	F64       ValType = 0x7c
+	FuncRef   ValType = 0x70
+	ExternRef ValType = 0x6f
+)
+
+func (t ValType) String() string {
+	switch t {
+	case I32:
+		return "i32"
+	case I64:
+		return "i64"
+	case F32:
+		return "f32"
+	case F64:
+		return "f64"
+	case FuncRef:
+		return "funcref"
+	case ExternRef:
+		return "externref"
+	}
+	return fmt.Sprintf("0x%02x", byte(t))
+}
+
+// FuncType is the signature of a function.
+type FuncType struct {
+	Params, Results []ValType
+}
+
+func (ft FuncType) String() string {
+	return fmt.Sprintf("%v -> %v", ft.Params, ft.Results)
+}
+
+// Equal reports whether the types have the same params and results.
+func (ft FuncType) Equal(other FuncType) bool {
+	return string(valTypes(ft.Params)) == string(valTypes(other.Params)) && string(valTypes(ft.Results)) == string(valTypes(other.Results))
+}
+
+func valTypes(types []ValType) []byte {
+	b := make([]byte, len(types))
+	for i, t := range types {
+		b[i] = byte(t)
+	}
+	return b
+}
+
+// Export kinds.
+const (
+	kindFunc   = 0x00
+	kindTable  = 0x01
+	kindMemory = 0x02
+	kindGlobal = 0x03
+)
+
+// Limits of decoded modules, which keep a malicious module from exhausting the host.
+const (
+	maxLocals = 50000
+	maxPages  = 1024 // of 64KiB, 64MiB of memory
+	maxTable  = 1 << 20
+	pageSize  = 64 << 10
+)
+
+type limits struct {
+	min, max uint32
+	hasMax   bool
+}
+
+type importEntry struct {
+	module, name string
+	typ          uint32
+}
+
+type export struct {
+	kind  byte
+	index uint32
+}
+
+// constExpr is an initializer of globals and offsets of segments: a constant, 'global.get', 'ref.func' or 'ref.null'.
+type constExpr struct {
+	op  byte
+	val uint64 // the constant, index, or type of 'ref.null'
+}
+
+type global struct {
+	typ     ValType
+	mutable bool
+	init    constExpr
+}
+
+type elemSegment struct {
+	active bool
+	offset constExpr
+	funcs  []int64 // function indices, -1 for null references
+}
+
+type dataSegment struct {
+	active bool
+	offset constExpr
+	init   []byte
+}
+
+type code struct {
+	locals []ValType
+	body   []instr
+}
+
+// instr is a decoded instruction with its immediates and precomputed targets of jumps.
+type instr struct {
+	op    uint16   // opcode, or 0xfc00 plus the subcode of prefixed ones
+	a, b  uint32   // immediates, e.g. an index, or offsets of 'end' and 'else' of blocks
+	v     uint64   // constant, or numbers of params and results of blocks
+	table []uint32 // labels of 'br_table'
+}
+
+// Module is a decoded module, which runs once it is instantiated.
+type Module struct {
+	types    []FuncType
+	imports  []importEntry
+	funcs    []uint32 // type indices of functions defined by the module
+	table    *limits
+	memory   *limits
+	globals  []global
+	exports  map[string]export
+	start    *uint32
+	elems    []elemSegment
+	codes    []code
+	datas    []dataSegment
+	numDatas *uint32
+}
+
+// Imports returns modules and names of functions imported by the module, e.g. 'env.dial'.
+func (m *Module) Imports() []string {
+	names := make([]string, len(m.imports))
+	for i, imp := range m.imports {
+		names[i] = imp.module + "." + imp.name
+	}
+	return names
+}
+
+// ExportedFunc returns the type of the exported function.
+func (m *Module) ExportedFunc(name string) (FuncType, bool) {
+	exp, ok := m.exports[name]
+	if !ok || exp.kind != kindFunc {
+		return FuncType{}, false
+	}
+	return m.types[m.funcType(exp.index)], true
+}
+
+// funcType returns the type index of the function, imported or defined.
+func (m *Module) funcType(index uint32) uint32 {
+	if int(index) < len(m.imports) {
+		return m.imports[index].typ
+	}
+	return m.funcs[int(index)-len(m.imports)]
+}
+
+type decodeError struct {
+	err error
+}
+
+// reader decodes values of the binary format, panicking with decodeError on malformed input.
+type reader struct {
+	b   []byte
+	pos int
+}
+
+func (r *reader) fail(format string, args ...any) {
+	panic(decodeError{fmt.Errorf("offset 0x%x: "+format, append([]any{r.pos}, args...)...)})
+}
+
+func (r *reader) eof() bool {
+	return r.pos >= len(r.b)
+}
+
+func (r *reader) byte() byte {
+	if r.eof() {
+		r.fail("unexpected end")
+	}
+	r.pos++
+	return r.b[r.pos-1]
+}
+
+func (r *reader) bytes(n uint32) []byte {
+	if uint64(r.pos)+uint64(n) > uint64(len(r.b)) {
+		r.fail("unexpected end")
+	}
+	r.pos += int(n)
+	return r.b[r.pos-int(n) : r.pos]
+}
+
+// sub returns the reader of the next n bytes, which shares offsets of r.
+func (r *reader) sub(n uint32) *reader {
+	pos := r.pos
+	return &reader{b: r.b[:pos+len(r.bytes(n))], pos: pos}
+}
+
+// uleb reads an unsigned LEB128 number of at most the bits.
+func (r *reader) uleb(bits uint) uint64 {
+	var v uint64
+	for shift := uint(0); ; shift += 7 {
+		b := r.byte()
+		if shift >= bits || (shift+7 > bits && b&0x7f>>(bits-shift) != 0) {
+			r.fail("integer too large")
+		}
+		v |= uint64(b&0x7f) << shift
+		if b&0x80 == 0 {
+			return v
+		}
+	}
+}
+
+// sleb reads a signed LEB128 number of at most the bits.
+func (r *reader) sleb(bits uint) int64 {
+	var v int64
+	var shift uint
+	for {
+		b := r.byte()
+		if shift >= bits {
+			r.fail("integer too large")
+		}
+		v |= int64(b&0x7f) << shift
+		shift += 7
+		if b&0x80 == 0 {
+			if shift < 64 && b&0x40 != 0 {
+				v |= -1 << shift
+			}
+			return v
+		}
+	}
+}
+
+func (r *reader) u32() uint32 {
+	return uint32(r.uleb(32))
+}
+
+func (r *reader) name() string {
+	b := r.bytes(r.u32())
+	if !utf8.Valid(b) {
+		r.fail("invalid UTF-8 name")
+	}
+	return string(b)
+}
+
+func (r *reader) valType() ValType {
+	t := ValType(r.byte())
+	switch t {
+	case I32, I64, F32, F64, FuncRef, ExternRef:
+		return t
+	}
+	r.fail("invalid value type 0x%02x", byte(t))
+	return 0
+}
+
+func (r *reader) memoryIndex() {
+	if r.byte() != 0 {
+		r.fail("multiple memories are not supported")
+	}
+}
+
+func (r *reader) refType() ValType {
+	t := r.valType()
+	if t != FuncRef && t != ExternRef {
+		r.fail("invalid reference type %s", t)
+	}
+	return t
+}
+
+func (r *reader) limits(max uint32) limits {
+	var l limits
+	switch flags := r.byte(); flags {
+	case 0x00:
+		l.min = r.u32()
+	case 0x01:
+		l.min, l.max, l.hasMax = r.u32(), r.u32(), true
+		if l.max < l.min {
+			r.fail("maximum below minimum")
+		}
+	default:
+		r.fail("unsupported limits 0x%02x", flags)
+	}
+	if l.min > max {
+		r.fail("minimum %d above limit %d", l.min, max)
+	}
+	return l
+}
+
+func (r *reader) constExpr() constExpr {
+	var e constExpr
+	switch e.op = r.byte(); e.op {
+	case 0x41:
+		e.val = uint64(uint32(r.sleb(32)))
+	case 0x42:
+		e.val = uint64(r.sleb(64))
+	case 0x43:
+		e.val = uint64(leUint32(r.bytes(4)))
+	case 0x44:
+		e.val = leUint64(r.bytes(8))
+	case 0x23, 0xd2:
+		e.val = uint64(r.u32())
+	case 0xd0:
+		e.val = uint64(r.refType())
+	default:
+		r.fail("unsupported constant expression 0x%02x", e.op)
+	}
+	if r.byte() != 0x0b {
+		r.fail("constant expression is not terminated")
+	}
+	return e
+}
+
+func leUint32(b []byte) uint32 {
+	return uint32(b[0]) | uint32(b[1])<<8 | uint32(b[2])<<16 | uint32(b[3])<<24
+}
+
+func leUint64(b []byte) uint64 {
+	return uint64(leUint32(b)) | uint64(leUint32(b[4:]))<<32
+}
+
+// Decode decodes the module in the binary format.
+func Decode(b []byte) (m *Module, err error) {
+	defer func() {
+		if e := recover(); e != nil {
+			de, ok := e.(decodeError)
+			if !ok {
+				panic(e)
+			}
+			m, err = nil, fmt.Errorf("invalid module: %w", de.err)
+		}
+	}()
+	r := &reader{b: b}
+	if len(b) < 8 || string(b[:4]) != "\x00asm" {
+		return nil, errors.New("invalid module: no magic number")
+	}
+	if leUint32(b[4:8]) != 1 {
+		return nil, fmt.Errorf("invalid module: unsupported version %d", leUint32(b[4:8]))
+	}
+	r.pos = 8
+	m = &Module{exports: map[string]export{}}
+	var last byte
+	for !r.eof() {
+		id := r.byte()
+		s := r.sub(r.u32())
+		if id != 0 {
+			// the data count section comes before the code section
+			if order := sectionOrder(id); order <= sectionOrder(last) {
+				r.fail("section %d out of order", id)
+			}
+			last = id
+		}
+		m.decodeSection(id, s)
+		if id != 0 && !s.eof() {
+			s.fail("%d bytes left in section %d", len(s.b)-s.pos, id)
+		}
+	}
+	if len(m.funcs) != len(m.codes) {
+		return nil, fmt.Errorf("invalid module: %d functions with %d bodies", len(m.funcs), len(m.codes))
+	}
+	if m.numDatas != nil && int(*m.numDatas) != len(m.datas) {
+		return nil, fmt.Errorf("invalid module: data count %d with %d data segments", *m.numDatas, len(m.datas))
+	}
+	return m, nil
+}
+
+func sectionOrder(id byte) int {
+	switch id {
+	case 0:
+		return 0
+	case 12:
+		return 10
+	case 10, 11:
+		return int(id) + 1
+	}
+	return int(id)
+}
+
+func (m *Module) decodeSection(id byte, r *reader) {
+	switch id {
+	case 0: // custom sections, e.g. names, are skipped
+	case 1:
+		for n := r.u32(); n > 0; n-- {
+			if r.byte() != 0x60 {
+				r.fail("invalid function type")
+			}
+			var ft FuncType
+			for k := r.u32(); k > 0; k-- {
+				ft.Params = append(ft.Params, r.valType())
+			}
+			for k := r.u32(); k > 0; k-- {
+				ft.Results = append(ft.Results, r.valType())
+			}
+			m.types = append(m.types, ft)
+		}
+	case 2:
+		for n := r.u32(); n > 0; n-- {
+			imp := importEntry{module: r.name(), name: r.name()}
+			switch kind := r.byte(); kind {
+			case kindFunc:
+				imp.typ = m.typeIndex(r)
+			case kindTable:
+				r.fail("import of table %s.%s is not supported", imp.module, imp.name)
+			case kindMemory:
+				r.fail("import of memory %s.%s is not supported", imp.module, imp.name)
+			default:
+				r.fail("import of global %s.%s is not supported", imp.module, imp.name)
+			}
+			m.imports = append(m.imports, imp)
+		}
+	case 3:
+		for n := r.u32(); n > 0; n-- {
+			m.funcs = append(m.funcs, m.typeIndex(r))
+		}
+	case 4:
+		for n := r.u32(); n > 0; n-- {
+			if t := r.valType(); t != FuncRef {
+				r.fail("tables of %s are not supported", t)
+			}
+			l := r.limits(maxTable)
+			if m.table != nil {
+				r.fail("multiple tables are not supported")
+			}
+			m.table = &l
+		}
+	case 5:
+		for n := r.u32(); n > 0; n-- {
+			l := r.limits(maxPages)
+			if m.memory != nil {
+				r.fail("multiple memories are not supported")
+			}
+			m.memory = &l
+		}
+	case 6:
+		for n := r.u32(); n > 0; n-- {
+			g := global{typ: r.valType()}
+			switch mut := r.byte(); mut {
+			case 0x00:
+			case 0x01:
+				g.mutable = true
+			default:
+				r.fail("invalid mutability 0x%02x", mut)
+			}
+			g.init = r.constExpr()
+			if t := m.constType(r, g.init, len(m.globals)); t != g.typ {
+				r.fail("type mismatch: global of %s initialized with %s", g.typ, t)
+			}
+			m.globals = append(m.globals, g)
+		}
+	case 7:
+		for n := r.u32(); n > 0; n-- {
+			name := r.name()
+			if _, ok := m.exports[name]; ok {
+				r.fail("duplicate export %s", name)
+			}
+			exp := export{kind: r.byte(), index: r.u32()}
+			switch exp.kind {
+			case kindFunc:
+				m.funcIndex(r, uint64(exp.index))
+			case kindTable:
+				if m.table == nil || exp.index != 0 {
+					r.fail("unknown table %d", exp.index)
+				}
+			case kindMemory:
+				if m.memory == nil || exp.index != 0 {
+					r.fail("unknown memory %d", exp.index)
+				}
+			case kindGlobal:
+				if int(exp.index) >= len(m.globals) {
+					r.fail("unknown global %d", exp.index)
+				}
+			default:
+				r.fail("invalid export kind 0x%02x", exp.kind)
+			}
+			m.exports[name] = exp
+		}
+	case 8:
+		start := r.u32()
+		m.funcIndex(r, uint64(start))
+		if ft := m.types[m.funcType(start)]; len(ft.Params) > 0 || len(ft.Results) > 0 {
+			r.fail("start function is %v, not [] -> []", ft)
+		}
+		m.start = &start
+	case 9:
+		for n := r.u32(); n > 0; n-- {
+			m.elems = append(m.elems, m.decodeElem(r))
+		}
+	case 10:
+		n := r.u32()
+		if int(n) != len(m.funcs) {
+			r.fail("%d functions with %d bodies", len(m.funcs), n)
+		}
+		for i := range n {
+			body := r.sub(r.u32())
+			m.codes = append(m.codes, m.decodeCode(body, m.types[m.funcs[i]]))
+		}
+	case 11:
+		n := r.u32()
+		if m.numDatas != nil && n != *m.numDatas {
+			r.fail("data count %d with %d data segments", *m.numDatas, n)
+		}
+		for ; n > 0; n-- {
+			var d dataSegment
+			switch flags := r.u32(); flags {
+			case 0:
+				d.active, d.offset = true, r.constExpr()
+			case 1:
+			case 2:
+				if r.u32() != 0 {
+					r.fail("multiple memories are not supported")
+				}
+				d.active, d.offset = true, r.constExpr()
+			default:
+				r.fail("invalid data segment flags %d", flags)
+			}
+			if d.active {
+				if m.memory == nil {
+					r.fail("unknown memory 0")
+				}
+				m.offset(r, d.offset)
+			}
+			d.init = r.bytes(r.u32())
+			m.datas = append(m.datas, d)
+		}
+	case 12:
+		n := r.u32()
+		m.numDatas = &n
+	default:
+		r.fail("unknown section %d", id)
+	}
+}
+
+func (m *Module) typeIndex(r *reader) uint32 {
+	i := r.u32()
+	if int(i) >= len(m.types) {
+		r.fail("unknown type %d", i)
+	}
+	return i
+}
+
+func (m *Module) decodeElem(r *reader) elemSegment {
+	var e elemSegment
+	flags := r.u32()
+	if flags > 7 {
+		r.fail("invalid element segment flags %d", flags)
+	}
+	e.active = flags&0x01 == 0
+	if e.active {
+		if flags&0x02 != 0 && r.u32() != 0 {
+			r.fail("multiple tables are not supported")
+		}
+		e.offset = r.constExpr()
+		if m.table == nil {
+			r.fail("unknown table 0")
+		}
+		m.offset(r, e.offset)
+	}
+	exprs := flags&0x04 != 0
+	if flags&0x03 != 0 {
+		// element kind of indices, or reference type of expressions
+		if kind := r.byte(); (!exprs && kind != 0x00) || (exprs && ValType(kind) != FuncRef) {
+			r.fail("element segments of 0x%02x are not supported", kind)
+		}
+	}
+	for n := r.u32(); n > 0; n-- {
+		if !exprs {
+			i := r.u32()
+			m.funcIndex(r, uint64(i))
+			e.funcs = append(e.funcs, int64(i))
+			continue
+		}
+		switch c := r.constExpr(); c.op {
+		case 0xd2:
+			m.funcIndex(r, c.val)
+			e.funcs = append(e.funcs, int64(c.val))
+		case 0xd0:
+			if ValType(c.val) != FuncRef {
+				r.fail("type mismatch: element of %s", ValType(c.val))
+			}
+			e.funcs = append(e.funcs, -1)
+		default:
+			r.fail("unsupported element expression 0x%02x", c.op)
+		}
+	}
+	return e
+}
+
+// blockType returns the type of a block: no params and no results, a single result, or a function type.
+func (m *Module) blockType(r *reader) FuncType {
+	switch b := r.b[min(r.pos, len(r.b)-1)]; {
+	case b == 0x40:
+		r.pos++
+		return FuncType{}
+	case b >= 0x6f && b <= 0x7f:
+		return FuncType{Results: []ValType{r.valType()}}
+	}
+	i := r.sleb(33)
+	if i < 0 || int(i) >= len(m.types) {
+		r.fail("unknown block type %d", i)
+	}
+	return m.types[i]
+}
+
+// decodeCode decodes and validates the body of a function of the type.
+func (m *Module) decodeCode(r *reader, ft FuncType) code {
+	var c code
+	total := 0
+	for n := r.u32(); n > 0; n-- {
+		count := r.u32()
+		if total += int(count); total > maxLocals {
+			r.fail("too many locals")
+		}
+		t := r.valType()
+		for range count {
+			c.locals = append(c.locals, t)
+		}
+	}
+	v := newValidator(r, m, ft, c.locals)
+	var blocks []int // indices of open 'block', 'loop' and 'if' instructions
+	for {
+		in := instr{op: uint16(r.byte())}
+		var bt FuncType
+		var align uint32
+		switch in.op {
+		case 0x02, 0x03, 0x04:
+			bt = m.blockType(r)
+			in.v = uint64(len(bt.Params))<<32 | uint64(len(bt.Results))
+			blocks = append(blocks, len(c.body))
+		case 0x05:
+			if len(blocks) == 0 || c.body[blocks[len(blocks)-1]].op != 0x04 {
+				r.fail("'else' without 'if'")
+			}
+			c.body[blocks[len(blocks)-1]].b = uint32(len(c.body))
+		case 0x0b:
+			if len(blocks) == 0 {
+				if !r.eof() {
+					r.fail("code after the end of function")
+				}
+				v.instr(&in, bt, align)
+				c.body = append(c.body, in)
+				return c
+			}
+			start := blocks[len(blocks)-1]
+			blocks = blocks[:len(blocks)-1]
+			c.body[start].a = uint32(len(c.body))
+			// the end of the block is where 'else' jumps to
+			if c.body[start].op == 0x04 && c.body[start].b != 0 {
+				c.body[c.body[start].b].a = uint32(len(c.body))
+			}
+		case 0x0c, 0x0d, 0x10, 0x20, 0x21, 0x22, 0x23, 0x24:
+			in.a = r.u32()
+		case 0x0e:
+			for n := r.u32(); n > 0; n-- {
+				in.table = append(in.table, r.u32())
+			}
+			in.a = r.u32()
+		case 0x11:
+			in.a = m.typeIndex(r)
+			if r.u32() != 0 {
+				r.fail("multiple tables are not supported")
+			}
+		case 0x1c:
+			for n := r.u32(); n > 0; n-- {
+				bt.Results = append(bt.Results, r.valType())
+			}
+			if len(bt.Results) == 0 {
+				r.fail("invalid result arity of 'select'")
+			}
+			in.op = 0x1b
+		case 0x3f, 0x40:
+			r.memoryIndex()
+		case 0x41:
+			in.v = uint64(uint32(r.sleb(32)))
+		case 0x42:
+			in.v = uint64(r.sleb(64))
+		case 0x43:
+			in.v = uint64(leUint32(r.bytes(4)))
+		case 0x44:
+			in.v = leUint64(r.bytes(8))
+		case 0xfc:
+			in.op = 0xfc00 | uint16(r.u32())
+			switch in.op & 0xff {
+			case 0, 1, 2, 3, 4, 5, 6, 7:
+			case 8:
+				in.a = r.u32()
+				r.memoryIndex()
+			case 9:
+				in.a = r.u32()
+			case 10:
+				r.memoryIndex()
+				r.memoryIndex()
+			case 11:
+				r.memoryIndex()
+			default:
+				r.fail("unsupported instruction 0xfc %d", in.op&0xff)
+			}
+		default:
+			switch {
+			case in.op >= 0x28 && in.op <= 0x3e:
+				// alignment is only a hint, which must not be larger than natural
+				align = r.u32()
+				in.a = r.u32()
+			case in.op == 0x00, in.op == 0x01, in.op == 0x0f, in.op == 0x1a, in.op == 0x1b,
+				in.op >= 0x45 && in.op <= 0xc4:
+			default:
+				r.fail("unsupported instruction 0x%02x", in.op)
+			}
+		}
+		v.instr(&in, bt, align)
+		c.body = append(c.body, in)
+	}
+}
+
+// float helpers of instructions, which keep NaN payloads aside
+func f32(v uint64) float32  { return math.Float32frombits(uint32(v)) }
+func f64(v uint64) float64  { return math.Float64frombits(v) }
+func u32f(f float32) uint64 { return uint64(math.Float32bits(f)) }
+func u64f(f float64) uint64 { return math.Float64bits(f) }
@@ -0,0 +1,387 @@
+package wasm
+
+// unknown is the type of values popped by unreachable code, which matches any type.
+const unknown ValType = 0
+
+// ctrlFrame is a block of a function body being validated: 'block', 'loop', 'if', 'else' or the body itself.
+type ctrlFrame struct {
+	op              uint16
+	params, results []ValType
+	height          int // of the value stack at the start of the block
+	unreachable     bool
+}
+
+// validator checks types of operands of instructions of a function body while it is decoded,
+// so invalid code fails to load instead of trapping once it runs.
+type validator struct {
+	r      *reader
+	m      *Module
+	locals []ValType // params and locals
+	vals   []ValType
+	ctrls  []ctrlFrame
+}
+
+func newValidator(r *reader, m *Module, ft FuncType, locals []ValType) *validator {
+	v := &validator{r: r, m: m, locals: append(append([]ValType(nil), ft.Params...), locals...)}
+	v.ctrls = []ctrlFrame{{op: 0x02, results: ft.Results}}
+	return v
+}
+
+func (v *validator) push(types ...ValType) {
+	v.vals = append(v.vals, types...)
+}
+
+// pop pops a value of the type, or of any type if it is unknown.
+func (v *validator) pop(want ValType) ValType {
+	f := &v.ctrls[len(v.ctrls)-1]
+	if len(v.vals) == f.height {
+		if f.unreachable {
+			return want
+		}
+		if want == unknown {
+			v.r.fail("type mismatch: expected a value, but the stack is empty")
+		}
+		v.r.fail("type mismatch: expected %s, but the stack is empty", want)
+	}
+	got := v.vals[len(v.vals)-1]
+	v.vals = v.vals[:len(v.vals)-1]
+	if got != want && got != unknown && want != unknown {
+		v.r.fail("type mismatch: expected %s, got %s", want, got)
+	}
+	if got == unknown {
+		return want
+	}
+	return got
+}
+
+func (v *validator) popAll(types []ValType) {
+	for i := len(types) - 1; i >= 0; i-- {
+		v.pop(types[i])
+	}
+}
+
+func (v *validator) pushCtrl(op uint16, params, results []ValType) {
+	v.ctrls = append(v.ctrls, ctrlFrame{op: op, params: params, results: results, height: len(v.vals)})
+	v.push(params...)
+}
+
+func (v *validator) popCtrl() ctrlFrame {
+	f := v.ctrls[len(v.ctrls)-1]
+	v.popAll(f.results)
+	if len(v.vals) != f.height {
+		v.r.fail("type mismatch: %d values left at the end of block", len(v.vals)-f.height)
+	}
+	v.ctrls = v.ctrls[:len(v.ctrls)-1]
+	return f
+}
+
+// label returns types of values passed by branches to the label of the depth.
+func (v *validator) label(depth uint32) []ValType {
+	if int(depth) >= len(v.ctrls) {
+		v.r.fail("unknown label %d", depth)
+	}
+	f := v.ctrls[len(v.ctrls)-1-int(depth)]
+	if f.op == 0x03 {
+		return f.params
+	}
+	return f.results
+}
+
+// unreachable marks the rest of the block as unreachable, e.g. after 'br' or 'return'.
+func (v *validator) unreachable() {
+	f := &v.ctrls[len(v.ctrls)-1]
+	v.vals = v.vals[:f.height]
+	f.unreachable = true
+}
+
+func (v *validator) local(i uint32) ValType {
+	if int(i) >= len(v.locals) {
+		v.r.fail("unknown local %d", i)
+	}
+	return v.locals[i]
+}
+
+func (v *validator) global(i uint32) global {
+	if int(i) >= len(v.m.globals) {
+		v.r.fail("unknown global %d", i)
+	}
+	return v.m.globals[i]
+}
+
+func (v *validator) memory() {
+	if v.m.memory == nil {
+		v.r.fail("unknown memory 0")
+	}
+}
+
+func (v *validator) data(i uint32) {
+	if v.m.numDatas == nil {
+		v.r.fail("data count section required")
+	}
+	if i >= *v.m.numDatas {
+		v.r.fail("unknown data segment %d", i)
+	}
+}
+
+// instr checks the decoded instruction. bt is the type of 'block', 'loop' and 'if',
+// or of values of the typed 'select', align is the alignment of memory instructions.
+func (v *validator) instr(in *instr, bt FuncType, align uint32) {
+	switch op := in.op; {
+	case op == 0x00:
+		v.unreachable()
+	case op == 0x01:
+	case op == 0x02, op == 0x03:
+		v.popAll(bt.Params)
+		v.pushCtrl(op, bt.Params, bt.Results)
+	case op == 0x04:
+		v.pop(I32)
+		v.popAll(bt.Params)
+		v.pushCtrl(op, bt.Params, bt.Results)
+	case op == 0x05:
+		f := v.popCtrl()
+		v.pushCtrl(op, f.params, f.results)
+	case op == 0x0b:
+		f := v.popCtrl()
+		if f.op == 0x04 && string(valTypes(f.params)) != string(valTypes(f.results)) {
+			v.r.fail("type mismatch: 'if' without 'else' returns %v instead of its params %v", f.results, f.params)
+		}
+		v.push(f.results...)
+	case op == 0x0c:
+		v.popAll(v.label(in.a))
+		v.unreachable()
+	case op == 0x0d:
+		v.pop(I32)
+		types := v.label(in.a)
+		v.popAll(types)
+		v.push(types...)
+	case op == 0x0e:
+		v.pop(I32)
+		types := v.label(in.a)
+		for _, depth := range in.table {
+			if other := v.label(depth); len(other) != len(types) {
+				v.r.fail("type mismatch: labels of 'br_table' pass %d and %d values", len(other), len(types))
+			} else {
+				v.popAll(other)
+				v.push(other...)
+			}
+		}
+		v.popAll(types)
+		v.unreachable()
+	case op == 0x0f:
+		v.popAll(v.ctrls[0].results)
+		v.unreachable()
+	case op == 0x10:
+		if int(in.a) >= len(v.m.imports)+len(v.m.funcs) {
+			v.r.fail("unknown function %d", in.a)
+		}
+		ft := v.m.types[v.m.funcType(in.a)]
+		v.popAll(ft.Params)
+		v.push(ft.Results...)
+	case op == 0x11:
+		if v.m.table == nil {
+			v.r.fail("unknown table 0")
+		}
+		v.pop(I32)
+		ft := v.m.types[in.a]
+		v.popAll(ft.Params)
+		v.push(ft.Results...)
+	case op == 0x1a:
+		v.pop(unknown)
+	case op == 0x1b:
+		v.pop(I32)
+		if len(bt.Results) > 0 {
+			if len(bt.Results) != 1 {
+				v.r.fail("invalid result arity of 'select'")
+			}
+			t := bt.Results[0]
+			v.pop(t)
+			v.pop(t)
+			v.push(t)
+			break
+		}
+		x, y := v.pop(unknown), v.pop(unknown)
+		if x == FuncRef || x == ExternRef || y == FuncRef || y == ExternRef {
+			v.r.fail("type mismatch: 'select' of references without a type")
+		}
+		if x != y && x != unknown && y != unknown {
+			v.r.fail("type mismatch: 'select' of %s and %s", y, x)
+		}
+		if x == unknown {
+			x = y
+		}
+		v.push(x)
+	case op == 0x20:
+		v.push(v.local(in.a))
+	case op == 0x21:
+		v.pop(v.local(in.a))
+	case op == 0x22:
+		t := v.local(in.a)
+		v.pop(t)
+		v.push(t)
+	case op == 0x23:
+		v.push(v.global(in.a).typ)
+	case op == 0x24:
+		g := v.global(in.a)
+		if !g.mutable {
+			v.r.fail("global %d is immutable", in.a)
+		}
+		v.pop(g.typ)
+	case op >= 0x28 && op <= 0x3e:
+		v.memory()
+		t, size := memoryOps[op-0x28].typ, memoryOps[op-0x28].size
+		if align > 3 || 1<<align > size {
+			v.r.fail("alignment must not be larger than natural")
+		}
+		if op >= 0x36 {
+			v.pop(t)
+			v.pop(I32)
+		} else {
+			v.pop(I32)
+			v.push(t)
+		}
+	case op == 0x3f:
+		v.memory()
+		v.push(I32)
+	case op == 0x40:
+		v.memory()
+		v.pop(I32)
+		v.push(I32)
+	case op == 0x41:
+		v.push(I32)
+	case op == 0x42:
+		v.push(I64)
+	case op == 0x43:
+		v.push(F32)
+	case op == 0x44:
+		v.push(F64)
+	case op == 0xfc08:
+		v.memory()
+		v.data(in.a)
+		v.popAll([]ValType{I32, I32, I32})
+	case op == 0xfc09:
+		v.data(in.a)
+	case op == 0xfc0a, op == 0xfc0b:
+		v.memory()
+		v.popAll([]ValType{I32, I32, I32})
+	default:
+		params, result := numericType(op)
+		v.popAll(params)
+		v.push(result)
+	}
+}
+
+// memoryOps are types and sizes of loads 0x28 to 0x35 and stores 0x36 to 0x3e.
+var memoryOps = [...]struct {
+	typ  ValType
+	size uint32
+}{
+	{I32, 4}, {I64, 8}, {F32, 4}, {F64, 8}, {I32, 1}, {I32, 1}, {I32, 2}, {I32, 2},
+	{I64, 1}, {I64, 1}, {I64, 2}, {I64, 2}, {I64, 4}, {I64, 4},
+	{I32, 4}, {I64, 8}, {F32, 4}, {F64, 8}, {I32, 1}, {I32, 2}, {I64, 1}, {I64, 2}, {I64, 4},
+}
+
+// numericType returns types of operands and the result of a numeric instruction, see numeric.
+func numericType(op uint16) ([]ValType, ValType) {
+	unary := func(x, result ValType) ([]ValType, ValType) { return []ValType{x}, result }
+	binary := func(x, result ValType) ([]ValType, ValType) { return []ValType{x, x}, result }
+	switch {
+	case op == 0x45:
+		return unary(I32, I32)
+	case op >= 0x46 && op <= 0x4f:
+		return binary(I32, I32)
+	case op == 0x50:
+		return unary(I64, I32)
+	case op >= 0x51 && op <= 0x5a:
+		return binary(I64, I32)
+	case op >= 0x5b && op <= 0x60:
+		return binary(F32, I32)
+	case op >= 0x61 && op <= 0x66:
+		return binary(F64, I32)
+	case op >= 0x67 && op <= 0x69:
+		return unary(I32, I32)
+	case op >= 0x6a && op <= 0x78:
+		return binary(I32, I32)
+	case op >= 0x79 && op <= 0x7b:
+		return unary(I64, I64)
+	case op >= 0x7c && op <= 0x8a:
+		return binary(I64, I64)
+	case op >= 0x8b && op <= 0x91:
+		return unary(F32, F32)
+	case op >= 0x92 && op <= 0x98:
+		return binary(F32, F32)
+	case op >= 0x99 && op <= 0x9f:
+		return unary(F64, F64)
+	case op >= 0xa0 && op <= 0xa6:
+		return binary(F64, F64)
+	case op == 0xa7:
+		return unary(I64, I32)
+	case op == 0xa8, op == 0xa9, op == 0xbc, op == 0xfc00, op == 0xfc01:
+		return unary(F32, I32)
+	case op == 0xaa, op == 0xab, op == 0xfc02, op == 0xfc03:
+		return unary(F64, I32)
+	case op == 0xac, op == 0xad:
+		return unary(I32, I64)
+	case op == 0xae, op == 0xaf, op == 0xfc04, op == 0xfc05:
+		return unary(F32, I64)
+	case op == 0xb0, op == 0xb1, op == 0xbd, op == 0xfc06, op == 0xfc07:
+		return unary(F64, I64)
+	case op == 0xb2, op == 0xb3, op == 0xbe:
+		return unary(I32, F32)
+	case op == 0xb4, op == 0xb5:
+		return unary(I64, F32)
+	case op == 0xb6:
+		return unary(F64, F32)
+	case op == 0xb7, op == 0xb8:
+		return unary(I32, F64)
+	case op == 0xb9, op == 0xba, op == 0xbf:
+		return unary(I64, F64)
+	case op == 0xbb:
+		return unary(F32, F64)
+	case op == 0xc0, op == 0xc1:
+		return unary(I32, I32)
+	case op >= 0xc2 && op <= 0xc4:
+		return unary(I64, I64)
+	}
+	return nil, unknown
+}
+
+// constType returns the type of the constant expression, which may only get the first globals
+// and only immutable ones.
+func (m *Module) constType(r *reader, e constExpr, globals int) ValType {
+	switch e.op {
+	case 0x41:
+		return I32
+	case 0x42:
+		return I64
+	case 0x43:
+		return F32
+	case 0x44:
+		return F64
+	case 0x23:
+		if e.val >= uint64(globals) {
+			r.fail("unknown global %d", e.val)
+		}
+		if m.globals[e.val].mutable {
+			r.fail("constant expression gets mutable global %d", e.val)
+		}
+		return m.globals[e.val].typ
+	case 0xd2:
+		m.funcIndex(r, e.val)
+		return FuncRef
+	}
+	// ref.null keeps its type as the value
+	return ValType(e.val)
+}
+
+// offset checks the constant expression of the offset of a segment.
+func (m *Module) offset(r *reader, e constExpr) {
+	if t := m.constType(r, e, len(m.globals)); t != I32 {
+		r.fail("type mismatch: offset of %s", t)
+	}
+}
+
+func (m *Module) funcIndex(r *reader, i uint64) {
+	if i >= uint64(len(m.imports)+len(m.funcs)) {
+		r.fail("unknown function %d", i)
+	}
+}
@@ -0,0 +1,358 @@
+package wasm
+
+import (
+	"context"
+	"errors"
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+func uleb(v uint64) []byte {
+	var b []byte
+	for {
+		c := byte(v & 0x7f)
+		if v >>= 7; v != 0 {
+			b = append(b, c|0x80)
+			continue
+		}
+		return append(b, c)
+	}
+}
+
+// vec encodes items as a vector: their number followed by them.
+func vec(items ...[]byte) []byte {
+	b := uleb(uint64(len(items)))
+	for _, item := range items {
+		b = append(b, item...)
+	}
+	return b
+}
+
+func name(s string) []byte {
+	return append(uleb(uint64(len(s))), s...)
+}
+
+func section(id byte, items ...[]byte) []byte {
+	contents := vec(items...)
+	return append(append([]byte{id}, uleb(uint64(len(contents)))...), contents...)
+}
+
+func cat(parts ...[]byte) []byte {
+	var b []byte
+	for _, p := range parts {
+		b = append(b, p...)
+	}
+	return b
+}
+
+// body encodes a function body with declarations of locals.
+func body(locals []byte, code ...byte) []byte {
+	b := cat(locals, code)
+	return append(uleb(uint64(len(b))), b...)
+}
+
+func funcType(params, results []byte) []byte {
+	return cat([]byte{0x60}, vec(bytesOf(params)...), vec(bytesOf(results)...))
+}
+
+func bytesOf(types []byte) [][]byte {
+	items := make([][]byte, len(types))
+	for i, t := range types {
+		items[i] = []byte{t}
+	}
+	return items
+}
+
+func exportOf(s string, kind byte, index uint32) []byte {
+	return cat(name(s), []byte{kind}, uleb(uint64(index)))
+}
+
+var noLocals = vec()
+
+// testModule exports functions of the tests, which call 'env.add' imported from the host.
+var testModule = cat(
+	[]byte("\x00asm\x01\x00\x00\x00"),
+	section(1,
+		funcType([]byte{0x7e}, []byte{0x7e}),       // 0
+		funcType([]byte{0x7f}, []byte{0x7f}),       // 1
+		funcType(nil, []byte{0x7f}),                // 2
+		funcType([]byte{0x7f, 0x7f}, []byte{0x7f}), // 3
+		funcType([]byte{0x7f}, []byte{0x7e}),       // 4
+		funcType(nil, nil),                         // 5
+		funcType([]byte{0x7c, 0x7c}, []byte{0x7c}), // 6
+		funcType([]byte{0x7c}, []byte{0x7f}),       // 7
+	),
+	section(2, cat(name("env"), name("add"), []byte{0x00, 0x03})),
+	section(3, []byte{0}, []byte{1}, []byte{2}, []byte{2}, []byte{3}, []byte{2}, []byte{5}, []byte{4}, []byte{3}, []byte{5},
+		[]byte{1}, []byte{2}, []byte{6}, []byte{7}, []byte{7}),
+	section(4, []byte{0x70, 0x00, 0x02}),
+	section(5, []byte{0x00, 0x01}),
+	section(7,
+		exportOf("fac", 0, 1), exportOf("sum", 0, 2), exportOf("load", 0, 3), exportOf("grow", 0, 4), exportOf("div", 0, 5),
+		exportOf("oob", 0, 6), exportOf("unreachable", 0, 7), exportOf("indirect", 0, 8), exportOf("host", 0, 9), exportOf("spin", 0, 10),
+		exportOf("btable", 0, 11), exportOf("multi", 0, 12), exportOf("hyp", 0, 13), exportOf("sat", 0, 14), exportOf("trunc", 0, 15),
+		exportOf("memory", 2, 0)),
+	section(9, []byte{0x00, 0x41, 0x00, 0x0b, 0x01, 0x01}),
+	section(10,
+		// fac: recursive factorial
+		body(noLocals, 0x20, 0x00, 0x50, 0x04, 0x7e, 0x42, 0x01, 0x05, 0x20, 0x00, 0x20, 0x00, 0x42, 0x01, 0x7d, 0x10, 0x01, 0x7e, 0x0b, 0x0b),
+		// sum: 1 + ... + n in a loop
+		body(vec([]byte{0x01, 0x7f}), 0x02, 0x40, 0x03, 0x40, 0x20, 0x00, 0x45, 0x0d, 0x01, 0x20, 0x01, 0x20, 0x00, 0x6a, 0x21, 0x01,
+			0x20, 0x00, 0x41, 0x01, 0x6b, 0x21, 0x00, 0x0c, 0x00, 0x0b, 0x0b, 0x20, 0x01, 0x0b),
+		// load: the second byte of the data segment
+		body(noLocals, 0x41, 0x10, 0x2d, 0x00, 0x01, 0x0b),
+		// grow: memory.size after memory.grow by a page
+		body(noLocals, 0x41, 0x01, 0x40, 0x00, 0x1a, 0x3f, 0x00, 0x0b),
+		// div: i32.div_s
+		body(noLocals, 0x20, 0x00, 0x20, 0x01, 0x6d, 0x0b),
+		// oob: i32.load past the memory
+		body(noLocals, 0x41, 0x7f, 0x28, 0x02, 0x00, 0x0b),
+		// unreachable
+		body(noLocals, 0x00, 0x0b),
+		// indirect: fac(5) through the table
+		body(noLocals, 0x42, 0x05, 0x20, 0x00, 0x11, 0x00, 0x00, 0x0b),
+		// host: env.add
+		body(noLocals, 0x20, 0x00, 0x20, 0x01, 0x10, 0x00, 0x0b),
+		// spin: an endless loop
+		body(noLocals, 0x03, 0x40, 0x0c, 0x00, 0x0b, 0x0b),
+		// btable: 10, 20 or 30 by br_table
+		body(noLocals, 0x02, 0x40, 0x02, 0x40, 0x02, 0x40, 0x20, 0x00, 0x0e, 0x02, 0x00, 0x01, 0x02, 0x0b,
+			0x41, 0x0a, 0x0f, 0x0b, 0x41, 0x14, 0x0f, 0x0b, 0x41, 0x1e, 0x0b),
+		// multi: a block of type 3 adding its params
+		body(noLocals, 0x41, 0x02, 0x41, 0x03, 0x02, 0x03, 0x6a, 0x0b, 0x0b),
+		// hyp: sqrt(x*x + y*y)
+		body(noLocals, 0x20, 0x00, 0x20, 0x00, 0xa2, 0x20, 0x01, 0x20, 0x01, 0xa2, 0xa0, 0x9f, 0x0b),
+		// sat: i32.trunc_sat_f64_s
+		body(noLocals, 0x20, 0x00, 0xfc, 0x02, 0x0b),
+		// trunc: i32.trunc_f64_s
+		body(noLocals, 0x20, 0x00, 0xaa, 0x0b),
+	),
+	section(11, cat([]byte{0x00, 0x41, 0x10, 0x0b}, name("hello"))),
+)
+
+func TestInstance(t *testing.T) {
+	m, err := Decode(testModule)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if imports := m.Imports(); len(imports) != 1 || imports[0] != "env.add" {
+		t.Fatalf("Unexpected imports: %v", imports)
+	}
+	if ft, ok := m.ExportedFunc("fac"); !ok || ft.String() != "[i64] -> [i64]" {
+		t.Fatalf("Unexpected type: %v", ft)
+	}
+	var hostErr error
+	imports := Imports{"env": {"add": HostFunc{
+		Type: FuncType{Params: []ValType{I32, I32}, Results: []ValType{I32}},
+		Fn: func(inst *Instance, args []uint64) ([]uint64, error) {
+			return []uint64{uint64(uint32(args[0] + args[1]))}, hostErr
+		},
+	}}}
+	ctx := context.Background()
+	inst, err := Instantiate(ctx, m, imports, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f64 := math.Float64bits
+	minusOne := uint64(math.MaxUint32)
+
+	t.Run("Test calls", func(t *testing.T) {
+		for _, test := range []struct {
+			name string
+			args []uint64
+			want uint64
+		}{
+			{"fac", []uint64{20}, 2432902008176640000},
+			{"sum", []uint64{100}, 5050},
+			{"load", nil, 'e'},
+			{"grow", nil, 2},
+			{"div", []uint64{minusOne - 6, 2}, minusOne - 2},
+			{"indirect", []uint64{0}, 120},
+			{"host", []uint64{40, 2}, 42},
+			{"btable", []uint64{0}, 10},
+			{"btable", []uint64{1}, 20},
+			{"btable", []uint64{7}, 30},
+			{"multi", nil, 5},
+			{"hyp", []uint64{f64(3), f64(4)}, f64(5)},
+			{"sat", []uint64{f64(1e20)}, math.MaxInt32},
+			{"sat", []uint64{f64(math.NaN())}, 0},
+			{"trunc", []uint64{f64(-2.9)}, minusOne - 1},
+		} {
+			results, err := inst.Call(ctx, test.name, test.args...)
+			if err != nil || len(results) != 1 || results[0] != test.want {
+				t.Fatalf("Unexpected results of %s%v: %v, %v", test.name, test.args, results, err)
+			}
+		}
+		if mem := inst.Memory(); len(mem) != 2*pageSize || string(mem[16:21]) != "hello" {
+			t.Fatalf("Unexpected memory of %d bytes", len(mem))
+		}
+	})
+
+	t.Run("Test traps", func(t *testing.T) {
+		for _, test := range []struct {
+			name string
+			args []uint64
+			want string
+		}{
+			{"div", []uint64{1, 0}, "wasm trap: integer divide by zero"},
+			{"div", []uint64{1 << 31, minusOne}, "wasm trap: integer overflow"},
+			{"oob", nil, "wasm trap: out of bounds memory access"},
+			{"unreachable", nil, "wasm trap: unreachable"},
+			{"indirect", []uint64{1}, "wasm trap: uninitialized element 1"},
+			{"indirect", []uint64{2}, "wasm trap: undefined element 2"},
+			{"fac", []uint64{1 << 20}, "wasm trap: call stack exhausted"},
+			{"trunc", []uint64{f64(math.Inf(1))}, "wasm trap: integer overflow"},
+			{"trunc", []uint64{f64(math.NaN())}, "wasm trap: invalid conversion to integer"},
+			{"missing", nil, "no exported function missing"},
+			{"sum", nil, "sum takes 1 arguments, not 0"},
+		} {
+			var trap *Trap
+			_, err := inst.Call(ctx, test.name, test.args...)
+			if err == nil || err.Error() != test.want || (strings.HasPrefix(test.want, "wasm trap") && !errors.As(err, &trap)) {
+				t.Fatalf("Unexpected error of %s%v: %v", test.name, test.args, err)
+			}
+		}
+		// the instance is usable after a trap
+		if results, err := inst.Call(ctx, "sum", 3); err != nil || results[0] != 6 {
+			t.Fatalf("Unexpected results: %v, %v", results, err)
+		}
+	})
+
+	t.Run("Test host error", func(t *testing.T) {
+		hostErr = errors.New("connection refused")
+		defer func() { hostErr = nil }()
+		if _, err := inst.Call(ctx, "host", 1, 2); err != hostErr {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	})
+
+	t.Run("Test steps", func(t *testing.T) {
+		inst, err := Instantiate(ctx, m, imports, 100)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = inst.Call(ctx, "spin"); err == nil || err.Error() != "wasm trap: too many steps, more than 100" {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	})
+
+	t.Run("Test context", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+		defer cancel()
+		if _, err := inst.Call(ctx, "spin"); !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	})
+
+	t.Run("Test imports", func(t *testing.T) {
+		if _, err := Instantiate(ctx, m, nil, 0); err == nil || err.Error() != "unknown import env.add" {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		add := imports["env"]["add"]
+		add.Type.Results = nil
+		_, err := Instantiate(ctx, m, Imports{"env": {"add": add}}, 0)
+		if err == nil || err.Error() != "import env.add is [i32 i32] -> [], not [i32 i32] -> [i32]" {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	})
+}
+
+func TestDecode(t *testing.T) {
+	header := "\x00asm\x01\x00\x00\x00"
+	for src, want := range map[string]string{
+		"":                                  "invalid module: no magic number",
+		"\x00asm\x02\x00\x00\x00":           "invalid module: unsupported version 2",
+		header + "\x01\x05":                 "invalid module: offset 0xa: unexpected end",
+		header + "\x0d\x00":                 "invalid module: offset 0xa: unknown section 13",
+		header + "\x03\x02\x01\x00":         "invalid module: offset 0xc: unknown type 0",
+		header + "\x05\x04\x01\x00\xff\x0f": "invalid module: offset 0xe: minimum 2047 above limit 1024",
+		header + "\x03\x01\x00\x01\x01\x00": "invalid module: offset 0xe: section 1 out of order",
+		header + "\x01\x04\x01\x60\x00\x00\x03\x02\x01\x00":                             "invalid module: 1 functions with 0 bodies",
+		header + "\x01\x04\x01\x60\x00\x00\x03\x02\x01\x00\x0a\x05\x01\x03\x00\xfe\x0b": "invalid module: offset 0x18: unsupported instruction 0xfe",
+	} {
+		if _, err := Decode([]byte(src)); err == nil || err.Error() != want {
+			t.Fatalf("Unexpected error of %q: %v", src, err)
+		}
+	}
+}
+
+// funcModule encodes a module of a function of the type with the body, with the sections between them, if any.
+func funcModule(ft []byte, sections []byte, body []byte) string {
+	return string(cat([]byte("\x00asm\x01\x00\x00\x00"), section(1, ft), section(3, []byte{0}), sections, section(10, body)))
+}
+
+func TestValidate(t *testing.T) {
+	void, i32 := funcType(nil, nil), funcType(nil, []byte{0x7f})
+	memory := section(5, []byte{0x00, 0x01})
+	for src, want := range map[string]string{
+		funcModule(i32, nil, body(noLocals, 0x42, 0x00, 0x0b)):                                                           "invalid module: offset 0x1b: type mismatch: expected i32, got i64",
+		funcModule(i32, nil, body(noLocals, 0x41, 0x00, 0x6a, 0x0b)):                                                     "invalid module: offset 0x1b: type mismatch: expected i32, but the stack is empty",
+		funcModule(void, nil, body(noLocals, 0x41, 0x00, 0x0b)):                                                          "invalid module: offset 0x1a: type mismatch: 1 values left at the end of block",
+		funcModule(void, nil, body(noLocals, 0x20, 0x05, 0x1a, 0x0b)):                                                    "invalid module: offset 0x19: unknown local 5",
+		funcModule(void, nil, body(noLocals, 0x0c, 0x01, 0x0b)):                                                          "invalid module: offset 0x19: unknown label 1",
+		funcModule(void, nil, body(noLocals, 0x10, 0x07, 0x0b)):                                                          "invalid module: offset 0x19: unknown function 7",
+		funcModule(void, nil, body(noLocals, 0x41, 0x00, 0x28, 0x02, 0x00, 0x1a, 0x0b)):                                  "invalid module: offset 0x1c: unknown memory 0",
+		funcModule(void, memory, body(noLocals, 0x41, 0x00, 0x28, 0x03, 0x00, 0x1a, 0x0b)):                               "invalid module: offset 0x21: alignment must not be larger than natural",
+		funcModule(i32, nil, body(noLocals, 0x41, 0x01, 0x04, 0x7f, 0x41, 0x02, 0x0b, 0x0b)):                             "invalid module: offset 0x1f: type mismatch: 'if' without 'else' returns [i32] instead of its params []",
+		funcModule(void, nil, body(noLocals, 0x41, 0x00, 0x42, 0x00, 0x41, 0x00, 0x1b, 0x1a, 0x0b)):                      "invalid module: offset 0x1e: type mismatch: 'select' of i32 and i64",
+		funcModule(void, nil, body(noLocals, 0x41, 0x00, 0x11, 0x00, 0x00, 0x0b)):                                        "invalid module: offset 0x1c: unknown table 0",
+		funcModule(void, nil, body(noLocals, 0xfc, 0x09, 0x00, 0x0b)):                                                    "invalid module: offset 0x1a: data count section required",
+		funcModule(void, section(6, []byte{0x7f, 0x00, 0x41, 0x00, 0x0b}), body(noLocals, 0x41, 0x00, 0x24, 0x00, 0x0b)): "invalid module: offset 0x23: global 0 is immutable",
+		funcModule(void, section(6, []byte{0x7f, 0x00, 0x42, 0x00, 0x0b}), body(noLocals, 0x0b)):                         "invalid module: offset 0x1a: type mismatch: global of i32 initialized with i64",
+		funcModule(void, section(7, exportOf("f", 0, 1)), body(noLocals, 0x0b)):                                          "invalid module: offset 0x19: unknown function 1",
+		funcModule(funcType([]byte{0x7f}, nil), []byte{8, 1, 0}, body(noLocals, 0x0b)):                                   "invalid module: offset 0x16: start function is [i32] -> [], not [] -> []",
+		funcModule(void, []byte{12, 1, 1}, body(noLocals, 0x0b)):                                                         "invalid module: data count 1 with 0 data segments",
+	} {
+		if _, err := Decode([]byte(src)); err == nil || err.Error() != want {
+			t.Fatalf("Unexpected error of %x: %v", src, err)
+		}
+	}
+
+	// unreachable code pops values of any type
+	if _, err := Decode([]byte(funcModule(i32, nil, body(noLocals, 0x00, 0x6a, 0x0b)))); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+}
+
+// FuzzDecode runs exports of modules, which are valid once they are decoded, so they may trap
+// but never on a runtime error of invalid code.
+func FuzzDecode(f *testing.F) {
+	f.Add(testModule)
+	f.Add([]byte(funcModule(funcType(nil, []byte{0x7f}), nil, body(noLocals, 0x00, 0x6a, 0x0b))))
+	f.Fuzz(func(t *testing.T, b []byte) {
+		m, err := Decode(b)
+		if err != nil {
+			return
+		}
+		imports := Imports{}
+		for _, imp := range m.imports {
+			ft := m.types[imp.typ]
+			if imports[imp.module] == nil {
+				imports[imp.module] = map[string]HostFunc{}
+			}
+			imports[imp.module][imp.name] = HostFunc{Type: ft, Fn: func(*Instance, []uint64) ([]uint64, error) {
+				return make([]uint64, len(ft.Results)), nil
+			}}
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		check := func(err error) {
+			var trap *Trap
+			if errors.As(err, &trap) && strings.HasPrefix(trap.Reason, "invalid code") {
+				t.Fatalf("Unexpected error of a valid module: %v", err)
+			}
+		}
+		inst, err := Instantiate(ctx, m, imports, 1<<16)
+		if check(err); err != nil {
+			return
+		}
+		for name := range m.exports {
+			if ft, ok := m.ExportedFunc(name); ok {
+				_, err = inst.Call(ctx, name, make([]uint64, len(ft.Params))...)
+				check(err)
+			}
+		}
+	})
+}
@@ -0,0 +1,229 @@
+//go:build !minimal
+
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/jackcvr/tcpw/tcpw"
+	"net"
+	"sync"
+	"time"
+)
+
+// jobRetention is how long the status of a finished wait is kept for API clients of 'tcpw serve'.
+const jobRetention = time.Hour
+
+// Limits of waits requested from the APIs of 'tcpw serve', so clients can not pile them up.
+const (
+	maxJobTimeout   = time.Hour // also the timeout of waits requested without one
+	maxActiveJobs   = 256
+	maxJobEndpoints = 64
+)
+
+var (
+	// ErrUnknownJob is reported for a wait which was never requested or has been forgotten.
+	ErrUnknownJob = errors.New("unknown wait")
+	// ErrTooManyJobs is reported for a wait requested while maxActiveJobs waits are in progress.
+	ErrTooManyJobs = fmt.Errorf("too many waits in progress, at most %d are allowed", maxActiveJobs)
+)
+
+// WaitRequest is a wait requested from the API of 'tcpw serve'.
+type WaitRequest struct {
+	Endpoints []string      `json:"endpoints"`
+	Timeout   time.Duration `json:"-"` // of the whole wait, maxJobTimeout if zero
+	Interval  time.Duration `json:"-"` // between attempts, '-i' if zero
+}
+
+// JobStatus is the state of a requested wait: 'waiting', 'success', 'timeout', 'failure' or 'canceled'.
+type JobStatus struct {
+	ID         string           `json:"id"`
+	Status     string           `json:"status"`
+	Endpoints  []*EndpointState `json:"endpoints"`
+	DurationMs int64            `json:"duration_ms"`
+	Error      string           `json:"error,omitempty"`
+}
+
+// Done reports whether the wait is over.
+func (s JobStatus) Done() bool {
+	return s.Status != "waiting"
+}
+
+// WaitJob is a wait running on behalf of an API client.
+type WaitJob struct {
+	mu      sync.Mutex
+	status  JobStatus
+	start   time.Time
+	changed chan struct{} // closed and replaced on every change
+	done    chan struct{} // closed once the wait is over
+	cancel  context.CancelFunc
+}
+
+// Status returns the current state of the wait and a channel closed once it changes.
+func (job *WaitJob) Status() (JobStatus, <-chan struct{}) {
+	job.mu.Lock()
+	defer job.mu.Unlock()
+	s := job.status
+	s.Endpoints = make([]*EndpointState, len(job.status.Endpoints))
+	for i, ep := range job.status.Endpoints {
+		copied := *ep
+		s.Endpoints[i] = &copied
+	}
+	if !s.Done() {
+		s.DurationMs = time.Since(job.start).Milliseconds()
+	}
+	return s, job.changed
+}
+
+// update changes the state of the wait under the lock and notifies watchers.
+func (job *WaitJob) update(f func(s *JobStatus)) {
+	job.mu.Lock()
+	defer job.mu.Unlock()
+	f(&job.status)
+	close(job.changed)
+	job.changed = make(chan struct{})
+}
+
+// endpoint updates the state of the endpoint.
+func (job *WaitJob) endpoint(addr string, f func(ep *EndpointState)) {
+	job.update(func(s *JobStatus) {
+		for _, ep := range s.Endpoints {
+			if ep.Endpoint == addr {
+				f(ep)
+			}
+		}
+	})
+}
+
+// WaitJobs are waits requested from the API of 'tcpw serve'.
+type WaitJobs struct {
+	app    App
+	mu     sync.Mutex
+	jobs   map[string]*WaitJob
+	active int // waits in progress
+}
+
+func NewWaitJobs(app App) *WaitJobs {
+	return &WaitJobs{app: app, jobs: map[string]*WaitJob{}}
+}
+
+// Submit starts the wait with tcpw.Waiter, dialing with '-hosts-file' overrides and '-w' timeout.
+// Only endpoints of probeSchemes are awaited, within the limits of maxJobTimeout, maxActiveJobs and maxJobEndpoints.
+func (j *WaitJobs) Submit(req WaitRequest) (*WaitJob, error) {
+	if len(req.Endpoints) > maxJobEndpoints {
+		return nil, fmt.Errorf("at most %d endpoints per wait are allowed", maxJobEndpoints)
+	}
+	endpoints := make([]string, len(req.Endpoints))
+	for i, e := range req.Endpoints {
+		if err := CheckProbeScheme(e); err != nil {
+			return nil, err
+		}
+		endpoints[i] = j.app.hosts.ReplaceEndpoint(e)
+	}
+	if req.Timeout > maxJobTimeout {
+		return nil, fmt.Errorf("timeout of a wait can not exceed %v", maxJobTimeout)
+	}
+	if req.Timeout == 0 {
+		req.Timeout = maxJobTimeout
+	}
+	if req.Interval == 0 {
+		req.Interval = j.app.interval
+	}
+	id := make([]byte, 8)
+	_, _ = rand.Read(id)
+	job := &WaitJob{start: time.Now(), changed: make(chan struct{}), done: make(chan struct{})}
+	job.status = JobStatus{ID: hex.EncodeToString(id), Status: "waiting"}
+	jobID := job.status.ID
+	waiter, err := tcpw.NewWaiter(tcpw.Options{
+		Endpoints:   endpoints,
+		Timeout:     req.Timeout,
+		Interval:    req.Interval,
+		DialTimeout: j.app.dialTimeout,
+		Dialer:      j.app.hosts.Dial(net.Dialer{}),
+		Hooks: tcpw.Hooks{
+			OnAttempt: func(a tcpw.Attempt) {
+				job.endpoint(a.Endpoint, func(ep *EndpointState) {
+					ep.Attempts = a.Number
+					ep.Error = ""
+					if a.Err != nil {
+						ep.Error = a.Err.Error()
+					}
+				})
+			},
+			OnStateChange: func(endpoint, state string, err error) {
+				job.endpoint(endpoint, func(ep *EndpointState) {
+					ep.State = state
+				})
+			},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, addr := range waiter.Endpoints() {
+		job.status.Endpoints = append(job.status.Endpoints, &EndpointState{Endpoint: addr, State: tcpw.StateWaiting})
+	}
+	var ctx context.Context
+	ctx, job.cancel = context.WithCancel(context.Background())
+	j.mu.Lock()
+	if j.active >= maxActiveJobs {
+		j.mu.Unlock()
+		job.cancel()
+		return nil, ErrTooManyJobs
+	}
+	j.active++
+	j.jobs[jobID] = job
+	j.mu.Unlock()
+	j.app.Info("wait %s for %s requested", jobID, tcpw.Plural(len(endpoints), "endpoint"))
+
+	go func() {
+		defer job.cancel()
+		_, err := waiter.Wait(ctx)
+		job.update(func(s *JobStatus) {
+			s.Status = Status(err)
+			if errors.Is(err, context.Canceled) {
+				s.Status = "canceled"
+			}
+			if err != nil {
+				s.Error = err.Error()
+			}
+			s.DurationMs = time.Since(job.start).Milliseconds()
+		})
+		close(job.done)
+		j.mu.Lock()
+		j.active--
+		j.mu.Unlock()
+		j.app.Info("wait %s is over: %s", jobID, Status(err))
+		time.AfterFunc(jobRetention, func() {
+			j.mu.Lock()
+			defer j.mu.Unlock()
+			delete(j.jobs, jobID)
+		})
+	}()
+	return job, nil
+}
+
+// Get returns the requested wait.
+func (j *WaitJobs) Get(id string) (*WaitJob, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	job, ok := j.jobs[id]
+	if !ok {
+		return nil, ErrUnknownJob
+	}
+	return job, nil
+}
+
+// Cancel stops the requested wait and returns once it is over, with 'canceled' status unless it was over already.
+func (j *WaitJobs) Cancel(id string) (*WaitJob, error) {
+	job, err := j.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	job.cancel()
+	<-job.done
+	return job, nil
+}
@@ -0,0 +1,124 @@
+//go:build !minimal
+
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// k8sServiceAccountDir is where the token and the CA certificate of the pod's service account are mounted.
+var k8sServiceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+
+func init() {
+	discoverers["k8s"] = Discoverer{
+		Parse: func(name string) error {
+			_, _, _, err := ParseK8sService(name)
+			return err
+		},
+		Discover: DiscoverK8s,
+	}
+}
+
+type endpointSliceList struct {
+	Items []struct {
+		Endpoints []struct {
+			Addresses  []string
+			Conditions struct {
+				Ready *bool
+			}
+		}
+		Ports []struct {
+			Name string
+			Port int
+		}
+	}
+}
+
+// ParseK8sService parses the service in the form 'NAMESPACE/NAME[:PORT]', where PORT is a name or a number.
+func ParseK8sService(service string) (namespace, name, port string, err error) {
+	namespace, name, ok := strings.Cut(service, "/")
+	name, port, _ = strings.Cut(name, ":")
+	if !ok || namespace == "" || name == "" {
+		return "", "", "", errors.New("service expected in the form 'namespace/name[:port]', e.g. 'k8s://default/db'")
+	}
+	return namespace, name, port, nil
+}
+
+// DiscoverK8s returns ready addresses published in EndpointSlices of the Kubernetes service, see ParseK8sService.
+// The first port of the service is used, unless a port is given. The API server is queried with in-cluster credentials.
+func DiscoverK8s(ctx context.Context, service string) ([]string, error) {
+	namespace, name, port, err := ParseK8sService(service)
+	if err != nil {
+		return nil, err
+	}
+	host, apiPort := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || apiPort == "" {
+		return nil, errors.New("not running in a Kubernetes cluster: KUBERNETES_SERVICE_HOST is not set")
+	}
+	token, err := os.ReadFile(filepath.Join(k8sServiceAccountDir, "token"))
+	if err != nil {
+		return nil, err
+	}
+	ca, err := os.ReadFile(filepath.Join(k8sServiceAccountDir, "ca.crt"))
+	if err != nil {
+		return nil, err
+	}
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(ca) {
+		return nil, errors.New("invalid CA certificate of the service account")
+	}
+	u := fmt.Sprintf("https://%s/apis/discovery.k8s.io/v1/namespaces/%s/endpointslices?labelSelector=%s",
+		net.JoinHostPort(host, apiPort), url.PathEscape(namespace), url.QueryEscape("kubernetes.io/service-name="+name))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	client := http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots}}}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("kubernetes API returned %s", resp.Status)
+	}
+	var list endpointSliceList
+	if err = json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		return nil, fmt.Errorf("invalid kubernetes API response: %w", err)
+	}
+	var addrs []string
+	for _, slice := range list.Items {
+		number := 0
+		for i, p := range slice.Ports {
+			if (port == "" && i == 0) || p.Name == port || strconv.Itoa(p.Port) == port {
+				number = p.Port
+				break
+			}
+		}
+		if number == 0 {
+			continue
+		}
+		for _, e := range slice.Endpoints {
+			if e.Conditions.Ready != nil && !*e.Conditions.Ready {
+				continue
+			}
+			for _, addr := range e.Addresses {
+				addrs = append(addrs, net.JoinHostPort(addr, strconv.Itoa(number)))
+			}
+		}
+	}
+	return addrs, nil
+}
@@ -0,0 +1,71 @@
+//go:build !minimal
+
+package main
+
+import (
+	"context"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDiscoverK8s(t *testing.T) {
+	up, down := startListener(""), getFreeTCPAddr()
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/apis/discovery.k8s.io/v1/namespaces/prod/endpointslices" ||
+			r.URL.Query().Get("labelSelector") != "kubernetes.io/service-name=db" || r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		fmt.Fprintf(w, `{"items": [{"endpoints": [{"addresses": ["127.0.0.1"], "conditions": {"ready": true}}, `+
+			`{"addresses": ["10.0.0.1"], "conditions": {"ready": false}}], "ports": [{"name": "metrics", "port": 9187}, {"name": "sql", "port": %d}]}, `+
+			`{"endpoints": [{"addresses": ["127.0.0.1"]}], "ports": [{"name": "sql", "port": %d}]}]}`, up.Port, down.Port)
+	}))
+	srv.Config.ErrorLog = log.New(io.Discard, "", 0)
+	srv.StartTLS()
+	defer srv.Close()
+
+	dir := t.TempDir()
+	ca := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	if err := os.WriteFile(filepath.Join(dir, "ca.crt"), ca, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "token"), []byte("token\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	accountDir := k8sServiceAccountDir
+	k8sServiceAccountDir = dir
+	defer func() {
+		k8sServiceAccountDir = accountDir
+	}()
+	host, port, _ := net.SplitHostPort(srv.Listener.Addr().String())
+	t.Setenv("KUBERNETES_SERVICE_HOST", host)
+	t.Setenv("KUBERNETES_SERVICE_PORT", port)
+
+	addrs, err := DiscoverK8s(context.Background(), "prod/db:sql")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if strings.Join(addrs, " ") != up.String()+" "+down.String() {
+		t.Fatalf("Wrong addresses: %v", addrs)
+	}
+
+	app := newApp()
+	if err = app.Parse("tcpw", []string{"-k8s", "prod/db:sql", "-require", "2"}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err = app.Dial(context.Background(), net.Dialer{}, app.endpoints[0]); err == nil || !strings.HasPrefix(err.Error(), "1 of 2 instances available, 2 required") {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err = DiscoverK8s(context.Background(), "prod/cache"); err == nil || err.Error() != "kubernetes API returned 404 Not Found" {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+}
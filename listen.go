@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// TestServer is a server of 'listen' subcommand, to try tcpw invocations and wait logic against.
+type TestServer struct {
+	Delay           time.Duration // before listening, like a service which is slow to start
+	AcceptThenClose bool          // close connections right after accepting them and writing the banner
+	Banner          string        // written to every connection once accepted
+	Flap            time.Duration // stop and start listening again every duration, if set
+}
+
+// RunListen runs 'listen' subcommand: test servers on the addresses given as arguments until interrupted.
+func (app App) RunListen(name string, args []string) error {
+	fs := flag.NewFlagSet(name+" listen", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	var s TestServer
+	fs.DurationVar(&s.Delay, "delay", 0, "Time to wait before listening in format N{ns,ms,s,m,h} (default 0)")
+	fs.BoolVar(&s.AcceptThenClose, "accept-then-close", false, "Close connections right after accepting them, and writing '-banner', if any")
+	fs.StringVar(&s.Banner, "banner", "", "Text to write to every connection once accepted. Escape sequences like '\\r\\n' are interpreted")
+	fs.DurationVar(&s.Flap, "flap", 0, "Stop listening for the duration, then listen for the duration again, and so on, in format N{ns,ms,s,m,h}. Zero to listen all the time (default 0)")
+	fs.Var(&app.logLevel, "log-level", "Minimal level of log lines: 'debug' to log accepted connections, 'info' or 'quiet' (default info)")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s listen [-delay duration] [-accept-then-close] [-banner text] [-flap duration] [-log-level level] [host]:port ...\n\n", name)
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		return errors.New("no address to listen on provided")
+	}
+	if banner, err := strconv.Unquote(`"` + s.Banner + `"`); err == nil {
+		s.Banner = banner
+	}
+	interrupts, stop := Interrupts()
+	defer stop()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-interrupts:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	var wg sync.WaitGroup
+	errs := make([]error, fs.NArg())
+	for i, addr := range fs.Args() {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if errs[i] = s.Serve(ctx, app, addr); errs[i] != nil {
+				cancel()
+			}
+		}()
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// Serve listens on the address after the delay and serves connections until ctx is done.
+func (s TestServer) Serve(ctx context.Context, app App, addr string) error {
+	timer := time.NewTimer(s.Delay)
+	defer timer.Stop()
+	for {
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			return nil
+		}
+		l, err := net.Listen("tcp", addr)
+		if err != nil {
+			return err
+		}
+		app.Info("listening on %s", l.Addr())
+		serveCtx, cancel := ctx, context.CancelFunc(func() {})
+		if s.Flap > 0 {
+			serveCtx, cancel = context.WithTimeout(ctx, s.Flap)
+		}
+		s.serve(serveCtx, app, l)
+		cancel()
+		app.Info("stopped listening on %s", l.Addr())
+		if s.Flap == 0 {
+			return nil
+		}
+		timer.Reset(s.Flap)
+	}
+}
+
+// serve accepts connections of the listener until ctx is done, then closes it and the connections.
+func (s TestServer) serve(ctx context.Context, app App, l net.Listener) {
+	stop := context.AfterFunc(ctx, func() {
+		_ = l.Close()
+	})
+	defer stop()
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			_ = l.Close()
+			return
+		}
+		app.Debug("accepted %s on %s", conn.RemoteAddr(), l.Addr())
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer conn.Close()
+			if s.Banner != "" {
+				if _, err := io.WriteString(conn, s.Banner); err != nil {
+					return
+				}
+			}
+			if s.AcceptThenClose {
+				return
+			}
+			stop := context.AfterFunc(ctx, func() {
+				_ = conn.Close()
+			})
+			defer stop()
+			_, _ = io.Copy(io.Discard, conn)
+		}()
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestListen(t *testing.T) {
+	serveTest := func(t *testing.T, s TestServer) string {
+		addr := getFreeTCPAddr().String()
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan error)
+		go func() {
+			done <- s.Serve(ctx, newApp(), addr)
+		}()
+		t.Cleanup(func() {
+			cancel()
+			if err := <-done; err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			}
+		})
+		return addr
+	}
+	dial := func(addr string) (net.Conn, error) {
+		var (
+			conn net.Conn
+			err  error
+		)
+		for range 100 {
+			if conn, err = net.Dial("tcp", addr); err == nil {
+				break
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		return conn, err
+	}
+
+	t.Run("Test banner", func(t *testing.T) {
+		addr := serveTest(t, TestServer{Banner: "+OK\r\n", AcceptThenClose: true})
+		conn, err := dial(addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		if b, err := io.ReadAll(conn); err != nil || string(b) != "+OK\r\n" {
+			t.Fatalf("Unexpected banner: %q, %v", b, err)
+		}
+	})
+
+	t.Run("Test delay", func(t *testing.T) {
+		start := time.Now()
+		addr := serveTest(t, TestServer{Delay: 200 * time.Millisecond})
+		conn, err := dial(addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		if elapsed := time.Since(start); elapsed < 200*time.Millisecond {
+			t.Fatalf("Listening too early: %s", elapsed)
+		}
+		// the connection is kept open
+		_ = conn.SetReadDeadline(time.Now().Add(50 * time.Millisecond))
+		if _, err := conn.Read(make([]byte, 1)); !errors.Is(err, os.ErrDeadlineExceeded) {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	})
+
+	t.Run("Test flap", func(t *testing.T) {
+		addr := serveTest(t, TestServer{Flap: 100 * time.Millisecond})
+		var up, down bool
+		for deadline := time.Now().Add(time.Second); time.Now().Before(deadline) && !(up && down); time.Sleep(10 * time.Millisecond) {
+			if conn, err := net.Dial("tcp", addr); err == nil {
+				conn.Close()
+				up = true
+			} else if up {
+				down = true
+			}
+		}
+		if !up || !down {
+			t.Fatalf("Unexpected listening: up %v, down %v", up, down)
+		}
+	})
+
+	t.Run("Test no address", func(t *testing.T) {
+		if err := newApp().RunListen("tcpw", []string{"-delay", "1s"}); err == nil || err.Error() != "no address to listen on provided" {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	})
+}
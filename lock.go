@@ -0,0 +1,68 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrLocked is returned when '-lock' file is locked by another run of tcpw.
+var ErrLocked = errors.New("locked by another run")
+
+// Lock takes an exclusive lock of the file, creating it if needed, and writes the PID of tcpw to it.
+// If the file is locked by another process, it fails with ErrLocked, or with wait, retries every interval
+// until it takes the lock or tcpw is interrupted. The lock is released by the returned function or on exit.
+func (app App) Lock(path string, wait bool) (unlock func(), err error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	var interrupts <-chan os.Signal
+	var ticker *time.Ticker
+	for {
+		if err = TryLock(f); err == nil {
+			break
+		}
+		if !errors.Is(err, ErrLocked) {
+			_ = f.Close()
+			return nil, err
+		}
+		holder := "another process"
+		if data, err := os.ReadFile(path); err == nil {
+			if pid, err := strconv.Atoi(strings.TrimSpace(string(data))); err == nil {
+				holder = "PID " + strconv.Itoa(pid)
+			}
+		}
+		if !wait {
+			_ = f.Close()
+			return nil, fmt.Errorf("%w: %s is held by %s", ErrLocked, path, holder)
+		}
+		if ticker == nil {
+			app.Info("waiting for %s held by %s...", path, holder)
+			var stop func()
+			interrupts, stop = Interrupts()
+			defer stop()
+			ticker = time.NewTicker(app.interval)
+			defer ticker.Stop()
+		}
+		select {
+		case <-ticker.C:
+		case sig := <-interrupts:
+			_ = f.Close()
+			return nil, &InterruptError{sig}
+		}
+	}
+	if err = f.Truncate(0); err == nil {
+		_, err = f.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0)
+	}
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	return func() {
+		_ = f.Close()
+	}, nil
+}
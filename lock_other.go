@@ -0,0 +1,12 @@
+//go:build !(linux || darwin || freebsd || netbsd || openbsd || dragonfly)
+
+package main
+
+import (
+	"errors"
+	"os"
+)
+
+func TryLock(*os.File) error {
+	return errors.New("'-lock' is not supported on this platform")
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tcpw.lock")
+	app := newApp()
+	app.interval = 10 * time.Millisecond
+	unlock, err := app.Lock(path, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("Test locked", func(t *testing.T) {
+		_, err := app.Lock(path, false)
+		if !errors.Is(err, ErrLocked) || err.Error() != "locked by another run: "+path+" is held by PID "+strconv.Itoa(os.Getpid()) {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if code := app.ExitCode(err); code != ExitLocked {
+			t.Fatalf("Wrong exit code: %d", code)
+		}
+	})
+
+	t.Run("Test wait (-lock-wait)", func(t *testing.T) {
+		go func() {
+			time.Sleep(100 * time.Millisecond)
+			unlock()
+		}()
+		start := time.Now()
+		unlock, err := app.Lock(path, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer unlock()
+		if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+			t.Fatalf("Lock was taken too early: %v", elapsed)
+		}
+	})
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/jackcvr/tcpw/tcpw"
+	"log"
+	"log/slog"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	// LevelTrace is for internals of connection attempts: DNS, connect and TLS phases (-vv).
+	LevelTrace = slog.LevelDebug - 4
+	// LevelWire is for protocol details: addresses of connections and HTTP headers (-vvv).
+	LevelWire = slog.LevelDebug - 8
+	// LevelQuiet is above any level in use, so nothing is printed.
+	LevelQuiet = slog.LevelError + 4
+)
+
+// LogLevel is the minimal level of printed log lines: 'wire', 'trace', 'debug', 'info', 'warn', 'error' or 'quiet'.
+type LogLevel struct {
+	slog.Level
+}
+
+func (l *LogLevel) String() string {
+	switch {
+	case l.Level >= LevelQuiet:
+		return "quiet"
+	case l.Level <= LevelWire:
+		return "wire"
+	case l.Level <= LevelTrace:
+		return "trace"
+	}
+	return strings.ToLower(l.Level.String())
+}
+
+func (l *LogLevel) Set(value string) error {
+	switch strings.ToLower(value) {
+	case "quiet":
+		l.Level = LevelQuiet
+	case "trace":
+		l.Level = LevelTrace
+	case "wire":
+		l.Level = LevelWire
+	default:
+		return l.Level.UnmarshalText([]byte(value))
+	}
+	return nil
+}
+
+// Logger returns the logger built by SetLogger, or a new one of an App which is not parsed, e.g. in tests.
+func (app App) Logger() *slog.Logger {
+	if app.log != nil {
+		return app.log
+	}
+	return app.NewLogger()
+}
+
+// SetLogger builds the logger returned by Logger, once its options, output or the progress line change,
+// so it is not built again for every line.
+func (app *App) SetLogger() {
+	app.log = app.NewLogger()
+}
+
+// NewLogger returns a logger printing lines in '-log-format' at '-log-level',
+// or passing them to the injected logger, e.g. of a test capturing them.
+func (app App) NewLogger() *slog.Logger {
+	if logger, ok := app.logger.(*slog.Logger); ok {
+		return logger
+	} else if app.logger != nil {
+		return slog.New(loggerHandler{logger: app.logger})
+	}
+	if app.syslog != nil {
+		return slog.New(syslogHandler{level: app.logLevel.Level, w: app.syslog})
+	}
+	if app.logFormat == "json" {
+		return slog.New(slog.NewJSONHandler(app.StdLogger().Writer(), &slog.HandlerOptions{
+			Level: app.logLevel.Level,
+			ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+				if a.Key == slog.LevelKey && len(groups) == 0 {
+					level := LogLevel{a.Value.Any().(slog.Level)}
+					a.Value = slog.StringValue(level.String())
+				}
+				return a
+			},
+		}))
+	}
+	return slog.New(textHandler{level: app.logLevel.Level, color: app.Colored(), progress: app.progress, stderr: app.logFile == nil, out: app.StdLogger()})
+}
+
+// StdLogger returns the logger of text lines: that of '-log-file', if any, or the standard one.
+func (app App) StdLogger() *log.Logger {
+	if app.logFile != nil {
+		return log.New(app.logFile, "", log.Flags())
+	}
+	return log.Default()
+}
+
+// loggerHandler passes log lines to the logger.
+type loggerHandler struct {
+	logger tcpw.Logger
+	attrs  []any
+}
+
+func (h loggerHandler) Enabled(context.Context, slog.Level) bool {
+	return true
+}
+
+func (h loggerHandler) Handle(ctx context.Context, r slog.Record) error {
+	args := slices.Clip(h.attrs)
+	r.Attrs(func(a slog.Attr) bool {
+		args = append(args, a)
+		return true
+	})
+	h.logger.Log(ctx, r.Level, r.Message, args...)
+	return nil
+}
+
+func (h loggerHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	for _, a := range attrs {
+		h.attrs = append(slices.Clip(h.attrs), a)
+	}
+	return h
+}
+
+func (h loggerHandler) WithGroup(string) slog.Handler {
+	return h
+}
+
+// textHandler prints the message followed by the error attribute, if any:
+// errors as is to stderr (unless there is '-log-file'), other levels with the standard logger.
+// With color the 'status' attribute is printed as an aligned colored tag before the message.
+type textHandler struct {
+	level    slog.Level
+	color    bool
+	progress *Progress
+	stderr   bool        // errors are printed as is to stderr, otherwise with out
+	out      *log.Logger // see StdLogger
+}
+
+func (h textHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level
+}
+
+func (h textHandler) Handle(_ context.Context, r slog.Record) error {
+	msg := r.Message
+	var status string
+	r.Attrs(func(a slog.Attr) bool {
+		switch a.Key {
+		case "error":
+			msg += ": " + a.Value.String()
+		case "status":
+			status = a.Value.String()
+		}
+		return true
+	})
+	if h.color {
+		msg = Colorize(r.Level, status, msg)
+	}
+	var err error
+	h.progress.Pause(func() {
+		if r.Level >= slog.LevelError && h.stderr {
+			_, err = fmt.Fprintln(os.Stderr, msg)
+		} else {
+			err = h.out.Output(0, msg)
+		}
+	})
+	return err
+}
+
+func (h textHandler) WithAttrs([]slog.Attr) slog.Handler {
+	return h
+}
+
+func (h textHandler) WithGroup(string) slog.Handler {
+	return h
+}
+
+func (app App) Error(format string, args ...any) {
+	app.Logger().Error(fmt.Sprintf(format, args...))
+}
+
+func (app App) Warn(format string, args ...any) {
+	app.Logger().Warn(fmt.Sprintf(format, args...))
+}
+
+func (app App) Info(format string, args ...any) {
+	app.Logger().Info(fmt.Sprintf(format, args...))
+}
+
+func (app App) Debug(format string, args ...any) {
+	app.Logger().Debug(fmt.Sprintf(format, args...))
+}
+
+func (app App) Trace(format string, args ...any) {
+	app.Logger().Log(context.Background(), LevelTrace, fmt.Sprintf(format, args...))
+}
+
+func (app App) Wire(format string, args ...any) {
+	app.Logger().Log(context.Background(), LevelWire, fmt.Sprintf(format, args...))
+}
+
+// Print prints text, e.g. usage, as is unless '-q' is set. It is an info line of the injected logger, if any.
+func (app App) Print(format string, args ...any) {
+	if app.logger != nil {
+		app.logger.Log(context.Background(), slog.LevelInfo, fmt.Sprintf(format, args...))
+	} else if app.logLevel.Level < LevelQuiet {
+		fmt.Fprintf(os.Stderr, format+"\n", args...)
+	}
+}
+
+// Latency converts d to milliseconds with a fraction.
+func Latency(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"log/slog"
+	"net"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestAppLog(t *testing.T) {
+	t.Run("Test JSON log format (-log-format json)", func(t *testing.T) {
+		var buf bytes.Buffer
+		log.SetOutput(&buf)
+		defer log.SetOutput(os.Stderr)
+		app := newApp()
+		app.logLevel.Level = slog.LevelDebug
+		app.logFormat = "json"
+		addr := startListener("").String()
+		if r := app.Await(context.Background(), net.Dialer{}, addr); r.Err != nil {
+			t.Fatal(r.Err)
+		}
+		var entries []map[string]any
+		for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+			var e map[string]any
+			if err := json.Unmarshal([]byte(line), &e); err != nil {
+				t.Fatalf("Invalid JSON line %q: %v", line, err)
+			}
+			entries = append(entries, e)
+		}
+		var info map[string]any
+		for _, e := range entries {
+			if e["level"] == "info" {
+				info = e
+			}
+		}
+		if info == nil || info["endpoint"] != addr || info["attempt"] != 1.0 || info["time"] == nil {
+			t.Fatalf("Unexpected entries: %v", entries)
+		}
+	})
+
+	t.Run("Test lines below the level are filtered out (-log-level)", func(t *testing.T) {
+		var buf bytes.Buffer
+		log.SetOutput(&buf)
+		defer log.SetOutput(os.Stderr)
+		app := newApp()
+		app.logLevel.Level = slog.LevelWarn
+		app.Info("hidden")
+		app.Warn("shown")
+		if out := buf.String(); strings.Contains(out, "hidden") || !strings.Contains(out, "shown") {
+			t.Fatalf("Unexpected output: %s", out)
+		}
+	})
+
+	t.Run("Test injected logger", func(t *testing.T) {
+		var buf bytes.Buffer
+		log.SetOutput(&buf)
+		defer log.SetOutput(os.Stderr)
+		var logged recordLogger
+		app := newApp()
+		app.logger = &logged
+		addr := startListener("").String()
+		if r := app.Await(context.Background(), net.Dialer{}, addr); r.Err != nil {
+			t.Fatal(r.Err)
+		}
+		app.Print("summary")
+		if buf.Len() > 0 {
+			t.Fatalf("Unexpected output: %s", buf.String())
+		}
+		connected := slices.IndexFunc(logged, func(line string) bool { return strings.HasPrefix(line, "INFO successfully connected to "+addr) })
+		if connected < 0 || !strings.Contains(logged[connected], "endpoint="+addr) || logged[len(logged)-1] != "INFO summary" {
+			t.Fatalf("Unexpected lines: %q", logged)
+		}
+	})
+
+	t.Run("Test logger is built once by Parse", func(t *testing.T) {
+		app := newApp()
+		if err := app.Parse("tcpw", []string{"-q", "-a", "localhost:5432"}); err != nil {
+			t.Fatal(err)
+		}
+		if logger := app.Logger(); logger != app.Logger() || logger.Enabled(context.Background(), slog.LevelError) {
+			t.Fatal("Unexpected logger")
+		}
+	})
+}
+
+// recordLogger keeps log lines with their attributes.
+type recordLogger []string
+
+func (l *recordLogger) Log(_ context.Context, level slog.Level, msg string, args ...any) {
+	*l = append(*l, strings.TrimSpace(fmt.Sprintln(append([]any{level, msg}, args...)...)))
+}
+
+func TestLogLevel(t *testing.T) {
+	for value, level := range map[string]slog.Level{
+		"debug": slog.LevelDebug,
+		"INFO":  slog.LevelInfo,
+		"warn":  slog.LevelWarn,
+		"error": slog.LevelError,
+		"quiet": LevelQuiet,
+		"trace": LevelTrace,
+		"Wire":  LevelWire,
+	} {
+		var l LogLevel
+		if err := l.Set(value); err != nil || l.Level != level {
+			t.Fatalf("Wrong level of %q: %v, %v", value, l.Level, err)
+		}
+	}
+	var l LogLevel
+	if err := l.Set("loud"); err == nil {
+		t.Fatal("Unexpected success")
+	}
+}
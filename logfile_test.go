@@ -0,0 +1,64 @@
+package main
+
+import (
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLogFile(t *testing.T) {
+	t.Run("Test rotation by size (-log-max-size)", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "tcpw.log")
+		l, err := OpenLogFile(path, 10, 0, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer l.Close()
+		for _, line := range []string{"first\n", "second\n", "third\n", "fourth\n"} {
+			if _, err = l.Write([]byte(line)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		for name, want := range map[string]string{"tcpw.log": "fourth\n", "tcpw.log.1": "third\n", "tcpw.log.2": "second\n"} {
+			data, err := os.ReadFile(filepath.Join(filepath.Dir(path), name))
+			if err != nil || string(data) != want {
+				t.Fatalf("Wrong content of %s: %q, %v", name, data, err)
+			}
+		}
+		if _, err = os.Stat(path + ".3"); !os.IsNotExist(err) {
+			t.Fatal("Too many backups")
+		}
+	})
+
+	t.Run("Test log lines are written to file (-log-file)", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "tcpw.log")
+		app := newApp()
+		app.logLevel.Level = slog.LevelInfo
+		app.endpoints = []string{badAddr}
+		app.logFilePath = path
+		if err := app.Run(); err == nil {
+			t.Fatal("Unexpected success")
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(data), badAddrError) {
+			t.Fatalf("Unexpected content: %q", data)
+		}
+	})
+}
+
+func TestSize(t *testing.T) {
+	for value, want := range map[string]Size{"512": 512, "512K": 512 << 10, "10M": 10 << 20, "1G": 1 << 30} {
+		var s Size
+		if err := s.Set(value); err != nil || s != want {
+			t.Fatalf("Wrong size of %q: %d, %v", value, s, err)
+		}
+		if s.String() != value {
+			t.Fatalf("Wrong string of %q: %s", value, s.String())
+		}
+	}
+}
@@ -1,28 +1,144 @@
 package main
 
 import (
+	"bufio"
 	"context"
 	"errors"
 	"flag"
 	"fmt"
-	"golang.org/x/sync/errgroup"
+	"github.com/jackcvr/tcpw/internal/errgroup"
+	"github.com/jackcvr/tcpw/tcpw"
+	"io"
 	"log"
+	"log/slog"
+	"maps"
 	"net"
 	"os"
-	"os/exec"
+	"regexp"
+	"runtime"
 	"runtime/debug"
+	"slices"
+	"strconv"
 	"strings"
+	"sync/atomic"
+	"syscall"
+	"text/template"
 	"time"
 )
 
 type App struct {
-	timeout   time.Duration
-	interval  time.Duration
-	quiet     bool
-	verbose   bool
-	endpoints Endpoints
-	on        string
-	command   []string
+	timeout          time.Duration
+	interval         time.Duration
+	logLevel         LogLevel
+	endpoints        Endpoints
+	labels           Labels
+	endpointOptions  map[string]EndpointOptions
+	on               string
+	command          []string
+	shell            string
+	each             bool
+	workdir          string
+	env              Env
+	user             User
+	supervise        bool
+	killOnLoss       bool
+	signal           Signal
+	ready            Ready
+	noStdin          bool
+	cmdLogPath       string
+	cmdLogTime       bool
+	cmdLog           *CmdLog
+	cmdRetries       int
+	cmdRetryInterval time.Duration
+	pre              string
+	post             string
+	onAttempt        string
+	onReady          string
+	onStateChange    string
+	onFinish         string
+	checkCmd         string
+	wasmCheck        string
+	webhook          string
+	webhookMethod    string
+	webhookBody      string
+	exitSuccess      int
+	exitTimeout      int
+	exitRefused      int
+	timeoutCompat    bool
+	scan             bool
+	dialTimeout      time.Duration
+	concurrency      int
+	dryRun           bool
+	runs             Strings
+	cmdTimeout       time.Duration
+	grace            time.Duration
+	reap             bool
+	logFormat        string
+	eventsPath       string
+	report           string
+	csvPath          string
+	csvLog           *CSVLog
+	forwards         Forwards
+	recordPath       string
+	recorder         *Recorder
+	replayPath       string
+	replay           *Replay
+	format           string
+	formatter        *Formatter
+	color            string
+	noProgress       bool
+	progress         *Progress
+	stats            bool
+	count            int
+	statsdAddr       string
+	statsd           *StatsD
+	otelEndpoint     string
+	otel             *OTel
+	sdNotify         bool
+	syslogAddr       SyslogAddr
+	syslogFacility   string
+	syslog           SyslogWriter
+	logFilePath      string
+	logMaxSize       Size
+	logMaxAge        time.Duration
+	logMaxBackups    int
+	logFile          *LogFile
+	logger           tcpw.Logger  // receives log lines instead of handlers of '-log-format', if set
+	log              *slog.Logger // see SetLogger
+	statusListen     string
+	grpcListen       string
+	agent            string
+	agentToken       string
+	apiToken         string // of '-grpc' and '-http' APIs of 'serve'
+	agentListen      string // of 'agent' subcommand
+	httpListen       string
+	tracker          *Tracker
+	summary          bool
+	notify           Strings
+	notifyBody       string
+	statePath        string
+	stateMaxSize     Size
+	stateMaxBackups  int
+	state            *StateStore
+	resumePath       string
+	resume           *Resume
+	tui              bool
+	serve            bool
+	compose          *Compose
+	hosts            Hosts
+	plugins          Plugins
+	pidFile          string
+	daemon           bool
+	lockPath         string
+	lockWait         bool
+	reloadPath       string // '-config' file to re-read endpoints from on SIGHUP
+	require          int
+	when             string
+	condition        *Condition // of '-when', set by Run
+	expectReplicas   int
+	events           *Events
+	executed         *atomic.Bool // whether a command was started, set by Parse, see ExitCode
+	usage            func()
 }
 
 type Endpoints []string
@@ -31,149 +147,1392 @@ func (ep *Endpoints) String() string {
 	return strings.Join(*ep, ", ")
 }
 
+// Set adds an endpoint in the form 'host:port' or URL with 'tcp', 'udp', 'tls', 'unix' or HTTP(S) scheme, see ParseEndpoint.
 func (ep *Endpoints) Set(value string) error {
-	addr, err := net.ResolveTCPAddr("tcp", value)
+	addr, err := ParseEndpoint(value)
 	if err != nil {
 		return err
 	}
-	*ep = append(*ep, addr.String())
+	*ep = append(*ep, addr)
 	return nil
 }
 
-func (app App) Error(format string, args ...any) {
-	if !app.quiet {
-		fmt.Fprintf(os.Stderr, format+"\n", args...)
+// Labels maps endpoints to their labels given with '-a LABEL=ENDPOINT'.
+type Labels map[string]string
+
+var labelRe = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// EndpointOptions are options of an endpoint given after it, e.g. 'db:5432 timeout=5s interval=500ms',
+// or in its table of '-config' file, which override those of the wait.
+type EndpointOptions struct {
+	Timeout  time.Duration // of the wait for the endpoint, which is over after '-t' anyway
+	Interval time.Duration // between connection attempts to the endpoint, instead of '-i'
+	Status   []int         // expected statuses of responses of an HTTP endpoint, instead of any 2xx one
+}
+
+// endpointOptionKeys are keys of options of endpoints, see ParseEndpointOptions.
+var endpointOptionKeys = []string{"label", "timeout", "interval", "status"}
+
+// ParseEndpointOptions parses options of the endpoint in the form 'key=value': its label and EndpointOptions,
+// e.g. 'label=api', 'timeout=30s', 'interval=2s' and 'status=200,204' of an HTTP endpoint.
+func ParseEndpointOptions(addr string, options []string) (label string, opts EndpointOptions, err error) {
+	for _, option := range options {
+		key, value, _ := strings.Cut(option, "=")
+		switch key {
+		case "label":
+			if !labelRe.MatchString(value) {
+				return "", opts, fmt.Errorf("invalid label '%s'", value)
+			}
+			label = value
+		case "timeout", "interval":
+			d, err := time.ParseDuration(value)
+			if err != nil || d < 0 {
+				return "", opts, fmt.Errorf("invalid value %q of option '%s', expected a non-negative duration", value, key)
+			}
+			if key == "timeout" {
+				opts.Timeout = d
+			} else {
+				opts.Interval = d
+			}
+		case "status":
+			if !IsHTTP(addr) {
+				return "", opts, fmt.Errorf("option 'status' of %s, which is not an HTTP endpoint", addr)
+			}
+			for _, s := range strings.Split(value, ",") {
+				code, err := strconv.Atoi(s)
+				if err != nil || code < 100 || code > 599 {
+					return "", opts, fmt.Errorf("invalid value %q of option 'status', expected HTTP status codes", value)
+				}
+				opts.Status = append(opts.Status, code)
+			}
+		default:
+			return "", opts, fmt.Errorf("invalid option '%s', expected %s", option, tcpw.QuoteList(endpointOptionKeys))
+		}
 	}
+	return label, opts, nil
+}
+
+// LabeledEndpoints is the value of '-a' flag: an endpoint optionally prefixed with a label, e.g. 'db=10.3.2.1:5432',
+// and followed by options separated by spaces, see ParseEndpointOptions.
+type LabeledEndpoints struct {
+	*Endpoints
+	labels  Labels
+	hosts   Hosts
+	options map[string]EndpointOptions
 }
 
-func (app App) Info(format string, args ...any) {
-	if !app.quiet {
-		log.Printf(format, args...)
+func (le LabeledEndpoints) String() string {
+	if le.Endpoints == nil {
+		return ""
 	}
+	return le.Endpoints.String()
 }
 
-func (app App) Debug(format string, args ...any) {
-	if !app.quiet && app.verbose {
-		log.Printf(format, args...)
+// Set adds endpoints separated by commas, or reads them from stdin if the value is '-'.
+// Environment variables are expanded, see ExpandVars.
+func (le LabeledEndpoints) Set(value string) error {
+	if value == "-" {
+		return le.Load(os.Stdin)
+	}
+	value, err := ExpandVars(value)
+	if err != nil {
+		return err
+	}
+	for _, endpoint := range SplitEndpoints(value) {
+		if err := le.add(endpoint); err != nil {
+			return err
+		}
 	}
+	return nil
+}
+
+func (le LabeledEndpoints) add(value string) error {
+	value, options, _ := strings.Cut(strings.TrimSpace(value), " ")
+	label, endpoint, ok := strings.Cut(value, "=")
+	if !ok || !labelRe.MatchString(label) {
+		label, endpoint = "", value
+	}
+	if err := le.Endpoints.Set(le.hosts.ReplaceEndpoint(endpoint)); err != nil {
+		return err
+	}
+	addr := (*le.Endpoints)[len(*le.Endpoints)-1]
+	optionLabel, opts, err := ParseEndpointOptions(addr, strings.Fields(options))
+	if err != nil {
+		return err
+	}
+	if optionLabel != "" {
+		label = optionLabel
+	}
+	if label != "" {
+		le.labels[addr] = label
+	}
+	if opts.Timeout > 0 || opts.Interval > 0 || opts.Status != nil {
+		le.options[addr] = opts
+	}
+	return nil
+}
+
+// SplitEndpoints splits a comma separated list of endpoints, e.g. 'db:5432,redis:6379'.
+// A part without ':' continues the previous one, so commas in URL queries are kept.
+func SplitEndpoints(value string) []string {
+	var endpoints []string
+	for _, part := range strings.Split(value, ",") {
+		if n := len(endpoints); n > 0 && !strings.Contains(part, ":") {
+			endpoints[n-1] += "," + part
+			continue
+		}
+		endpoints = append(endpoints, part)
+	}
+	return endpoints
+}
+
+// Load adds endpoints read from r, one per line, optionally followed by options, e.g. 'label=LABEL', see ParseEndpointOptions.
+// Environment variables are expanded, see ExpandVars.
+// Empty lines and '#' comments are skipped.
+func (le LabeledEndpoints) Load(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		line, err := ExpandVars(line)
+		if err != nil {
+			return fmt.Errorf("line %d: %w", n, err)
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if err := le.add(strings.Join(fields, " ")); err != nil {
+			return fmt.Errorf("line %d: %w", n, err)
+		}
+	}
+	return scanner.Err()
+}
+
+// EndpointsFile is the value of '-A' flag: a file with endpoints, see LabeledEndpoints.Load.
+type EndpointsFile LabeledEndpoints
+
+func (ef EndpointsFile) String() string {
+	return ""
+}
+
+func (ef EndpointsFile) Set(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err = LabeledEndpoints(ef).Load(f); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
+
+// Label returns the label of the endpoint, or the endpoint itself if it has no label.
+func (app App) Label(addr string) string {
+	if label, ok := app.labels[addr]; ok {
+		return label
+	}
+	return addr
+}
+
+// Labels returns labels of all endpoints, see Label.
+func (app App) Labels() []string {
+	labels := make([]string, len(app.endpoints))
+	for i, addr := range app.endpoints {
+		labels[i] = app.Label(addr)
+	}
+	return labels
 }
 
 func (app App) Check() error {
-	if len(app.endpoints) == 0 {
+	if err := app.CheckBuild(); err != nil {
+		return err
+	}
+	if len(app.endpoints) == 0 && app.grpcListen == "" && app.httpListen == "" && app.agentListen == "" {
 		return errors.New("no endpoints provided")
 	}
+	if app.logFormat != "text" && app.logFormat != "json" {
+		return errors.New("only 'text' or 'json' are allowed for '-log-format' argument")
+	}
+	if app.format != "" {
+		if _, err := NewFormatter(app.format); err != nil {
+			return err
+		}
+	}
+	if app.checkCmd != "" {
+		if _, err := template.New("check-cmd").Parse(app.checkCmd); err != nil {
+			return err
+		}
+	}
+	if app.agent != "" && (app.checkCmd != "" || app.wasmCheck != "") {
+		return errors.New("'-agent' can not be combined with '-check-cmd' or '-wasm-check'")
+	}
+	if app.agentListen != "" && (len(app.endpoints) > 0 || app.Commands() != nil) {
+		return errors.New("'agent' can not be combined with endpoints or a command")
+	}
+	if app.agentListen != "" && (app.checkCmd != "" || app.wasmCheck != "") {
+		return errors.New("'agent' can not be combined with '-check-cmd' or '-wasm-check', which would run them for endpoints of remote clients")
+	}
+	if app.agentListen != "" && app.agentToken == "" && !IsLoopback(app.agentListen) {
+		return fmt.Errorf("'agent' listening on %s beyond localhost requires '-agent-token'", app.agentListen)
+	}
+	if app.wasmCheck != "" {
+		if app.checkCmd != "" {
+			return errors.New("'-wasm-check' can not be combined with '-check-cmd'")
+		}
+		if _, err := LoadWasm(app.wasmCheck); err != nil {
+			return err
+		}
+	}
+	if app.report != "" {
+		if _, _, err := ParseReport(app.report); err != nil {
+			return err
+		}
+	}
+	if app.color != "" && app.color != "auto" && app.color != "always" && app.color != "never" {
+		return errors.New("only 'auto', 'always' or 'never' are allowed for '-color' argument")
+	}
+	if app.count < 0 {
+		return errors.New("'-count' can not be negative")
+	}
+	if app.serve && app.statusListen == "" && app.grpcListen == "" && app.httpListen == "" {
+		return errors.New("'serve' requires '-status-listen', '-grpc' or '-http'")
+	}
+	if app.grpcListen != "" && !app.serve {
+		return errors.New("'-grpc' requires 'serve' subcommand")
+	}
+	if app.httpListen != "" && !app.serve {
+		return errors.New("'-http' requires 'serve' subcommand")
+	}
+	if (app.grpcListen != "" || app.httpListen != "") && app.apiToken == "" {
+		return errors.New("'-grpc' and '-http' require '-api-token'")
+	}
+	if app.serve && app.Commands() != nil {
+		return errors.New("'serve' can not be combined with a command")
+	}
+	if app.stats && app.Commands() != nil {
+		return errors.New("'-stats' can not be combined with a command")
+	}
+	if app.count > 0 && !app.stats {
+		return errors.New("'-count' requires '-stats'")
+	}
 	if app.on != "s" && app.on != "f" && app.on != "any" {
 		return errors.New("only 's' or 'f' of 'any' are allowed for '-on' argument")
 	}
+	if app.shell != "" && len(app.command) > 0 {
+		return errors.New("'-c' can not be combined with a command")
+	}
+	if (app.supervise || app.killOnLoss) && app.each {
+		return errors.New("'-supervise' and '-kill-on-loss' can not be combined with '-each'")
+	}
+	if len(app.forwards) > 0 {
+		if app.Commands() == nil || app.each {
+			return errors.New("'-forward' requires a command and can not be combined with '-each'")
+		}
+		if err := app.CheckForwards(); err != nil {
+			return err
+		}
+	}
+	if app.ready.value != "" && (app.supervise || app.killOnLoss || app.each) {
+		return errors.New("'-ready' can not be combined with '-supervise', '-kill-on-loss' or '-each'")
+	}
+	if app.ready.value != "" && app.Args() == nil {
+		return errors.New("'-ready' requires a command")
+	}
+	if app.cmdRetries < 0 {
+		return errors.New("'-cmd-retries' must not be negative")
+	}
+	if app.require < 0 || app.expectReplicas < 0 {
+		return errors.New("'-require' and '-expect-replicas' must not be negative")
+	}
+	if app.when != "" {
+		cond, err := ParseCondition(app.when)
+		if err != nil {
+			return err
+		}
+		if err := cond.Check(app.Labels()); err != nil {
+			return err
+		}
+		if app.supervise || app.killOnLoss || app.stats || app.serve || app.tui {
+			return errors.New("'-when' can not be combined with '-supervise', '-kill-on-loss', '-stats', 'serve' or 'watch'")
+		}
+	}
+	if app.concurrency < 0 {
+		return errors.New("'-concurrency' can not be negative")
+	}
+	if app.lockWait && app.lockPath == "" {
+		return errors.New("'-lock-wait' requires '-lock'")
+	}
+	if app.syslogAddr.set && app.logFilePath != "" {
+		return errors.New("'-syslog' can not be combined with '-log-file'")
+	}
+	if app.daemon && app.tui {
+		return errors.New("'-daemon' can not be combined with 'watch', which needs a terminal")
+	}
+	if app.webhook != "" {
+		if _, err := ParseWebhookBody(app.webhookBody); err != nil {
+			return fmt.Errorf("invalid webhook body: %w", err)
+		}
+	}
+	for _, value := range app.notify {
+		if _, err := NotifyURL(value); err != nil {
+			return err
+		}
+		if _, err := app.NotifyBody(value, NotifyData{}); err != nil {
+			return fmt.Errorf("invalid notify body: %w", err)
+		}
+	}
+	if len(app.runs) > 0 && (app.ready.value != "" || app.supervise || app.killOnLoss) {
+		return errors.New("'-run' can not be combined with '-ready', '-supervise' or '-kill-on-loss'")
+	}
 	return nil
 }
 
-func (app App) Run() error {
-	err := app.Connect()
+func (app App) Run() (err error) {
+	if app.dryRun {
+		if err = app.Plan(os.Stdout); err != nil {
+			app.Error("%v", err)
+		}
+		return err
+	}
+	if app.daemon {
+		if !IsDaemon() {
+			pid, err := Daemonize()
+			if err != nil {
+				app.Error("%v", err)
+				return err
+			}
+			if IsSessionLeader() {
+				return nil // which started the daemon, see Daemonize
+			}
+			logTo := app.logFilePath
+			if app.syslogAddr.set {
+				logTo = "syslog"
+			}
+			app.Info("started in the background with PID %d, logging to %s", pid, logTo)
+			return nil
+		}
+		_ = os.Unsetenv(daemonEnv) // not passed to the command
+	}
+	if app.lockPath != "" {
+		unlock, err := app.Lock(app.lockPath, app.lockWait)
+		if err != nil {
+			app.Error("%v", err)
+			return err
+		}
+		defer unlock()
+	}
+	if app.pidFile != "" {
+		remove, err := WritePidFile(app.pidFile)
+		if err != nil {
+			app.Error("%v", err)
+			return err
+		}
+		defer remove()
+	}
+	if app.tui {
+		return app.RunTUI()
+	}
+	if app.cmdLogPath != "" {
+		if app.cmdLog, err = OpenCmdLog(app.cmdLogPath, app.cmdLogTime, func(err error) {
+			app.Error("failed to write command log, dropping the rest of the output: %v", err)
+		}); err != nil {
+			app.Error("%v", err)
+			return err
+		}
+		defer app.cmdLog.Close()
+	}
+	if app.logFilePath != "" {
+		if app.logFile, err = OpenLogFile(app.logFilePath, int64(app.logMaxSize), app.logMaxAge, app.logMaxBackups); err != nil {
+			app.Error("%v", err)
+			return err
+		}
+		defer app.logFile.Close()
+	}
+	if app.syslogAddr.set {
+		if app.syslog, err = OpenSyslog(&app.syslogAddr, app.syslogFacility); err != nil {
+			app.Error("%v", err)
+			return err
+		}
+		defer app.syslog.Close()
+	}
+	app.SetLogger()
+	if app.otelEndpoint != "" {
+		app.otel = NewOTel(app.otelEndpoint)
+		defer func() {
+			if exportErr := app.otel.Export(err); exportErr != nil {
+				app.Error("failed to export telemetry: %v", exportErr)
+			}
+		}()
+	}
+	if app.statsdAddr != "" {
+		if app.statsd, err = OpenStatsD(app.statsdAddr); err != nil {
+			app.Error("%v", err)
+			return err
+		}
+		defer app.statsd.Close()
+	}
+	if app.format != "" {
+		if app.formatter, err = NewFormatter(app.format); err != nil {
+			app.Error("%v", err)
+			return err
+		}
+	}
+	if app.when != "" {
+		if app.condition, err = ParseCondition(app.when); err != nil {
+			app.Error("%v", err)
+			return err
+		}
+	}
+	if app.csvPath != "" {
+		if app.csvLog, err = OpenCSVLog(app.csvPath); err != nil {
+			app.Error("%v", err)
+			return err
+		}
+		defer app.csvLog.Close()
+	}
+	if app.recordPath != "" {
+		if app.recorder, err = CreateRecorder(app.recordPath, app); err != nil {
+			app.Error("%v", err)
+			return err
+		}
+		defer app.recorder.Close()
+	}
+	if app.statePath != "" {
+		if app.state, err = OpenStateStore(app.statePath, int64(app.stateMaxSize), app.stateMaxBackups); err != nil {
+			app.Error("%v", err)
+			return err
+		}
+		defer app.state.Close()
+	}
+	if app.resumePath != "" {
+		if app.resume, err = LoadResume(app.resumePath); err != nil {
+			app.Error("failed to load resume file: %v", err)
+			return err
+		}
+	}
+	if app.eventsPath != "" {
+		if app.events, err = OpenEvents(app.eventsPath); err != nil {
+			app.Error("%v", err)
+			return err
+		}
+		defer app.events.Close()
+	}
+	if app.reap {
+		defer StartReaper()()
+	}
+	if app.pre != "" {
+		if err = app.Hook(app.pre); err != nil {
+			app.Error("pre hook failed: %v", err)
+			return err
+		}
+	}
+	if app.post != "" {
+		defer func() {
+			if err := app.Hook(app.post); err != nil {
+				app.Error("post hook failed: %v", err)
+			}
+		}()
+	}
+	app.tracker = NewTracker(app.Labels())
+	defer app.DumpOnSignal()()
+	if app.statusListen != "" {
+		closeStatus, err := app.ServeStatus()
+		if err != nil {
+			app.Error("%v", err)
+			return err
+		}
+		defer closeStatus()
+	}
+	if app.serve {
+		return app.RunServe()
+	}
+	if app.agentListen != "" {
+		return app.RunAgent()
+	}
+	if app.ready.value != "" {
+		return app.RunReady()
+	}
+	if app.stats {
+		return app.RunStats()
+	}
+	start := time.Now()
+	var results []EndpointResult
+	if app.ShowProgress() {
+		app.progress = NewProgress(os.Stderr, app.Labels(), app.timeout)
+		app.progress.Start()
+		app.SetLogger()
+	}
+	ctx, stop := InterruptContext(context.Background())
+	results = app.Connect(ctx)
+	stop()
+	err = app.WaitErr(results)
+	if intErr := Interrupted(ctx); intErr != nil {
+		// pending endpoints are reported, and the wait continues from here with '-resume'
+		err = intErr
+	} else if err := app.resume.Remove(); err != nil {
+		app.Error("failed to remove resume file: %v", err)
+	}
+	app.progress.Stop()
+	app.progress = nil
+	app.SetLogger()
+	elapsed := time.Since(start)
+	app.Report(results, err)
+	app.FinishHook(results, err, elapsed)
+	app.MeasureWait(err, elapsed)
+	app.otel.Wait(err, elapsed)
+	if err == nil {
+		app.Notify("READY=1\nSTATUS=all endpoints available")
+	}
+	app.FormatResults(results)
+	if app.summary {
+		app.Print("%s", Summary(results))
+	}
+	app.AlertResults(results, err)
+	if app.webhook != "" {
+		if err := app.Webhook(results, time.Since(start), err); err != nil {
+			app.Error("webhook failed: %v", err)
+		}
+	}
+	report := NewReport(results, elapsed, err)
+	if app.Commands() != nil && !app.each && app.ShouldExec(err) {
+		stopForwards := func() {}
+		if err == nil {
+			if stopForwards, err = app.StartForwards(); err != nil {
+				app.Error("%v", err)
+				return err
+			}
+		}
+		if (app.supervise || app.killOnLoss) && err == nil {
+			err = app.Monitor(results, time.Since(start))
+		} else {
+			err = app.Exec(results, time.Since(start))
+		}
+		stopForwards()
+		code := 0
+		if err != nil {
+			code = app.ExitCode(err)
+		}
+		report.ExitCode = &code
+	}
+	if app.report != "" {
+		if err := app.WriteReport(report); err != nil {
+			app.Error("report failed: %v", err)
+		}
+	}
+	return err
+}
+
+// EndpointResult is the outcome of the wait for an endpoint, which drives reports, exit codes and environment of the command.
+type EndpointResult struct {
+	Addr         string
+	Label        string // given with '-a LABEL=ENDPOINT', if any
+	Ready        bool
+	Err          error         // of the last failed connection attempt, if the endpoint is not ready
+	Executed     bool          // whether the command was executed for the endpoint with '-each'
+	CmdErr       error         // of the command executed for the endpoint
+	Duration     time.Duration // since the wait started
+	FirstSuccess time.Time     // zero if the endpoint was available before the wait was interrupted, see '-resume'
+	Attempts     int
+	Latency      time.Duration // of the last connection attempt
+	Phases       Phases        // of the last connection attempt
+}
+
+// Name returns the label of the endpoint, or its address if it has no label.
+func (r EndpointResult) Name() string {
+	if r.Label != "" {
+		return r.Label
+	}
+	return r.Addr
+}
+
+// Report prints the error of Connect, if any, with the last state of every endpoint.
+func (app App) Report(results []EndpointResult, err error) {
 	if err != nil {
+		app.Error("%s", Failure(results, err))
+	}
+}
+
+// Failure returns the message of the error of Connect, which lists the last state of every endpoint
+// if some of them are not available, e.g. 'timeout error: db:5432 is up; cache:6379 timed out after 3 attempts (connection refused)'.
+func Failure(results []EndpointResult, err error) string {
+	if !slices.ContainsFunc(results, func(r EndpointResult) bool { return !r.Ready }) {
+		return err.Error()
+	}
+	msg := err.Error()
+	// the error of an endpoint is listed with it, e.g. not an error of the command in '-each' mode
+	if slices.ContainsFunc(results, func(r EndpointResult) bool { return r.Err != nil && errors.Is(err, r.Err) }) {
+		msg = "connection failed"
 		if errors.Is(err, context.DeadlineExceeded) {
-			app.Error("timeout error")
-		} else {
-			app.Error(err.Error())
+			msg = "timeout error"
 		}
 	}
-	if len(app.command) > 0 && ((app.on == "s" && err == nil) || (app.on == "f" && err != nil) || app.on == "any") {
-		cmd := exec.Command(app.command[0], app.command[1:]...)
-		cmd.Stdout = os.Stdout
-		cmd.Stderr = os.Stderr
-		err = cmd.Run()
+	states := make([]string, len(results))
+	for i, r := range results {
+		states[i] = r.State()
+	}
+	return msg + ": " + strings.Join(states, "; ")
+}
+
+// State describes the last state of the endpoint, e.g. 'db:5432 failed after 1 attempt (no such host)'.
+func (r EndpointResult) State() string {
+	attempts := tcpw.Plural(r.Attempts, "attempt")
+	switch {
+	case r.Ready:
+		return r.Name() + " is up"
+	case errors.Is(r.Err, context.DeadlineExceeded):
+		return fmt.Sprintf("%s timed out after %s (%v)", r.Name(), attempts, Cause(r.Err))
+	case errors.Is(r.Err, context.Canceled):
+		return fmt.Sprintf("%s was still pending after %s (%v)", r.Name(), attempts, Cause(r.Err))
+	default:
+		return fmt.Sprintf("%s failed after %s (%v)", r.Name(), attempts, r.Err)
+	}
+}
+
+// Cause returns the reason of the last failed attempt kept in the error of an interrupted wait.
+func Cause(err error) error {
+	if errs, ok := err.(interface{ Unwrap() []error }); ok && len(errs.Unwrap()) > 1 {
+		return errs.Unwrap()[len(errs.Unwrap())-1]
 	}
 	return err
 }
 
-func (app App) Connect() error {
-	g, ctx := errgroup.WithContext(context.Background())
+// WaitErr returns the error of the wait: that of the endpoint which failed first,
+// which made the wait for the rest of them fail as well, or of its command executed with '-each'.
+func WaitErr(results []EndpointResult) error {
+	var first error
+	var at time.Duration
+	for _, r := range results {
+		if err := r.FinalErr(); err != nil && (first == nil || r.Duration < at) {
+			first, at = err, r.Duration
+		}
+	}
+	return first
+}
+
+// FinalErr returns the error of the command executed for the endpoint with '-each', if it was, or that of the wait for it.
+func (r EndpointResult) FinalErr() error {
+	if r.Executed {
+		return r.CmdErr
+	}
+	return r.Err
+}
+
+// Connect waits for all endpoints concurrently, until they are available or one of them fails, see WaitErr.
+// Connection attempts are made by '-concurrency' workers, see Schedule.
+// With the '-when' condition, it waits until the condition is met or can not be met anymore.
+func (app App) Connect(ctx context.Context) []EndpointResult {
+	g, ctx := errgroup.WithContext(ctx)
 	if app.timeout > 0 {
+		// the rest of the timeout of the interrupted wait with '-resume'
+		timeout := max(app.timeout-app.resume.Spent(), time.Nanosecond)
 		var cancel context.CancelFunc
-		ctx, cancel = context.WithTimeout(ctx, app.timeout)
+		ctx, cancel = context.WithTimeout(ctx, timeout)
 		defer cancel()
 	}
+	defer app.resume.Keep(app.interval)()
 
+	start := time.Now()
+	results := make([]EndpointResult, len(app.endpoints))
+	var available atomic.Int32
+	app.Notify(fmt.Sprintf("STATUS=waiting for %s", tcpw.Plural(len(app.endpoints), "endpoint")))
 	d := net.Dialer{Timeout: app.timeout}
-	for _, addr := range app.endpoints {
-		g.Go(func() error {
-			ticker := time.NewTicker(app.interval)
-			defer ticker.Stop()
-			app.Debug("connecting to %s...", addr)
-			for {
-				res, err := app.TryDial(ctx, d, addr)
-				if err != nil {
-					return err
-				}
-				if res {
-					app.Info("successfully connected to %s", addr)
-					return nil
-				} else {
-					select {
-					case <-ticker.C:
-						break
-					case <-ctx.Done():
-						return ctx.Err()
-					}
-				}
+	if app.dialTimeout > 0 {
+		d.Timeout = app.dialTimeout
+	}
+	var verdict *Verdict
+	if app.condition != nil {
+		verdict = NewVerdict(app.condition, app.Labels())
+	}
+	var tasks []int
+	for i, addr := range app.endpoints {
+		if app.resume.IsDone(addr) {
+			app.Info("%s was available before the wait was interrupted", app.Label(addr))
+			results[i] = EndpointResult{Addr: addr, Label: app.labels[addr], Ready: true}
+			app.progress.Done(app.Label(addr))
+			available.Add(1)
+			if verdict != nil {
+				_ = verdict.Done(app.Label(addr), true)
+			}
+			continue
+		}
+		tasks = append(tasks, i)
+	}
+	if verdict != nil {
+		// the condition may be decided by endpoints done before the wait was interrupted
+		if err := verdict.Decide(); err != nil {
+			g.Go(func() error { return err })
+		}
+	}
+	// done is run once the wait for the endpoint is over, while others may still be waited for
+	done := func(i int) error {
+		addr := app.endpoints[i]
+		err := results[i].Err
+		if err == nil {
+			if err := app.resume.Done(addr); err != nil {
+				app.Error("failed to save progress to resume file: %v", err)
 			}
-		})
+			app.Notify(fmt.Sprintf("STATUS=%d/%d endpoints available", available.Add(1), len(app.endpoints)))
+		}
+		if app.Commands() != nil && app.each && app.ShouldExec(err) && Interrupted(ctx) == nil {
+			results[i].Executed = true
+			results[i].CmdErr = app.Exec(results[i:i+1], results[i].Duration)
+			if verdict == nil || results[i].CmdErr != nil {
+				return results[i].CmdErr
+			}
+		}
+		if verdict != nil {
+			// a failed endpoint fails the wait only if the condition can not be met without it
+			return verdict.Done(app.Label(addr), err == nil)
+		}
+		return err
+	}
+
+	awaiters := make([]*awaiter, len(app.endpoints))
+	over := make([]bool, len(app.endpoints))
+	workers := app.concurrency
+	if workers <= 0 {
+		workers = len(tasks)
+	}
+	interval := func(i int) time.Duration { return app.Interval(app.endpoints[i]) }
+	tcpw.Schedule(ctx, tasks, workers, interval, func(i int) bool {
+		if awaiters[i] == nil {
+			awaiters[i] = app.newAwaiter(app.endpoints[i])
+		}
+		if !awaiters[i].Step(ctx, d) {
+			return true
+		}
+		results[i] = awaiters[i].End()
+		results[i].Duration = time.Since(start)
+		over[i] = true
+		g.Go(func() error { return done(i) })
+		return false
+	})
+	// the wait for the rest of endpoints is over as well
+	for _, i := range tasks {
+		if over[i] {
+			continue
+		}
+		if awaiters[i] == nil {
+			awaiters[i] = app.newAwaiter(app.endpoints[i])
+		}
+		awaiters[i].Expire(ctx)
+		results[i] = awaiters[i].End()
+		results[i].Duration = time.Since(start)
+	}
+
+	// errors are kept in results
+	_ = g.Wait()
+	return results
+}
+
+// Interval returns the interval between connection attempts to the endpoint: its 'interval' option or '-i'.
+func (app App) Interval(addr string) time.Duration {
+	if interval := app.endpointOptions[addr].Interval; interval > 0 {
+		return interval
+	}
+	return app.interval
+}
+
+// Await makes connection attempts to addr until it is available.
+// The result holds the number of attempts made and the latency of the last one.
+func (app App) Await(ctx context.Context, d net.Dialer, addr string) EndpointResult {
+	a := app.newAwaiter(addr)
+	ticker := time.NewTicker(app.Interval(addr))
+	defer ticker.Stop()
+	for !a.Step(ctx, d) {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			a.Expire(ctx)
+			return a.End()
+		}
+	}
+	return a.End()
+}
+
+// awaiter is the wait for an endpoint, which makes a connection attempt per step, see Await.
+type awaiter struct {
+	app     App
+	addr    string
+	name    string
+	span    *Span
+	logger  *slog.Logger
+	start   time.Time
+	end     time.Time // of the wait with 'timeout' option of the endpoint, if any
+	failure tcpw.LastFailure
+	result  EndpointResult
+}
+
+func (app App) newAwaiter(addr string) *awaiter {
+	name := app.Label(addr)
+	a := &awaiter{
+		app:    app,
+		addr:   addr,
+		name:   name,
+		span:   app.otel.Start("wait "+name, "endpoint", name),
+		logger: app.Logger().With("endpoint", name),
+		start:  time.Now(),
+		result: EndpointResult{Addr: addr, Label: app.labels[addr]},
+	}
+	if timeout := app.endpointOptions[addr].Timeout; timeout > 0 {
+		a.end = a.start.Add(timeout)
+	}
+	if name != addr {
+		a.logger.Debug(fmt.Sprintf("connecting to %s (%s)...", name, addr))
+	} else {
+		a.logger.Debug(fmt.Sprintf("connecting to %s...", addr))
+	}
+	app.Emit(Event{Type: EventState, Endpoint: name, State: "waiting"})
+	return a
+}
+
+// Step makes the next connection attempt and reports whether the wait for the endpoint is over:
+// it is available, failed with a fatal error, or with any error in '-scan' mode, or its 'timeout' option has passed.
+func (a *awaiter) Step(ctx context.Context, d net.Dialer) bool {
+	if !a.end.IsZero() {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, a.end)
+		defer cancel()
+		if ctx.Err() != nil {
+			a.Expire(ctx)
+			return true
+		}
+	}
+	app, name, logger := a.app, a.name, a.logger
+	attempt := a.result.Attempts + 1
+	var phases Phases
+	dialStart := time.Now()
+	err := app.Dial(TraceDial(phases.Trace(ctx), logger), d, a.addr)
+	result := EndpointResult{Addr: a.addr, Label: app.labels[a.addr], Err: err, Attempts: attempt, Latency: time.Since(dialStart), Phases: phases}
+	a.result = result
+	attrs := []any{"attempt", attempt, "latency_ms", Latency(result.Latency)}
+	event := Event{Type: EventAttempt, Endpoint: name, Attempt: attempt, LatencyMs: Latency(result.Latency)}
+	if err != nil {
+		event.Error = err.Error()
+	}
+	app.Emit(event)
+	app.Record(event)
+	app.Store(name, err)
+	app.Measure(event)
+	a.span.Event("attempt", "endpoint", name, "attempt", attempt, "latency_ms", event.LatencyMs, "result", AttemptStatus(err))
+	app.progress.Attempt(name)
+	app.Format(FormatData{Type: "attempt", Endpoint: name, Status: AttemptStatus(err), Attempt: attempt, Latency: result.Latency, Error: event.Error})
+	if err == nil {
+		a.result.Ready, a.result.FirstSuccess = true, time.Now()
+		app.progress.Done(name)
+		app.Emit(Event{Type: EventState, Endpoint: name, State: "up"})
+		if app.onReady != "" {
+			err = app.Hook(app.onReady,
+				"TCPW_ENDPOINT="+a.addr,
+				fmt.Sprintf("TCPW_ATTEMPT=%d", attempt),
+				fmt.Sprintf("TCPW_LATENCY_MS=%d", result.Latency.Milliseconds()))
+			if err != nil {
+				app.Error("on-ready hook failed: %v", err)
+			}
+		}
+		logger.Info(fmt.Sprintf("successfully connected to %s in %v after %s", name, result.Latency.Round(time.Microsecond), tcpw.Plural(attempt, "attempt")),
+			append(attrs, "status", StatusUp)...)
+		// no phases are traced by the minimal build
+		if phases != (Phases{}) {
+			logger.Log(ctx, LevelTrace, fmt.Sprintf("connection to %s: %v", name, phases),
+				"dns_ms", Latency(phases.DNS), "connect_ms", Latency(phases.Connect), "tls_ms", Latency(phases.TLS))
+		}
+		return true
 	}
+	status := StatusRetrying
+	if IsFatal(err) || app.scan {
+		status = StatusDown
+	}
+	logger.Debug(fmt.Sprintf("attempt %d to connect to %s failed in %v", attempt, name, result.Latency.Round(time.Microsecond)), append(attrs, "status", status, "error", err)...)
+	if IsFatal(err) {
+		app.Emit(Event{Type: EventState, Endpoint: name, State: "failed", Error: err.Error()})
+		return true
+	}
+	a.failure.Add(ctx, err)
+	if !a.end.IsZero() && !time.Now().Before(a.end) {
+		// the context may notice the deadline a bit later
+		<-ctx.Done()
+		a.Expire(ctx)
+		return true
+	}
+	if app.scan {
+		app.Emit(Event{Type: EventState, Endpoint: name, State: "failed", Error: err.Error()})
+		return true
+	}
+	if app.onAttempt != "" {
+		err = app.Hook(app.onAttempt,
+			"TCPW_ENDPOINT="+a.addr,
+			fmt.Sprintf("TCPW_ATTEMPT=%d", attempt),
+			fmt.Sprintf("TCPW_ELAPSED_MS=%d", time.Since(a.start).Milliseconds()))
+		if err != nil {
+			app.Error("on-attempt hook failed: %v", err)
+		}
+	}
+	return false
+}
+
+// Expire ends the wait for the endpoint, which is not over, once ctx is done.
+func (a *awaiter) Expire(ctx context.Context) {
+	a.result.Err = a.failure.Timeout(ctx)
+	a.app.Emit(Event{Type: EventState, Endpoint: a.name, State: "timeout", Error: a.result.Err.Error()})
+}
 
-	return g.Wait()
+// End returns the result of the wait for the endpoint, which is over.
+func (a *awaiter) End() EndpointResult {
+	a.span.End(a.result.Err)
+	return a.result
 }
 
 func (app App) TryDial(ctx context.Context, d net.Dialer, addr string) (bool, error) {
-	var addrErr *net.AddrError
-	var dnsErr *net.DNSError
-	if conn, err := d.DialContext(ctx, "tcp", addr); err != nil {
-		app.Debug(err.Error())
-		if errors.As(err, &addrErr) || errors.As(err, &dnsErr) {
+	if err := app.Dial(ctx, d, addr); err != nil {
+		if IsFatal(err) {
 			return false, err
 		}
 		return false, nil
-	} else {
-		if err = conn.Close(); err != nil {
-			app.Error(err.Error())
+	}
+	return true, nil
+}
+
+// Dial makes a single connection attempt through the middleware of the command (see Middleware),
+// which is recorded with '-record', or replays the recorded one with '-replay'.
+func (app App) Dial(ctx context.Context, d net.Dialer, addr string) error {
+	if app.replay != nil {
+		return app.DialReplay(ctx, addr)
+	}
+	check := tcpw.Chain(tcpw.CheckerFunc(func(ctx context.Context, e tcpw.Endpoint) (tcpw.Result, error) {
+		return tcpw.Result{}, app.dial(ctx, d, e.Addr)
+	}), app.Middleware()...)
+	start := time.Now()
+	_, err := check.Check(ctx, tcpw.NewEndpoint(addr, app.hosts.Dial(d)))
+	app.RecordAttempt(addr, start, err)
+	return err
+}
+
+// Middleware returns the middleware wrapping every connection attempt, like Options.Middleware of tcpw.Waiter:
+// '-w' limits the whole attempt with tcpw.WithTimeout, e.g. the response of an HTTP endpoint besides the connection.
+func (app App) Middleware() []tcpw.Middleware {
+	var middleware []tcpw.Middleware
+	if app.dialTimeout > 0 {
+		middleware = append(middleware, tcpw.WithTimeout(app.dialTimeout))
+	}
+	return middleware
+}
+
+func (app App) dial(ctx context.Context, d net.Dialer, addr string) error {
+	if app.agent != "" {
+		return app.DialAgent(ctx, addr)
+	}
+	if app.checkCmd != "" {
+		return app.CheckCmd(ctx, addr)
+	}
+	if addrs, ok, err := app.Discover(ctx, addr); ok {
+		if err != nil {
+			return err
 		}
-		return true, nil
+		return app.DialDiscovered(ctx, d, addr, addrs)
+	}
+	dial := app.hosts.Dial(d)
+	if app.wasmCheck != "" {
+		return app.WasmCheck(ctx, dial, addr)
+	}
+	if IsHTTP(addr) {
+		return app.DialHTTP(ctx, dial, addr)
+	}
+	if tcpw.Scheme(addr) == "script" {
+		return app.RunScript(ctx, dial, addr)
+	}
+	return tcpw.Dial(ctx, dial, addr)
+}
+
+// IsLoopback reports whether the address to listen on, in the form 'host:port', is reachable from localhost only.
+func IsLoopback(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil || host == "" {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// IsFatal reports whether the dial error is not worth retrying.
+func IsFatal(err error) bool {
+	var attemptErr *AttemptError
+	if errors.As(err, &attemptErr) {
+		return attemptErr.Fatal
+	}
+	var discoveryErr *DiscoveryError
+	if errors.As(err, &discoveryErr) {
+		return false
 	}
+	return tcpw.IsFatal(err)
+}
+
+func (app *App) Parse(name string, args []string) error {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	fs.DurationVar(&app.timeout, "t", 0, "Timeout in format N{ns,ms,s,m,h}, e.g. '5s' == 5 seconds. Zero for no timeout (default 0)")
+	fs.DurationVar(&app.interval, "i", time.Second, "Interval between retries in format N{ns,ms,s,m,h}")
+	var quiet, silent, verbose, verbose2, verbose3 bool
+	fs.BoolVar(&quiet, "q", false, "Do not print anything, same as '-log-level quiet' (default false)")
+	fs.BoolVar(&silent, "silent", false, "Print only errors, e.g. why the wait failed, same as '-log-level error' (default false)")
+	fs.BoolVar(&verbose, "v", false, "Verbose mode with results of connection attempts, same as '-log-level debug' (default false)")
+	fs.BoolVar(&verbose2, "vv", false, "More verbose mode with DNS, connect and TLS phases of connection attempts, same as '-log-level trace' (default false)")
+	fs.BoolVar(&verbose3, "vvv", false, "Most verbose mode with addresses of connections and HTTP headers, same as '-log-level wire' (default false)")
+	fs.Var(&app.logLevel, "log-level", "Minimal level of log lines: 'wire', 'trace', 'debug', 'info', 'warn', 'error' or 'quiet' (default info)")
+	app.labels = Labels{}
+	app.endpointOptions = map[string]EndpointOptions{}
+	app.executed = new(atomic.Bool)
+	app.hosts = Hosts{}
+	fs.Var(LabeledEndpoints{&app.endpoints, app.labels, app.hosts, app.endpointOptions}, "a", "Endpoint to await, in the form 'host:port' or URL: 'tcp://host:port', 'udp://host:port' (available unless the port is unreachable), "+
+		"'tls://host:port' (with TLS handshake), 'unix:///path' or HTTP(S) URL, which must respond with 2xx status, "+
+		"optionally prefixed with a label used in output instead of it, e.g. 'db=10.3.2.1:5432', and followed by options separated by spaces: "+
+		"'timeout=DURATION' of the wait for it, 'interval=DURATION' between attempts and 'status=CODE[,CODE]' of HTTP responses it must respond with, "+
+		"e.g. 'db=10.3.2.1:5432 timeout=30s'. Several endpoints may be separated by commas, e.g. 'db:5432,redis:6379', "+
+		"or '-' reads endpoints from stdin, one per line")
+	fs.Var(&app.plugins, "plugin", "Go plugin built with 'go build -buildmode=plugin', which registers checkers of its schemes "+
+		"with tcpw.Register in its init functions, so '-a' accepts endpoints of them, e.g. 'redis://cache:6379'. "+
+		"Release binaries are static and can not load plugins, so tcpw must be built with cgo for them. Can be repeated")
+	fs.StringVar(&app.shell, "c", "", "Shell command to execute instead of command args, run with '$SHELL -c' (or 'sh -c')")
+	fs.BoolVar(&app.each, "each", false, "Execute command once per endpoint as soon as it is done, replacing '{}' in arguments except for '-c' and '-run' scripts with the endpoint (default false)")
+	fs.Var(app.hosts, "hosts-file", "File with entries in the format of /etc/hosts, which override DNS for endpoints, "+
+		"e.g. to probe staged addresses behind production host names. Can be repeated")
+	fs.StringVar(&app.workdir, "workdir", "", "Working directory of the command (default: current directory)")
+	fs.Var(&app.env, "env", "Environment variable for the command, in the form 'KEY=VALUE'. Can be repeated")
+	fs.Var(EnvFile{&app.env}, "env-file", "File with environment variables for the command, one 'KEY=VALUE' per line, like '.env' file. "+
+		"Can be repeated")
+	fs.Var(&app.user, "user", "User to execute the command as, in the form 'uid[:gid]' (names are allowed)")
+	fs.BoolVar(&app.supervise, "supervise", false, "Keep monitoring endpoints after the command started and restart it when they recover after a loss (default false)")
+	fs.BoolVar(&app.killOnLoss, "kill-on-loss", false, "Keep monitoring endpoints after the command started and terminate it when any of them becomes unavailable (default false)")
+	app.signal = Signal{syscall.SIGTERM}
+	fs.Var(&app.signal, "signal", "Signal sent to the command to terminate it, e.g. 'TERM', 'INT' or 'KILL'")
+	fs.Var(&app.ready, "ready", "Start the command immediately and perform the action once endpoints are available: 'file:PATH' - touch file, 'signal:NAME' - send signal to the command, URL - send GET request")
+	fs.BoolVar(&app.noStdin, "no-stdin", false, "Do not pass stdin to the command (default false)")
+	fs.StringVar(&app.cmdLogPath, "cmd-log", "", "File to append a copy of the command output to. "+
+		"The output of the command is piped then, so it is not a terminal, e.g. without colors or progress bars")
+	fs.BoolVar(&app.cmdLogTime, "cmd-log-timestamps", false, "Prefix every line of the '-cmd-log' file with a timestamp (default false)")
+	fs.IntVar(&app.cmdRetries, "cmd-retries", 0, "Number of times to retry the command if it fails (default 0)")
+	fs.DurationVar(&app.cmdRetryInterval, "cmd-retry-interval", time.Second, "Interval between command retries in format N{ns,ms,s,m,h}")
+	fs.StringVar(&app.pre, "pre", "", "Shell command to execute before probing starts. If it fails, tcpw exits with its exit code")
+	fs.StringVar(&app.post, "post", "", "Shell command to execute when everything else is done, e.g. to clean up after '-pre'")
+	fs.StringVar(&app.onAttempt, "on-attempt", "", "Shell command to execute after every failed connection attempt, "+
+		"with TCPW_ENDPOINT, TCPW_ATTEMPT and TCPW_ELAPSED_MS environment variables")
+	fs.StringVar(&app.checkCmd, "check-cmd", "", "Shell command to execute instead of connecting to every endpoint, which is available if it exits with zero, "+
+		"with {{.Addr}}, {{.Host}}, {{.Port}} and {{.Label}} placeholders of the endpoint, e.g. 'pg_isready -h {{.Host}} -p {{.Port}}'")
+	fs.StringVar(&app.wasmCheck, "wasm-check", "", "WebAssembly module to run instead of connecting to every endpoint, which is available once "+
+		"the function 'check' exported by the module returns zero. The module is sandboxed: it connects only to the endpoint, with functions imported from 'tcpw': "+
+		"endpoint(ptr, cap), dial(), read(conn, ptr, len), write(conn, ptr, len), close(conn), log(ptr, len) and fail(ptr, len)")
+	fs.StringVar(&app.onReady, "on-ready", "", "Shell command to execute once an endpoint is available, "+
+		"with TCPW_ENDPOINT, TCPW_ATTEMPT and TCPW_LATENCY_MS environment variables")
+	fs.StringVar(&app.onStateChange, "on-state-change", "", "Shell command to execute when an endpoint becomes waiting, up, failed or timeout, "+
+		"with TCPW_ENDPOINT, TCPW_ENDPOINT_STATE and TCPW_ERROR environment variables")
+	fs.StringVar(&app.onFinish, "on-finish", "", "Shell command to execute once the wait is over, "+
+		"with TCPW_STATUS (success, timeout or failure), TCPW_FAILED (comma separated endpoints) and TCPW_ELAPSED_MS environment variables")
+	fs.StringVar(&app.webhook, "webhook", "", "URL to send the result of the wait to")
+	fs.StringVar(&app.webhookMethod, "webhook-method", "POST", "HTTP method of the webhook request")
+	fs.StringVar(&app.webhookBody, "webhook-body", DefaultWebhookBody, "Template of the webhook request body, "+
+		"with {{.Status}}, {{.Endpoints}}, {{.Failed}}, {{.DurationMs}} and {{.Error}} placeholders and 'json' function")
+	fs.IntVar(&app.exitSuccess, "exit-success", ExitSuccess, "Exit code if endpoints are available and no command was executed (default 0)")
+	fs.IntVar(&app.exitTimeout, "exit-timeout", ExitTimeout, "Exit code if endpoints are not available in time")
+	fs.IntVar(&app.exitRefused, "exit-refused", ExitRefused, "Exit code if endpoints are not available in time and refused the last connection attempt")
+	fs.BoolVar(&app.timeoutCompat, "timeout-compat", false, "Exit with 124 like GNU timeout(1) if endpoints are not available in time, whatever the reason (default false)")
+	fs.Var(EndpointsFile{&app.endpoints, app.labels, app.hosts, app.endpointOptions}, "A", "File with endpoints to await, one per line, optionally followed by 'label=LABEL' and options of '-a'. "+
+		"Empty lines and '#' comments are skipped. Can be repeated")
+	fs.Var(SchemeEndpoints{LabeledEndpoints{&app.endpoints, app.labels, app.hosts, app.endpointOptions}, "path"}, "path", "File system path to await, "+
+		"e.g. a pid file or a unix socket, which is connected to as well. Same as '-a path:///PATH'")
+	fs.Var(SchemeEndpoints{LabeledEndpoints{&app.endpoints, app.labels, app.hosts, app.endpointOptions}, "listen"}, "listen-local", "Local endpoint in the form 'host:port' "+
+		"to await a socket listening on it without connecting, for servers where connections have side effects. "+
+		"The host may be empty for any address. Linux and Windows only. Same as '-a listen://host:port'")
+	fs.Var(SchemeEndpoints{LabeledEndpoints{&app.endpoints, app.labels, app.hosts, app.endpointOptions}, "dns"}, "all-ips", "Endpoint in the form 'host:port' to await every address "+
+		"the host resolves to, e.g. all pods of a headless Kubernetes service. The host is resolved again on every attempt. Same as '-a dns://host:port'")
+	fs.Var(SchemeEndpoints{LabeledEndpoints{&app.endpoints, app.labels, app.hosts, app.endpointOptions}, "srv"}, "srv", "DNS name of SRV records, e.g. '_postgres._tcp.example.com', "+
+		"to await instances they point to. Records are resolved again on every attempt. Same as '-a srv://NAME'")
+	fs.Var(SchemeEndpoints{LabeledEndpoints{&app.endpoints, app.labels, app.hosts, app.endpointOptions}, "consul"}, "consul", "Service registered in Consul to await its healthy instances, "+
+		"in the form 'NAME[?tag=TAG]', or with 'passing=false' for all instances. The agent is queried at $CONSUL_HTTP_ADDR (default: "+defaultConsulAddr+") "+
+		"on every attempt. Same as '-a consul://NAME'")
+	fs.Var(SchemeEndpoints{LabeledEndpoints{&app.endpoints, app.labels, app.hosts, app.endpointOptions}, "k8s"}, "k8s", "Kubernetes service to await its ready pods published in EndpointSlices, "+
+		"in the form 'NAMESPACE/NAME[:PORT]', with the first port by default. The API server is queried with in-cluster credentials "+
+		"on every attempt. Same as '-a k8s://NAMESPACE/NAME'")
+	fs.Var(SchemeEndpoints{LabeledEndpoints{&app.endpoints, app.labels, app.hosts, app.endpointOptions}, "docker"}, "docker", "Docker container to await, in the form 'NAME[:PORT]', "+
+		"with the lowest exposed port by default, on its published port or its network address. The engine at $DOCKER_HOST "+
+		"(default: "+defaultDockerHost+") is queried on every attempt, so the container may not exist yet. Same as '-a docker://NAME'")
+	fs.Var(SchemeEndpoints{LabeledEndpoints{&app.endpoints, app.labels, app.hosts, app.endpointOptions}, "script"}, "script", "Starlark script checking an endpoint "+
+		"with its own handshake, available once the script ends without fail(). The script connects with dial(address[, network]) "+
+		"to 'tcp', 'udp', 'unix' or 'tls' connections with send(data), recv([n]), recv_until(delim) and close() methods, "+
+		"and waits with sleep(seconds). Same as '-a script://FILE'")
+	fs.IntVar(&app.require, "require", 0, "Number of discovered instances of '-all-ips', '-srv', '-consul', '-k8s' and '-docker' endpoints, "+
+		"which must be available. Zero for all of them (default 0)")
+	fs.StringVar(&app.when, "when", "", "Condition of the wait over labels of endpoints with '&&', '||', '!' and parentheses "+
+		"instead of awaiting all of them, e.g. 'db && (cache1 || cache2) && !legacy'. A label is true once its endpoint is available and false once it failed or timed out. "+
+		"The wait ends as soon as the condition is met or can not be met anymore")
+	fs.IntVar(&app.expectReplicas, "expect-replicas", 0, "Number of instances of discovered endpoints, which must be discovered, "+
+		"e.g. replicas of a headless Kubernetes service with '-all-ips' (default 0)")
+	fs.Var(LabeledEndpoints{&app.endpoints, app.labels, app.hosts, app.endpointOptions}, "wait", "Alias of '-a' for dockerize compatibility, e.g. 'tcp://host:port' or 'http://host/health'")
+	fs.DurationVar(&app.interval, "wait-retry-interval", time.Second, "Alias of '-i' for dockerize compatibility")
+	fs.DurationVar(&app.timeout, "timeout", 0, "Alias of '-t' for dockerize compatibility (default 0)")
+	fs.BoolVar(&app.scan, "z", false, "Make a single connection attempt to every endpoint and exit with 0 or 1, like 'nc -z' (default false)")
+	fs.Var((*Seconds)(&app.dialTimeout), "w", "Timeout of a single connection attempt, e.g. including the response of an HTTP endpoint, "+
+		"in seconds or format N{ns,ms,s,m,h} (default: '-t')")
+	fs.IntVar(&app.concurrency, "concurrency", tcpw.DefaultConcurrency, "Maximal number of concurrent connection attempts of the wait, e.g. to check thousands of endpoints "+
+		"without running out of file descriptors. Zero for no limit")
+	fs.BoolVar(&app.dryRun, "n", false, "Print the effective configuration and exit without dialing (default false)")
+	fs.BoolVar(&app.dryRun, "dry-run", false, "Alias of '-n'")
+	fs.Var(&app.runs, "run", "Shell command to execute before the command. Can be repeated: commands are executed in sequence, stopping at the first failure")
+	fs.DurationVar(&app.cmdTimeout, "cmd-timeout", 0, "Terminate the command if it is still running after the timeout in format N{ns,ms,s,m,h}. Zero for no timeout (default 0)")
+	fs.DurationVar(&app.grace, "grace", 10*time.Second, "Period to wait for the command to exit after '-signal' before killing it. Zero to wait forever")
+	fs.BoolVar(&app.reap, "reap", os.Getpid() == 1, "Reap orphaned zombie processes, like init, on Linux (default: if running as PID 1)")
+	fs.StringVar(&app.logFormat, "log-format", "text", "Format of log lines: 'text' or 'json' - one JSON object per line with time, level, msg, endpoint, attempt, latency_ms and error fields")
+	fs.StringVar(&app.eventsPath, "events", "", "File to append events to, or '-' for stdout: one JSON object per connection attempt, "+
+		"endpoint state change and command start or exit")
+	fs.StringVar(&app.report, "report", "", "Write a summary of the run at exit in the form 'FORMAT[:FILE]', where FORMAT is 'json' or 'yaml'. It is written to stdout if FILE is omitted")
+	fs.Var(&app.forwards, "forward", "Local address in the form '[LABEL=]host:port' to listen on once endpoints are available and to proxy connections "+
+		"to the endpoint of the label, or to the only endpoint, while the command runs, e.g. 'db=localhost:5432' for tools which only connect to localhost. "+
+		"TLS of endpoints is not terminated. Can be repeated")
+	fs.StringVar(&app.recordPath, "record", "", "File to record endpoints, timeout, interval and every connection attempt with its timing and result to, "+
+		"to reproduce the run offline with '-replay'")
+	fs.StringVar(&app.replayPath, "replay", "", "File written with '-record' to replay the recorded run from without connecting: "+
+		"attempts to every endpoint return recorded results in order after their latency, the last one once they run out. "+
+		"Endpoints, '-t' and '-i' of the recorded run are taken unless they are given")
+	fs.StringVar(&app.csvPath, "csv", "", "CSV file to append a row to per connection attempt: timestamp, endpoint, result, latency_ms and error")
+	fs.StringVar(&app.format, "format", "", "Template of the line printed to stdout per connection attempt and per endpoint after the wait, "+
+		"with {{.Type}} ('attempt' or 'summary'), {{.Endpoint}}, {{.Status}}, {{.Attempt}}, {{.Latency}}, {{.Duration}} and {{.Error}} placeholders")
+	fs.StringVar(&app.color, "color", "auto", "Color status of endpoints in log lines: 'auto' - if stderr is a terminal, 'always' or 'never'")
+	fs.BoolVar(&app.noProgress, "no-progress", false, "Do not show the progress line with remaining time and pending endpoints when stderr is a terminal (default false)")
+	fs.BoolVar(&app.stats, "stats", false, "Keep making connection attempts every interval until '-count', '-t' or Ctrl-C, "+
+		"then print statistics per endpoint like ping(8) (default false)")
+	fs.IntVar(&app.count, "count", 0, "Number of connection attempts to every endpoint in '-stats' mode. Zero for no limit (default 0)")
+	fs.StringVar(&app.statsdAddr, "statsd", "", "StatsD server to send metrics of attempts and waits to over UDP, in the form 'host:port'")
+	fs.StringVar(&app.otelEndpoint, "otel-endpoint", os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"), "OpenTelemetry collector URL to export traces and metrics to "+
+		"with OTLP/HTTP, e.g. 'http://localhost:4318' (default: $OTEL_EXPORTER_OTLP_ENDPOINT)")
+	fs.BoolVar(&app.sdNotify, "sd-notify", false, "Notify systemd via $NOTIFY_SOCKET about progress and readiness (READY=1) once all endpoints are available, "+
+		"for units of Type=notify (default false)")
+	fs.Var(&app.syslogAddr, "syslog", "Send log lines to the local syslog, or to a remote one with '-syslog=[udp://|tcp://]host:port', instead of stderr")
+	fs.StringVar(&app.syslogFacility, "syslog-facility", "daemon", "Syslog facility, e.g. 'daemon', 'user' or 'local0'")
+	fs.StringVar(&app.logFilePath, "log-file", "", "File to write log lines to instead of stderr")
+	app.logMaxSize = 10 << 20
+	fs.Var(&app.logMaxSize, "log-max-size", "Rotate '-log-file' when it exceeds the size in bytes, e.g. '512K' or '10M'. Zero for no limit")
+	fs.DurationVar(&app.logMaxAge, "log-max-age", 0, "Rotate '-log-file' when it is older than the duration in format N{ns,ms,s,m,h}. Zero for no limit (default 0)")
+	fs.IntVar(&app.logMaxBackups, "log-max-backups", 5, "Number of rotated '-log-file' files to keep as FILE.1, FILE.2 and so on")
+	fs.StringVar(&app.statusListen, "status-listen", "", "Address to serve the current state of endpoints in JSON over HTTP on, e.g. ':8081'")
+	fs.StringVar(&app.grpcListen, "grpc", "", "Address to serve the gRPC API of 'serve' subcommand on, e.g. ':7777', "+
+		"to request waits from other services with SubmitWait, StreamStatus and Cancel methods of tcpw.proto")
+	fs.StringVar(&app.httpListen, "http", "", "Address to serve the JSON API of 'serve' subcommand on, e.g. ':8080', "+
+		"to request waits with 'POST /v1/wait', query them with 'GET /v1/status/{id}' and cancel them with 'DELETE /v1/wait/{id}'")
+	fs.StringVar(&app.apiToken, "api-token", "", "Shared secret of '-grpc' and '-http' APIs, which reject requests without it as a bearer token. Required by them")
+	fs.StringVar(&app.agent, "agent", "", "Address of 'tcpw agent' in the form 'host:port' to make connection attempts from instead, "+
+		"e.g. to check endpoints from the network of a private subnet. The agent checks endpoints with its own options and sends back the result of every attempt")
+	fs.StringVar(&app.agentToken, "agent-token", "", "Shared secret of '-agent' and 'agent' subcommand, which rejects requests without it. "+
+		"Required by 'agent' listening beyond localhost")
+	fs.BoolVar(&app.summary, "summary", false, "Print a table with the state, number of attempts and time to ready of every endpoint after the wait (default false)")
+	fs.Var(&app.notify, "notify", "URL to send notifications about the result of the wait and, with '-supervise' or '-kill-on-loss', about loss and recovery of endpoints to: "+
+		"'slack://' incoming webhook URL or HTTP(S) URL receiving '-notify-body'. Can be repeated")
+	fs.StringVar(&app.notifyBody, "notify-body", DefaultNotifyBody, "Template of the body of notifications to HTTP(S) URLs, with {{.Status}} and {{.Message}} placeholders and 'json' function")
+	fs.StringVar(&app.statePath, "state", "", "File to append results of connection attempts and of '-supervise' or '-kill-on-loss' monitoring to, "+
+		"to query uptime and outages of endpoints with 'history' subcommand")
+	app.stateMaxSize = 10 << 20
+	fs.Var(&app.stateMaxSize, "state-max-size", "Rotate '-state' file when it exceeds the size in bytes, e.g. '512K' or '10M'. Zero for no limit")
+	fs.IntVar(&app.stateMaxBackups, "state-max-backups", 5, "Number of rotated '-state' files to keep as FILE.1, FILE.2 and so on, "+
+		"read by 'history' subcommand along with the file. Older samples are removed")
+	fs.StringVar(&app.resumePath, "resume", "", "File to keep the progress of the wait in, so an interrupted wait continues "+
+		"with available endpoints done and the rest of '-t' timeout. It is removed once the wait is over")
+	fs.StringVar(&app.pidFile, "pidfile", "", "File to write the PID of tcpw to at startup. It is removed on exit")
+	fs.StringVar(&app.lockPath, "lock", "", "File to take an exclusive lock of, so overlapping runs, e.g. launched by cron, "+
+		"exit with 75 instead of executing the command twice. The lock is held until tcpw exits")
+	fs.BoolVar(&app.lockWait, "lock-wait", false, "Queue behind the run holding '-lock' instead of exiting, until it exits")
+	daemonPid, daemonLog := DaemonPaths()
+	fs.BoolVar(&app.daemon, "daemon", false, "Run in the background, detached from the terminal, with stdin, stdout and stderr redirected to /dev/null, e.g. to start 'serve' or '-supervise' monitoring from rc scripts. "+
+		"'-pidfile' and '-log-file' (unless '-syslog') default to "+daemonPid+" and "+daemonLog)
+	var configPath string
+	fs.StringVar(&configPath, "config", "", "YAML or TOML (by '.toml' extension) file with values of flags by their names, "+
+		"'endpoints' list and 'command' to execute. Flags on the command line and TCPW_* environment variables, e.g. TCPW_TIMEOUT, "+
+		"override values from the file. Endpoints of the file are re-read on SIGHUP by '-supervise', '-kill-on-loss' and 'tui' "+
+		"(default: $TCPW_CONFIG)")
+	if app.compose != nil {
+		app.compose.Flags(fs)
+	}
+	fs.StringVar(&app.on, "on", "s", "Condition for command execution. Possible values: 's' - after success, 'f' - after failure, 'any' - always")
+	fs.Usage = func() {
+		const usageFormat = "Usage: %s [-t timeout] [-i interval] [-on (s|f|any)] [-each] [-workdir path] [-env KEY=VALUE ...] [-user uid[:gid]] [-supervise] [-kill-on-loss] [-signal name] [-ready action] [-no-stdin] [-cmd-log file] [-cmd-retries N] [-pre command] [-post command] [-on-attempt command] [-webhook URL] [-q] [-v] [-a host:port ...] [host port --] [-c command | [--] command [args]]\n"
+		app.Print(usageFormat, name)
+		app.Print("Subcommands: %s\n", strings.Join(Subcommands, ", "))
+		fs.PrintDefaults()
+		app.Print("  command args\n    \tExecute command with arguments after the test finishes (default: if connection succeeded).\n" +
+			"    \tUse '--' to separate it from tcpw flags if its arguments start with '-'.\n" +
+			"    \tPlaceholders {{.Host}}, {{.Port}}, {{.Addr}}, {{.Label}}, {{.LatencyMs}} and {{.Env.KEY}} (of '-env' and '-env-file') are replaced in arguments,\n" +
+			"    \tother templates, e.g. '{{.Names}}' of 'docker ps --format', are kept as is. '-c' and '-run' scripts are never templated\n")
+	}
+	app.usage = fs.Usage
+	for _, path := range FlagArgs(fs, args, "plugin") {
+		if err := app.plugins.Set(path); err != nil {
+			fmt.Fprintf(fs.Output(), "invalid value %q for flag -plugin: %v\n", path, err)
+			return err
+		}
+	}
+	// endpoints given before '-agent' are not resolved either
+	if agents := FlagArgs(fs, args, "agent"); len(agents) > 0 {
+		app.agent = agents[len(agents)-1]
+	}
+	for _, path := range FlagArgs(fs, args, "hosts-file") {
+		if err := app.hosts.Set(path); err != nil {
+			fmt.Fprintf(fs.Output(), "invalid value %q for flag -hosts-file: %v\n", path, err)
+			return err
+		}
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	app.command = fs.Args()
+	if app.compose != nil {
+		services, command := fs.Args(), []string(nil)
+		if i := slices.Index(services, "--"); i >= 0 {
+			services, command = services[:i], services[i+1:]
+		}
+		if len(services) == 0 {
+			fmt.Fprintln(fs.Output(), "no services given")
+			fs.Usage()
+			return errors.New("no services given")
+		}
+		endpoints, labels, err := app.compose.Endpoints(services)
+		if err != nil {
+			fmt.Fprintln(fs.Output(), err)
+			return err
+		}
+		app.endpoints = append(app.endpoints, endpoints...)
+		maps.Copy(app.labels, labels)
+		app.command = command
+	} else if endpoint, command, ok := CutHostPort(args, fs.Args()); ok {
+		if err := app.endpoints.Set(endpoint); err != nil {
+			fmt.Fprintf(fs.Output(), "invalid endpoint %s: %v\n", endpoint, err)
+			return err
+		}
+		app.command = command
+	}
+	if err := ApplyConfig(fs, EnvConfig(fs, os.Environ()), &app.command); err != nil {
+		fmt.Fprintf(fs.Output(), "invalid environment: %v\n", err)
+		return err
+	}
+	if configPath == "" {
+		configPath = os.Getenv("TCPW_CONFIG")
+	}
+	if configPath != "" {
+		if fs.Lookup("a").Value.String() == "" {
+			app.reloadPath = configPath
+		}
+		values, err := LoadConfig(configPath)
+		if err == nil {
+			err = ApplyConfig(fs, values, &app.command)
+		}
+		if err != nil {
+			fmt.Fprintf(fs.Output(), "invalid config: %v\n", err)
+			return err
+		}
+	}
+	if app.replayPath != "" {
+		if err := app.ApplyReplay(fs); err != nil {
+			fmt.Fprintf(fs.Output(), "invalid value %q for flag -replay: %v\n", app.replayPath, err)
+			return err
+		}
+	}
+	// the flags may be set by the environment, '-config' and '-replay' too
+	durations := map[string]time.Duration{"t": app.timeout, "i": app.interval, "w": app.dialTimeout}
+	for _, name := range []string{"t", "i", "w"} {
+		if durations[name] < 0 {
+			err := fmt.Errorf("invalid value %q for flag -%s: negative duration", fs.Lookup(name).Value, name)
+			fmt.Fprintln(fs.Output(), err)
+			fs.Usage()
+			return err
+		}
+	}
+	if app.daemon {
+		if app.pidFile == "" {
+			app.pidFile = daemonPid
+		}
+		if app.logFilePath == "" && !app.syslogAddr.set {
+			app.logFilePath = daemonLog
+		}
+	}
+	if quiet {
+		app.logLevel.Level = LevelQuiet
+	} else if silent {
+		app.logLevel.Level = slog.LevelError
+	} else if verbose3 {
+		app.logLevel.Level = LevelWire
+	} else if verbose2 {
+		app.logLevel.Level = LevelTrace
+	} else if verbose {
+		app.logLevel.Level = slog.LevelDebug
+	}
+	app.SetLogger()
+	return nil
+}
+
+// Version returns the version of tcpw with the revision and Go version it is built with,
+// e.g. 'tcpw v0.1.10 (go1.23.0 linux/amd64)', or 'tcpw v0.1.10 minimal (go1.23.0 linux/amd64)' if built with 'minimal' tag.
+func Version() string {
+	version, revision, build := "(devel)", "", ""
+	if info, ok := debug.ReadBuildInfo(); ok {
+		version = info.Main.Version
+		for _, s := range info.Settings {
+			if s.Key == "vcs.revision" && len(s.Value) >= 7 {
+				revision = " " + s.Value[:7]
+			}
+			if s.Key == "-tags" && slices.Contains(strings.Split(s.Value, ","), "minimal") {
+				build = " minimal"
+			}
+		}
+	}
+	return fmt.Sprintf("tcpw %s%s%s (%s %s/%s)", version, revision, build, runtime.Version(), runtime.GOOS, runtime.GOARCH)
 }
 
 func init() {
 	debug.SetGCPercent(25)
 	log.SetFlags(log.LstdFlags | log.Lmicroseconds)
-	flag.CommandLine.SetOutput(os.Stderr)
 }
 
 func main() {
 	var app App
 
-	flag.DurationVar(&app.timeout, "t", 0, "Timeout in format N{ns,ms,s,m,h}, e.g. '5s' == 5 seconds. Zero for no timeout (default 0)")
-	flag.DurationVar(&app.interval, "i", time.Second, "Interval between retries in format N{ns,ms,s,m,h}")
-	flag.BoolVar(&app.quiet, "q", false, "Do not print anything (default false)")
-	flag.BoolVar(&app.verbose, "v", false, "Verbose mode (default false)")
-	flag.Var(&app.endpoints, "a", "Endpoint to await, in the form 'host:port'")
-	flag.StringVar(&app.on, "on", "s", "Condition for command execution. Possible values: 's' - after success, 'f' - after failure, 'any' - always")
-	flag.Usage = func() {
-		const usageFormat = "Usage: %s [-t timeout] [-i interval] [-on (s|f|any)] [-q] [-v] [-a host:port ...] [command [args]]\n"
-		app.Error(usageFormat, os.Args[0])
-		flag.PrintDefaults()
-		app.Error("  command args\n    \tExecute command with arguments after the test finishes (default: if connection succeeded)\n")
+	if len(os.Args) > 1 && os.Args[1] == "version" {
+		fmt.Println(Version())
+		os.Exit(ExitSuccess)
 	}
-	flag.Parse()
-
-	if err := app.Check(); err != nil {
-		app.Error(err.Error())
-		flag.Usage()
-		os.Exit(22) // Invalid argument code
+	if len(os.Args) > 1 && os.Args[1] == "history" {
+		if err := RunHistory(os.Args[0], os.Args[2:]); err != nil {
+			if errors.Is(err, flag.ErrHelp) {
+				os.Exit(ExitSuccess)
+			}
+			app.Error("%v", err)
+			os.Exit(ExitFailure)
+		}
+		os.Exit(ExitSuccess)
+	}
+	if len(os.Args) > 1 && (os.Args[1] == "listen" || os.Args[1] == "service") {
+		run := app.RunListen
+		if os.Args[1] == "service" {
+			run = app.RunService
+		}
+		if err := run(os.Args[0], os.Args[2:]); err != nil {
+			if errors.Is(err, flag.ErrHelp) {
+				os.Exit(ExitSuccess)
+			}
+			app.Error("%v", err)
+			os.Exit(ExitFailure)
+		}
+		os.Exit(ExitSuccess)
 	}
-	app.command = flag.Args()
-	if err := app.Run(); err != nil {
-		var exErr *exec.ExitError
-		if errors.As(err, &exErr) {
-			os.Exit(exErr.ExitCode())
+	if err := app.ParseArgs(os.Args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(ExitSuccess)
 		}
-		os.Exit(1)
+		os.Exit(ExitInvalid)
+	}
+	if err := app.Check(); err != nil {
+		app.Error("%v", err)
+		app.usage()
+		os.Exit(ExitInvalid)
 	}
+	os.Exit(app.ExitCode(app.Run()))
 }
@@ -0,0 +1,61 @@
+//go:build !minimal
+
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+// TestAppParseURLs parses endpoints with HTTP URLs among them, which the minimal build rejects.
+func TestAppParseURLs(t *testing.T) {
+	t.Run("Test endpoints from file (-A)", func(t *testing.T) {
+		path := t.TempDir() + "/endpoints.txt"
+		content := "# generated\n127.0.0.1:5432 label=db\n\nhttp://127.0.0.1:8080/health # api\n"
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		var app App
+		if err := app.Parse("tcpw", []string{"-A", path, "-a", "127.0.0.1:6379"}); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if labels := strings.Join(app.Labels(), " "); labels != "db http://127.0.0.1:8080/health 127.0.0.1:6379" {
+			t.Fatalf("Wrong endpoints: %s", labels)
+		}
+
+		if err := os.WriteFile(path, []byte("127.0.0.1:5432\n127.0.0.1:6379 retries=5\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		app = newApp()
+		want := "line 2: invalid option 'retries=5', expected 'label', 'timeout', 'interval' or 'status'"
+		if err := app.Parse("tcpw", []string{"-A", path}); err == nil || !strings.Contains(err.Error(), want) {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	})
+
+	t.Run("Test comma separated endpoints", func(t *testing.T) {
+		var app App
+		args := []string{"-a", "db=127.0.0.1:5432,127.0.0.1:6379,http://127.0.0.1/health?check=db,cache"}
+		if err := app.Parse("tcpw", args); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if labels := strings.Join(app.Labels(), " "); labels != "db 127.0.0.1:6379 http://127.0.0.1/health?check=db,cache" {
+			t.Fatalf("Wrong endpoints: %s", labels)
+		}
+	})
+
+	t.Run("Test labeled endpoints", func(t *testing.T) {
+		var app App
+		args := []string{"-a", "db=127.0.0.1:5432", "-a", "127.0.0.1:6379", "-a", "http://127.0.0.1/health?full=1"}
+		if err := app.Parse("tcpw", args); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if strings.Join(app.endpoints, " ") != "127.0.0.1:5432 127.0.0.1:6379 http://127.0.0.1/health?full=1" {
+			t.Fatalf("Wrong endpoints parsed: %v", app.endpoints)
+		}
+		if labels := strings.Join(app.Labels(), " "); labels != "db 127.0.0.1:6379 http://127.0.0.1/health?full=1" {
+			t.Fatalf("Wrong labels: %s", labels)
+		}
+	})
+}
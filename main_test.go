@@ -2,9 +2,16 @@ package main
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"github.com/jackcvr/tcpw/tcpw"
 	"log"
+	"log/slog"
 	"net"
 	"os"
+	"os/exec"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -16,10 +23,12 @@ func newApp() App {
 	return App{
 		timeout:   1 * time.Second,
 		interval:  100 * time.Millisecond,
-		quiet:     true,
+		logLevel:  LogLevel{LevelQuiet},
 		endpoints: []string{},
 		on:        "s",
 		command:   []string{},
+		logFormat: "text",
+		executed:  new(atomic.Bool),
 	}
 }
 
@@ -73,6 +82,104 @@ func TestAppCheck(t *testing.T) {
 			t.Fatal("Returned wrong error")
 		}
 	})
+
+	t.Run("Test error: '-c' with command", func(t *testing.T) {
+		app := newApp()
+		app.endpoints = []string{"localhost:1234"}
+		app.shell = "true"
+		app.command = []string{"true"}
+		if err := app.Check(); err == nil || err.Error() != "'-c' can not be combined with a command" {
+			t.Fatal("Returned wrong error")
+		}
+	})
+
+	t.Run("Test error: wrong '-log-format' value", func(t *testing.T) {
+		app := newApp()
+		app.endpoints = []string{"localhost:1234"}
+		app.logFormat = "xml"
+		if err := app.Check(); err == nil || err.Error() != "only 'text' or 'json' are allowed for '-log-format' argument" {
+			t.Fatal("Returned wrong error")
+		}
+	})
+
+	t.Run("Test command arguments which are not templates", func(t *testing.T) {
+		app := newApp()
+		app.endpoints = []string{"localhost:1234"}
+		app.command = []string{"docker", "ps", "--format", "{{.Names}} {{.Addr"}
+		if err := app.Check(); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	})
+}
+
+func TestAppParse(t *testing.T) {
+	t.Run("Test command after '--'", func(t *testing.T) {
+		var app App
+		args := []string{"-a", "localhost:1234", "-t", "30s", "--", "mybinary", "-a", "something"}
+		if err := app.Parse("tcpw", args); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if len(app.endpoints) != 1 || app.timeout != 30*time.Second {
+			t.Fatalf("Wrong flags parsed: %v, %v", app.endpoints, app.timeout)
+		}
+		if strings.Join(app.command, " ") != "mybinary -a something" {
+			t.Fatalf("Wrong command parsed: %v", app.command)
+		}
+	})
+
+	t.Run("Test command without '--'", func(t *testing.T) {
+		var app App
+		if err := app.Parse("tcpw", []string{"-a", "localhost:1234", "echo", "-n", "up"}); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if strings.Join(app.command, " ") != "echo -n up" {
+			t.Fatalf("Wrong command parsed: %v", app.command)
+		}
+	})
+
+	t.Run("Test endpoints from stdin", func(t *testing.T) {
+		var endpoints Endpoints
+		labels := Labels{}
+		le := LabeledEndpoints{&endpoints, labels, nil, nil}
+		if err := le.Load(strings.NewReader("db=127.0.0.1:5432\n\n# cache\n  127.0.0.1:6379  \n")); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if strings.Join(endpoints, " ") != "127.0.0.1:5432 127.0.0.1:6379" || labels["127.0.0.1:5432"] != "db" {
+			t.Fatalf("Wrong endpoints: %v, %v", endpoints, labels)
+		}
+		if err := le.Load(strings.NewReader("localhost:99999\n")); err == nil {
+			t.Fatal("Unexpected success")
+		}
+	})
+
+	t.Run("Test negative durations", func(t *testing.T) {
+		for args, want := range map[string]string{
+			"-t -1s":        `invalid value "-1s" for flag -t: negative duration`,
+			"-i -100ms":     `invalid value "-100ms" for flag -i: negative duration`,
+			"-w -2":         `invalid value "-2s" for flag -w: negative duration`,
+			"-t 1s -i -1ms": `invalid value "-1ms" for flag -i: negative duration`,
+		} {
+			var app App
+			if err := app.Parse("tcpw", append(strings.Fields(args), "-a", "localhost:1234")); err == nil || err.Error() != want {
+				t.Fatalf("Unexpected error of %s: %v", args, err)
+			}
+		}
+		t.Setenv("TCPW_I", "-1s")
+		var app App
+		if err := app.Parse("tcpw", []string{"-a", "localhost:1234"}); err == nil || err.Error() != `invalid value "-1s" for flag -i: negative duration` {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	})
+
+	t.Run("Test silent mode", func(t *testing.T) {
+		var app App
+		if err := app.Parse("tcpw", []string{"-silent", "-v", "-a", "localhost:1234"}); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if app.logLevel.Level != slog.LevelError {
+			t.Fatalf("Wrong log level: %v", app.logLevel.String())
+		}
+	})
 }
 
 func TestTryDial(t *testing.T) {
@@ -201,4 +308,336 @@ func TestRun(t *testing.T) {
 			t.Fatalf("File %s does not exist, but '-on f' argument was provided", file)
 		}
 	})
+
+	t.Run("Test success with shell command (-c)", func(t *testing.T) {
+		app := newApp()
+		addr := startListener("")
+		app.endpoints = []string{addr.String()}
+		dir := t.TempDir()
+		app.shell = "cd '" + dir + "' && touch test1 && touch test2"
+		if err := app.Run(); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		for _, file := range []string{dir + "/test1", dir + "/test2"} {
+			if _, err := os.Stat(file); os.IsNotExist(err) {
+				t.Fatalf("File %s does not exist", file)
+			}
+		}
+	})
+
+	t.Run("Test success with command per endpoint (-each)", func(t *testing.T) {
+		app := newApp()
+		app.each = true
+		app.endpoints = []string{startListener("").String(), startListener("").String()}
+		dir := t.TempDir()
+		app.command = []string{"touch", dir + "/{}"}
+		if err := app.Run(); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		for _, addr := range app.endpoints {
+			if _, err := os.Stat(dir + "/" + addr); os.IsNotExist(err) {
+				t.Fatalf("File %s does not exist", dir+"/"+addr)
+			}
+		}
+	})
+
+	t.Run("Test success with command workdir and env", func(t *testing.T) {
+		app := newApp()
+		app.endpoints = []string{startListener("").String()}
+		app.workdir = t.TempDir()
+		app.env = []string{"TCPW_TEST_FILE=test"}
+		app.shell = "touch \"$TCPW_TEST_FILE\""
+		if err := app.Run(); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if _, err := os.Stat(app.workdir + "/test"); os.IsNotExist(err) {
+			t.Fatalf("File %s does not exist", app.workdir+"/test")
+		}
+	})
+
+	t.Run("Test result environment of command", func(t *testing.T) {
+		app := newApp()
+		addr := startListener("")
+		app.endpoints = []string{addr.String()}
+		file := t.TempDir() + "/test"
+		app.shell = "echo \"$TCPW_RESULT $TCPW_" + EnvName(addr.String()) + "_ADDR\" > " + file
+		if err := app.Run(); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if data, err := os.ReadFile(file); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		} else if string(data) != "success "+addr.String()+"\n" {
+			t.Fatalf("Wrong command output: %q", data)
+		}
+	})
+
+	t.Run("Test retry of failed command (-cmd-retries)", func(t *testing.T) {
+		for retries, expectSuccess := range map[int]bool{1: false, 2: true} {
+			app := newApp()
+			app.endpoints = []string{startListener("").String()}
+			app.cmdRetries = retries
+			app.cmdRetryInterval = 10 * time.Millisecond
+			file := t.TempDir() + "/test"
+			// succeeds on the third run only
+			app.shell = "echo run >> '" + file + "'; [ $(wc -l < '" + file + "') -ge 3 ]"
+			if err := app.Run(); (err == nil) != expectSuccess {
+				t.Fatalf("Unexpected result with %d retries: %v", retries, err)
+			}
+		}
+	})
+
+	t.Run("Test pre and post hooks", func(t *testing.T) {
+		app := newApp()
+		app.endpoints = []string{startListener("").String()}
+		app.workdir = t.TempDir()
+		app.pre = "touch pre"
+		app.command = []string{"test", "-f", "pre"}
+		app.post = "rm pre && touch post"
+		if err := app.Run(); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if _, err := os.Stat(app.workdir + "/post"); os.IsNotExist(err) {
+			t.Fatal("Post hook was not executed")
+		}
+	})
+
+	t.Run("Test failed pre hook", func(t *testing.T) {
+		app := newApp()
+		app.timeout = 10 * time.Second
+		app.endpoints = []string{getFreeTCPAddr().String()}
+		app.pre = "exit 3"
+		var exErr *exec.ExitError
+		if err := app.Run(); !errors.As(err, &exErr) || exErr.ExitCode() != 3 {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	})
+
+	t.Run("Test on-attempt hook", func(t *testing.T) {
+		app := newApp()
+		addr := getFreeTCPAddr().String()
+		go func() {
+			time.Sleep(250 * time.Millisecond)
+			_ = startListener(addr)
+		}()
+		app.endpoints = []string{addr}
+		file := t.TempDir() + "/test"
+		app.onAttempt = "echo \"$TCPW_ENDPOINT $TCPW_ATTEMPT\" >> '" + file + "'"
+		if err := app.Run(); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		data, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if !strings.HasPrefix(string(data), addr+" 1\n"+addr+" 2\n") {
+			t.Fatalf("Wrong hook output: %q", data)
+		}
+	})
+
+	t.Run("Test on-ready, on-state-change and on-finish hooks", func(t *testing.T) {
+		app := newApp()
+		app.timeout = 300 * time.Millisecond
+		app.interval = 100 * time.Millisecond
+		up, down := startListener("").String(), getFreeTCPAddr().String()
+		app.endpoints = []string{up, down}
+		file := t.TempDir() + "/test"
+		app.onReady = "echo \"ready $TCPW_ENDPOINT $TCPW_ATTEMPT\" >> '" + file + "'"
+		app.onStateChange = "echo \"$TCPW_ENDPOINT_STATE $TCPW_ENDPOINT\" >> '" + file + "'"
+		app.onFinish = "echo \"$TCPW_STATUS $TCPW_FAILED\" >> '" + file + "'"
+		if err := app.Run(); err == nil {
+			t.Fatal("Unexpected success")
+		}
+		data, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		for _, line := range []string{"waiting " + up, "up " + up, "ready " + up + " 1", "timeout " + down, "timeout " + down + "\n"} {
+			if !strings.Contains(string(data), line) {
+				t.Fatalf("No %q in hook output: %q", line, data)
+			}
+		}
+		if !strings.HasSuffix(string(data), "timeout "+down+"\n") {
+			t.Fatalf("Wrong hook output: %q", data)
+		}
+	})
+
+	t.Run("Test single attempt (-z)", func(t *testing.T) {
+		app := newApp()
+		app.timeout = 0
+		app.scan = true
+		app.endpoints = []string{startListener("").String()}
+		if err := app.Run(); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		app.endpoints = []string{getFreeTCPAddr().String()}
+		if code := app.ExitCode(app.Run()); code != ExitFailure {
+			t.Fatalf("Wrong exit code: %d", code)
+		}
+	})
+
+	t.Run("Test chained commands (-run)", func(t *testing.T) {
+		app := newApp()
+		app.endpoints = []string{startListener("").String()}
+		app.workdir = t.TempDir()
+		app.runs = []string{"touch migrated", "test -f migrated && touch seeded"}
+		app.command = []string{"test", "-f", "seeded"}
+		if err := app.Run(); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		app.endpoints = []string{startListener("").String()}
+		app.runs = []string{"exit 4", "touch skipped"}
+		app.command = []string{"touch", "started"}
+		var exErr *exec.ExitError
+		if err := app.Run(); !errors.As(err, &exErr) || exErr.ExitCode() != 4 {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		for _, file := range []string{"skipped", "started"} {
+			if _, err := os.Stat(app.workdir + "/" + file); err == nil {
+				t.Fatalf("Command after failure was executed: %s", file)
+			}
+		}
+	})
+}
+
+func TestWaitErr(t *testing.T) {
+	refused := errors.New("connection refused")
+	canceled := fmt.Errorf("%w: %w", context.Canceled, refused)
+	cmdErr := errors.New("exit status 1")
+	for _, test := range []struct {
+		results []EndpointResult
+		want    error
+	}{
+		{[]EndpointResult{{Ready: true}, {Ready: true}}, nil},
+		{[]EndpointResult{{Err: canceled, Duration: 2 * time.Second}, {Err: refused, Duration: time.Second}}, refused},
+		{[]EndpointResult{{Ready: true, Executed: true, CmdErr: cmdErr, Duration: time.Second}, {Err: canceled, Duration: 2 * time.Second}}, cmdErr},
+		{[]EndpointResult{{Err: refused, Executed: true}}, nil},
+	} {
+		if err := WaitErr(test.results); err != test.want {
+			t.Fatalf("Unexpected error of %+v: %v", test.results, err)
+		}
+	}
+}
+
+// TestConnectWaiter checks that the command and package tcpw wait the same way.
+func TestConnectWaiter(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	up := l.Addr().String()
+	for _, endpoints := range [][]string{
+		{up, getFreeTCPAddr().String()},
+		{"no-such-host.invalid:80", up},
+	} {
+		app := newApp()
+		app.timeout = 300 * time.Millisecond
+		app.endpoints = endpoints
+		results := app.Connect(context.Background())
+		w, err := tcpw.NewWaiter(tcpw.Options{Endpoints: endpoints, Timeout: app.timeout, Interval: app.interval})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		want, _ := w.Wait(context.Background())
+		for i, r := range results {
+			if r.Addr != want[i].Endpoint || r.Ready != (want[i].Err == nil) || fmt.Sprint(r.Err) != fmt.Sprint(want[i].Err) ||
+				r.Attempts < want[i].Attempts-1 || r.Attempts > want[i].Attempts+1 {
+				t.Fatalf("Results of %s differ: %+v, %+v", r.Addr, r, want[i])
+			}
+		}
+	}
+}
+
+func TestEndpointOptions(t *testing.T) {
+	t.Run("Test options after endpoints", func(t *testing.T) {
+		var app App
+		if err := app.Parse("tcpw", []string{"-a", "db=127.0.0.1:5432 timeout=5s interval=1s", "-a", "127.0.0.1:6379 label=cache"}); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if strings.Join(app.Labels(), " ") != "db cache" {
+			t.Fatalf("Wrong endpoints: %v", app.Labels())
+		}
+		if opts := app.endpointOptions["127.0.0.1:5432"]; opts.Timeout != 5*time.Second || opts.Interval != time.Second || len(app.endpointOptions) != 1 {
+			t.Fatalf("Wrong options: %+v", app.endpointOptions)
+		}
+		for value, want := range map[string]string{
+			"127.0.0.1:5432 timeout=-1s": "invalid value \"-1s\" of option 'timeout', expected a non-negative duration",
+			"127.0.0.1:5432 status=200":  "option 'status' of 127.0.0.1:5432, which is not an HTTP endpoint",
+			"127.0.0.1:5432 label=a+b":   "invalid label 'a+b'",
+		} {
+			app = App{}
+			if err := app.Parse("tcpw", []string{"-a", value}); err == nil || !strings.HasSuffix(err.Error(), want) {
+				t.Fatalf("Unexpected error of %s: %v", value, err)
+			}
+		}
+	})
+
+	t.Run("Test timeout of endpoint", func(t *testing.T) {
+		app := newApp()
+		app.timeout = 5 * time.Second
+		up, down := startListener("").String(), getFreeTCPAddr().String()
+		app.endpoints = []string{up, down}
+		app.endpointOptions = map[string]EndpointOptions{down: {Timeout: 300 * time.Millisecond}}
+		start := time.Now()
+		results := app.Connect(context.Background())
+		if !results[0].Ready || !errors.Is(results[1].Err, context.DeadlineExceeded) || time.Since(start) > 2*time.Second {
+			t.Fatalf("Unexpected results after %v: %+v", time.Since(start), results)
+		}
+	})
+
+	t.Run("Test interval of endpoint", func(t *testing.T) {
+		app := newApp()
+		app.timeout = 500 * time.Millisecond
+		app.interval = 200 * time.Millisecond
+		fast, slow := getFreeTCPAddr().String(), getFreeTCPAddr().String()
+		app.endpoints = []string{fast, slow}
+		app.endpointOptions = map[string]EndpointOptions{fast: {Interval: 50 * time.Millisecond}}
+		results := app.Connect(context.Background())
+		if results[0].Attempts < 2*results[1].Attempts {
+			t.Fatalf("Unexpected attempts: %d, %d", results[0].Attempts, results[1].Attempts)
+		}
+	})
+}
+
+func TestFailure(t *testing.T) {
+	t.Run("Test timeout", func(t *testing.T) {
+		app := newApp()
+		app.timeout = 300 * time.Millisecond
+		up := startListener("").String()
+		down := getFreeTCPAddr().String()
+		app.endpoints = []string{up, down}
+		results := app.Connect(context.Background())
+		err := WaitErr(results)
+		if err == nil {
+			t.Fatal("Connection succeeded on fail test")
+		}
+		msg := Failure(results, err)
+		if !strings.HasPrefix(msg, "timeout error: "+up+" is up; "+down+" timed out after ") ||
+			!strings.HasSuffix(msg, "connection refused)") {
+			t.Fatalf("Unexpected message: %s", msg)
+		}
+	})
+
+	t.Run("Test pending endpoint", func(t *testing.T) {
+		app := newApp()
+		down := getFreeTCPAddr().String()
+		app.endpoints = []string{badAddr, down}
+		results := app.Connect(context.Background())
+		err := WaitErr(results)
+		if err == nil {
+			t.Fatal("Connection succeeded on fail test")
+		}
+		want := "connection failed: " + badAddr + " failed after 1 attempt (" + badAddrError + "); " + down + " was still pending after "
+		if msg := Failure(results, err); !strings.HasPrefix(msg, want) {
+			t.Fatalf("Unexpected message: %s", msg)
+		}
+	})
+
+	t.Run("Test failure of command", func(t *testing.T) {
+		results := []EndpointResult{{Addr: "db:5432", Ready: true, Attempts: 1}}
+		if msg := Failure(results, errors.New("exit status 1")); msg != "exit status 1" {
+			t.Fatalf("Unexpected message: %s", msg)
+		}
+	})
 }
@@ -0,0 +1,155 @@
+//go:build minimal
+
+package main
+
+import (
+	"context"
+	"errors"
+	"github.com/jackcvr/tcpw/tcpw"
+	"log/slog"
+	"net"
+	"os"
+	"strings"
+	"time"
+)
+
+// The minimal build leaves out every user of net/http and crypto/tls: HTTP(S) and TLS endpoints,
+// webhooks and notifications, the status server, OTLP export, 'agent' and 'serve' subcommands
+// and tracing of connection attempts, as well as Go plugins, which need dynamic linking,
+// and interpreters of Starlark check scripts and WebAssembly checkers.
+
+// hasScripts reports whether Starlark scripts of 'script://' endpoints and WebAssembly modules of '-wasm-check' are run.
+const hasScripts = false
+
+// CheckBuild reports options of features which are left out of the minimal build.
+// '-otel-endpoint' inherited from $OTEL_EXPORTER_OTLP_ENDPOINT is ignored instead.
+func (app App) CheckBuild() error {
+	var option string
+	switch {
+	case app.webhook != "":
+		option = "'-webhook' is"
+	case len(app.notify) > 0:
+		option = "'-notify' is"
+	case app.statusListen != "":
+		option = "'-status-listen' is"
+	case app.otelEndpoint != "" && app.otelEndpoint != os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"):
+		option = "'-otel-endpoint' is"
+	case app.ready.url != "":
+		option = "'-ready' URL is"
+	case app.agent != "":
+		option = "'-agent' is"
+	case app.agentListen != "":
+		option = "'agent' subcommand is"
+	case app.serve:
+		option = "'serve' subcommand is"
+	case app.wasmCheck != "":
+		option = "'-wasm-check' is"
+	default:
+		return nil
+	}
+	return errors.New(option + " not supported by the minimal build of tcpw")
+}
+
+// IsHTTP reports whether the endpoint is an HTTP(S) URL, which is never accepted by the minimal build.
+func IsHTTP(string) bool {
+	return false
+}
+
+func (app App) DialHTTP(context.Context, tcpw.DialFunc, string) error {
+	return errors.New("HTTP endpoints are not supported by the minimal build of tcpw")
+}
+
+func DialTLS(context.Context, tcpw.DialFunc, string) (net.Conn, error) {
+	return nil, errors.New("TLS connections are not supported by the minimal build of tcpw")
+}
+
+func GetURL(string) error {
+	return errors.New("HTTP requests are not supported by the minimal build of tcpw")
+}
+
+func PostJSON(string, string, string) error {
+	return errors.New("HTTP requests are not supported by the minimal build of tcpw")
+}
+
+// Trace returns ctx as is, phases of connection attempts are not traced by the minimal build.
+func (p *Phases) Trace(ctx context.Context) context.Context {
+	return ctx
+}
+
+// TraceDial returns ctx as is, internals of connection attempts are not traced by the minimal build.
+func TraceDial(ctx context.Context, _ *slog.Logger) context.Context {
+	return ctx
+}
+
+func (app App) ServeStatus() (func(), error) {
+	return nil, errors.New("'-status-listen' is not supported by the minimal build of tcpw")
+}
+
+func (app App) RunAgent() error {
+	return errors.New("'agent' subcommand is not supported by the minimal build of tcpw")
+}
+
+func (app App) DialAgent(context.Context, string) error {
+	return errors.New("'-agent' is not supported by the minimal build of tcpw")
+}
+
+func (app App) RunServe() error {
+	return errors.New("'serve' subcommand is not supported by the minimal build of tcpw")
+}
+
+// OTel is never created by the minimal build, see NewOTel. Methods of nil OTel and Span do nothing.
+type OTel struct{}
+
+// Span is an operation of the run, which is not exported by the minimal build.
+type Span struct{}
+
+func NewOTel(string) *OTel {
+	return nil
+}
+
+func (o *OTel) Start(string, ...any) *Span {
+	return nil
+}
+
+func (o *OTel) Wait(error, time.Duration) {}
+
+func (o *OTel) Export(error) error {
+	return nil
+}
+
+func (s *Span) Event(string, ...any) {}
+
+func (s *Span) End(error) {}
+
+func (s *Span) TraceParent() string {
+	return ""
+}
+
+// LoadScript is never called by the minimal build, which rejects 'script://' endpoints, see IsSupported.
+func LoadScript(string) (any, error) {
+	return nil, errors.New("'script' endpoints are not supported by the minimal build of tcpw")
+}
+
+func (app App) RunScript(context.Context, tcpw.DialFunc, string) error {
+	return errors.New("'script' endpoints are not supported by the minimal build of tcpw")
+}
+
+// LoadWasm is never called by the minimal build, which rejects '-wasm-check', see CheckBuild.
+func LoadWasm(string) (any, error) {
+	return nil, errors.New("'-wasm-check' is not supported by the minimal build of tcpw")
+}
+
+func (app App) WasmCheck(context.Context, tcpw.DialFunc, string) error {
+	return errors.New("'-wasm-check' is not supported by the minimal build of tcpw")
+}
+
+// Plugins is the value of '-plugin' flag, which is rejected by the minimal build.
+type Plugins []string
+
+func (p *Plugins) String() string {
+	return strings.Join(*p, ", ")
+}
+
+func (p *Plugins) Set(string) error {
+	return errors.New("Go plugins are not supported by the minimal build of tcpw")
+}
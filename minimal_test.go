@@ -0,0 +1,61 @@
+package main
+
+import (
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestMinimalBuild(t *testing.T) {
+	if testing.Short() {
+		t.Skip("building tcpw takes a while")
+	}
+	path := filepath.Join(t.TempDir(), "tcpw-minimal")
+	if out, err := exec.Command("go", "build", "-tags", "minimal", "-o", path, ".").CombinedOutput(); err != nil {
+		t.Fatalf("Unexpected error: %v: %s", err, out)
+	}
+	if out, err := exec.Command(path, "version").Output(); err != nil || !strings.Contains(string(out), " minimal (") {
+		t.Fatalf("Unexpected version: %q, %v", out, err)
+	}
+	addr := startListener("").String()
+	if out, err := exec.Command(path, "-q", "-t", "1s", "-a", addr).CombinedOutput(); err != nil {
+		t.Fatalf("Unexpected error: %v: %s", err, out)
+	}
+	for _, scheme := range optionalSchemes {
+		out, err := exec.Command(path, "-q", "-t", "1s", "-a", scheme+"://"+addr).CombinedOutput()
+		want := "'" + scheme + "' endpoints are not supported by the minimal build of tcpw"
+		if exitErr, ok := err.(*exec.ExitError); !ok || exitErr.ExitCode() != ExitInvalid || !strings.Contains(string(out), want) {
+			t.Fatalf("Unexpected result of %s: %v: %s", scheme, err, out)
+		}
+	}
+	for _, args := range [][]string{
+		{"-webhook", "http://127.0.0.1:1"},
+		{"-notify", "slack://hooks.slack.com/services/T/B/X"},
+		{"-status-listen", "127.0.0.1:0"},
+		{"-otel-endpoint", "http://127.0.0.1:4318"},
+		{"-agent", "127.0.0.1:7070"},
+		{"-ready", "http://127.0.0.1:1", "--", "true"},
+		{"-plugin", "checker.so"},
+		{"-wasm-check", "check.wasm"},
+	} {
+		out, err := exec.Command(path, append([]string{"-t", "1s", "-a", addr}, args...)...).CombinedOutput()
+		if exitErr, ok := err.(*exec.ExitError); !ok || exitErr.ExitCode() != ExitInvalid || !strings.Contains(string(out), "not supported by the minimal build of tcpw") {
+			t.Fatalf("Unexpected result of %s: %v: %s", args[0], err, out)
+		}
+	}
+	// neither the command nor the library links the HTTP client, servers, TLS or interpreters
+	for _, pkg := range []string{".", "./tcpw"} {
+		out, err := exec.Command("go", "list", "-tags", "minimal", "-deps", pkg).Output()
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		deps := strings.Fields(string(out))
+		if len(deps) == 0 || slices.ContainsFunc(deps, func(dep string) bool {
+			return slices.Contains([]string{"net/http", "crypto/tls", "plugin"}, dep) || strings.HasPrefix(dep, "github.com/jackcvr/tcpw/internal/starlark") || strings.HasPrefix(dep, "github.com/jackcvr/tcpw/internal/wasm")
+		}) {
+			t.Fatalf("Unexpected dependencies of %s: %v", pkg, deps)
+		}
+	}
+}
@@ -0,0 +1,6 @@
+//go:build !race
+
+package main
+
+// raceEnabled reports whether tests are built with '-race', which plugins they load must be built with as well.
+const raceEnabled = false
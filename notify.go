@@ -0,0 +1,88 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+const DefaultNotifyBody = `{"text":{{json .Message}},"status":{{json .Status}}}`
+
+// NotifyData holds values for the '-notify-body' template.
+type NotifyData struct {
+	Status  string // 'success', 'timeout', 'failure', 'down' or 'up'
+	Message string
+}
+
+// NotifyURL converts the '-notify' URL to the URL to post to:
+// 'slack://hooks.slack.com/services/...' is posted to with HTTPS, HTTP(S) URLs are used as is.
+func NotifyURL(value string) (string, error) {
+	u, err := url.Parse(value)
+	if err != nil {
+		return "", err
+	}
+	switch u.Scheme {
+	case "slack":
+		u.Scheme = "https"
+		return u.String(), nil
+	case "http", "https":
+		return value, nil
+	}
+	return "", errors.New("only 'slack://', 'http://' or 'https://' URLs are allowed for '-notify' argument")
+}
+
+// NotifyBody returns the body of the notification for the URL:
+// Slack message for 'slack://' URLs, or '-notify-body' for others.
+func (app App) NotifyBody(value string, data NotifyData) (string, error) {
+	body := app.notifyBody
+	if strings.HasPrefix(value, "slack://") {
+		body = `{"text":{{json .Message}}}`
+	}
+	tmpl, err := ParseWebhookBody(body)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	if err = tmpl.Execute(&b, data); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// Alert sends the notification to every '-notify' URL.
+func (app App) Alert(status, format string, args ...any) {
+	data := NotifyData{Status: status, Message: "tcpw: " + fmt.Sprintf(format, args...)}
+	for _, value := range app.notify {
+		u, err := NotifyURL(value)
+		if err == nil {
+			var body string
+			if body, err = app.NotifyBody(value, data); err == nil {
+				err = PostJSON("POST", u, body)
+			}
+		}
+		if err != nil {
+			app.Error("notification failed: %v", err)
+		}
+	}
+}
+
+// AlertResults sends the notification about the result of the wait.
+func (app App) AlertResults(results []EndpointResult, err error) {
+	if len(app.notify) == 0 {
+		return
+	}
+	var ok, failed []string
+	for _, r := range results {
+		if r.Ready {
+			ok = append(ok, r.Name())
+		} else {
+			failed = append(failed, r.Name())
+		}
+	}
+	if err == nil {
+		app.Alert(Status(err), "all endpoints are available: %s", strings.Join(ok, ", "))
+	} else {
+		app.Alert(Status(err), "endpoints are not available: %s: %v", strings.Join(failed, ", "), err)
+	}
+}
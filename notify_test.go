@@ -0,0 +1,55 @@
+//go:build !minimal
+
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAppAlert(t *testing.T) {
+	t.Run("Test notification about the result (-notify)", func(t *testing.T) {
+		bodies := make(chan string, 1)
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			b, _ := io.ReadAll(r.Body)
+			bodies <- string(b)
+		}))
+		defer srv.Close()
+		app := newApp()
+		addr := startListener("").String()
+		app.endpoints = []string{addr}
+		app.notify = []string{srv.URL}
+		app.notifyBody = DefaultNotifyBody
+		if err := app.Run(); err != nil {
+			t.Fatal(err)
+		}
+		want := `{"text":"tcpw: all endpoints are available: ` + addr + `","status":"success"}`
+		if body := <-bodies; body != want {
+			t.Fatalf("Unexpected body: %s", body)
+		}
+	})
+
+	t.Run("Test Slack URL and body", func(t *testing.T) {
+		app := newApp()
+		u, err := NotifyURL("slack://hooks.slack.com/services/T0/B0/X")
+		if err != nil || u != "https://hooks.slack.com/services/T0/B0/X" {
+			t.Fatalf("Wrong URL: %s, %v", u, err)
+		}
+		body, err := app.NotifyBody("slack://hooks.slack.com/services/T0/B0/X", NotifyData{Status: "down", Message: "tcpw: lost"})
+		if err != nil || body != `{"text":"tcpw: lost"}` {
+			t.Fatalf("Wrong body: %s, %v", body, err)
+		}
+	})
+
+	t.Run("Test error: unsupported URL", func(t *testing.T) {
+		app := newApp()
+		app.endpoints = []string{"localhost:1234"}
+		app.notify = []string{"smtp://mail"}
+		if err := app.Check(); err == nil || !strings.Contains(err.Error(), "'-notify'") {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	})
+}
@@ -0,0 +1,259 @@
+//go:build !minimal
+
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// OTel collects spans and metrics of the run and exports them to an OpenTelemetry collector
+// with OTLP/HTTP JSON protocol. Spans are children of the 'tcpw' root span, which joins
+// the trace of TRACEPARENT environment variable, if any.
+// Methods of nil OTel and Span do nothing.
+type OTel struct {
+	mu       sync.Mutex
+	endpoint string
+	traceID  string
+	root     *Span
+	spans    []*Span
+	waitMs   float64
+	status   string
+}
+
+// Span is an operation of the run: a wait for an endpoint, or the command.
+type Span struct {
+	otel     *OTel
+	traceID  string
+	spanID   string
+	parentID string
+	name     string
+	start    time.Time
+	end      time.Time
+	attrs    []otelAttr
+	events   []otelEvent
+	err      error
+}
+
+type otelValue struct {
+	StringValue *string  `json:"stringValue,omitempty"`
+	IntValue    *string  `json:"intValue,omitempty"`
+	DoubleValue *float64 `json:"doubleValue,omitempty"`
+}
+
+type otelAttr struct {
+	Key   string    `json:"key"`
+	Value otelValue `json:"value"`
+}
+
+type otelEvent struct {
+	TimeUnixNano string     `json:"timeUnixNano"`
+	Name         string     `json:"name"`
+	Attributes   []otelAttr `json:"attributes,omitempty"`
+}
+
+// Attrs converts key-value pairs to OTLP attributes.
+func Attrs(kv ...any) []otelAttr {
+	var attrs []otelAttr
+	for i := 0; i+1 < len(kv); i += 2 {
+		attr := otelAttr{Key: fmt.Sprint(kv[i])}
+		switch v := kv[i+1].(type) {
+		case int:
+			s := strconv.Itoa(v)
+			attr.Value.IntValue = &s
+		case float64:
+			attr.Value.DoubleValue = &v
+		default:
+			s := fmt.Sprint(v)
+			attr.Value.StringValue = &s
+		}
+		attrs = append(attrs, attr)
+	}
+	return attrs
+}
+
+func NewOTel(endpoint string) *OTel {
+	o := &OTel{endpoint: strings.TrimSuffix(endpoint, "/")}
+	var parentID string
+	// https://www.w3.org/TR/trace-context/#traceparent-header
+	if parts := strings.Split(os.Getenv("TRACEPARENT"), "-"); len(parts) == 4 && len(parts[1]) == 32 && len(parts[2]) == 16 {
+		o.traceID, parentID = parts[1], parts[2]
+	} else {
+		o.traceID = randomID(16)
+	}
+	o.root = &Span{otel: o, traceID: o.traceID, spanID: randomID(8), parentID: parentID, name: "tcpw", start: time.Now()}
+	return o
+}
+
+func randomID(n int) string {
+	b := make([]byte, n)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// Start starts a child span of the root span with attributes in key-value pairs.
+func (o *OTel) Start(name string, kv ...any) *Span {
+	if o == nil {
+		return nil
+	}
+	return &Span{otel: o, traceID: o.traceID, spanID: randomID(8), parentID: o.root.spanID, name: name, start: time.Now(), attrs: Attrs(kv...)}
+}
+
+// Wait records the outcome and the duration of the wait for all endpoints.
+func (o *OTel) Wait(err error, elapsed time.Duration) {
+	if o == nil {
+		return
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.status, o.waitMs = Status(err), Latency(elapsed)
+}
+
+// Event adds an event with attributes in key-value pairs to the span.
+func (s *Span) Event(name string, kv ...any) {
+	if s == nil {
+		return
+	}
+	s.otel.mu.Lock()
+	defer s.otel.mu.Unlock()
+	s.events = append(s.events, otelEvent{TimeUnixNano: unixNano(time.Now()), Name: name, Attributes: Attrs(kv...)})
+}
+
+// End ends the span with the error of the operation, if any.
+func (s *Span) End(err error) {
+	if s == nil {
+		return
+	}
+	s.otel.mu.Lock()
+	defer s.otel.mu.Unlock()
+	s.end, s.err = time.Now(), err
+	if s != s.otel.root {
+		s.otel.spans = append(s.otel.spans, s)
+	}
+}
+
+// TraceParent returns the W3C trace context of the span to pass to the command.
+func (s *Span) TraceParent() string {
+	return fmt.Sprintf("00-%s-%s-01", s.traceID, s.spanID)
+}
+
+func unixNano(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
+
+func (s *Span) otlp() map[string]any {
+	status := map[string]any{"code": 1}
+	if s.err != nil {
+		status = map[string]any{"code": 2, "message": s.err.Error()}
+	}
+	span := map[string]any{
+		"traceId":           s.traceID,
+		"spanId":            s.spanID,
+		"name":              s.name,
+		"kind":              1,
+		"startTimeUnixNano": unixNano(s.start),
+		"endTimeUnixNano":   unixNano(s.end),
+		"status":            status,
+	}
+	if len(s.attrs) > 0 {
+		span["attributes"] = s.attrs
+	}
+	if len(s.events) > 0 {
+		span["events"] = s.events
+	}
+	if s.parentID != "" {
+		span["parentSpanId"] = s.parentID
+	}
+	return span
+}
+
+// Export ends the root span with the error of the run and sends spans and metrics to the collector.
+func (o *OTel) Export(err error) error {
+	if o == nil {
+		return nil
+	}
+	o.root.End(err)
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	resource := map[string]any{"attributes": Attrs("service.name", "tcpw")}
+	scope := map[string]any{"name": "tcpw"}
+
+	spans := []map[string]any{o.root.otlp()}
+	for _, s := range o.spans {
+		spans = append(spans, s.otlp())
+	}
+	traces := map[string]any{"resourceSpans": []any{map[string]any{
+		"resource":   resource,
+		"scopeSpans": []any{map[string]any{"scope": scope, "spans": spans}},
+	}}}
+	if err := o.post("/v1/traces", traces); err != nil {
+		return err
+	}
+
+	// attempts are counted from events of the spans
+	now := unixNano(time.Now())
+	attempts := map[[2]string]int{}
+	for _, s := range o.spans {
+		for _, e := range s.events {
+			if e.Name == "attempt" {
+				var endpoint, result string
+				for _, a := range e.Attributes {
+					switch a.Key {
+					case "endpoint":
+						endpoint = *a.Value.StringValue
+					case "result":
+						result = *a.Value.StringValue
+					}
+				}
+				attempts[[2]string{endpoint, result}]++
+			}
+		}
+	}
+	var points []any
+	for k, n := range attempts {
+		points = append(points, map[string]any{
+			"attributes":        Attrs("endpoint", k[0], "result", k[1]),
+			"startTimeUnixNano": unixNano(o.root.start),
+			"timeUnixNano":      now,
+			"asInt":             strconv.Itoa(n),
+		})
+	}
+	metrics := []any{map[string]any{
+		"name": "tcpw.attempts",
+		"sum":  map[string]any{"dataPoints": points, "aggregationTemporality": 2, "isMonotonic": true},
+	}}
+	if o.status != "" {
+		metrics = append(metrics, map[string]any{
+			"name": "tcpw.wait.duration",
+			"unit": "ms",
+			"gauge": map[string]any{"dataPoints": []any{map[string]any{
+				"attributes":   Attrs("status", o.status),
+				"timeUnixNano": now,
+				"asDouble":     o.waitMs,
+			}}},
+		})
+	}
+	return o.post("/v1/metrics", map[string]any{"resourceMetrics": []any{map[string]any{
+		"resource":     resource,
+		"scopeMetrics": []any{map[string]any{"scope": scope, "metrics": metrics}},
+	}}})
+}
+
+func (o *OTel) post(path string, payload any) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	if err = PostJSON(http.MethodPost, o.endpoint+path, string(body)); err != nil {
+		return fmt.Errorf("%s: %w", o.endpoint+path, err)
+	}
+	return nil
+}
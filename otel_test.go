@@ -0,0 +1,71 @@
+//go:build !minimal
+
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestOTel(t *testing.T) {
+	t.Run("Test spans and metrics are exported (-otel-endpoint)", func(t *testing.T) {
+		var mu sync.Mutex
+		bodies := map[string]string{}
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			b, _ := io.ReadAll(r.Body)
+			mu.Lock()
+			defer mu.Unlock()
+			bodies[r.URL.Path] = string(b)
+		}))
+		defer srv.Close()
+		t.Setenv("TRACEPARENT", "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01")
+
+		app := newApp()
+		addr := startListener("").String()
+		app.endpoints = []string{addr}
+		app.otelEndpoint = srv.URL
+		app.shell = `test "${TRACEPARENT#00-0af7651916cd43dd8448eb211c80319c-}" != "$TRACEPARENT"`
+		if err := app.Run(); err != nil {
+			t.Fatalf("Command did not receive TRACEPARENT: %v", err)
+		}
+
+		var traces struct {
+			ResourceSpans []struct {
+				ScopeSpans []struct {
+					Spans []struct {
+						TraceID      string `json:"traceId"`
+						ParentSpanID string `json:"parentSpanId"`
+						Name         string `json:"name"`
+						Events       []struct {
+							Name string `json:"name"`
+						} `json:"events"`
+					} `json:"spans"`
+				} `json:"scopeSpans"`
+			} `json:"resourceSpans"`
+		}
+		if err := json.Unmarshal([]byte(bodies["/v1/traces"]), &traces); err != nil {
+			t.Fatal(err)
+		}
+		spans := traces.ResourceSpans[0].ScopeSpans[0].Spans
+		var names []string
+		for _, s := range spans {
+			if s.TraceID != "0af7651916cd43dd8448eb211c80319c" {
+				t.Fatalf("Wrong trace ID: %s", s.TraceID)
+			}
+			names = append(names, s.Name)
+		}
+		if strings.Join(names, ",") != "tcpw,wait "+addr+",command "+filepath.Base(Shell()) || spans[0].ParentSpanID != "b7ad6b7169203331" ||
+			len(spans[1].Events) != 1 || spans[1].Events[0].Name != "attempt" {
+			t.Fatalf("Unexpected spans: %s", bodies["/v1/traces"])
+		}
+		if metrics := bodies["/v1/metrics"]; !strings.Contains(metrics, `"tcpw.attempts"`) || !strings.Contains(metrics, `"tcpw.wait.duration"`) {
+			t.Fatalf("Unexpected metrics: %s", metrics)
+		}
+	})
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Phases holds durations of the phases of a connection attempt.
+// DNS is resolved by attempts to endpoints with host names, not IP addresses.
+type Phases struct {
+	DNS     time.Duration
+	Connect time.Duration
+	TLS     time.Duration
+}
+
+// String returns the phases, which took place, e.g. 'dns 1.2ms, connect 310µs, tls 4.1ms'.
+func (p Phases) String() string {
+	var parts []string
+	if p.DNS > 0 {
+		parts = append(parts, fmt.Sprintf("dns %v", p.DNS.Round(time.Microsecond)))
+	}
+	parts = append(parts, fmt.Sprintf("connect %v", p.Connect.Round(time.Microsecond)))
+	if p.TLS > 0 {
+		parts = append(parts, fmt.Sprintf("tls %v", p.TLS.Round(time.Microsecond)))
+	}
+	return strings.Join(parts, ", ")
+}
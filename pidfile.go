@@ -0,0 +1,38 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// WritePidFile writes the PID of tcpw to the file atomically and returns a function removing it,
+// unless it was overwritten by another process meanwhile.
+func WritePidFile(path string) (remove func(), err error) {
+	pid := strconv.Itoa(os.Getpid())
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err = tmp.WriteString(pid + "\n"); err != nil {
+		_ = tmp.Close()
+		return nil, err
+	}
+	if err = tmp.Chmod(0644); err != nil {
+		_ = tmp.Close()
+		return nil, err
+	}
+	if err = tmp.Close(); err != nil {
+		return nil, err
+	}
+	if err = os.Rename(tmp.Name(), path); err != nil {
+		return nil, err
+	}
+	return func() {
+		if data, err := os.ReadFile(path); err == nil && strings.TrimSpace(string(data)) == pid {
+			_ = os.Remove(path)
+		}
+	}, nil
+}
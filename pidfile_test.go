@@ -0,0 +1,54 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+func TestWritePidFile(t *testing.T) {
+	t.Run("Test written and removed", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "tcpw.pid")
+		remove, err := WritePidFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if data, err := os.ReadFile(path); err != nil || string(data) != strconv.Itoa(os.Getpid())+"\n" {
+			t.Fatalf("Unexpected content: %q, %v", data, err)
+		}
+		remove()
+		if _, err = os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+			t.Fatalf("File is not removed: %v", err)
+		}
+	})
+
+	t.Run("Test file of another process is kept", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "tcpw.pid")
+		remove, err := WritePidFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = os.WriteFile(path, []byte("1\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		remove()
+		if _, err = os.Stat(path); err != nil {
+			t.Fatalf("File is removed: %v", err)
+		}
+	})
+
+	t.Run("Test run with pidfile", func(t *testing.T) {
+		app := newApp()
+		app.pidFile = filepath.Join(t.TempDir(), "tcpw.pid")
+		app.endpoints = []string{startListener("").String()}
+		app.command = []string{"sh", "-c", "test -f " + app.pidFile}
+		if err := app.Run(); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if _, err := os.Stat(app.pidFile); !errors.Is(err, os.ErrNotExist) {
+			t.Fatalf("File is not removed: %v", err)
+		}
+	})
+}
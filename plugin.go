@@ -0,0 +1,43 @@
+//go:build !minimal
+
+package main
+
+import (
+	"github.com/jackcvr/tcpw/tcpw"
+	"plugin"
+	"slices"
+	"strings"
+)
+
+// Plugins is the value of '-plugin' flag: Go plugins loaded as soon as the flag is parsed,
+// so endpoints of their schemes are accepted by '-a' afterwards.
+type Plugins []string
+
+func (p *Plugins) String() string {
+	return strings.Join(*p, ", ")
+}
+
+// Set loads the plugin, unless it is loaded already, see LoadPlugin.
+func (p *Plugins) Set(path string) error {
+	if slices.Contains(*p, path) {
+		return nil
+	}
+	if _, err := LoadPlugin(path); err != nil {
+		return err
+	}
+	*p = append(*p, path)
+	return nil
+}
+
+// LoadPlugin opens the Go plugin built with 'go build -buildmode=plugin', whose init functions
+// register checkers of its schemes with tcpw.Register, and returns the schemes it added.
+// The plugin must be built with the same version of Go and of package tcpw as the command.
+func LoadPlugin(path string) ([]string, error) {
+	before := tcpw.Schemes()
+	if _, err := plugin.Open(path); err != nil {
+		return nil, err
+	}
+	return slices.DeleteFunc(tcpw.Schemes(), func(scheme string) bool {
+		return slices.Contains(before, scheme)
+	}), nil
+}
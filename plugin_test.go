@@ -0,0 +1,43 @@
+//go:build !minimal
+
+package main
+
+import (
+	"context"
+	"net"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestPlugin(t *testing.T) {
+	if testing.Short() {
+		t.Skip("building a plugin takes a while")
+	}
+	path := filepath.Join(t.TempDir(), "echo.so")
+	// the plugin shares packages with the test binary, so they are built alike
+	args := []string{"build", "-buildmode=plugin", "-o", path}
+	if raceEnabled {
+		args = append(args, "-race")
+	}
+	if out, err := exec.Command("go", append(args, "./testdata/plugin")...).CombinedOutput(); err != nil {
+		t.Skipf("plugins are not supported: %v: %s", err, out)
+	}
+	var app App
+	// the flag is loaded before endpoints of the plugin given ahead of it
+	if err := app.Parse("tcpw", []string{"-a", "echo://up", "-plugin", path, "-plugin", path}); err != nil {
+		t.Fatal(err)
+	}
+	if len(app.plugins) != 1 || app.endpoints[0] != "echo://up" {
+		t.Fatalf("Unexpected plugins: %v, endpoints: %v", app.plugins, app.endpoints)
+	}
+	if err := app.Dial(context.Background(), net.Dialer{}, "echo://up"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := app.Dial(context.Background(), net.Dialer{}, "echo://down"); err == nil || err.Error() != "echo: down" {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err := LoadPlugin(filepath.Join(t.TempDir(), "missing.so")); err == nil {
+		t.Fatal("Missing plugin is loaded")
+	}
+}
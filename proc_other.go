@@ -0,0 +1,29 @@
+//go:build !(linux || darwin || freebsd || netbsd || openbsd || dragonfly)
+
+package main
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+func SetProcessGroup(*exec.Cmd) {}
+
+func Detach(*exec.Cmd) error {
+	return errors.New("'-daemon' is not supported on this platform")
+}
+
+func SignalProcess(cmd *exec.Cmd, sig syscall.Signal) error {
+	return cmd.Process.Signal(sig)
+}
+
+func IsTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+func SetCbreakMode(*os.File) (func(), error) {
+	return nil, errors.New("terminal modes are not supported")
+}
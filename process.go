@@ -0,0 +1,208 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+var ErrCmdTimeout = errors.New("command timed out")
+
+// children holds PIDs of processes started by tcpw, which must not be reaped by the reaper.
+// spawning is held for reading while a process is started and registered, and for writing by the reaper,
+// so a child exiting right away is never reaped before it is registered.
+var (
+	children sync.Map
+	spawning sync.RWMutex
+)
+
+// StartProcess starts the command registered in children, so it is left to WaitProcess by the reaper.
+// Every process started by tcpw, e.g. a hook or '-check-cmd', must be started with it.
+func StartProcess(cmd *exec.Cmd) error {
+	spawning.RLock()
+	defer spawning.RUnlock()
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	children.Store(cmd.Process.Pid, struct{}{})
+	return nil
+}
+
+// WaitProcess waits for the command started with StartProcess to exit and unregisters it.
+func WaitProcess(cmd *exec.Cmd) error {
+	err := cmd.Wait()
+	children.Delete(cmd.Process.Pid)
+	return err
+}
+
+// RunProcess starts the command with StartProcess and waits for it to exit, like exec.Cmd.Run.
+func RunProcess(cmd *exec.Cmd) error {
+	if err := StartProcess(cmd); err != nil {
+		return err
+	}
+	return WaitProcess(cmd)
+}
+
+// InterruptError is returned when tcpw is asked to stop by a signal.
+type InterruptError struct {
+	Signal os.Signal
+}
+
+func (e *InterruptError) Error() string {
+	return "interrupted by " + e.Signal.String()
+}
+
+// interrupted holds channels of Interrupts and the signal of Interrupt, once it is called.
+var interrupted struct {
+	sync.Mutex
+	sig   os.Signal
+	chans map[chan os.Signal]struct{}
+}
+
+// Interrupts returns a channel receiving signals which ask tcpw to stop, or those of Interrupt,
+// and a function to stop receiving them.
+func Interrupts() (<-chan os.Signal, func()) {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, os.Interrupt, syscall.SIGTERM)
+	interrupted.Lock()
+	defer interrupted.Unlock()
+	if interrupted.sig != nil {
+		ch <- interrupted.sig
+	}
+	if interrupted.chans == nil {
+		interrupted.chans = map[chan os.Signal]struct{}{}
+	}
+	interrupted.chans[ch] = struct{}{}
+	return ch, func() {
+		signal.Stop(ch)
+		interrupted.Lock()
+		defer interrupted.Unlock()
+		delete(interrupted.chans, ch)
+	}
+}
+
+// Interrupt asks tcpw to stop as if it received the signal, e.g. on a request of the Windows service manager.
+// Channels of Interrupts, including those created later, receive it.
+func Interrupt(sig os.Signal) {
+	interrupted.Lock()
+	defer interrupted.Unlock()
+	interrupted.sig = sig
+	for ch := range interrupted.chans {
+		select {
+		case ch <- sig:
+		default: // a signal is pending already
+		}
+	}
+}
+
+// InterruptContext returns a context, which is canceled with InterruptError once tcpw is asked to stop,
+// and a function to cancel it and stop receiving signals.
+func InterruptContext(parent context.Context) (context.Context, func()) {
+	ctx, cancel := context.WithCancelCause(parent)
+	interrupts, stop := Interrupts()
+	go func() {
+		select {
+		case sig := <-interrupts:
+			cancel(&InterruptError{sig})
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, func() {
+		stop()
+		cancel(nil)
+	}
+}
+
+// Interrupted returns the InterruptError of the context of InterruptContext, if tcpw was asked to stop.
+func Interrupted(ctx context.Context) error {
+	var intErr *InterruptError
+	if errors.As(context.Cause(ctx), &intErr) {
+		return intErr
+	}
+	return nil
+}
+
+// Start starts the command line and returns a channel receiving the result of its Wait.
+func (app App) Start(args []string, results []EndpointResult, elapsed time.Duration) (*exec.Cmd, <-chan error, error) {
+	cmd := app.Cmd(args, results, elapsed)
+	span := app.otel.Start("command "+filepath.Base(cmd.Args[0]), "command", strings.Join(cmd.Args, " "))
+	if span != nil {
+		cmd.Env = append(cmd.Env, "TRACEPARENT="+span.TraceParent())
+	}
+	if err := StartProcess(cmd); err != nil {
+		span.End(err)
+		return nil, nil, &LaunchError{err}
+	}
+	if app.executed != nil {
+		app.executed.Store(true)
+	}
+	app.Emit(Event{Type: EventCommand, State: "started", Pid: cmd.Process.Pid})
+	exited := make(chan error, 1)
+	go func() {
+		err := WaitProcess(cmd)
+		span.End(err)
+		code := cmd.ProcessState.ExitCode()
+		app.Emit(Event{Type: EventCommand, State: "exited", Pid: cmd.Process.Pid, ExitCode: &code})
+		exited <- err
+	}()
+	return cmd, exited, nil
+}
+
+// Wait waits for the started command to exit.
+// The command is terminated on '-cmd-timeout' or when tcpw is asked to stop.
+func (app App) Wait(cmd *exec.Cmd, exited <-chan error) error {
+	interrupts, stop := Interrupts()
+	defer stop()
+	var timeout <-chan time.Time
+	if app.cmdTimeout > 0 {
+		timer := time.NewTimer(app.cmdTimeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	select {
+	case err := <-exited:
+		return err
+	case sig := <-interrupts:
+		app.Info("received %v, terminating command", sig)
+		_ = app.Stop(cmd, exited)
+		return &InterruptError{sig}
+	case <-timeout:
+		app.Warn("command timed out after %v, terminating it", app.cmdTimeout)
+		_ = app.Stop(cmd, exited)
+		return ErrCmdTimeout
+	}
+}
+
+// Stop sends '-signal' (SIGTERM by default) to the process group of the started command
+// and waits for it to exit. If it is still running after '-grace' period, it is killed.
+func (app App) Stop(cmd *exec.Cmd, exited <-chan error) error {
+	sig := app.signal.Signal
+	if sig == 0 {
+		sig = syscall.SIGTERM
+	}
+	if err := SignalProcess(cmd, sig); err != nil {
+		_ = SignalProcess(cmd, syscall.SIGKILL)
+		return <-exited
+	}
+	var grace <-chan time.Time
+	if app.grace > 0 {
+		timer := time.NewTimer(app.grace)
+		defer timer.Stop()
+		grace = timer.C
+	}
+	select {
+	case err := <-exited:
+		return err
+	case <-grace:
+		app.Warn("command did not exit in %v, killing it", app.grace)
+		_ = SignalProcess(cmd, syscall.SIGKILL)
+		return <-exited
+	}
+}
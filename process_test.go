@@ -0,0 +1,70 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestAppWait(t *testing.T) {
+	t.Run("Test command timeout (-cmd-timeout)", func(t *testing.T) {
+		app := newApp()
+		app.endpoints = []string{startListener("").String()}
+		app.cmdTimeout = 100 * time.Millisecond
+		// the whole process group is terminated, including the background sleep
+		app.shell = "sleep 10 & wait"
+		start := time.Now()
+		if err := app.Run(); err != ErrCmdTimeout {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if elapsed := time.Since(start); elapsed > 2*time.Second {
+			t.Fatalf("Command was not terminated in time: %v", elapsed)
+		}
+		if code := app.ExitCode(ErrCmdTimeout); code != ExitCmdTimeout {
+			t.Fatalf("Wrong exit code: %d", code)
+		}
+	})
+
+	t.Run("Test kill after grace period (-grace)", func(t *testing.T) {
+		app := newApp()
+		app.endpoints = []string{startListener("").String()}
+		app.cmdTimeout = 100 * time.Millisecond
+		app.grace = 100 * time.Millisecond
+		app.shell = "trap '' TERM; sleep 10 & wait"
+		start := time.Now()
+		if err := app.Run(); err != ErrCmdTimeout {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if elapsed := time.Since(start); elapsed > 2*time.Second {
+			t.Fatalf("Command was not killed in time: %v", elapsed)
+		}
+	})
+}
+
+func TestInterrupt(t *testing.T) {
+	app := newApp()
+	app.endpoints = []string{getFreeTCPAddr().String()}
+	app.interval = 10 * time.Millisecond
+	app.on = "any"
+	path := filepath.Join(t.TempDir(), "executed")
+	app.shell = "touch " + path
+	go func() {
+		time.Sleep(200 * time.Millisecond)
+		p, _ := os.FindProcess(os.Getpid())
+		_ = p.Signal(syscall.SIGTERM)
+	}()
+	err := app.Run()
+	var intErr *InterruptError
+	if !errors.As(err, &intErr) || intErr.Signal != syscall.SIGTERM {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if code := app.ExitCode(err); code != 143 {
+		t.Fatalf("Wrong exit code: %d", code)
+	}
+	if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("Command was executed: %v", err)
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const clearLine = "\r\033[K"
+
+// Progress is a single line on a terminal showing elapsed and remaining time of the wait,
+// and the number of attempts to endpoints, which are still pending.
+// Methods of nil Progress do nothing.
+type Progress struct {
+	mu        sync.Mutex
+	w         io.Writer
+	start     time.Time
+	timeout   time.Duration
+	endpoints []string
+	attempts  map[string]int
+	done      map[string]bool
+	stop      chan struct{}
+	stopped   chan struct{}
+}
+
+func NewProgress(w io.Writer, endpoints []string, timeout time.Duration) *Progress {
+	return &Progress{
+		w:         w,
+		start:     time.Now(),
+		timeout:   timeout,
+		endpoints: endpoints,
+		attempts:  map[string]int{},
+		done:      map[string]bool{},
+		stop:      make(chan struct{}),
+		stopped:   make(chan struct{}),
+	}
+}
+
+// ShowProgress reports whether the progress line is shown:
+// only if stderr is a terminal and it is not disabled, silenced or filled with other output.
+func (app App) ShowProgress() bool {
+	return !app.noProgress && app.logFormat != "json" && app.logLevel.Level < slog.LevelError && IsTerminal(os.Stderr)
+}
+
+// Start redraws the line periodically until Stop is called.
+func (p *Progress) Start() {
+	if p == nil {
+		return
+	}
+	go func() {
+		defer close(p.stopped)
+		ticker := time.NewTicker(100 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			p.mu.Lock()
+			_, _ = io.WriteString(p.w, clearLine+p.Line())
+			p.mu.Unlock()
+			select {
+			case <-ticker.C:
+			case <-p.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops redrawing and clears the line.
+func (p *Progress) Stop() {
+	if p == nil {
+		return
+	}
+	close(p.stop)
+	<-p.stopped
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	_, _ = io.WriteString(p.w, clearLine)
+}
+
+// Attempt counts a connection attempt to the endpoint.
+func (p *Progress) Attempt(addr string) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.attempts[addr]++
+}
+
+// Done removes the endpoint from pending ones.
+func (p *Progress) Done(addr string) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.done[addr] = true
+}
+
+// Pause clears the line while write prints something else, e.g. a log line.
+func (p *Progress) Pause(write func()) {
+	if p == nil {
+		write()
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	_, _ = io.WriteString(p.w, clearLine)
+	write()
+}
+
+// Line returns the text of the progress line, e.g. '[3s/30s] waiting for 1/2: db:5432 (3)'.
+func (p *Progress) Line() string {
+	elapsed := time.Since(p.start).Truncate(100 * time.Millisecond)
+	var b strings.Builder
+	if p.timeout > 0 {
+		fmt.Fprintf(&b, "[%v/%v, %v left]", elapsed, p.timeout, (p.timeout - elapsed).Truncate(100*time.Millisecond))
+	} else {
+		fmt.Fprintf(&b, "[%v]", elapsed)
+	}
+	var pending []string
+	for _, addr := range p.endpoints {
+		if !p.done[addr] {
+			pending = append(pending, fmt.Sprintf("%s (%d)", addr, p.attempts[addr]))
+		}
+	}
+	fmt.Fprintf(&b, " waiting for %d/%d: %s", len(pending), len(p.endpoints), strings.Join(pending, ", "))
+	return b.String()
+}
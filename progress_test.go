@@ -0,0 +1,44 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestProgress(t *testing.T) {
+	t.Run("Test line shows pending endpoints with attempts", func(t *testing.T) {
+		p := NewProgress(&bytes.Buffer{}, []string{"127.0.0.1:1", "127.0.0.1:2"}, 30*time.Second)
+		p.Attempt("127.0.0.1:1")
+		p.Attempt("127.0.0.1:1")
+		p.Attempt("127.0.0.1:2")
+		p.Done("127.0.0.1:2")
+		line := p.Line()
+		if !strings.HasPrefix(line, "[0s/30s, 30s left]") || !strings.HasSuffix(line, " waiting for 1/2: 127.0.0.1:1 (2)") {
+			t.Fatalf("Unexpected line: %q", line)
+		}
+	})
+
+	t.Run("Test line is cleared on stop", func(t *testing.T) {
+		var buf bytes.Buffer
+		p := NewProgress(&buf, []string{"127.0.0.1:1"}, 0)
+		p.Start()
+		p.Stop()
+		if out := buf.String(); !strings.Contains(out, "waiting for 1/1") || !strings.HasSuffix(out, clearLine) {
+			t.Fatalf("Unexpected output: %q", out)
+		}
+	})
+
+	t.Run("Test nil progress does nothing", func(t *testing.T) {
+		var p *Progress
+		p.Start()
+		p.Attempt("127.0.0.1:1")
+		called := false
+		p.Pause(func() { called = true })
+		p.Stop()
+		if !called {
+			t.Fatal("Write was not called")
+		}
+	})
+}
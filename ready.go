@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// Ready is an action performed when endpoints become available while the command is already running.
+type Ready struct {
+	value  string
+	file   string
+	signal Signal
+	url    string
+}
+
+func (r *Ready) String() string {
+	if r == nil {
+		return ""
+	}
+	return r.value
+}
+
+func (r *Ready) Set(value string) error {
+	kind, arg, _ := strings.Cut(value, ":")
+	*r = Ready{value: value}
+	switch kind {
+	case "file":
+		if arg == "" {
+			return errors.New("empty file path")
+		}
+		r.file = arg
+	case "signal":
+		return r.signal.Set(arg)
+	case "http", "https":
+		r.url = value
+	default:
+		return errors.New("must be one of 'file:PATH', 'signal:NAME' or URL")
+	}
+	return nil
+}
+
+// Do performs the action for the running command.
+func (r Ready) Do(cmd *exec.Cmd) error {
+	switch {
+	case r.file != "":
+		now := time.Now()
+		if err := os.Chtimes(r.file, now, now); err == nil || !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		f, err := os.Create(r.file)
+		if err != nil {
+			return err
+		}
+		return f.Close()
+	case r.signal.Signal != 0:
+		return cmd.Process.Signal(r.signal.Signal)
+	case r.url != "":
+		return GetURL(r.url)
+	}
+	return nil
+}
+
+// RunReady starts the command immediately and performs the '-ready' action
+// as soon as the endpoints become available.
+// The command is terminated if the endpoints are not available in time.
+func (app App) RunReady() error {
+	cmd, exited, err := app.Start(app.Args(), nil, 0)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := InterruptContext(context.Background())
+	defer cancel()
+	connected := make(chan error, 1)
+	var results []EndpointResult
+	go func() {
+		results = app.Connect(ctx)
+		connected <- app.WaitErr(results)
+	}()
+
+	select {
+	case err = <-exited:
+		return err
+	case err = <-connected:
+		if intErr := Interrupted(ctx); intErr != nil {
+			err = intErr
+		} else if err := app.resume.Remove(); err != nil {
+			app.Error("failed to remove resume file: %v", err)
+		}
+	}
+	if err == nil {
+		if err = app.ready.Do(cmd); err == nil {
+			app.Debug("performed ready action %s", app.ready.String())
+			return app.Wait(cmd, exited)
+		}
+	}
+	app.Report(results, err)
+	_ = app.Stop(cmd, exited)
+	return err
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRunReady(t *testing.T) {
+	t.Run("Test success with file action", func(t *testing.T) {
+		app := newApp()
+		addr := getFreeTCPAddr().String()
+		go func() {
+			time.Sleep(250 * time.Millisecond)
+			_ = startListener(addr)
+		}()
+		app.endpoints = []string{addr}
+		app.workdir = t.TempDir()
+		if err := app.ready.Set("file:" + app.workdir + "/ready"); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		app.shell = "while [ ! -f ready ]; do sleep 0.05; done"
+		if err := app.Run(); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	})
+
+	t.Run("Test success with signal action", func(t *testing.T) {
+		app := newApp()
+		addr := getFreeTCPAddr().String()
+		go func() {
+			// give the shell time to set the trap
+			time.Sleep(250 * time.Millisecond)
+			_ = startListener(addr)
+		}()
+		app.endpoints = []string{addr}
+		if err := app.ready.Set("signal:USR1"); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		app.shell = "trap 'exit 0' USR1; while true; do sleep 0.05; done"
+		if err := app.Run(); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	})
+
+	t.Run("Test fail terminates command", func(t *testing.T) {
+		app := newApp()
+		app.timeout = 100 * time.Millisecond
+		app.endpoints = []string{getFreeTCPAddr().String()}
+		if err := app.ready.Set("file:" + t.TempDir() + "/ready"); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		app.shell = "exec sleep 10"
+		if err := app.Run(); !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	})
+}
+
+func TestReadySet(t *testing.T) {
+	var r Ready
+	for _, value := range []string{"file:/tmp/ready", "signal:HUP", "http://localhost/ready"} {
+		if err := r.Set(value); err != nil {
+			t.Fatalf("Unexpected error for %s: %v", value, err)
+		}
+	}
+	for _, value := range []string{"file:", "signal:NOPE", "ready"} {
+		if err := r.Set(value); err == nil {
+			t.Fatalf("Unexpected success for %s", value)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+const prSetChildSubreaper = 36
+
+// offset of si_pid in siginfo_t
+const siginfoPidOffset = 3*4 + (unsafe.Sizeof(uintptr(0)) - 4)
+
+// StartReaper reaps orphaned processes re-parented to tcpw until the returned function is called.
+// Unless tcpw runs as PID 1, it is registered as a child subreaper to receive them.
+// Processes started by tcpw with StartProcess are left to WaitProcess.
+func StartReaper() func() {
+	if os.Getpid() != 1 {
+		_, _, _ = syscall.Syscall(syscall.SYS_PRCTL, prSetChildSubreaper, 1, 0)
+	}
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGCHLD)
+	ticker := time.NewTicker(time.Second)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-sigs:
+			case <-ticker.C:
+			case <-done:
+				return
+			}
+			ReapOrphans()
+		}
+	}()
+	return func() {
+		signal.Stop(sigs)
+		ticker.Stop()
+		close(done)
+	}
+}
+
+// ReapOrphans reaps exited children, which were not started by tcpw, see StartProcess.
+func ReapOrphans() {
+	spawning.Lock()
+	defer spawning.Unlock()
+	for {
+		// peek at an exited child without reaping it
+		var info [128]byte
+		_, _, errno := syscall.Syscall6(syscall.SYS_WAITID, 0 /* P_ALL */, 0, uintptr(unsafe.Pointer(&info)),
+			syscall.WEXITED|syscall.WNOHANG|syscall.WNOWAIT, 0, 0)
+		pid := int(*(*int32)(unsafe.Pointer(&info[siginfoPidOffset])))
+		if errno != 0 || pid == 0 {
+			return
+		}
+		if _, ok := children.Load(pid); ok {
+			// it will be reaped by its exec.Cmd soon, try again later
+			return
+		}
+		var status syscall.WaitStatus
+		if _, err := syscall.Wait4(pid, &status, syscall.WNOHANG, nil); err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStartReaper(t *testing.T) {
+	t.Run("Test orphaned zombie is reaped", func(t *testing.T) {
+		defer StartReaper()()
+		var out bytes.Buffer
+		cmd := exec.Command("sh", "-c", "sleep 0.1 & echo $!")
+		cmd.Stdout = &out
+		if err := RunProcess(cmd); err != nil {
+			t.Fatal(err)
+		}
+		stat := "/proc/" + strings.TrimSpace(out.String()) + "/stat"
+		deadline := time.Now().Add(3 * time.Second)
+		for {
+			if _, err := os.Stat(stat); os.IsNotExist(err) {
+				return
+			}
+			if time.Now().After(deadline) {
+				t.Fatal("Orphan was not reaped")
+			}
+			time.Sleep(50 * time.Millisecond)
+		}
+	})
+
+	t.Run("Test exit status of started processes is kept", func(t *testing.T) {
+		defer StartReaper()()
+		app := newApp()
+		app.checkCmd = "exit 3"
+		for range 300 {
+			if err := app.Hook("exit 3"); err == nil || err.Error() != "exit status 3" {
+				t.Fatalf("Unexpected error of hook: %v", err)
+			}
+			var exitErr *exec.ExitError
+			if err := app.CheckCmd(context.Background(), "localhost:80"); !errors.As(err, &exitErr) || exitErr.ExitCode() != 3 {
+				t.Fatalf("Unexpected error of check command: %v", err)
+			}
+		}
+	})
+}
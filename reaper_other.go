@@ -0,0 +1,7 @@
+//go:build !linux
+
+package main
+
+func StartReaper() func() {
+	return func() {}
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// RecordHeader is the first line of '-record' files, describing the run whose attempts follow, one per line.
+type RecordHeader struct {
+	Version    string             `json:"version"`
+	Start      time.Time          `json:"start"`
+	TimeoutMs  int64              `json:"timeout_ms"`
+	IntervalMs int64              `json:"interval_ms"`
+	Endpoints  []RecordedEndpoint `json:"endpoints"`
+}
+
+type RecordedEndpoint struct {
+	Endpoint string `json:"endpoint"`
+	Label    string `json:"label,omitempty"`
+}
+
+// RecordedAttempt is a line of '-record' files per connection attempt.
+type RecordedAttempt struct {
+	Endpoint string  `json:"endpoint"`
+	OffsetMs float64 `json:"offset_ms"` // since the start of the run
+	AttemptResult
+}
+
+// Recorder writes connection attempts to '-record' file, to replay the run with '-replay'.
+type Recorder struct {
+	mu    sync.Mutex
+	file  *os.File
+	enc   *json.Encoder
+	start time.Time
+}
+
+// CreateRecorder creates or truncates the file and writes the header of the run of app to it.
+func CreateRecorder(path string, app App) (*Recorder, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	r := &Recorder{file: f, enc: json.NewEncoder(f), start: time.Now()}
+	header := RecordHeader{
+		Version:    Version(),
+		Start:      r.start,
+		TimeoutMs:  app.timeout.Milliseconds(),
+		IntervalMs: app.interval.Milliseconds(),
+		Endpoints:  make([]RecordedEndpoint, len(app.endpoints)),
+	}
+	for i, addr := range app.endpoints {
+		header.Endpoints[i] = RecordedEndpoint{Endpoint: addr, Label: app.labels[addr]}
+	}
+	if err = r.enc.Encode(header); err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *Recorder) Close() error {
+	return r.file.Close()
+}
+
+// Record writes the attempt to the endpoint, which started at the time and failed with err, unless it is nil.
+func (r *Recorder) Record(addr string, start time.Time, err error) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.enc.Encode(RecordedAttempt{
+		Endpoint:      addr,
+		OffsetMs:      float64(start.Sub(r.start)) / float64(time.Millisecond),
+		AttemptResult: NewAttemptResult(err, time.Since(start)),
+	})
+}
+
+// Replay returns results of connection attempts of the run recorded in '-replay' file, in order per endpoint.
+type Replay struct {
+	Header   RecordHeader
+	mu       sync.Mutex
+	attempts map[string][]AttemptResult
+}
+
+// LoadReplay reads the file written with '-record'.
+func LoadReplay(path string) (*Replay, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	r := &Replay{attempts: map[string][]AttemptResult{}}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 1<<20)
+	for n := 1; scanner.Scan(); n++ {
+		if n == 1 {
+			err = json.Unmarshal(scanner.Bytes(), &r.Header)
+		} else {
+			var a RecordedAttempt
+			if err = json.Unmarshal(scanner.Bytes(), &a); err == nil {
+				r.attempts[a.Endpoint] = append(r.attempts[a.Endpoint], a.AttemptResult)
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: line %d: %w", path, n, err)
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, err
+	}
+	if r.Header.Version == "" {
+		return nil, fmt.Errorf("%s: no header of a recorded run", path)
+	}
+	return r, nil
+}
+
+// Next returns the result of the next attempt to the endpoint. Once attempts run out, the last one is repeated.
+func (r *Replay) Next(addr string) (AttemptResult, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	attempts := r.attempts[addr]
+	if len(attempts) == 0 {
+		return AttemptResult{}, false
+	}
+	if len(attempts) > 1 {
+		r.attempts[addr] = attempts[1:]
+	}
+	return attempts[0], true
+}
+
+// ApplyReplay loads '-replay' file and takes endpoints, timeout and interval of the recorded run, unless they are given.
+func (app *App) ApplyReplay(fs *flag.FlagSet) error {
+	replay, err := LoadReplay(app.replayPath)
+	if err != nil {
+		return err
+	}
+	app.replay = replay
+	given := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) {
+		given[f.Name] = true
+	})
+	if len(app.endpoints) == 0 {
+		for _, e := range replay.Header.Endpoints {
+			app.endpoints = append(app.endpoints, e.Endpoint)
+			if e.Label != "" {
+				app.labels[e.Endpoint] = e.Label
+			}
+		}
+	}
+	if !given["t"] && !given["timeout"] {
+		app.timeout = time.Duration(replay.Header.TimeoutMs) * time.Millisecond
+	}
+	if !given["i"] && !given["wait-retry-interval"] {
+		app.interval = time.Duration(replay.Header.IntervalMs) * time.Millisecond
+	}
+	return nil
+}
+
+// DialReplay returns the result of the next recorded attempt to the endpoint after its latency, without connecting.
+func (app App) DialReplay(ctx context.Context, addr string) error {
+	result, ok := app.replay.Next(addr)
+	if !ok {
+		return &AttemptError{Msg: fmt.Sprintf("no attempts of %s in %s", addr, app.replayPath), Fatal: true}
+	}
+	timer := time.NewTimer(time.Duration(result.LatencyMs * float64(time.Millisecond)))
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return result.Err("")
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// RecordAttempt writes the attempt to '-record' file, if any.
+func (app App) RecordAttempt(addr string, start time.Time, err error) {
+	if app.recorder == nil {
+		return
+	}
+	if recordErr := app.recorder.Record(addr, start, err); recordErr != nil && !errors.Is(recordErr, os.ErrClosed) {
+		app.Error("failed to record attempt: %v", recordErr)
+	}
+}
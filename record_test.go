@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestRecord(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "run.ndjson")
+	l := serve("127.0.0.1:0")
+	defer l.Close()
+	up, down := l.Addr().String(), getFreeTCPAddr().String()
+	ctx := context.Background()
+
+	app := newApp()
+	if err := app.Parse("tcpw", []string{"-t", "3s", "-i", "10ms", "-a", "db=" + up, "-a", down, "-record", path}); err != nil {
+		t.Fatal(err)
+	}
+	var err error
+	if app.recorder, err = CreateRecorder(app.recordPath, app); err != nil {
+		t.Fatal(err)
+	}
+	for _, addr := range []string{up, down, up} {
+		_ = app.Dial(ctx, net.Dialer{}, addr)
+	}
+	if err := app.recorder.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("Test replay", func(t *testing.T) {
+		l.Close()
+		app := newApp()
+		if err := app.Parse("tcpw", []string{"-replay", path, "-i", "1s"}); err != nil {
+			t.Fatal(err)
+		}
+		if len(app.endpoints) != 2 || app.endpoints[0] != up || app.labels[up] != "db" || app.timeout != 3*time.Second || app.interval != time.Second {
+			t.Fatalf("Unexpected run: %v %v %v %v", app.endpoints, app.labels, app.timeout, app.interval)
+		}
+		// two recorded attempts, then the last one again
+		for range 3 {
+			if err := app.Dial(ctx, net.Dialer{}, up); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+		}
+		for range 2 {
+			if err := app.Dial(ctx, net.Dialer{}, down); !errors.Is(err, syscall.ECONNREFUSED) || IsFatal(err) {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+		}
+		if err := app.Dial(ctx, net.Dialer{}, "127.0.0.1:1"); !IsFatal(err) || err.Error() != "no attempts of 127.0.0.1:1 in "+path {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	})
+
+	t.Run("Test invalid file", func(t *testing.T) {
+		if _, err := LoadReplay(filepath.Join(filepath.Dir(path), "missing")); !errors.Is(err, syscall.ENOENT) {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		app := newApp()
+		if err := app.Parse("tcpw", []string{"-replay", "record_test.go"}); err == nil || err.Error() != "record_test.go: line 1: invalid character 'p' looking for beginning of value" {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	})
+}
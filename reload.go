@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"slices"
+	"syscall"
+)
+
+// LoadEndpoints reads endpoints of the '-config' file with their labels and options.
+func LoadEndpoints(path string, hosts Hosts) (LabeledEndpoints, error) {
+	le := LabeledEndpoints{&Endpoints{}, Labels{}, hosts, map[string]EndpointOptions{}}
+	values, err := LoadConfig(path)
+	if err != nil {
+		return le, err
+	}
+	for _, v := range values {
+		if name, ok := configAliases[v.Key]; (ok && name == "a") || v.Key == "a" {
+			if err = le.Set(v.Value); err != nil {
+				return le, fmt.Errorf("%s: %s: invalid value %q for '%s': %w", path, v.Source, v.Value, v.Key, err)
+			}
+		}
+	}
+	return le, nil
+}
+
+// Reload re-reads endpoints of the '-config' file and returns the added and removed ones.
+// Endpoints are kept if the file is invalid or has none of them.
+func (app *App) Reload() (added, removed []string, err error) {
+	le, err := LoadEndpoints(app.reloadPath, app.hosts)
+	if err != nil {
+		return nil, nil, err
+	}
+	endpoints := *le.Endpoints
+	if len(endpoints) == 0 {
+		return nil, nil, fmt.Errorf("%s: no endpoints", app.reloadPath)
+	}
+	for _, addr := range endpoints {
+		if !slices.Contains(app.endpoints, addr) {
+			added = append(added, addr)
+		}
+	}
+	for _, addr := range app.endpoints {
+		if !slices.Contains(endpoints, addr) {
+			removed = append(removed, addr)
+		}
+	}
+	app.endpoints, app.labels, app.endpointOptions = endpoints, le.labels, le.options
+	return added, removed, nil
+}
+
+// Hangups returns a channel receiving SIGHUP, which asks to reload endpoints, and a function to stop receiving it.
+// Without '-config' file the channel is nil, so SIGHUP keeps its default action.
+func (app App) Hangups() (<-chan os.Signal, func()) {
+	if app.reloadPath == "" {
+		return nil, func() {}
+	}
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, syscall.SIGHUP)
+	return ch, func() {
+		signal.Stop(ch)
+	}
+}
+
+// ReloadOnHangup reloads endpoints after SIGHUP and logs the changes. It reports whether they have changed.
+func (app *App) ReloadOnHangup() bool {
+	previous := *app
+	added, removed, err := app.Reload()
+	if err != nil {
+		app.Error("failed to reload endpoints: %v", err)
+		return false
+	}
+	for _, addr := range added {
+		app.Info("added endpoint %s", app.Label(addr))
+	}
+	for _, addr := range removed {
+		app.Info("removed endpoint %s", previous.Label(addr))
+	}
+	return len(added) > 0 || len(removed) > 0
+}
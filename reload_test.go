@@ -0,0 +1,70 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAppReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tcpw.yaml")
+	write := func(content string) {
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("timeout: 5s\nendpoints:\n  - 127.0.0.1:5432\n  - 127.0.0.1:6379\n")
+	var app App
+	if err := app.Parse("tcpw", []string{"-config", path}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if app.reloadPath != path {
+		t.Fatalf("Wrong reload path: %q", app.reloadPath)
+	}
+	d := NewDashboard(app)
+
+	t.Run("Test added and removed endpoints", func(t *testing.T) {
+		write("endpoints:\n  - address: 127.0.0.1:6379\n    label: cache\n  - 127.0.0.1:8080\n")
+		added, removed, err := app.Reload()
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if strings.Join(added, " ") != "127.0.0.1:8080" || strings.Join(removed, " ") != "127.0.0.1:5432" {
+			t.Fatalf("Wrong changes: %v, %v", added, removed)
+		}
+		if labels := strings.Join(app.Labels(), " "); labels != "cache 127.0.0.1:8080" {
+			t.Fatalf("Wrong endpoints: %s", labels)
+		}
+
+		cache := d.rows[1]
+		rows := d.Sync(app)
+		if len(rows) != 1 || rows[0].Addr != "127.0.0.1:8080" || len(d.rows) != 2 || d.rows[0] != cache || cache.Label != "cache" {
+			t.Fatalf("Wrong rows: %+v", d.rows)
+		}
+	})
+
+	t.Run("Test invalid file", func(t *testing.T) {
+		write("endpoints: [localhost:99999]\n")
+		if _, _, err := app.Reload(); err == nil {
+			t.Fatal("Unexpected success")
+		}
+		write("timeout: 5s\n")
+		if _, _, err := app.Reload(); err == nil {
+			t.Fatal("Unexpected success")
+		}
+		if len(app.endpoints) != 2 {
+			t.Fatalf("Endpoints are not kept: %v", app.endpoints)
+		}
+	})
+
+	t.Run("Test endpoints on the command line", func(t *testing.T) {
+		var app App
+		if err := app.Parse("tcpw", []string{"-config", path, "-a", "127.0.0.1:80"}); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if app.reloadPath != "" {
+			t.Fatalf("Unexpected reload path: %q", app.reloadPath)
+		}
+	})
+}
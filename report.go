@@ -0,0 +1,133 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// Report is the summary of the run written with '-report'.
+type Report struct {
+	Status     string           `json:"status"`
+	DurationMs int64            `json:"duration_ms"`
+	Endpoints  []EndpointReport `json:"endpoints"`
+	ExitCode   *int             `json:"exit_code"` // of the command, nil if it was not executed
+	Error      string           `json:"error,omitempty"`
+}
+
+type EndpointReport struct {
+	Endpoint  string        `json:"endpoint"`
+	Status    string        `json:"status"`
+	Attempts  int           `json:"attempts"`
+	LatencyMs float64       `json:"latency_ms"` // of the last connection attempt
+	Phases    *PhasesReport `json:"phases"`     // of the successful connection attempt
+	SuccessMs *int64        `json:"success_ms"` // time since start of the wait, nil if the endpoint was not available
+	Error     string        `json:"error,omitempty"`
+}
+
+// PhasesReport holds durations of phases of a connection attempt in milliseconds.
+type PhasesReport struct {
+	DNSMs     float64 `json:"dns_ms"`
+	ConnectMs float64 `json:"connect_ms"`
+	TLSMs     float64 `json:"tls_ms"`
+}
+
+func NewReport(results []EndpointResult, elapsed time.Duration, err error) Report {
+	report := Report{
+		Status:     Status(err),
+		DurationMs: elapsed.Milliseconds(),
+		Endpoints:  []EndpointReport{},
+	}
+	if err != nil {
+		report.Error = err.Error()
+	}
+	for _, r := range results {
+		ep := EndpointReport{Endpoint: r.Name(), Status: Status(r.Err), Attempts: r.Attempts, LatencyMs: Latency(r.Latency)}
+		if r.Ready {
+			ms := r.Duration.Milliseconds()
+			ep.SuccessMs = &ms
+			ep.Phases = &PhasesReport{Latency(r.Phases.DNS), Latency(r.Phases.Connect), Latency(r.Phases.TLS)}
+		} else {
+			ep.Error = r.Err.Error()
+		}
+		report.Endpoints = append(report.Endpoints, ep)
+	}
+	return report
+}
+
+// ParseReport splits the '-report' value in the form 'FORMAT[:FILE]'.
+func ParseReport(value string) (format, path string, err error) {
+	format, path, _ = strings.Cut(value, ":")
+	if format != "json" && format != "yaml" {
+		return "", "", errors.New("only 'json' or 'yaml' are allowed for '-report' argument, optionally followed by ':FILE'")
+	}
+	return format, path, nil
+}
+
+// WriteReport writes the report to '-report' file, or stdout if no file is specified.
+func (app App) WriteReport(report Report) error {
+	format, path, err := ParseReport(app.report)
+	if err != nil {
+		return err
+	}
+	var w io.Writer = os.Stdout
+	if path != "" {
+		f, err := os.Create(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	if format == "yaml" {
+		_, err = w.Write(report.YAML())
+		return err
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(report)
+}
+
+// YAML returns the report in YAML format. Values are written in JSON syntax, which is valid YAML.
+func (r Report) YAML() []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "status: %s\n", yamlValue(r.Status))
+	fmt.Fprintf(&b, "duration_ms: %d\n", r.DurationMs)
+	fmt.Fprintf(&b, "exit_code: %s\n", yamlValue(r.ExitCode))
+	if r.Error != "" {
+		fmt.Fprintf(&b, "error: %s\n", yamlValue(r.Error))
+	}
+	if len(r.Endpoints) == 0 {
+		b.WriteString("endpoints: []\n")
+	} else {
+		b.WriteString("endpoints:\n")
+	}
+	for _, ep := range r.Endpoints {
+		fmt.Fprintf(&b, "  - endpoint: %s\n", yamlValue(ep.Endpoint))
+		fmt.Fprintf(&b, "    status: %s\n", yamlValue(ep.Status))
+		fmt.Fprintf(&b, "    attempts: %d\n", ep.Attempts)
+		fmt.Fprintf(&b, "    latency_ms: %s\n", yamlValue(ep.LatencyMs))
+		fmt.Fprintf(&b, "    success_ms: %s\n", yamlValue(ep.SuccessMs))
+		if ep.Phases != nil {
+			b.WriteString("    phases:\n")
+			fmt.Fprintf(&b, "      dns_ms: %s\n", yamlValue(ep.Phases.DNSMs))
+			fmt.Fprintf(&b, "      connect_ms: %s\n", yamlValue(ep.Phases.ConnectMs))
+			fmt.Fprintf(&b, "      tls_ms: %s\n", yamlValue(ep.Phases.TLSMs))
+		} else {
+			b.WriteString("    phases: null\n")
+		}
+		if ep.Error != "" {
+			fmt.Fprintf(&b, "    error: %s\n", yamlValue(ep.Error))
+		}
+	}
+	return []byte(b.String())
+}
+
+func yamlValue(v any) string {
+	b, _ := json.Marshal(v)
+	return string(b)
+}
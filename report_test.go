@@ -0,0 +1,81 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAppWriteReport(t *testing.T) {
+	t.Run("Test JSON report (-report json:FILE)", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "report.json")
+		app := newApp()
+		addr := startListener("").String()
+		app.endpoints = []string{addr}
+		app.report = "json:" + path
+		app.command = []string{"false"}
+		if err := app.Run(); err == nil {
+			t.Fatal("Unexpected success")
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var report Report
+		if err = json.Unmarshal(data, &report); err != nil {
+			t.Fatal(err)
+		}
+		if report.Status != "success" || report.ExitCode == nil || *report.ExitCode != 1 || len(report.Endpoints) != 1 {
+			t.Fatalf("Unexpected report: %s", data)
+		}
+		if ep := report.Endpoints[0]; ep.Endpoint != addr || ep.Status != "success" || ep.Attempts != 1 || ep.LatencyMs <= 0 || ep.SuccessMs == nil {
+			t.Fatalf("Unexpected endpoint report: %s", data)
+		}
+	})
+
+	t.Run("Test YAML report (-report yaml)", func(t *testing.T) {
+		ms := int64(5)
+		report := Report{
+			Status:     "timeout",
+			DurationMs: 1000,
+			Endpoints: []EndpointReport{
+				{Endpoint: "127.0.0.1:1", Status: "timeout", Attempts: 10, Error: "context deadline exceeded"},
+				{Endpoint: "127.0.0.1:2", Status: "success", Attempts: 1, LatencyMs: 0.5, SuccessMs: &ms, Phases: &PhasesReport{ConnectMs: 0.4}},
+			},
+			Error: "context deadline exceeded",
+		}
+		want := `status: "timeout"
+duration_ms: 1000
+exit_code: null
+error: "context deadline exceeded"
+endpoints:
+  - endpoint: "127.0.0.1:1"
+    status: "timeout"
+    attempts: 10
+    latency_ms: 0
+    success_ms: null
+    phases: null
+    error: "context deadline exceeded"
+  - endpoint: "127.0.0.1:2"
+    status: "success"
+    attempts: 1
+    latency_ms: 0.5
+    success_ms: 5
+    phases:
+      dns_ms: 0
+      connect_ms: 0.4
+      tls_ms: 0
+`
+		if got := string(report.YAML()); got != want {
+			t.Fatalf("Unexpected YAML:\n%s", got)
+		}
+	})
+
+	t.Run("Test error: wrong '-report' format", func(t *testing.T) {
+		if _, _, err := ParseReport("xml:" + filepath.Join(t.TempDir(), "report")); err == nil || !strings.Contains(err.Error(), "'-report'") {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	})
+}
@@ -0,0 +1,131 @@
+//go:build !minimal
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RESTServer serves the JSON API of 'serve' subcommand:
+//
+//	POST   /v1/wait        - start a wait, e.g. {"endpoints": ["db:5432"], "timeout_ms": 30000, "interval_ms": 500}
+//	GET    /v1/status/{id} - status of the wait, with '?wait=true' once it is over
+//	DELETE /v1/wait/{id}   - cancel the wait
+//
+// Requests must have the '-api-token' as a bearer token.
+type RESTServer struct {
+	jobs *WaitJobs
+	mux  *http.ServeMux
+}
+
+func NewRESTServer(jobs *WaitJobs) *RESTServer {
+	s := &RESTServer{jobs: jobs, mux: http.NewServeMux()}
+	s.mux.HandleFunc("POST /v1/wait", s.submit)
+	s.mux.HandleFunc("GET /v1/status/{id}", s.status)
+	s.mux.HandleFunc("DELETE /v1/wait/{id}", s.cancel)
+	return s
+}
+
+func (s *RESTServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !Authorized(r, s.jobs.app.apiToken) {
+		writeJSONError(w, http.StatusUnauthorized, errors.New("invalid API token"))
+		return
+	}
+	s.mux.ServeHTTP(w, r)
+}
+
+func (s *RESTServer) submit(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Endpoints  []string `json:"endpoints"`
+		TimeoutMs  int64    `json:"timeout_ms"`
+		IntervalMs int64    `json:"interval_ms"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, grpcMaxMessage)).Decode(&req); err != nil {
+		writeJSONError(w, http.StatusBadRequest, err)
+		return
+	}
+	if len(req.Endpoints) == 0 || req.TimeoutMs < 0 || req.IntervalMs < 0 {
+		writeJSONError(w, http.StatusBadRequest, errors.New("endpoints and non-negative 'timeout_ms' and 'interval_ms' expected"))
+		return
+	}
+	job, err := s.jobs.Submit(WaitRequest{
+		Endpoints: req.Endpoints,
+		Timeout:   time.Duration(req.TimeoutMs) * time.Millisecond,
+		Interval:  time.Duration(req.IntervalMs) * time.Millisecond,
+	})
+	if errors.Is(err, ErrTooManyJobs) {
+		writeJSONError(w, http.StatusTooManyRequests, err)
+		return
+	}
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err)
+		return
+	}
+	status, _ := job.Status()
+	w.Header().Set("Location", "/v1/status/"+status.ID)
+	writeJSON(w, http.StatusAccepted, status)
+}
+
+func (s *RESTServer) status(w http.ResponseWriter, r *http.Request) {
+	job, err := s.jobs.Get(r.PathValue("id"))
+	if err != nil {
+		writeJSONError(w, http.StatusNotFound, err)
+		return
+	}
+	wait, _ := strconv.ParseBool(r.URL.Query().Get("wait"))
+	for {
+		status, changed := job.Status()
+		if !wait || status.Done() {
+			writeJSON(w, http.StatusOK, status)
+			return
+		}
+		select {
+		case <-changed:
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+func (s *RESTServer) cancel(w http.ResponseWriter, r *http.Request) {
+	job, err := s.jobs.Cancel(r.PathValue("id"))
+	if err != nil {
+		writeJSONError(w, http.StatusNotFound, err)
+		return
+	}
+	status, _ := job.Status()
+	writeJSON(w, http.StatusOK, status)
+}
+
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeJSONError(w http.ResponseWriter, code int, err error) {
+	writeJSON(w, code, struct {
+		Error string `json:"error"`
+	}{err.Error()})
+}
+
+// ServeREST serves the JSON API on '-http' address until the returned function is called.
+func (app App) ServeREST(jobs *WaitJobs) (func(), error) {
+	l, err := net.Listen("tcp", app.httpListen)
+	if err != nil {
+		return nil, err
+	}
+	srv := &http.Server{Handler: NewRESTServer(jobs), ReadHeaderTimeout: 5 * time.Second}
+	go func() {
+		_ = srv.Serve(l)
+	}()
+	app.Info("serving HTTP API on %s", l.Addr())
+	return func() {
+		_ = srv.Close()
+	}, nil
+}
@@ -0,0 +1,104 @@
+//go:build !minimal
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRESTServer(t *testing.T) {
+	app := newApp()
+	app.apiToken = "secret"
+	l := serve("127.0.0.1:0")
+	defer l.Close()
+	up, down := l.Addr().String(), getFreeTCPAddr().String()
+	jobs := NewWaitJobs(app)
+	srv := httptest.NewServer(NewRESTServer(jobs))
+	defer srv.Close()
+
+	call := func(method, path, body string, wantCode int) JobStatus {
+		req, err := http.NewRequest(method, srv.URL+path, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+app.apiToken)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != wantCode || resp.Header.Get("Content-Type") != "application/json" {
+			t.Fatalf("Unexpected response of %s %s: %s", method, path, resp.Status)
+		}
+		var s JobStatus
+		if err = json.NewDecoder(resp.Body).Decode(&s); err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+
+	t.Run("Test wait and status", func(t *testing.T) {
+		s := call("POST", "/v1/wait", `{"endpoints": ["`+up+`", "`+down+`"], "timeout_ms": 300, "interval_ms": 50}`, http.StatusAccepted)
+		if s.ID == "" || s.Status != "waiting" || len(s.Endpoints) != 2 {
+			t.Fatalf("Unexpected status: %+v", s)
+		}
+		s = call("GET", "/v1/status/"+s.ID+"?wait=true", "", http.StatusOK)
+		if s.Status != "timeout" || s.DurationMs < 300 || s.Error == "" {
+			t.Fatalf("Unexpected status: %+v", s)
+		}
+		if s.Endpoints[0].State != "up" || s.Endpoints[1].State != "timeout" || s.Endpoints[1].Attempts < 2 {
+			t.Fatalf("Unexpected endpoints: %+v, %+v", s.Endpoints[0], s.Endpoints[1])
+		}
+	})
+
+	t.Run("Test cancel", func(t *testing.T) {
+		s := call("POST", "/v1/wait", `{"endpoints": ["`+down+`"]}`, http.StatusAccepted)
+		if s = call("DELETE", "/v1/wait/"+s.ID, "", http.StatusOK); s.Status != "canceled" {
+			t.Fatalf("Unexpected status: %+v", s)
+		}
+		if s = call("GET", "/v1/status/"+s.ID, "", http.StatusOK); s.Status != "canceled" {
+			t.Fatalf("Unexpected status: %+v", s)
+		}
+	})
+
+	t.Run("Test errors", func(t *testing.T) {
+		if s := call("POST", "/v1/wait", `{"endpoints": ["ftp://host:21"]}`, http.StatusBadRequest); s.Error == "" {
+			t.Fatalf("Unexpected status: %+v", s)
+		}
+		call("POST", "/v1/wait", `{}`, http.StatusBadRequest)
+		call("GET", "/v1/status/unknown", "", http.StatusNotFound)
+		call("DELETE", "/v1/wait/unknown", "", http.StatusNotFound)
+		resp, err := http.Post(srv.URL+"/v1/wait", "application/json", strings.NewReader(`{"endpoints": ["`+up+`"]}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusUnauthorized {
+			t.Fatalf("Unexpected response without token: %s", resp.Status)
+		}
+	})
+
+	t.Run("Test limits", func(t *testing.T) {
+		for body, want := range map[string]string{
+			`{"endpoints": ["path:///etc/shadow"]}`:                "'path' endpoints are not checked for remote clients, expected 'tcp', 'udp', 'tls', 'http' or 'https'",
+			`{"endpoints": ["` + up + `"], "timeout_ms": 7200000}`: "timeout of a wait can not exceed 1h0m0s",
+		} {
+			if s := call("POST", "/v1/wait", body, http.StatusBadRequest); s.Error != want {
+				t.Fatalf("Unexpected error of %s: %s", body, s.Error)
+			}
+		}
+		jobs.mu.Lock()
+		jobs.active += maxActiveJobs
+		jobs.mu.Unlock()
+		defer func() {
+			jobs.mu.Lock()
+			jobs.active -= maxActiveJobs
+			jobs.mu.Unlock()
+		}()
+		call("POST", "/v1/wait", `{"endpoints": ["`+up+`"]}`, http.StatusTooManyRequests)
+	})
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Resume is the progress of the wait kept in the '-resume' file, so an interrupted wait
+// continues with available endpoints done and the rest of the timeout, instead of starting over.
+// The file is updated every interval and removed once the wait is over.
+// Methods of nil Resume do nothing.
+type Resume struct {
+	mu    sync.Mutex
+	path  string
+	start time.Time
+	spent time.Duration // by previous runs
+	done  []string
+}
+
+type resumeFile struct {
+	SpentMs int64    `json:"spent_ms"`
+	Done    []string `json:"done"`
+}
+
+// LoadResume reads the progress of the interrupted wait from the file, if it exists.
+func LoadResume(path string) (*Resume, error) {
+	r := &Resume{path: path, start: time.Now()}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return r, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var f resumeFile
+	if err = json.Unmarshal(data, &f); err != nil {
+		return nil, err
+	}
+	r.spent = time.Duration(f.SpentMs) * time.Millisecond
+	r.done = f.Done
+	return r, nil
+}
+
+// Spent returns the time spent waiting by previous runs.
+func (r *Resume) Spent() time.Duration {
+	if r == nil {
+		return 0
+	}
+	return r.spent
+}
+
+// IsDone reports whether the endpoint was available in a previous run.
+func (r *Resume) IsDone(addr string) bool {
+	if r == nil {
+		return false
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return slices.Contains(r.done, addr)
+}
+
+// Done marks the endpoint as available and saves the progress.
+func (r *Resume) Done(addr string) error {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	if !slices.Contains(r.done, addr) {
+		r.done = append(r.done, addr)
+	}
+	r.mu.Unlock()
+	return r.Save()
+}
+
+// Save writes the progress to the file atomically.
+func (r *Resume) Save() error {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	data, err := json.Marshal(resumeFile{SpentMs: (r.spent + time.Since(r.start)).Milliseconds(), Done: r.done})
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(r.path), filepath.Base(r.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err = tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), r.path)
+}
+
+// Remove removes the file once the wait is over.
+func (r *Resume) Remove() error {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := os.Remove(r.path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// Keep saves the progress every interval until the returned function is called.
+func (r *Resume) Keep(interval time.Duration) (stop func()) {
+	if r == nil {
+		return func() {}
+	}
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				// a failure is reported by Done, it only makes the spent time less accurate
+				_ = r.Save()
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
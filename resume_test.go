@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestResume(t *testing.T) {
+	t.Run("Test available endpoints are done", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "resume")
+		down := getFreeTCPAddr().String()
+		if err := os.WriteFile(path, []byte(`{"spent_ms":100,"done":["`+down+`"]}`), 0644); err != nil {
+			t.Fatal(err)
+		}
+		app := newApp()
+		app.timeout = time.Second
+		app.endpoints = []string{down, startListener("").String()}
+		var err error
+		if app.resume, err = LoadResume(path); err != nil {
+			t.Fatal(err)
+		}
+		results := app.Connect(context.Background())
+		if err = WaitErr(results); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if results[0].Addr != down || results[0].Attempts != 0 || results[1].Attempts != 1 {
+			t.Fatalf("Unexpected results: %+v", results)
+		}
+	})
+
+	t.Run("Test the rest of the timeout", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "resume")
+		if err := os.WriteFile(path, []byte(`{"spent_ms":9800,"done":[]}`), 0644); err != nil {
+			t.Fatal(err)
+		}
+		app := newApp()
+		app.timeout = 10 * time.Second
+		app.endpoints = []string{getFreeTCPAddr().String()}
+		var err error
+		if app.resume, err = LoadResume(path); err != nil {
+			t.Fatal(err)
+		}
+		start := time.Now()
+		if err = WaitErr(app.Connect(context.Background())); !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Fatalf("Timeout is not resumed: %v", elapsed)
+		}
+	})
+
+	t.Run("Test progress is saved and removed", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "resume")
+		r, err := LoadResume(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = r.Done("127.0.0.1:5432"); err != nil {
+			t.Fatal(err)
+		}
+		loaded, err := LoadResume(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !loaded.IsDone("127.0.0.1:5432") || loaded.IsDone("127.0.0.1:6379") {
+			t.Fatalf("Unexpected progress: %+v", loaded)
+		}
+		if err = loaded.Remove(); err != nil {
+			t.Fatal(err)
+		}
+		if _, err = os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+			t.Fatalf("File is not removed: %v", err)
+		}
+	})
+}
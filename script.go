@@ -0,0 +1,199 @@
+//go:build !minimal
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/jackcvr/tcpw/internal/starlark"
+	"github.com/jackcvr/tcpw/tcpw"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"time"
+)
+
+// maxRecvUntil is how many bytes recv_until of check scripts reads at most looking for the delimiter.
+const maxRecvUntil = 64 << 10
+
+// LoadScript reads and parses the check script of 'script://PATH' endpoints.
+func LoadScript(path string) (*starlark.Program, error) {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return starlark.Parse(path, src)
+}
+
+// RunScript runs the check script of the endpoint 'script://PATH': the endpoint is available once the script ends without error.
+// The script is read again on every attempt, so it may be fixed while waiting.
+func (app App) RunScript(ctx context.Context, dial tcpw.DialFunc, addr string) error {
+	path := strings.TrimPrefix(addr, "script://")
+	prog, err := LoadScript(path)
+	if err != nil {
+		return err
+	}
+	var conns []*ScriptConn
+	defer func() {
+		for _, c := range conns {
+			_ = c.conn.Close()
+		}
+	}()
+	builtin := func(name string, fn func(th *starlark.Thread, args []starlark.Value) (starlark.Value, error)) *starlark.Builtin {
+		return &starlark.Builtin{Name: name, Fn: fn}
+	}
+	predeclared := map[string]starlark.Value{
+		"dial": builtin("dial", func(th *starlark.Thread, args []starlark.Value) (starlark.Value, error) {
+			var address string
+			network := "tcp"
+			if err := starlark.UnpackArgs("dial", args, 1, &address, &network); err != nil {
+				return nil, err
+			}
+			c, err := app.dialScript(th.Context(), dial, network, address)
+			if err != nil {
+				return nil, err
+			}
+			conns = append(conns, c)
+			return c, nil
+		}),
+		"sleep": builtin("sleep", func(th *starlark.Thread, args []starlark.Value) (starlark.Value, error) {
+			var seconds float64
+			if err := starlark.UnpackArgs("sleep", args, 1, &seconds); err != nil {
+				return nil, err
+			}
+			timer := time.NewTimer(time.Duration(seconds * float64(time.Second)))
+			defer timer.Stop()
+			select {
+			case <-timer.C:
+				return nil, nil
+			case <-th.Context().Done():
+				return nil, th.Context().Err()
+			}
+		}),
+	}
+	return prog.Run(ctx, predeclared, func(msg string) {
+		app.Debug("%s: %s", path, msg)
+	})
+}
+
+// dialScript connects on the network of dial() of check scripts: 'tcp', 'udp', 'unix' or 'tls'.
+func (app App) dialScript(ctx context.Context, dial tcpw.DialFunc, network, address string) (*ScriptConn, error) {
+	var conn net.Conn
+	var err error
+	switch network {
+	case "tcp", "udp", "unix":
+		conn, err = dial(ctx, network, address)
+	case "tls":
+		conn, err = DialTLS(ctx, dial, address)
+	default:
+		return nil, fmt.Errorf("dial: unsupported network '%s', expected %s", network, tcpw.QuoteList([]string{"tcp", "udp", "unix", "tls"}))
+	}
+	if err != nil {
+		return nil, err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+	return &ScriptConn{app: app, ctx: ctx, conn: conn, r: bufio.NewReader(conn)}, nil
+}
+
+// ScriptConn is a connection of a check script with send, recv, recv_until and close methods.
+type ScriptConn struct {
+	app  App
+	ctx  context.Context
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+func (c *ScriptConn) Type() string {
+	return "conn"
+}
+
+func (c *ScriptConn) Attr(name string) starlark.Value {
+	var fn func(args []starlark.Value) (starlark.Value, error)
+	switch name {
+	case "send":
+		fn = c.send
+	case "recv":
+		fn = c.recv
+	case "recv_until":
+		fn = c.recvUntil
+	case "close":
+		fn = func(args []starlark.Value) (starlark.Value, error) {
+			if err := starlark.UnpackArgs("close", args, 0); err != nil {
+				return nil, err
+			}
+			return nil, c.conn.Close()
+		}
+	default:
+		return nil
+	}
+	return &starlark.Builtin{Name: name, Fn: func(th *starlark.Thread, args []starlark.Value) (starlark.Value, error) {
+		// reads and writes are interrupted once the attempt is canceled
+		stop := context.AfterFunc(c.ctx, func() {
+			_ = c.conn.SetDeadline(time.Now())
+		})
+		defer stop()
+		return fn(args)
+	}}
+}
+
+// send writes the data and returns its length.
+func (c *ScriptConn) send(args []starlark.Value) (starlark.Value, error) {
+	var data string
+	if err := starlark.UnpackArgs("send", args, 1, &data); err != nil {
+		return nil, err
+	}
+	c.app.Wire("> %q", data)
+	n, err := c.conn.Write([]byte(data))
+	return n, err
+}
+
+// recv reads up to n bytes, 4096 by default, and returns them, or an empty string once the connection is closed.
+func (c *ScriptConn) recv(args []starlark.Value) (starlark.Value, error) {
+	n := 4096
+	if err := starlark.UnpackArgs("recv", args, 0, &n); err != nil {
+		return nil, err
+	}
+	buf := make([]byte, max(n, 1))
+	n, err := c.r.Read(buf)
+	if n == 0 && err != nil {
+		if errors.Is(err, io.EOF) {
+			return "", nil
+		}
+		return nil, err
+	}
+	c.app.Wire("< %q", buf[:n])
+	return string(buf[:n]), nil
+}
+
+// recvUntil reads and returns the data up to and including the delimiter, e.g. '\r\n'.
+func (c *ScriptConn) recvUntil(args []starlark.Value) (starlark.Value, error) {
+	var delim string
+	if err := starlark.UnpackArgs("recv_until", args, 1, &delim); err != nil {
+		return nil, err
+	}
+	if delim == "" {
+		return nil, errors.New("recv_until: empty delimiter")
+	}
+	var data []byte
+	for !bytes.HasSuffix(data, []byte(delim)) {
+		if len(data) >= maxRecvUntil {
+			return nil, fmt.Errorf("recv_until: no %q in %d bytes", delim, len(data))
+		}
+		b, err := c.r.ReadByte()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil, fmt.Errorf("recv_until: connection closed before %q, got %q", delim, data)
+			}
+			return nil, err
+		}
+		data = append(data, b)
+	}
+	c.app.Wire("< %q", data)
+	return string(data), nil
+}
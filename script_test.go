@@ -0,0 +1,101 @@
+//go:build !minimal
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// serveRedis answers PING with PONG after a banner-less handshake, like Redis does.
+func serveRedis(t *testing.T) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				if line, _ := bufio.NewReader(conn).ReadString('\n'); line == "PING\r\n" {
+					_, _ = conn.Write([]byte("+PONG\r\n"))
+				}
+			}()
+		}
+	}()
+	return l.Addr().String()
+}
+
+func TestScript(t *testing.T) {
+	dir := t.TempDir()
+	addr := serveRedis(t)
+	write := func(name, src string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	t.Run("Test handshake", func(t *testing.T) {
+		src := `
+def ping(addr, expected):
+    conn = dial(addr)
+    conn.send("PING\r\n")
+    reply = conn.recv_until("\r\n").strip()
+    if reply != expected:
+        fail("unexpected reply %r" % reply)
+
+ping("` + addr + `", "+PONG")
+sleep(0.01)
+`
+		path := write("ping.star", src)
+		var app App
+		if err := app.Parse("tcpw", []string{"-script", path}); err != nil {
+			t.Fatal(err)
+		}
+		if app.endpoints[0] != "script://"+path {
+			t.Fatalf("Unexpected endpoints: %v", app.endpoints)
+		}
+		if err := app.Dial(ctx, net.Dialer{}, app.endpoints[0]); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		// the script is read again on every attempt
+		write("ping.star", strings.Replace(src, `"+PONG")`, `"+OK")`, 1))
+		if err := app.Dial(ctx, net.Dialer{}, app.endpoints[0]); err == nil || err.Error() != path+`:7: unexpected reply "+PONG"` {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	})
+
+	t.Run("Test connection errors", func(t *testing.T) {
+		path := write("closed.star", "c = dial('"+getFreeTCPAddr().String()+"')\n")
+		app := newApp()
+		if err := app.Dial(ctx, net.Dialer{}, "script://"+path); err == nil || !strings.Contains(err.Error(), "closed.star:1: dial tcp") {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		path = write("eof.star", "c = dial('"+addr+"')\nc.send('QUIT\\r\\n')\nprint(repr(c.recv()))\nc.recv_until('\\n')\n")
+		if err := app.Dial(ctx, net.Dialer{}, "script://"+path); err == nil || err.Error() != path+`:4: recv_until: connection closed before "\n", got ""` {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	})
+
+	t.Run("Test syntax error", func(t *testing.T) {
+		path := write("bad.star", "if True\n")
+		if _, err := ParseEndpoint("script://" + path); err == nil || err.Error() != path+":1: expected ':', got newline" {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	})
+}
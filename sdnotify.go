@@ -0,0 +1,37 @@
+package main
+
+import (
+	"net"
+	"os"
+	"strings"
+)
+
+// SdNotify sends the state, e.g. 'READY=1', to systemd via $NOTIFY_SOCKET, if it is set.
+// See sd_notify(3).
+func SdNotify(state string) error {
+	path := os.Getenv("NOTIFY_SOCKET")
+	if path == "" {
+		return nil
+	}
+	if strings.HasPrefix(path, "@") {
+		// abstract socket
+		path = "\x00" + path[1:]
+	}
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = conn.Write([]byte(state))
+	return err
+}
+
+// Notify sends the state to systemd with '-sd-notify'.
+func (app App) Notify(state string) {
+	if !app.sdNotify {
+		return
+	}
+	if err := SdNotify(state); err != nil {
+		app.Debug("failed to notify systemd: %v", err)
+	}
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAppNotify(t *testing.T) {
+	t.Run("Test READY=1 is sent to $NOTIFY_SOCKET (-sd-notify)", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "notify.sock")
+		conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+		if err != nil {
+			t.Skipf("Unix datagram sockets are not supported: %v", err)
+		}
+		defer conn.Close()
+		t.Setenv("NOTIFY_SOCKET", path)
+		app := newApp()
+		app.endpoints = []string{startListener("").String()}
+		app.sdNotify = true
+		if err = app.Run(); err != nil {
+			t.Fatal(err)
+		}
+		var states []string
+		buf := make([]byte, 1024)
+		for len(states) < 3 {
+			_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+			n, err := conn.Read(buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			states = append(states, string(buf[:n]))
+		}
+		if states[0] != "STATUS=waiting for 1 endpoint" || states[1] != "STATUS=1/1 endpoints available" ||
+			!strings.HasPrefix(states[2], "READY=1\n") {
+			t.Fatalf("Unexpected states: %q", states)
+		}
+	})
+}
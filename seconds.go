@@ -0,0 +1,29 @@
+package main
+
+import (
+	"strconv"
+	"time"
+)
+
+// Seconds is a duration flag, which also accepts a number of seconds like '5' or '0.5'.
+type Seconds time.Duration
+
+func (s *Seconds) String() string {
+	if s == nil || *s == 0 {
+		return ""
+	}
+	return time.Duration(*s).String()
+}
+
+func (s *Seconds) Set(value string) error {
+	if n, err := strconv.ParseFloat(value, 64); err == nil {
+		*s = Seconds(n * float64(time.Second))
+		return nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return err
+	}
+	*s = Seconds(d)
+	return nil
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSecondsSet(t *testing.T) {
+	for value, expected := range map[string]time.Duration{
+		"5":     5 * time.Second,
+		"0.5":   500 * time.Millisecond,
+		"100ms": 100 * time.Millisecond,
+	} {
+		var s Seconds
+		if err := s.Set(value); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if time.Duration(s) != expected {
+			t.Fatalf("Wrong duration for %s: %v", value, time.Duration(s))
+		}
+	}
+
+	var s Seconds
+	if err := s.Set("5x"); err == nil {
+		t.Fatal("Unexpected success")
+	}
+}
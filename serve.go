@@ -0,0 +1,93 @@
+//go:build !minimal
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/jackcvr/tcpw/tcpw"
+	"net"
+	"sync"
+	"time"
+)
+
+// RunServe runs 'tcpw serve' subcommand: endpoints are probed every interval until Ctrl-C,
+// and their current state is served on '-status-listen'. Waits are requested from the APIs on '-grpc' and '-http'.
+func (app App) RunServe() error {
+	jobs := NewWaitJobs(app)
+	if app.grpcListen != "" {
+		closeGRPC, err := app.ServeGRPC(jobs)
+		if err != nil {
+			app.Error("%v", err)
+			return err
+		}
+		defer closeGRPC()
+	}
+	if app.httpListen != "" {
+		closeREST, err := app.ServeREST(jobs)
+		if err != nil {
+			app.Error("%v", err)
+			return err
+		}
+		defer closeREST()
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	d := net.Dialer{Timeout: app.interval}
+	if app.dialTimeout > 0 {
+		d.Timeout = app.dialTimeout
+	}
+	var wg sync.WaitGroup
+	for _, addr := range app.endpoints {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			app.Watch(ctx, d, addr)
+		}()
+	}
+	interrupts, stop := Interrupts()
+	defer stop()
+	<-interrupts
+	cancel()
+	wg.Wait()
+	return nil
+}
+
+// Watch makes a connection attempt to the endpoint every interval until ctx is done,
+// emitting attempts and changes of its state: 'up' or 'down'.
+func (app App) Watch(ctx context.Context, d net.Dialer, addr string) {
+	name := app.Label(addr)
+	ticker := time.NewTicker(app.interval)
+	defer ticker.Stop()
+	var state string
+	for attempt := 1; ; attempt++ {
+		start := time.Now()
+		err := app.Dial(ctx, d, addr)
+		if tcpw.Expired(ctx) {
+			return
+		}
+		event := Event{Type: EventAttempt, Endpoint: name, Attempt: attempt, LatencyMs: Latency(time.Since(start))}
+		newState := StatusUp
+		if err != nil {
+			event.Error = err.Error()
+			newState = StatusDown
+		}
+		app.Emit(event)
+		app.Store(name, err)
+		app.Measure(event)
+		if newState != state {
+			state = newState
+			app.Emit(Event{Type: EventState, Endpoint: name, State: state, Error: event.Error})
+			if err != nil {
+				app.Logger().Warn(fmt.Sprintf("%s is down", name), "endpoint", name, "status", StatusDown, "error", err)
+			} else {
+				app.Logger().Info(fmt.Sprintf("%s is up", name), "endpoint", name, "status", StatusUp)
+			}
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,34 @@
+//go:build !minimal
+
+package main
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestWatch(t *testing.T) {
+	app := newApp()
+	app.interval = 50 * time.Millisecond
+	l := serve("127.0.0.1:0")
+	defer l.Close()
+	up, down := l.Addr().String(), getFreeTCPAddr().String()
+	app.tracker = NewTracker([]string{up, down})
+	ctx, cancel := context.WithTimeout(context.Background(), 175*time.Millisecond)
+	defer cancel()
+	for _, addr := range []string{up, down} {
+		go app.Watch(ctx, net.Dialer{}, addr)
+	}
+	<-ctx.Done()
+	time.Sleep(10 * time.Millisecond)
+	app.tracker.mu.Lock()
+	defer app.tracker.mu.Unlock()
+	if s := app.tracker.endpoints[0]; s.State != StatusUp || s.Attempts < 3 {
+		t.Fatalf("Unexpected state: %+v", s)
+	}
+	if s := app.tracker.endpoints[1]; s.State != StatusDown || s.Error == "" {
+		t.Fatalf("Unexpected state: %+v", s)
+	}
+}
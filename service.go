@@ -0,0 +1,98 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+)
+
+// DefaultServiceName is the name of the Windows service of 'service' subcommand, unless '-name' is given.
+const DefaultServiceName = "tcpw"
+
+// RunService runs 'service' subcommand, which manages tcpw running as a Windows service, e.g. 'serve' or '-supervise' monitoring:
+//
+//	install [-name NAME] [--] ARGS - install the service started automatically with arguments of tcpw, e.g. 'serve db:5432'
+//	start [-name NAME]             - start the installed service
+//	stop [-name NAME]              - stop the service
+//	remove [-name NAME]            - remove the stopped service
+//
+// The service manager starts 'tcpw service run -name NAME ARGS', whose log lines go to the Application event log.
+func (app App) RunService(name string, args []string) error {
+	actions := map[string]func(string, []string) error{
+		"install": app.installService,
+		"start": func(name string, _ []string) error {
+			return ControlService(name, "start")
+		},
+		"stop": func(name string, _ []string) error {
+			return ControlService(name, "stop")
+		},
+		"remove": func(name string, _ []string) error {
+			return ControlService(name, "remove")
+		},
+		"run": RunAsService,
+	}
+	fs := flag.NewFlagSet(name+" service", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	var serviceName string
+	fs.StringVar(&serviceName, "name", DefaultServiceName, "Name of the service, e.g. to run several of them")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s service install [-name NAME] [--] args\n"+
+			"       %s service (start|stop|remove) [-name NAME]\n\n", name, name)
+		fs.PrintDefaults()
+	}
+	if len(args) == 0 {
+		fs.Usage()
+		return errors.New("no action provided")
+	}
+	action, ok := actions[args[0]]
+	if !ok {
+		fs.Usage()
+		return fmt.Errorf("unknown action: %s", args[0])
+	}
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+	if args[0] != "install" && args[0] != "run" && fs.NArg() > 0 {
+		return fmt.Errorf("unexpected arguments of '%s': %q", args[0], fs.Args())
+	}
+	return action(serviceName, fs.Args())
+}
+
+// installService checks the arguments of tcpw run by the service, then installs it.
+func (app App) installService(name string, args []string) error {
+	if len(args) == 0 {
+		return errors.New("arguments of tcpw run by the service are required, e.g. 'serve db:5432'")
+	}
+	var svc App
+	if err := svc.ParseArgs(append([]string{"tcpw"}, args...)); err != nil {
+		return err
+	}
+	if err := svc.Check(); err != nil {
+		return err
+	}
+	if svc.tui {
+		return errors.New("'watch' can not run as a service, it needs a terminal")
+	}
+	if err := InstallService(name, args); err != nil {
+		return err
+	}
+	app.Info("installed service %s", name)
+	return nil
+}
+
+// ServeService runs tcpw with the arguments as the service, writing log lines to w, and returns its exit code.
+func ServeService(args []string, w SyslogWriter) int {
+	var app App
+	err := app.ParseArgs(append([]string{"tcpw"}, args...))
+	app.syslog = w
+	app.SetLogger()
+	if err == nil {
+		err = app.Check()
+	}
+	if err != nil {
+		app.Error("%v", err)
+		return ExitInvalid
+	}
+	return app.ExitCode(app.Run())
+}
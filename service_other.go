@@ -0,0 +1,19 @@
+//go:build !windows
+
+package main
+
+import "errors"
+
+var errNoService = errors.New("'service' subcommand is only supported on Windows")
+
+func InstallService(string, []string) error {
+	return errNoService
+}
+
+func ControlService(string, string) error {
+	return errNoService
+}
+
+func RunAsService(string, []string) error {
+	return errNoService
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestRunService(t *testing.T) {
+	for args, want := range map[string]string{
+		"":                    "no action provided",
+		"restart":             "unknown action: restart",
+		"stop -name db extra": `unexpected arguments of 'stop': ["extra"]`,
+		"install -name db":    "arguments of tcpw run by the service are required, e.g. 'serve db:5432'",
+		"install -- -log-format xml -a localhost:5432": "only 'text' or 'json' are allowed for '-log-format' argument",
+		"install -- watch localhost:5432":              "'watch' can not run as a service, it needs a terminal",
+	} {
+		if err := newApp().RunService("tcpw", strings.Fields(args)); err == nil || err.Error() != want {
+			t.Fatalf("Unexpected error of %q: %v", args, err)
+		}
+	}
+	if runtime.GOOS != "windows" {
+		err := newApp().RunService("tcpw", []string{"install", "--", "-supervise", "-a", "localhost:5432", "--", "./server"})
+		if err == nil || err.Error() != "'service' subcommand is only supported on Windows" {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+}
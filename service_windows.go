@@ -0,0 +1,303 @@
+//go:build windows
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+var (
+	advapi32                         = syscall.NewLazyDLL("advapi32.dll")
+	procOpenSCManagerW               = advapi32.NewProc("OpenSCManagerW")
+	procCreateServiceW               = advapi32.NewProc("CreateServiceW")
+	procOpenServiceW                 = advapi32.NewProc("OpenServiceW")
+	procStartServiceW                = advapi32.NewProc("StartServiceW")
+	procControlService               = advapi32.NewProc("ControlService")
+	procQueryServiceStatus           = advapi32.NewProc("QueryServiceStatus")
+	procDeleteService                = advapi32.NewProc("DeleteService")
+	procCloseServiceHandle           = advapi32.NewProc("CloseServiceHandle")
+	procStartServiceCtrlDispatcherW  = advapi32.NewProc("StartServiceCtrlDispatcherW")
+	procRegisterServiceCtrlHandlerEx = advapi32.NewProc("RegisterServiceCtrlHandlerExW")
+	procSetServiceStatus             = advapi32.NewProc("SetServiceStatus")
+	procRegisterEventSourceW         = advapi32.NewProc("RegisterEventSourceW")
+	procDeregisterEventSource        = advapi32.NewProc("DeregisterEventSource")
+	procReportEventW                 = advapi32.NewProc("ReportEventW")
+	procRegCreateKeyExW              = advapi32.NewProc("RegCreateKeyExW")
+	procRegSetValueExW               = advapi32.NewProc("RegSetValueExW")
+	procRegDeleteKeyW                = advapi32.NewProc("RegDeleteKeyW")
+)
+
+const (
+	scManagerAllAccess = 0xf003f
+	serviceAllAccess   = 0xf01ff
+
+	serviceWin32OwnProcess = 0x10
+	serviceAutoStart       = 2
+	serviceErrorNormal     = 1
+
+	serviceStopped      = 1
+	serviceStartPending = 2
+	serviceStopPending  = 3
+	serviceRunning      = 4
+
+	serviceAcceptStop     = 1
+	serviceAcceptShutdown = 4
+
+	serviceControlStop        = 1
+	serviceControlInterrogate = 4
+	serviceControlShutdown    = 5
+
+	errorServiceSpecificError = 1066
+	errorCallNotImplemented   = 120
+
+	eventlogErrorType       = 1
+	eventlogWarningType     = 2
+	eventlogInformationType = 4
+
+	regOptionNonVolatile = 0
+	keyAllAccess         = 0xf003f
+	regExpandSZ          = 2
+	regDWORD             = 4
+)
+
+// eventLogKey is the registry key of event sources of the Application log.
+const eventLogKey = `SYSTEM\CurrentControlSet\Services\EventLog\Application\`
+
+type serviceStatus struct {
+	ServiceType             uint32
+	CurrentState            uint32
+	ControlsAccepted        uint32
+	Win32ExitCode           uint32
+	ServiceSpecificExitCode uint32
+	CheckPoint              uint32
+	WaitHint                uint32
+}
+
+type serviceTableEntry struct {
+	Name *uint16
+	Proc uintptr
+}
+
+// procErr returns the error of the procedure, which failed by returning zero like Windows API functions do.
+func procErr(proc *syscall.LazyProc, err error) error {
+	return fmt.Errorf("%s: %w", proc.Name, err)
+}
+
+func utf16(s string) *uint16 {
+	p, _ := syscall.UTF16PtrFromString(s)
+	return p
+}
+
+// openService opens the service with the handle of the service manager, which must be closed with closeHandles.
+func openService(name string) (scm, svc uintptr, err error) {
+	if scm, _, err = procOpenSCManagerW.Call(0, 0, scManagerAllAccess); scm == 0 {
+		return 0, 0, procErr(procOpenSCManagerW, err)
+	}
+	if svc, _, err = procOpenServiceW.Call(scm, uintptr(unsafe.Pointer(utf16(name))), serviceAllAccess); svc == 0 {
+		closeHandles(scm)
+		return 0, 0, procErr(procOpenServiceW, err)
+	}
+	return scm, svc, nil
+}
+
+func closeHandles(handles ...uintptr) {
+	for _, h := range handles {
+		_, _, _ = procCloseServiceHandle.Call(h)
+	}
+}
+
+// InstallService installs the service started automatically with the arguments of tcpw,
+// and registers it as a source of the Application event log.
+func InstallService(name string, args []string) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	var cmdline []string
+	for _, arg := range append([]string{exe, "service", "run", "-name", name, "--"}, args...) {
+		cmdline = append(cmdline, syscall.EscapeArg(arg))
+	}
+	scm, _, err := procOpenSCManagerW.Call(0, 0, scManagerAllAccess)
+	if scm == 0 {
+		return procErr(procOpenSCManagerW, err)
+	}
+	defer closeHandles(scm)
+	svc, _, err := procCreateServiceW.Call(scm, uintptr(unsafe.Pointer(utf16(name))), uintptr(unsafe.Pointer(utf16(name))), serviceAllAccess,
+		serviceWin32OwnProcess, serviceAutoStart, serviceErrorNormal, uintptr(unsafe.Pointer(utf16(strings.Join(cmdline, " ")))), 0, 0, 0, 0, 0)
+	if svc == 0 {
+		return procErr(procCreateServiceW, err)
+	}
+	closeHandles(svc)
+	return installEventSource(name)
+}
+
+// installEventSource registers the event source, whose messages are passed as is with the message file of EventCreate.exe.
+func installEventSource(name string) error {
+	var key syscall.Handle
+	if r, _, _ := procRegCreateKeyExW.Call(uintptr(syscall.HKEY_LOCAL_MACHINE), uintptr(unsafe.Pointer(utf16(eventLogKey+name))), 0, 0,
+		regOptionNonVolatile, keyAllAccess, 0, uintptr(unsafe.Pointer(&key)), 0); r != 0 {
+		return fmt.Errorf("failed to register event source: %w", syscall.Errno(r))
+	}
+	defer syscall.RegCloseKey(key)
+	file, _ := syscall.UTF16FromString(`%SystemRoot%\System32\EventCreate.exe`)
+	if r, _, _ := procRegSetValueExW.Call(uintptr(key), uintptr(unsafe.Pointer(utf16("EventMessageFile"))), 0, regExpandSZ,
+		uintptr(unsafe.Pointer(&file[0])), uintptr(len(file)*2)); r != 0 {
+		return fmt.Errorf("failed to register event source: %w", syscall.Errno(r))
+	}
+	types := uint32(eventlogErrorType | eventlogWarningType | eventlogInformationType)
+	if r, _, _ := procRegSetValueExW.Call(uintptr(key), uintptr(unsafe.Pointer(utf16("TypesSupported"))), 0, regDWORD,
+		uintptr(unsafe.Pointer(&types)), 4); r != 0 {
+		return fmt.Errorf("failed to register event source: %w", syscall.Errno(r))
+	}
+	return nil
+}
+
+// ControlService starts, stops or removes the installed service. Stopping waits until the service is stopped.
+func ControlService(name, action string) error {
+	scm, svc, err := openService(name)
+	if err != nil {
+		return err
+	}
+	defer closeHandles(svc, scm)
+	var status serviceStatus
+	switch action {
+	case "start":
+		if r, _, err := procStartServiceW.Call(svc, 0, 0); r == 0 {
+			return procErr(procStartServiceW, err)
+		}
+		return nil
+	case "stop":
+		if r, _, err := procControlService.Call(svc, serviceControlStop, uintptr(unsafe.Pointer(&status))); r == 0 {
+			return procErr(procControlService, err)
+		}
+		for deadline := time.Now().Add(30 * time.Second); status.CurrentState != serviceStopped; time.Sleep(300 * time.Millisecond) {
+			if time.Now().After(deadline) {
+				return fmt.Errorf("service %s did not stop in time", name)
+			}
+			if r, _, err := procQueryServiceStatus.Call(svc, uintptr(unsafe.Pointer(&status))); r == 0 {
+				return procErr(procQueryServiceStatus, err)
+			}
+		}
+		return nil
+	case "remove":
+		if r, _, err := procDeleteService.Call(svc); r == 0 {
+			return procErr(procDeleteService, err)
+		}
+		if r, _, _ := procRegDeleteKeyW.Call(uintptr(syscall.HKEY_LOCAL_MACHINE), uintptr(unsafe.Pointer(utf16(eventLogKey+name)))); r != 0 {
+			return fmt.Errorf("failed to remove event source: %w", syscall.Errno(r))
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown action: %s", action)
+}
+
+// RunAsService runs tcpw with the arguments as the service, when it is started by the service manager.
+// A request to stop the service interrupts tcpw like SIGTERM does.
+func RunAsService(name string, args []string) error {
+	var (
+		mu     sync.Mutex
+		handle uintptr
+	)
+	setStatus := func(state uint32, code int) {
+		mu.Lock()
+		defer mu.Unlock()
+		status := serviceStatus{ServiceType: serviceWin32OwnProcess, CurrentState: state}
+		if state == serviceRunning {
+			status.ControlsAccepted = serviceAcceptStop | serviceAcceptShutdown
+		}
+		if code != 0 {
+			status.Win32ExitCode, status.ServiceSpecificExitCode = errorServiceSpecificError, uint32(code)
+		}
+		_, _, _ = procSetServiceStatus.Call(handle, uintptr(unsafe.Pointer(&status)))
+	}
+	handler := syscall.NewCallback(func(control, _ uint32, _, _ uintptr) uintptr {
+		switch control {
+		case serviceControlStop, serviceControlShutdown:
+			setStatus(serviceStopPending, 0)
+			Interrupt(syscall.SIGTERM)
+			return 0
+		case serviceControlInterrogate:
+			return 0
+		}
+		return errorCallNotImplemented
+	})
+	serviceName := utf16(name)
+	var runErr error
+	main := syscall.NewCallback(func(uint32, **uint16) uintptr {
+		h, _, err := procRegisterServiceCtrlHandlerEx.Call(uintptr(unsafe.Pointer(serviceName)), handler, 0)
+		if h == 0 {
+			runErr = procErr(procRegisterServiceCtrlHandlerEx, err)
+			return 0
+		}
+		mu.Lock()
+		handle = h
+		mu.Unlock()
+		setStatus(serviceStartPending, 0)
+		w, err := OpenEventLog(name)
+		if err != nil {
+			runErr = err
+			setStatus(serviceStopped, ExitFailure)
+			return 0
+		}
+		defer w.Close()
+		setStatus(serviceRunning, 0)
+		setStatus(serviceStopped, ServeService(args, w))
+		return 0
+	})
+	table := []serviceTableEntry{{serviceName, main}, {}}
+	if r, _, err := procStartServiceCtrlDispatcherW.Call(uintptr(unsafe.Pointer(&table[0]))); r == 0 {
+		return fmt.Errorf("%w, 'service run' is started by the service manager, see 'service start'", procErr(procStartServiceCtrlDispatcherW, err))
+	}
+	return runErr
+}
+
+// EventLog writes messages to the Application event log as the event source of the service, see SyslogWriter.
+type EventLog struct {
+	handle uintptr
+}
+
+func OpenEventLog(source string) (*EventLog, error) {
+	h, _, err := procRegisterEventSourceW.Call(0, uintptr(unsafe.Pointer(utf16(source))))
+	if h == 0 {
+		return nil, procErr(procRegisterEventSourceW, err)
+	}
+	return &EventLog{handle: h}, nil
+}
+
+func (l *EventLog) report(typ uint16, msg string) error {
+	strs := []*uint16{utf16(msg)}
+	// the message file of EventCreate.exe passes the message of event ID 1 as is
+	if r, _, err := procReportEventW.Call(l.handle, uintptr(typ), 0, 1, 0, 1, 0, uintptr(unsafe.Pointer(&strs[0])), 0); r == 0 {
+		return procErr(procReportEventW, err)
+	}
+	return nil
+}
+
+func (l *EventLog) Debug(msg string) error {
+	return l.report(eventlogInformationType, msg)
+}
+
+func (l *EventLog) Info(msg string) error {
+	return l.report(eventlogInformationType, msg)
+}
+
+func (l *EventLog) Warning(msg string) error {
+	return l.report(eventlogWarningType, msg)
+}
+
+func (l *EventLog) Err(msg string) error {
+	return l.report(eventlogErrorType, msg)
+}
+
+func (l *EventLog) Close() error {
+	if r, _, err := procDeregisterEventSource.Call(l.handle); r == 0 {
+		return procErr(procDeregisterEventSource, err)
+	}
+	return nil
+}
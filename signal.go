@@ -0,0 +1,37 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+type Signal struct {
+	syscall.Signal
+}
+
+func (s *Signal) String() string {
+	if s == nil || s.Signal == 0 {
+		return ""
+	}
+	for name, sig := range signals {
+		if sig == s.Signal {
+			return name
+		}
+	}
+	return strconv.Itoa(int(s.Signal))
+}
+
+func (s *Signal) Set(value string) error {
+	if sig, ok := signals[strings.TrimPrefix(strings.ToUpper(value), "SIG")]; ok {
+		s.Signal = sig
+		return nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n <= 0 {
+		return fmt.Errorf("unknown signal: %s", value)
+	}
+	s.Signal = syscall.Signal(n)
+	return nil
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"syscall"
+	"testing"
+)
+
+func TestSignalSet(t *testing.T) {
+	for value, expected := range map[string]syscall.Signal{
+		"TERM":    syscall.SIGTERM,
+		"sigkill": syscall.SIGKILL,
+		"2":       syscall.Signal(2),
+	} {
+		var s Signal
+		if err := s.Set(value); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if s.Signal != expected {
+			t.Fatalf("Wrong signal for %s: %v", value, s.Signal)
+		}
+	}
+
+	var s Signal
+	if err := s.Set("NOPE"); err == nil {
+		t.Fatal("Unexpected success")
+	}
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// Sample is the result of a connection attempt to an endpoint kept in the '-state' file.
+type Sample struct {
+	Time     time.Time `json:"time"`
+	Endpoint string    `json:"endpoint"`
+	Up       bool      `json:"up"`
+	Error    string    `json:"error,omitempty"`
+}
+
+// StateStore is the '-state' file receiving a sample per connection attempt and probe
+// of '-supervise' and '-kill-on-loss' monitoring, one JSON object per line.
+// It is rotated like '-log-file' when it exceeds the maximal size, so only
+// the maximal number of backups of older samples is retained.
+type StateStore struct {
+	mu   sync.Mutex
+	file *LogFile
+	enc  *json.Encoder
+}
+
+func OpenStateStore(path string, maxSize int64, maxBackups int) (*StateStore, error) {
+	f, err := OpenLogFile(path, maxSize, 0, maxBackups)
+	if err != nil {
+		return nil, err
+	}
+	return &StateStore{file: f, enc: json.NewEncoder(f)}, nil
+}
+
+func (s *StateStore) Close() error {
+	return s.file.Close()
+}
+
+func (s *StateStore) Write(sample Sample) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.enc.Encode(sample)
+}
+
+// Store appends the result of a connection attempt to the endpoint to '-state' file, if any.
+func (app App) Store(endpoint string, err error) {
+	if app.state == nil {
+		return
+	}
+	sample := Sample{Time: time.Now(), Endpoint: endpoint, Up: err == nil}
+	if err != nil {
+		sample.Error = err.Error()
+	}
+	if err = app.state.Write(sample); err != nil {
+		app.Error("failed to write state: %v", err)
+	}
+}
+
+// ReadSamples reads samples written to a '-state' file one by one, passing each to fn,
+// so the file is never loaded into memory as a whole.
+func ReadSamples(r io.Reader, fn func(Sample)) error {
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+		var s Sample
+		if err := json.Unmarshal(scanner.Bytes(), &s); err != nil {
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+		fn(s)
+	}
+	return scanner.Err()
+}
+
+// StateFiles returns the '-state' file preceded by its rotated backups, oldest first.
+func StateFiles(path string) []string {
+	files := []string{path}
+	for i := 1; ; i++ {
+		backup := fmt.Sprintf("%s.%d", path, i)
+		if _, err := os.Stat(backup); err != nil {
+			return files
+		}
+		files = append([]string{backup}, files...)
+	}
+}
+
+// Outage is a period of time, when an endpoint was not available.
+type Outage struct {
+	Start   time.Time
+	End     time.Time // of the first successful attempt after the outage, or of the last sample if it is ongoing
+	Ongoing bool
+	Error   string // of the first failed attempt
+}
+
+// History is the uptime and outages of an endpoint recorded in a '-state' file.
+type History struct {
+	Endpoint string
+	Samples  int
+	Up       int
+	First    time.Time
+	Last     time.Time
+	Outages  []Outage
+}
+
+// Uptime returns the percentage of successful samples.
+func (h History) Uptime() float64 {
+	if h.Samples == 0 {
+		return 0
+	}
+	return float64(h.Up) * 100 / float64(h.Samples)
+}
+
+// HistoryBuilder groups samples by endpoint, in order of their first appearance,
+// as they are added one by one.
+type HistoryBuilder struct {
+	since      time.Time
+	histories  []*History
+	byEndpoint map[string]*History
+}
+
+// NewHistoryBuilder returns a builder, which only takes samples since the given time
+// into account, unless it is zero.
+func NewHistoryBuilder(since time.Time) *HistoryBuilder {
+	return &HistoryBuilder{since: since, byEndpoint: map[string]*History{}}
+}
+
+func (b *HistoryBuilder) Add(s Sample) {
+	if s.Time.Before(b.since) {
+		return
+	}
+	h, ok := b.byEndpoint[s.Endpoint]
+	if !ok {
+		h = &History{Endpoint: s.Endpoint, First: s.Time}
+		b.byEndpoint[s.Endpoint] = h
+		b.histories = append(b.histories, h)
+	}
+	h.Samples++
+	h.Last = s.Time
+	var last *Outage
+	if n := len(h.Outages); n > 0 && h.Outages[n-1].Ongoing {
+		last = &h.Outages[n-1]
+	}
+	switch {
+	case s.Up:
+		h.Up++
+		if last != nil {
+			last.End, last.Ongoing = s.Time, false
+		}
+	case last != nil:
+		last.End = s.Time
+	default:
+		h.Outages = append(h.Outages, Outage{Start: s.Time, End: s.Time, Ongoing: true, Error: s.Error})
+	}
+}
+
+func (b *HistoryBuilder) Histories() []History {
+	result := make([]History, len(b.histories))
+	for i, h := range b.histories {
+		result[i] = *h
+	}
+	return result
+}
+
+// NewHistory groups the samples by endpoint, in order of their first appearance.
+// Samples since the given time are only taken into account, unless it is zero.
+func NewHistory(samples []Sample, since time.Time) []History {
+	b := NewHistoryBuilder(since)
+	for _, s := range samples {
+		b.Add(s)
+	}
+	return b.Histories()
+}
+
+// WriteHistory writes an aligned table of uptime of the endpoints followed by their outages.
+func WriteHistory(w io.Writer, histories []History) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ENDPOINT\tSAMPLES\tUPTIME\tOUTAGES\tFIRST\tLAST")
+	for _, h := range histories {
+		fmt.Fprintf(tw, "%s\t%d\t%.2f%%\t%d\t%s\t%s\n", h.Endpoint, h.Samples, h.Uptime(), len(h.Outages),
+			h.First.Format(time.DateTime), h.Last.Format(time.DateTime))
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	for _, h := range histories {
+		for _, o := range h.Outages {
+			end := o.End.Format(time.DateTime)
+			if o.Ongoing {
+				end = "now"
+			}
+			fmt.Fprintf(w, "%s down from %s to %s (%v): %s\n", h.Endpoint, o.Start.Format(time.DateTime), end,
+				o.End.Sub(o.Start).Round(time.Second), o.Error)
+		}
+	}
+	return nil
+}
+
+// RunHistory runs 'tcpw history' subcommand printing uptime and outages of endpoints recorded in a '-state' file.
+func RunHistory(name string, args []string) error {
+	fs := flag.NewFlagSet(name+" history", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	var path string
+	var since time.Duration
+	fs.StringVar(&path, "state", "", "File written with '-state' to read samples from")
+	fs.DurationVar(&since, "since", 0, "Only take samples of the last duration in format N{ns,ms,s,m,h} into account. Zero for all samples (default 0)")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s history -state FILE [-since duration]\n\n", name)
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if path == "" {
+		return errors.New("'-state' file is required")
+	}
+	var from time.Time
+	if since > 0 {
+		from = time.Now().Add(-since)
+	}
+	b := NewHistoryBuilder(from)
+	for _, file := range StateFiles(path) {
+		if err := readStateFile(file, b.Add); err != nil {
+			return err
+		}
+	}
+	return WriteHistory(os.Stdout, b.Histories())
+}
+
+func readStateFile(path string, fn func(Sample)) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err = ReadSamples(f, fn); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
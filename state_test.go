@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAppStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state")
+	app := newApp()
+	app.timeout = 300 * time.Millisecond
+	app.interval = 100 * time.Millisecond
+	var err error
+	if app.state, err = OpenStateStore(path, 0, 0); err != nil {
+		t.Fatal(err)
+	}
+	up := startListener("").String()
+	down := getFreeTCPAddr().String()
+	app.Await(context.Background(), net.Dialer{}, up)
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+	app.Await(ctx, net.Dialer{}, down)
+	if err = app.state.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var samples []Sample
+	if err = ReadSamples(f, func(s Sample) { samples = append(samples, s) }); err != nil {
+		t.Fatal(err)
+	}
+	if len(samples) < 3 || samples[0].Endpoint != up || !samples[0].Up || samples[1].Endpoint != down || samples[1].Up ||
+		!strings.Contains(samples[1].Error, "connection refused") {
+		t.Fatalf("Unexpected samples: %+v", samples)
+	}
+}
+
+func TestNewHistory(t *testing.T) {
+	start := time.Date(2024, 8, 26, 12, 0, 0, 0, time.UTC)
+	at := func(s int) time.Time {
+		return start.Add(time.Duration(s) * time.Second)
+	}
+	samples := []Sample{
+		{Time: at(0), Endpoint: "db", Up: true},
+		{Time: at(0), Endpoint: "cache", Error: "connection refused"},
+		{Time: at(1), Endpoint: "db", Error: "connection refused"},
+		{Time: at(1), Endpoint: "cache", Error: "i/o timeout"},
+		{Time: at(2), Endpoint: "db", Error: "connection refused"},
+		{Time: at(3), Endpoint: "db", Up: true},
+		{Time: at(4), Endpoint: "db", Up: true},
+	}
+
+	t.Run("Test uptime and outages", func(t *testing.T) {
+		histories := NewHistory(samples, time.Time{})
+		if len(histories) != 2 {
+			t.Fatalf("Unexpected histories: %+v", histories)
+		}
+		db, cache := histories[0], histories[1]
+		if db.Endpoint != "db" || db.Samples != 5 || db.Uptime() != 60 || len(db.Outages) != 1 {
+			t.Fatalf("Unexpected history: %+v", db)
+		}
+		if o := db.Outages[0]; o.Start != at(1) || o.End != at(3) || o.Ongoing || o.Error != "connection refused" {
+			t.Fatalf("Unexpected outage: %+v", o)
+		}
+		if cache.Uptime() != 0 || len(cache.Outages) != 1 || !cache.Outages[0].Ongoing || cache.Outages[0].End != at(1) {
+			t.Fatalf("Unexpected history: %+v", cache)
+		}
+
+		var b strings.Builder
+		if err := WriteHistory(&b, histories); err != nil {
+			t.Fatal(err)
+		}
+		for _, line := range []string{
+			"db        5        60.00%  1        2024-08-26 12:00:00  2024-08-26 12:00:04",
+			"db down from 2024-08-26 12:00:01 to 2024-08-26 12:00:03 (2s): connection refused",
+			"cache down from 2024-08-26 12:00:00 to now (1s): connection refused",
+		} {
+			if !strings.Contains(b.String(), line) {
+				t.Fatalf("No %q in output:\n%s", line, b.String())
+			}
+		}
+	})
+
+	t.Run("Test samples since the given time", func(t *testing.T) {
+		histories := NewHistory(samples, at(3))
+		if len(histories) != 1 || histories[0].Samples != 2 || histories[0].Uptime() != 100 {
+			t.Fatalf("Unexpected histories: %+v", histories)
+		}
+	})
+}
+
+func TestStateStore(t *testing.T) {
+	t.Run("Test rotation and retention (-state-max-size, -state-max-backups)", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "state")
+		store, err := OpenStateStore(path, 100, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		start := time.Date(2024, 8, 26, 12, 0, 0, 0, time.UTC)
+		for i := range 10 {
+			if err = store.Write(Sample{Time: start.Add(time.Duration(i) * time.Second), Endpoint: "db", Up: true}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err = store.Close(); err != nil {
+			t.Fatal(err)
+		}
+		files := StateFiles(path)
+		if len(files) != 3 || files[0] != path+".2" || files[1] != path+".1" || files[2] != path {
+			t.Fatalf("Unexpected files: %v", files)
+		}
+
+		b := NewHistoryBuilder(time.Time{})
+		var last time.Time
+		for _, file := range files {
+			if err = readStateFile(file, func(s Sample) {
+				if !s.Time.After(last) {
+					t.Fatalf("Samples out of order: %v after %v", s.Time, last)
+				}
+				last = s.Time
+				b.Add(s)
+			}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		histories := b.Histories()
+		if len(histories) != 1 || histories[0].Samples >= 10 || !histories[0].Last.Equal(start.Add(9*time.Second)) {
+			t.Fatalf("Unexpected histories: %+v", histories)
+		}
+	})
+
+	t.Run("Test malformed line", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "state")
+		if err := os.WriteFile(path, []byte("{\"endpoint\":\"db\"}\n\nnot json\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		err := readStateFile(path, func(Sample) {})
+		if err == nil || !strings.HasPrefix(err.Error(), path+": line 3: ") {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	})
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/jackcvr/tcpw/tcpw"
+	"io"
+	"net"
+	"os"
+	"slices"
+	"sync"
+	"time"
+)
+
+var ErrNoReplies = errors.New("some endpoints never accepted a connection")
+
+// Stats holds results of connection attempts to an endpoint in '-stats' mode.
+type Stats struct {
+	Addr      string
+	Label     string // given with '-a LABEL=ENDPOINT', if any
+	Sent      int
+	Latencies []time.Duration // of successful attempts
+}
+
+func (s *Stats) Add(latency time.Duration, err error) {
+	s.Sent++
+	if err == nil {
+		s.Latencies = append(s.Latencies, latency)
+	}
+}
+
+// FailureRatio returns the percentage of failed attempts, like packet loss of ping.
+func (s *Stats) FailureRatio() float64 {
+	if s.Sent == 0 {
+		return 0
+	}
+	return float64(s.Sent-len(s.Latencies)) * 100 / float64(s.Sent)
+}
+
+// Summary returns min, avg, max and 95th percentile of latencies of successful attempts.
+func (s *Stats) Summary() (minimum, avg, maximum, p95 time.Duration) {
+	if len(s.Latencies) == 0 {
+		return
+	}
+	sorted := slices.Clone(s.Latencies)
+	slices.Sort(sorted)
+	var sum time.Duration
+	for _, l := range sorted {
+		sum += l
+	}
+	// nearest-rank method
+	rank := (95*len(sorted) + 99) / 100
+	return sorted[0], sum / time.Duration(len(sorted)), sorted[len(sorted)-1], sorted[rank-1]
+}
+
+func (s *Stats) Write(w io.Writer) {
+	name := s.Addr
+	if s.Label != "" {
+		name = s.Label
+	}
+	fmt.Fprintf(w, "--- %s tcpw statistics ---\n", name)
+	fmt.Fprintf(w, "%s, %d succeeded, %.1f%% failed\n", tcpw.Plural(s.Sent, "attempt"), len(s.Latencies), s.FailureRatio())
+	if len(s.Latencies) > 0 {
+		minimum, avg, maximum, p95 := s.Summary()
+		fmt.Fprintf(w, "latency min/avg/max/p95 = %.3f/%.3f/%.3f/%.3f ms\n", Latency(minimum), Latency(avg), Latency(maximum), Latency(p95))
+	}
+}
+
+// RunStats makes '-count' connection attempts (unlimited if zero) to every endpoint with '-interval'
+// until '-t' timeout or interruption and prints statistics of them to stdout, like ping.
+func (app App) RunStats() error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if app.timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, app.timeout)
+		defer cancel()
+	}
+	interrupts, stop := Interrupts()
+	defer stop()
+	go func() {
+		select {
+		case <-interrupts:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	d := net.Dialer{Timeout: app.interval}
+	if app.dialTimeout > 0 {
+		d.Timeout = app.dialTimeout
+	}
+	stats := make([]Stats, len(app.endpoints))
+	var wg sync.WaitGroup
+	for i, addr := range app.endpoints {
+		stats[i].Addr, stats[i].Label = addr, app.labels[addr]
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			app.Ping(ctx, d, &stats[i])
+		}()
+	}
+	wg.Wait()
+
+	var err error
+	for i := range stats {
+		if i > 0 {
+			fmt.Fprintln(os.Stdout)
+		}
+		stats[i].Write(os.Stdout)
+		if len(stats[i].Latencies) == 0 {
+			err = ErrNoReplies
+		}
+	}
+	return err
+}
+
+// Ping makes connection attempts to the endpoint until ctx is done or '-count' is reached.
+func (app App) Ping(ctx context.Context, d net.Dialer, s *Stats) {
+	ticker := time.NewTicker(app.interval)
+	defer ticker.Stop()
+	for attempt := 1; app.count == 0 || attempt <= app.count; attempt++ {
+		start := time.Now()
+		err := app.Dial(ctx, d, s.Addr)
+		if tcpw.Expired(ctx) {
+			return
+		}
+		latency := time.Since(start)
+		s.Add(latency, err)
+		if err != nil {
+			app.Logger().Warn(fmt.Sprintf("attempt %d to connect to %s failed in %v", attempt, app.Label(s.Addr), latency.Round(time.Microsecond)),
+				"endpoint", app.Label(s.Addr), "attempt", attempt, "latency_ms", Latency(latency), "status", StatusDown, "error", err)
+		} else {
+			app.Logger().Info(fmt.Sprintf("connected to %s in %v (attempt %d)", app.Label(s.Addr), latency.Round(time.Microsecond), attempt),
+				"endpoint", app.Label(s.Addr), "attempt", attempt, "latency_ms", Latency(latency), "status", StatusUp)
+		}
+		if attempt == app.count {
+			return
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
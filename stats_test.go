@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStats(t *testing.T) {
+	t.Run("Test summary of latencies", func(t *testing.T) {
+		s := Stats{Addr: "127.0.0.1:1"}
+		for i := 1; i <= 20; i++ {
+			s.Add(time.Duration(i)*time.Millisecond, nil)
+		}
+		s.Add(0, errors.New("connection refused"))
+		minimum, avg, maximum, p95 := s.Summary()
+		if minimum != time.Millisecond || avg != 10500*time.Microsecond || maximum != 20*time.Millisecond || p95 != 19*time.Millisecond {
+			t.Fatalf("Wrong summary: %v/%v/%v/%v", minimum, avg, maximum, p95)
+		}
+		var buf bytes.Buffer
+		s.Write(&buf)
+		want := "--- 127.0.0.1:1 tcpw statistics ---\n" +
+			"21 attempts, 20 succeeded, 4.8% failed\n" +
+			"latency min/avg/max/p95 = 1.000/10.500/20.000/19.000 ms\n"
+		if buf.String() != want {
+			t.Fatalf("Unexpected output:\n%s", buf.String())
+		}
+	})
+
+	t.Run("Test '-count' attempts are made (-stats)", func(t *testing.T) {
+		l := serve("localhost:0")
+		defer l.Close()
+		app := newApp()
+		app.interval = 10 * time.Millisecond
+		app.endpoints = []string{l.Addr().String()}
+		app.stats = true
+		app.count = 3
+		if err := app.Run(); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("Test error if endpoint never accepted a connection", func(t *testing.T) {
+		app := newApp()
+		app.interval = 10 * time.Millisecond
+		app.endpoints = []string{getFreeTCPAddr().String()}
+		app.stats = true
+		app.count = 2
+		if err := app.Run(); err != ErrNoReplies {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	})
+
+	t.Run("Test error: '-count' without '-stats'", func(t *testing.T) {
+		app := newApp()
+		app.endpoints = []string{"localhost:1234"}
+		app.count = 3
+		if err := app.Check(); err == nil || !strings.Contains(err.Error(), "'-stats'") {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	})
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// StatsD sends metrics over UDP in StatsD line protocol.
+type StatsD struct {
+	conn net.Conn
+}
+
+func OpenStatsD(addr string) (*StatsD, error) {
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	return &StatsD{conn: conn}, nil
+}
+
+func (s *StatsD) Close() error {
+	return s.conn.Close()
+}
+
+// Send sends the metrics in a single packet, e.g. 'tcpw.attempts:1|c'.
+func (s *StatsD) Send(metrics ...string) error {
+	_, err := s.conn.Write([]byte(strings.Join(metrics, "\n")))
+	return err
+}
+
+// MetricName converts the endpoint to a valid metric name part, e.g. '10.0.0.1:5432' to '10_0_0_1_5432'.
+func MetricName(s string) string {
+	return strings.ToLower(EnvName(s))
+}
+
+// Measure sends the counter of attempts by result and the timer of latency for the attempt event to '-statsd', if any:
+//
+//	tcpw.attempts.<endpoint>.<success|failure>
+//	tcpw.latency.<endpoint>
+func (app App) Measure(e Event) {
+	if app.statsd == nil {
+		return
+	}
+	result := "success"
+	if e.Error != "" {
+		result = "failure"
+	}
+	name := MetricName(e.Endpoint)
+	err := app.statsd.Send(
+		fmt.Sprintf("tcpw.attempts.%s.%s:1|c", name, result),
+		fmt.Sprintf("tcpw.latency.%s:%.3f|ms", name, e.LatencyMs),
+	)
+	if err != nil {
+		app.Debug("failed to send metrics: %v", err)
+	}
+}
+
+// MeasureWait sends the counter of waits by outcome and the timer of the wait duration to '-statsd', if any:
+//
+//	tcpw.waits.<success|timeout|failure>
+//	tcpw.wait_duration
+func (app App) MeasureWait(err error, elapsed time.Duration) {
+	if app.statsd == nil {
+		return
+	}
+	err = app.statsd.Send(
+		fmt.Sprintf("tcpw.waits.%s:1|c", Status(err)),
+		fmt.Sprintf("tcpw.wait_duration:%d|ms", elapsed.Milliseconds()),
+	)
+	if err != nil {
+		app.Debug("failed to send metrics: %v", err)
+	}
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAppMeasure(t *testing.T) {
+	t.Run("Test metrics are sent to StatsD (-statsd)", func(t *testing.T) {
+		conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		app := newApp()
+		addr := startListener("").String()
+		app.endpoints = []string{addr}
+		app.statsdAddr = conn.LocalAddr().String()
+		if err = app.Run(); err != nil {
+			t.Fatal(err)
+		}
+		var lines []string
+		buf := make([]byte, 1024)
+		for len(lines) < 4 {
+			_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+			n, _, err := conn.ReadFrom(buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			lines = append(lines, strings.Split(string(buf[:n]), "\n")...)
+		}
+		name := MetricName(addr)
+		if lines[0] != "tcpw.attempts."+name+".success:1|c" || !strings.HasPrefix(lines[1], "tcpw.latency."+name+":") ||
+			lines[2] != "tcpw.waits.success:1|c" || !strings.HasPrefix(lines[3], "tcpw.wait_duration:") {
+			t.Fatalf("Unexpected metrics: %q", lines)
+		}
+	})
+}
@@ -0,0 +1,38 @@
+//go:build !minimal
+
+package main
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"time"
+)
+
+// ServeHTTP responds with the current state in JSON.
+func (t *Tracker) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(struct {
+		ElapsedMs int64            `json:"elapsed_ms"`
+		Endpoints []*EndpointState `json:"endpoints"`
+		Command   string           `json:"command,omitempty"`
+	}{time.Since(t.start).Milliseconds(), t.endpoints, t.command})
+}
+
+// ServeStatus serves the current state on '-status-listen' address until the returned function is called.
+func (app App) ServeStatus() (func(), error) {
+	l, err := net.Listen("tcp", app.statusListen)
+	if err != nil {
+		return nil, err
+	}
+	srv := &http.Server{Handler: app.tracker, ReadHeaderTimeout: 5 * time.Second}
+	go func() {
+		_ = srv.Serve(l)
+	}()
+	app.Debug("serving status on %s", l.Addr())
+	return func() {
+		_ = srv.Close()
+	}, nil
+}
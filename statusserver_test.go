@@ -0,0 +1,43 @@
+//go:build !minimal
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestAppServeStatus(t *testing.T) {
+	t.Run("Test current state is served (-status-listen)", func(t *testing.T) {
+		app := newApp()
+		addr := getFreeTCPAddr().String()
+		app.endpoints = []string{addr}
+		app.statusListen = getFreeTCPAddr().String()
+		done := make(chan error, 1)
+		go func() {
+			done <- app.Run()
+		}()
+		time.Sleep(300 * time.Millisecond)
+		resp, err := http.Get("http://" + app.statusListen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var status struct {
+			ElapsedMs int64           `json:"elapsed_ms"`
+			Endpoints []EndpointState `json:"endpoints"`
+		}
+		if err = json.NewDecoder(resp.Body).Decode(&status); err != nil {
+			t.Fatal(err)
+		}
+		if status.ElapsedMs <= 0 || len(status.Endpoints) != 1 || status.Endpoints[0].Endpoint != addr ||
+			status.Endpoints[0].State != "waiting" || status.Endpoints[0].Attempts < 1 || status.Endpoints[0].Error == "" {
+			t.Fatalf("Unexpected status: %+v", status)
+		}
+		if err = <-done; err == nil {
+			t.Fatal("Unexpected success")
+		}
+	})
+}
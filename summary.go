@@ -0,0 +1,24 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// Summary returns an aligned table of the results: endpoint, final state, attempts and time to ready.
+func Summary(results []EndpointResult) string {
+	var b strings.Builder
+	w := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ENDPOINT\tSTATE\tATTEMPTS\tREADY AFTER")
+	for _, r := range results {
+		ready := "-"
+		if r.Ready {
+			ready = r.Duration.Round(time.Millisecond).String()
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d\t%s\n", r.Name(), Status(r.Err), r.Attempts, ready)
+	}
+	_ = w.Flush()
+	return strings.TrimSuffix(b.String(), "\n")
+}
@@ -0,0 +1,21 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestSummary(t *testing.T) {
+	results := []EndpointResult{
+		{Addr: "127.0.0.1:5432", Ready: true, Attempts: 3, Duration: 2003 * time.Millisecond},
+		{Addr: "127.0.0.1:6379", Attempts: 12, Duration: 5 * time.Second, Err: fmt.Errorf("%w: connection refused", context.DeadlineExceeded)},
+	}
+	want := "ENDPOINT        STATE    ATTEMPTS  READY AFTER\n" +
+		"127.0.0.1:5432  success  3         2.003s\n" +
+		"127.0.0.1:6379  timeout  12        -"
+	if got := Summary(results); got != want {
+		t.Fatalf("Unexpected summary:\n%s", got)
+	}
+}
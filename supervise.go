@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+var ErrLost = errors.New("lost connection to endpoints")
+
+// Monitor runs the command and keeps monitoring the endpoints.
+// With '-kill-on-loss' the command is terminated as soon as an endpoint becomes unavailable,
+// with '-supervise' it is restarted every time the endpoints recover after a loss.
+// It returns when the command exits by itself or is terminated without supervision.
+func (app App) Monitor(results []EndpointResult, elapsed time.Duration) error {
+	cmd, exited, err := app.Start(app.Args(), results, elapsed)
+	if err != nil {
+		return err
+	}
+
+	interrupts, stop := Interrupts()
+	defer stop()
+	hangups, stopHangups := app.Hangups()
+	defer stopHangups()
+	ticker := time.NewTicker(app.interval)
+	defer ticker.Stop()
+	up := true
+	for {
+		select {
+		case err = <-exited:
+			return err
+		case sig := <-interrupts:
+			if exited != nil {
+				app.Info("received %v, terminating command", sig)
+				_ = app.Stop(cmd, exited)
+			}
+			return &InterruptError{sig}
+		case <-hangups:
+			app.ReloadOnHangup()
+		case <-ticker.C:
+			ok := app.Probe()
+			if up && !ok {
+				app.Logger().Warn("lost connection to endpoints", "status", StatusDown)
+				app.Emit(Event{Type: EventState, State: "down"})
+				app.Alert(StatusDown, "lost connection to endpoints: %s", strings.Join(app.Labels(), ", "))
+				if app.killOnLoss {
+					app.Info("terminating command")
+					_ = app.Stop(cmd, exited)
+					if !app.supervise {
+						return ErrLost
+					}
+					exited = nil
+				}
+			} else if !up && ok && app.supervise {
+				app.Logger().Info("endpoints recovered, restarting command", "status", StatusUp)
+				app.Emit(Event{Type: EventState, State: "up"})
+				app.Alert(StatusUp, "endpoints recovered: %s", strings.Join(app.Labels(), ", "))
+				if exited != nil {
+					_ = app.Stop(cmd, exited)
+				}
+				if cmd, exited, err = app.Start(app.Args(), results, elapsed); err != nil {
+					return err
+				}
+			}
+			up = ok
+		}
+	}
+}
+
+// Probe makes a single connection attempt to every endpoint and reports whether all of them are available.
+// With '-state' all endpoints are probed to record their state, otherwise it stops at the first failure.
+func (app App) Probe() bool {
+	ctx, cancel := context.WithTimeout(context.Background(), app.interval)
+	defer cancel()
+	d := net.Dialer{}
+	ok := true
+	for _, addr := range app.endpoints {
+		err := app.Dial(ctx, d, addr)
+		app.Store(app.Label(addr), err)
+		if err != nil {
+			app.Logger().Debug(fmt.Sprintf("probe of %s failed", app.Label(addr)), "endpoint", app.Label(addr), "error", err)
+			ok = false
+			if app.state == nil {
+				break
+			}
+		}
+	}
+	return ok
+}
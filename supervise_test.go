@@ -0,0 +1,81 @@
+package main
+
+import (
+	"log"
+	"net"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func serve(addr string) net.Listener {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		log.Panicf("Can't listen: %v", err)
+	}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+	return l
+}
+
+func TestSupervise(t *testing.T) {
+	app := newApp()
+	app.interval = 50 * time.Millisecond
+	app.supervise = true
+	l := serve("localhost:0")
+	addr := l.Addr().String()
+	app.endpoints = []string{addr}
+	file := t.TempDir() + "/test"
+	// the first run waits to be restarted, the second one exits
+	app.shell = "echo run >> '" + file + "'; [ $(wc -l < '" + file + "') -gt 1 ] || exec sleep 10"
+
+	restarted := make(chan net.Listener, 1)
+	go func() {
+		time.Sleep(300 * time.Millisecond)
+		l.Close()
+		time.Sleep(300 * time.Millisecond)
+		restarted <- serve(addr)
+	}()
+	t.Cleanup(func() {
+		(<-restarted).Close()
+	})
+
+	if err := app.Run(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if data, err := os.ReadFile(file); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	} else if n := strings.Count(string(data), "run"); n != 2 {
+		t.Fatalf("Command ran %d times instead of 2", n)
+	}
+}
+
+func TestKillOnLoss(t *testing.T) {
+	app := newApp()
+	app.interval = 50 * time.Millisecond
+	app.killOnLoss = true
+	l := serve("localhost:0")
+	app.endpoints = []string{l.Addr().String()}
+	app.shell = "exec sleep 10"
+
+	go func() {
+		time.Sleep(300 * time.Millisecond)
+		l.Close()
+	}()
+
+	start := time.Now()
+	if err := app.Run(); err != ErrLost {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatalf("Command was not terminated in time: %v", elapsed)
+	}
+}
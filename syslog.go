@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+)
+
+// SyslogAddr is the '-syslog' flag: the local syslog if it is set without a value,
+// or a remote one in the form '[udp://|tcp://]host:port'.
+type SyslogAddr struct {
+	value string
+	set   bool
+}
+
+func (a *SyslogAddr) String() string {
+	return a.value
+}
+
+func (a *SyslogAddr) Set(value string) error {
+	if value == "true" {
+		value = ""
+	}
+	a.value, a.set = value, value != "false"
+	return nil
+}
+
+func (a *SyslogAddr) IsBoolFlag() bool {
+	return true
+}
+
+// Network returns the network and the address of the remote syslog, or empty strings for the local one.
+func (a *SyslogAddr) Network() (string, string) {
+	if a.value == "" {
+		return "", ""
+	}
+	if network, addr, ok := strings.Cut(a.value, "://"); ok {
+		return network, addr
+	}
+	return "udp", a.value
+}
+
+// SyslogWriter writes messages to syslog with severity.
+type SyslogWriter interface {
+	Debug(msg string) error
+	Info(msg string) error
+	Warning(msg string) error
+	Err(msg string) error
+	Close() error
+}
+
+// syslogHandler writes text messages to syslog with severity corresponding to the level.
+type syslogHandler struct {
+	level slog.Level
+	w     SyslogWriter
+}
+
+func (h syslogHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level
+}
+
+func (h syslogHandler) Handle(_ context.Context, r slog.Record) error {
+	msg := r.Message
+	r.Attrs(func(a slog.Attr) bool {
+		if a.Key == "error" {
+			msg += ": " + a.Value.String()
+		}
+		return true
+	})
+	switch {
+	case r.Level >= slog.LevelError:
+		return h.w.Err(msg)
+	case r.Level >= slog.LevelWarn:
+		return h.w.Warning(msg)
+	case r.Level >= slog.LevelInfo:
+		return h.w.Info(msg)
+	default:
+		return h.w.Debug(msg)
+	}
+}
+
+func (h syslogHandler) WithAttrs([]slog.Attr) slog.Handler {
+	return h
+}
+
+func (h syslogHandler) WithGroup(string) slog.Handler {
+	return h
+}
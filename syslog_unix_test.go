@@ -0,0 +1,69 @@
+//go:build !windows && !plan9
+
+package main
+
+import (
+	"log/slog"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSyslog(t *testing.T) {
+	t.Run("Test log lines are sent to remote syslog (-syslog)", func(t *testing.T) {
+		conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		app := newApp()
+		app.logLevel.Level = slog.LevelInfo
+		app.endpoints = []string{startListener("").String()}
+		_ = app.syslogAddr.Set("udp://" + conn.LocalAddr().String())
+		app.syslogFacility = "local0"
+		if err = app.Run(); err != nil {
+			t.Fatal(err)
+		}
+		buf := make([]byte, 1024)
+		_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// local0 (16) * 8 + info (6)
+		if msg := string(buf[:n]); !strings.HasPrefix(msg, "<134>") || !strings.Contains(msg, "tcpw") || !strings.Contains(msg, "successfully connected") {
+			t.Fatalf("Unexpected message: %q", msg)
+		}
+	})
+
+	t.Run("Test error: unknown facility", func(t *testing.T) {
+		if _, err := OpenSyslog(&SyslogAddr{value: "127.0.0.1:514", set: true}, "local9"); err == nil {
+			t.Fatal("Unexpected success")
+		}
+	})
+
+	t.Run("Test error: '-syslog' with '-log-file'", func(t *testing.T) {
+		app := newApp()
+		if err := app.Parse("tcpw", []string{"-q", "-syslog", "-log-file", "tcpw.log", "-a", "localhost:5432"}); err != nil {
+			t.Fatal(err)
+		}
+		if err := app.Check(); err == nil || err.Error() != "'-syslog' can not be combined with '-log-file'" {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	})
+}
+
+func TestSyslogAddr(t *testing.T) {
+	for value, want := range map[string][2]string{
+		"true":               {"", ""},
+		"10.0.0.1:514":       {"udp", "10.0.0.1:514"},
+		"tcp://10.0.0.1:601": {"tcp", "10.0.0.1:601"},
+	} {
+		var a SyslogAddr
+		_ = a.Set(value)
+		if network, addr := a.Network(); !a.set || network != want[0] || addr != want[1] {
+			t.Fatalf("Wrong address of %q: %s %s", value, network, addr)
+		}
+	}
+}
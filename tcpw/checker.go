@@ -0,0 +1,121 @@
+package tcpw
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Endpoint is an endpoint being checked.
+type Endpoint struct {
+	Addr   string   // in the canonical form of ParseEndpoint, e.g. 'udp://10.3.2.1:53'
+	Scheme string   // e.g. 'udp', 'tcp' for endpoints without scheme
+	Target string   // the address without scheme, e.g. '10.3.2.1:53'
+	Dial   DialFunc // connects to addresses of the endpoint
+}
+
+// NewEndpoint returns the endpoint of addr in the canonical form of ParseEndpoint.
+func NewEndpoint(addr string, dial DialFunc) Endpoint {
+	scheme, target, ok := strings.Cut(addr, "://")
+	if !ok {
+		scheme, target = "tcp", addr
+	}
+	return Endpoint{Addr: addr, Scheme: scheme, Target: target, Dial: dial}
+}
+
+// Checker makes a single attempt to check that the endpoint is available, and reports why it is not.
+// The returned Result may carry details of the attempt, e.g. Latency measured by the checker itself,
+// the rest of it is filled in by the Waiter.
+type Checker interface {
+	Check(ctx context.Context, e Endpoint) (Result, error)
+}
+
+// CheckerFunc is a Checker of a function.
+type CheckerFunc func(ctx context.Context, e Endpoint) (Result, error)
+
+func (f CheckerFunc) Check(ctx context.Context, e Endpoint) (Result, error) {
+	return f(ctx, e)
+}
+
+var (
+	checkersMu sync.RWMutex
+	checkers   = map[string]Checker{}
+)
+
+func init() {
+	Register("tcp", CheckerFunc(CheckTCP))
+	Register("udp", errorChecker(DialUDP))
+	Register("unix", CheckerFunc(CheckUnix))
+	Register("path", errorChecker(CheckPath))
+	Register("listen", CheckerFunc(func(ctx context.Context, e Endpoint) (Result, error) {
+		return Result{}, CheckListening(ctx, e.Target)
+	}))
+}
+
+// errorChecker returns a Checker of the function checking the target of an endpoint.
+func errorChecker(check func(ctx context.Context, dial DialFunc, target string) error) Checker {
+	return CheckerFunc(func(ctx context.Context, e Endpoint) (Result, error) {
+		return Result{}, check(ctx, e.Dial, e.Target)
+	})
+}
+
+// Register makes the checker check endpoints of the scheme, replacing the one registered before,
+// e.g. in init functions of packages adding protocols. Endpoints of registered schemes are accepted by ParseEndpoint as is.
+func Register(scheme string, c Checker) {
+	checkersMu.Lock()
+	defer checkersMu.Unlock()
+	checkers[scheme] = c
+}
+
+// Lookup returns the checker of the scheme.
+func Lookup(scheme string) (Checker, bool) {
+	checkersMu.RLock()
+	defer checkersMu.RUnlock()
+	c, ok := checkers[scheme]
+	return c, ok
+}
+
+// Schemes returns registered schemes, sorted.
+func Schemes() []string {
+	checkersMu.RLock()
+	defer checkersMu.RUnlock()
+	schemes := make([]string, 0, len(checkers))
+	for scheme := range checkers {
+		schemes = append(schemes, scheme)
+	}
+	slices.Sort(schemes)
+	return schemes
+}
+
+// Check makes a single attempt to check the endpoint with the checker of its scheme.
+func Check(ctx context.Context, e Endpoint) (Result, error) {
+	c, ok := Lookup(e.Scheme)
+	if !ok {
+		return Result{}, fmt.Errorf("%w '%s'", ErrUnsupportedScheme, e.Scheme)
+	}
+	return c.Check(ctx, e)
+}
+
+// CheckTCP connects to the endpoint and closes the connection.
+func CheckTCP(ctx context.Context, e Endpoint) (Result, error) {
+	// connect phase is traced by the dialer itself
+	conn, err := e.Dial(ctx, "tcp", e.Target)
+	if err != nil {
+		return Result{}, err
+	}
+	traceConn(ctx, conn)
+	// the endpoint is available even if closing the connection fails
+	_ = conn.Close()
+	return Result{}, nil
+}
+
+// CheckUnix connects to the unix socket of the endpoint and closes the connection.
+func CheckUnix(ctx context.Context, e Endpoint) (Result, error) {
+	conn, err := e.Dial(ctx, "unix", e.Target)
+	if err != nil {
+		return Result{}, err
+	}
+	return Result{}, conn.Close()
+}
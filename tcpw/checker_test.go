@@ -0,0 +1,56 @@
+package tcpw
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRegister(t *testing.T) {
+	var checked []Endpoint
+	Register("fake", CheckerFunc(func(ctx context.Context, e Endpoint) (Result, error) {
+		checked = append(checked, e)
+		if len(checked) < 2 {
+			return Result{}, errors.New("not yet")
+		}
+		return Result{Latency: time.Millisecond}, nil
+	}))
+	defer func() {
+		checkersMu.Lock()
+		delete(checkers, "fake")
+		checkersMu.Unlock()
+	}()
+
+	if addr, err := ParseEndpoint("fake://db/1"); err != nil || addr != "fake://db/1" {
+		t.Fatalf("Unexpected endpoint: %q, %v", addr, err)
+	}
+	w, err := NewWaiter(Options{Endpoints: []string{"fake://db/1"}, Timeout: time.Second, Interval: 10 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	results, err := w.Wait(context.Background())
+	if err != nil || results[0].Attempts != 2 || results[0].Latency != time.Millisecond {
+		t.Fatalf("Unexpected results: %+v, %v", results, err)
+	}
+	if e := checked[0]; e.Scheme != "fake" || e.Target != "db/1" || e.Dial == nil {
+		t.Fatalf("Unexpected endpoint: %+v", e)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	addr := listen(t).Addr().String()
+	e := NewEndpoint(addr, (&net.Dialer{}).DialContext)
+	if e.Scheme != "tcp" || e.Target != addr {
+		t.Fatalf("Unexpected endpoint: %+v", e)
+	}
+	if _, err := Check(context.Background(), e); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	_, err := ParseEndpoint("ftp://localhost:21")
+	if !errors.Is(err, ErrUnsupportedScheme) || !strings.HasSuffix(err.Error(), "'udp' or 'unix'") {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+}
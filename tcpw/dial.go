@@ -0,0 +1,76 @@
+package tcpw
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"time"
+)
+
+// udpReplyTimeout is how long a UDP endpoint may stay silent before it is considered available.
+const udpReplyTimeout = 500 * time.Millisecond
+
+// DialFunc connects to the address on the named network, like net.Dialer.DialContext.
+type DialFunc func(ctx context.Context, network, addr string) (net.Conn, error)
+
+// DialContext calls f, so a function can be a ContextDialer.
+func (f DialFunc) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	return f(ctx, network, addr)
+}
+
+// Dial makes a single connection attempt to the endpoint in the canonical form of ParseEndpoint
+// with the checker of its scheme, see Check.
+func Dial(ctx context.Context, dial DialFunc, addr string) error {
+	_, err := Check(ctx, NewEndpoint(addr, dial))
+	return err
+}
+
+// DialUDP sends an empty datagram to the endpoint and waits for a reply.
+// The endpoint is not available only if the host reports the port unreachable.
+func DialUDP(ctx context.Context, dial DialFunc, addr string) error {
+	conn, err := dial(ctx, "udp", addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	deadline := time.Now().Add(udpReplyTimeout)
+	if ctxDeadline, ok := ctx.Deadline(); ok && ctxDeadline.Before(deadline) {
+		deadline = ctxDeadline
+	}
+	_ = conn.SetDeadline(deadline)
+	if _, err = conn.Write(nil); err != nil {
+		return err
+	}
+	if _, err = conn.Read(make([]byte, 1)); err != nil {
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			return nil
+		}
+		return err
+	}
+	return nil
+}
+
+// CheckPath reports an error unless the path exists. A unix socket is connected to as well.
+func CheckPath(ctx context.Context, dial DialFunc, path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if info.Mode()&os.ModeSocket == 0 {
+		return nil
+	}
+	conn, err := dial(ctx, "unix", path)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+// IsFatal reports whether the error of a connection attempt won't go away by retrying, e.g. an unknown host.
+func IsFatal(err error) bool {
+	var addrErr *net.AddrError
+	var dnsErr *net.DNSError
+	return errors.As(err, &addrErr) || errors.As(err, &dnsErr)
+}
@@ -0,0 +1,124 @@
+package tcpw
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// ErrUnsupportedScheme is reported by ParseEndpoint for endpoints of unknown schemes.
+var ErrUnsupportedScheme = errors.New("unsupported scheme")
+
+// Scheme returns the scheme of the endpoint, e.g. 'udp' of 'udp://10.3.2.1:53', or 'tcp' if there is none.
+func Scheme(addr string) string {
+	if scheme, _, ok := strings.Cut(addr, "://"); ok {
+		return scheme
+	}
+	return "tcp"
+}
+
+// HostPort returns the host and the port of the endpoint, empty for unix sockets.
+func HostPort(addr string) (host, port string) {
+	switch Scheme(addr) {
+	case "http", "https":
+		if u, err := url.Parse(addr); err == nil {
+			host, port = u.Hostname(), u.Port()
+			if port == "" {
+				port = map[string]string{"http": "80", "https": "443"}[u.Scheme]
+			}
+		}
+		return host, port
+	case "unix":
+		return "", ""
+	}
+	_, hostport, _ := strings.Cut(addr, "://")
+	if !strings.Contains(addr, "://") {
+		hostport = addr
+	}
+	host, port, _ = net.SplitHostPort(hostport)
+	return host, port
+}
+
+// ParseEndpoint validates the endpoint and returns its canonical form:
+// 'host:port' for TCP endpoints (with or without 'tcp://' scheme), 'udp://host:port' for UDP ones,
+// with the port number of named ports, e.g. 'db:5432' of 'db:postgresql', and hosts resolved on every attempt,
+// and the value as is for 'tls://host:port', 'unix:///path', 'path:///path', 'listen://host:port', HTTP(S) URLs
+// and endpoints of schemes added with Register. Schemes without a registered checker are unsupported,
+// e.g. 'https' in builds with 'minimal' tag.
+func ParseEndpoint(value string) (string, error) {
+	scheme, rest, ok := strings.Cut(value, "://")
+	if !ok {
+		scheme, rest = "tcp", value
+	}
+	if _, ok := Lookup(scheme); !ok {
+		return "", fmt.Errorf("%w '%s', expected %s", ErrUnsupportedScheme, scheme, QuoteList(Schemes()))
+	}
+	switch scheme {
+	case "tcp":
+		return hostPort("tcp", rest)
+	case "udp":
+		addr, err := hostPort("udp", rest)
+		if err != nil {
+			return "", err
+		}
+		return "udp://" + addr, nil
+	case "tls", "listen":
+		// the host of tls is kept to verify the certificate and resolved on every attempt, like hosts of URLs
+		_, port, err := net.SplitHostPort(rest)
+		if err != nil {
+			return "", err
+		}
+		if _, err = net.LookupPort("tcp", port); err != nil {
+			return "", err
+		}
+		return value, nil
+	case "path":
+		if rest == "" {
+			return "", errors.New("path expected, e.g. 'path:///run/app.pid'")
+		}
+		return value, nil
+	case "unix":
+		if rest == "" {
+			return "", errors.New("unix socket path expected, e.g. 'unix:///run/app.sock'")
+		}
+		return value, nil
+	case "http", "https":
+		if _, err := url.Parse(value); err != nil {
+			return "", err
+		}
+	}
+	return value, nil
+}
+
+// hostPort returns 'host:port' with the number of the port of the network and the IP host in its canonical form.
+// The host is not resolved, so the failure to resolve it is that of a connection attempt.
+func hostPort(network, hostport string) (string, error) {
+	host, port, err := net.SplitHostPort(hostport)
+	if err != nil {
+		return "", err
+	}
+	number, err := net.LookupPort(network, port)
+	if err != nil {
+		return "", err
+	}
+	if ip, err := netip.ParseAddr(host); err == nil {
+		host = ip.Unmap().String()
+	}
+	return net.JoinHostPort(host, strconv.Itoa(number)), nil
+}
+
+// QuoteList returns the values quoted and listed, e.g. "'tcp', 'udp' or 'unix'".
+func QuoteList(values []string) string {
+	quoted := make([]string, len(values))
+	for i, v := range values {
+		quoted[i] = "'" + v + "'"
+	}
+	if len(quoted) < 2 {
+		return strings.Join(quoted, "")
+	}
+	return strings.Join(quoted[:len(quoted)-1], ", ") + " or " + quoted[len(quoted)-1]
+}
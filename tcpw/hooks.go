@@ -0,0 +1,52 @@
+package tcpw
+
+import "time"
+
+// States of an endpoint reported to Hooks.OnStateChange, like those of '-events' of the command.
+const (
+	StateWaiting = "waiting"
+	StateUp      = "up"
+	StateFailed  = "failed"  // by a fatal error, see IsFatal
+	StateTimeout = "timeout" // or ctx of the wait is done
+)
+
+// Attempt is a connection attempt to an endpoint.
+type Attempt struct {
+	Endpoint string
+	Number   int // starting from 1
+	Latency  time.Duration
+	Err      error // nil if the endpoint is available
+}
+
+// Hooks are callbacks of a Waiter, e.g. to drive progress bars, metrics or alerts; nil ones are skipped.
+// Hooks of different endpoints are called concurrently, and the wait for an endpoint goes on once its hook returns.
+type Hooks struct {
+	OnAttempt       func(Attempt)                           // after every connection attempt
+	OnEndpointReady func(Result)                            // once an endpoint is available
+	OnStateChange   func(endpoint, state string, err error) // see StateWaiting and others
+	OnFinish        func(Results, error)                    // once the wait is over, with what Wait returns
+}
+
+func (h Hooks) attempt(a Attempt) {
+	if h.OnAttempt != nil {
+		h.OnAttempt(a)
+	}
+}
+
+func (h Hooks) ready(r Result) {
+	if h.OnEndpointReady != nil {
+		h.OnEndpointReady(r)
+	}
+}
+
+func (h Hooks) state(endpoint, state string, err error) {
+	if h.OnStateChange != nil {
+		h.OnStateChange(endpoint, state, err)
+	}
+}
+
+func (h Hooks) finish(results Results, err error) {
+	if h.OnFinish != nil {
+		h.OnFinish(results, err)
+	}
+}
@@ -0,0 +1,59 @@
+package tcpw
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestHooks(t *testing.T) {
+	up, down := listen(t).Addr().String(), freeAddr(t)
+	var mu sync.Mutex
+	var attempts []Attempt
+	var ready []Result
+	states := map[string][]string{}
+	var finished Results
+	w, err := NewWaiter(Options{
+		Endpoints: []string{up, down},
+		Timeout:   250 * time.Millisecond,
+		Interval:  100 * time.Millisecond,
+		Hooks: Hooks{
+			OnAttempt: func(a Attempt) {
+				mu.Lock()
+				defer mu.Unlock()
+				attempts = append(attempts, a)
+			},
+			OnEndpointReady: func(r Result) {
+				mu.Lock()
+				defer mu.Unlock()
+				ready = append(ready, r)
+			},
+			OnStateChange: func(endpoint, state string, err error) {
+				mu.Lock()
+				defer mu.Unlock()
+				states[endpoint] = append(states[endpoint], state)
+			},
+			OnFinish: func(results Results, err error) {
+				finished = results
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	results, _ := w.Wait(context.Background())
+	if len(attempts) < 3 || !slices.ContainsFunc(attempts, func(a Attempt) bool { return a.Endpoint == down && a.Err != nil }) {
+		t.Fatalf("Unexpected attempts: %+v", attempts)
+	}
+	if len(ready) != 1 || ready[0].Endpoint != up {
+		t.Fatalf("Unexpected ready endpoints: %+v", ready)
+	}
+	if !slices.Equal(states[up], []string{StateWaiting, StateUp}) || !slices.Equal(states[down], []string{StateWaiting, StateTimeout}) {
+		t.Fatalf("Unexpected states: %v", states)
+	}
+	if len(finished) != 2 || finished[1].Err != results[1].Err {
+		t.Fatalf("Unexpected results: %+v", finished)
+	}
+}
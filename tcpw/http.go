@@ -0,0 +1,64 @@
+//go:build !minimal
+
+package tcpw
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+)
+
+// Checkers of HTTP(S) and TLS endpoints are left out of builds with 'minimal' tag,
+// which link neither the HTTP client nor TLS.
+func init() {
+	Register("tls", errorChecker(DialTLS))
+	http := CheckerFunc(func(ctx context.Context, e Endpoint) (Result, error) {
+		_, err := DialHTTP(ctx, e.Dial, e.Addr)
+		return Result{}, err
+	})
+	Register("http", http)
+	Register("https", http)
+}
+
+// DialHTTP sends a GET request to the URL and checks that the response status is 2xx.
+// The response is returned for its status and headers, even if the status is not 2xx.
+func DialHTTP(ctx context.Context, dial DialFunc, url string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	client := http.Client{Transport: &http.Transport{
+		DialContext:       dial,
+		DisableKeepAlives: true,
+	}}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp, fmt.Errorf("%s returned %s", url, resp.Status)
+	}
+	return resp, nil
+}
+
+// DialTLS connects to the endpoint and performs a TLS handshake, verifying its certificate.
+func DialTLS(ctx context.Context, dial DialFunc, addr string) error {
+	host, _, _ := net.SplitHostPort(addr)
+	conn, err := dial(ctx, "tcp", addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	return tls.Client(conn, &tls.Config{ServerName: host}).HandshakeContext(ctx)
+}
+
+// traceConn reports the connection to GotConn of httptrace.ClientTrace of ctx, if any.
+func traceConn(ctx context.Context, conn net.Conn) {
+	if trace := httptrace.ContextClientTrace(ctx); trace != nil && trace.GotConn != nil {
+		trace.GotConn(httptrace.GotConnInfo{Conn: conn})
+	}
+}
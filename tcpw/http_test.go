@@ -0,0 +1,31 @@
+//go:build !minimal
+
+package tcpw
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWaitHTTP(t *testing.T) {
+	t.Run("Test endpoint not responding to HTTP", func(t *testing.T) {
+		up := listen(t).Addr().String()
+		w, err := NewWaiter(Options{Endpoints: []string{up, "http://" + up}, Timeout: time.Second, Interval: 100 * time.Millisecond})
+		if err != nil {
+			t.Fatal(err)
+		}
+		// the listener never responds to HTTP requests, so only the TCP endpoint is available
+		results, err := w.Wait(context.Background())
+		if len(results) != 2 || results[0].Err != nil || results[0].Attempts != 1 || results[1].Err == nil {
+			t.Fatalf("Unexpected results: %+v", results)
+		}
+		if failed := results.Failed(); len(failed) != 1 || failed[0].Endpoint != "http://"+up {
+			t.Fatalf("Unexpected failed results: %+v", failed)
+		}
+		if err == nil || !strings.HasPrefix(err.Error(), "http://"+up+": ") {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	})
+}
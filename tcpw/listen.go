@@ -0,0 +1,46 @@
+package tcpw
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/netip"
+	"slices"
+)
+
+// CheckListening reports an error unless a local TCP socket is listening on 'host:port',
+// on the address of the host or on any address, without connecting to it.
+// An empty host matches any address.
+func CheckListening(ctx context.Context, hostport string) error {
+	host, port, err := net.SplitHostPort(hostport)
+	if err != nil {
+		return err
+	}
+	portNum, err := net.LookupPort("tcp", port)
+	if err != nil {
+		return err
+	}
+	var ips []netip.Addr
+	if host != "" {
+		addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+		if err != nil {
+			return err
+		}
+		for _, addr := range addrs {
+			ips = append(ips, addr.Unmap())
+		}
+	}
+	sockets, err := ListeningSockets()
+	if err != nil {
+		return err
+	}
+	for _, s := range sockets {
+		if int(s.Port()) != portNum {
+			continue
+		}
+		if ip := s.Addr().Unmap(); host == "" || ip.IsUnspecified() || slices.Contains(ips, ip) {
+			return nil
+		}
+	}
+	return fmt.Errorf("no socket is listening on %s", hostport)
+}
@@ -0,0 +1,73 @@
+package tcpw
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/fs"
+	"net/netip"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// tcpListen is the state of listening sockets in /proc/net/tcp.
+const tcpListen = "0A"
+
+// ListeningSockets returns local addresses of listening TCP sockets of /proc/net/tcp and /proc/net/tcp6.
+func ListeningSockets() ([]netip.AddrPort, error) {
+	var sockets []netip.AddrPort
+	for _, path := range []string{"/proc/net/tcp", "/proc/net/tcp6"} {
+		f, err := os.Open(path)
+		if errors.Is(err, fs.ErrNotExist) && path == "/proc/net/tcp6" {
+			continue // IPv6 is disabled
+		} else if err != nil {
+			return nil, err
+		}
+		s, err := parseProcNetTCP(f)
+		f.Close()
+		if err != nil {
+			return nil, err
+		}
+		sockets = append(sockets, s...)
+	}
+	return sockets, nil
+}
+
+func parseProcNetTCP(r io.Reader) ([]netip.AddrPort, error) {
+	var sockets []netip.AddrPort
+	scanner := bufio.NewScanner(r)
+	scanner.Scan() // header
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 4 || fields[3] != tcpListen {
+			continue
+		}
+		addr, err := parseProcNetAddr(fields[1])
+		if err != nil {
+			return nil, err
+		}
+		sockets = append(sockets, addr)
+	}
+	return sockets, scanner.Err()
+}
+
+// parseProcNetAddr parses an address like '0100007F:1F90', where the IP is in 32 bit words of host byte order.
+func parseProcNetAddr(s string) (netip.AddrPort, error) {
+	hexIP, hexPort, _ := strings.Cut(s, ":")
+	b, err := hex.DecodeString(hexIP)
+	if err != nil || (len(b) != 4 && len(b) != 16) {
+		return netip.AddrPort{}, errors.New("invalid address: " + s)
+	}
+	for i := 0; i < len(b); i += 4 {
+		binary.BigEndian.PutUint32(b[i:], binary.NativeEndian.Uint32(b[i:]))
+	}
+	port, err := strconv.ParseUint(hexPort, 16, 16)
+	if err != nil {
+		return netip.AddrPort{}, errors.New("invalid address: " + s)
+	}
+	ip, _ := netip.AddrFromSlice(b)
+	return netip.AddrPortFrom(ip, uint16(port)), nil
+}
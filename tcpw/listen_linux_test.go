@@ -0,0 +1,41 @@
+package tcpw
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestParseProcNetTCP(t *testing.T) {
+	content := `  sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode
+   0: 0100007F:1F90 00000000:0000 0A 00000000:00000000 00:00000000 00000000     0        0 1 1 0000000000000000 100 0 0 10 0
+   1: 0100007F:8C2A 0100007F:1F90 01 00000000:00000000 00:00000000 00000000     0        0 2 1 0000000000000000 20 4 30 10 -1
+   2: 00000000000000000000000001000000:0016 00000000000000000000000000000000:0000 0A 00000000:00000000 00:00000000 00000000     0        0 3 1 0000000000000000 100 0 0 10 0
+`
+	sockets, err := parseProcNetTCP(strings.NewReader(content))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(sockets) != 2 || sockets[0].String() != "127.0.0.1:8080" || sockets[1].String() != "[::1]:22" {
+		t.Fatalf("Wrong sockets: %v", sockets)
+	}
+}
+
+func TestCheckListening(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	_, port, _ := net.SplitHostPort(addr)
+	for _, hostport := range []string{addr, "localhost:" + port, ":" + port} {
+		if err = CheckListening(context.Background(), hostport); err != nil {
+			t.Fatalf("Unexpected error of %s: %v", hostport, err)
+		}
+	}
+	l.Close()
+	if err = CheckListening(context.Background(), addr); err == nil || err.Error() != "no socket is listening on "+addr {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+}
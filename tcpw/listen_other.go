@@ -0,0 +1,12 @@
+//go:build !linux && !windows
+
+package tcpw
+
+import (
+	"errors"
+	"net/netip"
+)
+
+func ListeningSockets() ([]netip.AddrPort, error) {
+	return nil, errors.New("listening sockets can only be checked on Linux and Windows")
+}
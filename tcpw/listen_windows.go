@@ -0,0 +1,60 @@
+package tcpw
+
+import (
+	"encoding/binary"
+	"net/netip"
+	"syscall"
+	"unsafe"
+)
+
+const (
+	tcpTableOwnerPidListener = 3
+	errInsufficientBuffer    = 122
+)
+
+var procGetExtendedTcpTable = syscall.NewLazyDLL("iphlpapi.dll").NewProc("GetExtendedTcpTable")
+
+// ListeningSockets returns local addresses of listening TCP sockets of GetExtendedTcpTable.
+func ListeningSockets() ([]netip.AddrPort, error) {
+	var sockets []netip.AddrPort
+	for _, family := range []uint32{syscall.AF_INET, syscall.AF_INET6} {
+		table, err := extendedTCPTable(family)
+		if err != nil {
+			return nil, err
+		}
+		n := int(binary.LittleEndian.Uint32(table))
+		// MIB_TCPROW_OWNER_PID and MIB_TCP6ROW_OWNER_PID
+		rowSize, addrSize, portOffset := 24, 4, 4
+		if family == syscall.AF_INET6 {
+			rowSize, addrSize, portOffset = 56, 16, 20
+		}
+		for i := 0; i < n; i++ {
+			row := table[4+i*rowSize:]
+			if family == syscall.AF_INET {
+				row = row[4:] // dwState is first
+			}
+			ip, _ := netip.AddrFromSlice(row[:addrSize])
+			// the port is in network byte order in the low 16 bits
+			port := binary.BigEndian.Uint16(row[portOffset : portOffset+2])
+			sockets = append(sockets, netip.AddrPortFrom(ip, port))
+		}
+	}
+	return sockets, nil
+}
+
+func extendedTCPTable(family uint32) ([]byte, error) {
+	size := uint32(4096)
+	for {
+		table := make([]byte, size)
+		r, _, _ := procGetExtendedTcpTable.Call(uintptr(unsafe.Pointer(&table[0])), uintptr(unsafe.Pointer(&size)), 0,
+			uintptr(family), tcpTableOwnerPidListener, 0)
+		switch r {
+		case 0:
+			return table, nil
+		case errInsufficientBuffer:
+			continue
+		default:
+			return nil, syscall.Errno(r)
+		}
+	}
+}
@@ -0,0 +1,27 @@
+package tcpw
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+)
+
+// Logger receives log lines of a Waiter, e.g. a *slog.Logger, which prints them with its handler.
+type Logger interface {
+	Log(ctx context.Context, level slog.Level, msg string, args ...any)
+}
+
+// log passes the line to the logger of the options, if any.
+func (opts Options) log(ctx context.Context, level slog.Level, msg string, args ...any) {
+	if opts.Logger != nil {
+		opts.Logger.Log(ctx, level, msg, args...)
+	}
+}
+
+// Plural returns the count with the noun, e.g. '1 attempt' or '12 attempts'.
+func Plural(n int, noun string) string {
+	if n == 1 {
+		return fmt.Sprintf("%d %s", n, noun)
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
@@ -0,0 +1,138 @@
+package tcpw
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Middleware wraps a Checker with a cross-cutting behavior, e.g. WithTimeout or WithRetry,
+// for all endpoints of a Waiter or some of them, see Options.
+type Middleware func(Checker) Checker
+
+// Chain wraps the checker with the middleware, the first one outermost.
+func Chain(c Checker, middleware ...Middleware) Checker {
+	for i := len(middleware) - 1; i >= 0; i-- {
+		c = middleware[i](c)
+	}
+	return c
+}
+
+// WithTimeout limits every check to the timeout, e.g. for slow HTTP endpoints among fast TCP ones.
+func WithTimeout(timeout time.Duration) Middleware {
+	return func(next Checker) Checker {
+		return CheckerFunc(func(ctx context.Context, e Endpoint) (Result, error) {
+			ctx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+			return next.Check(ctx, e)
+		})
+	}
+}
+
+// WithRetry checks again up to n times after the delay if a check fails with an error which is not fatal (see IsFatal),
+// so a single lost packet of a flaky endpoint does not fail the attempt.
+func WithRetry(n int, delay time.Duration) Middleware {
+	return func(next Checker) Checker {
+		return CheckerFunc(func(ctx context.Context, e Endpoint) (Result, error) {
+			for i := 0; ; i++ {
+				r, err := next.Check(ctx, e)
+				if err == nil || i == n || IsFatal(err) {
+					return r, err
+				}
+				if ctxErr := sleep(ctx, delay); ctxErr != nil {
+					return r, fmt.Errorf("%w: %w", ctxErr, err)
+				}
+			}
+		})
+	}
+}
+
+// WithRateLimit makes checks at most once every interval, delaying the rest, e.g. to spare a rate limited API.
+// Checkers wrapped by the same middleware share the limit, so it applies to the endpoints together.
+func WithRateLimit(every time.Duration) Middleware {
+	var mu sync.Mutex
+	var next time.Time // of the next check allowed
+	return func(c Checker) Checker {
+		return CheckerFunc(func(ctx context.Context, e Endpoint) (Result, error) {
+			mu.Lock()
+			at := time.Now()
+			if next.After(at) {
+				at = next
+			}
+			next = at.Add(every)
+			mu.Unlock()
+			if err := sleep(ctx, time.Until(at)); err != nil {
+				return Result{}, err
+			}
+			return c.Check(ctx, e)
+		})
+	}
+}
+
+// WithLogging passes the outcome of every check to the logger at debug level, e.g. of some endpoints only.
+func WithLogging(logger Logger) Middleware {
+	return func(next Checker) Checker {
+		return CheckerFunc(func(ctx context.Context, e Endpoint) (Result, error) {
+			start := time.Now()
+			r, err := next.Check(ctx, e)
+			latency := latencyOf(r, start)
+			if err != nil {
+				logger.Log(ctx, slog.LevelDebug, fmt.Sprintf("check of %s failed in %v", e.Addr, latency.Round(time.Microsecond)),
+					"endpoint", e.Addr, "latency", latency, "error", err)
+			} else {
+				logger.Log(ctx, slog.LevelDebug, fmt.Sprintf("check of %s succeeded in %v", e.Addr, latency.Round(time.Microsecond)),
+					"endpoint", e.Addr, "latency", latency)
+			}
+			return r, err
+		})
+	}
+}
+
+// Metrics counts checks made through WithMetrics. It is safe for concurrent use.
+type Metrics struct {
+	Checks   atomic.Int64
+	Failures atomic.Int64
+	Latency  atomic.Int64 // total of all checks, in nanoseconds
+}
+
+// WithMetrics counts checks and their failures and latency in m, e.g. to export them.
+func WithMetrics(m *Metrics) Middleware {
+	return func(next Checker) Checker {
+		return CheckerFunc(func(ctx context.Context, e Endpoint) (Result, error) {
+			start := time.Now()
+			r, err := next.Check(ctx, e)
+			m.Checks.Add(1)
+			if err != nil {
+				m.Failures.Add(1)
+			}
+			m.Latency.Add(int64(latencyOf(r, start)))
+			return r, err
+		})
+	}
+}
+
+// latencyOf returns the latency of the check measured by the checker, or since start.
+func latencyOf(r Result, start time.Time) time.Duration {
+	if r.Latency > 0 {
+		return r.Latency
+	}
+	return time.Since(start)
+}
+
+// sleep waits for the duration, or returns the error of ctx once it is done.
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,118 @@
+package tcpw
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+// countingChecker fails until it is called n times.
+func countingChecker(n int, calls *int) Checker {
+	return CheckerFunc(func(ctx context.Context, e Endpoint) (Result, error) {
+		*calls++
+		if *calls < n {
+			return Result{}, errors.New("not yet")
+		}
+		return Result{Latency: time.Millisecond}, ctx.Err()
+	})
+}
+
+type logLines []string
+
+func (l *logLines) Log(ctx context.Context, level slog.Level, msg string, args ...any) {
+	*l = append(*l, msg)
+}
+
+func TestMiddleware(t *testing.T) {
+	e := NewEndpoint("fake://db", nil)
+	ctx := context.Background()
+
+	t.Run("Test chain", func(t *testing.T) {
+		var order []string
+		mark := func(name string) Middleware {
+			return func(next Checker) Checker {
+				return CheckerFunc(func(ctx context.Context, e Endpoint) (Result, error) {
+					order = append(order, name)
+					return next.Check(ctx, e)
+				})
+			}
+		}
+		var calls int
+		c := Chain(countingChecker(1, &calls), mark("outer"), mark("inner"))
+		if _, err := c.Check(ctx, e); err != nil || strings.Join(order, ",") != "outer,inner" {
+			t.Fatalf("Unexpected order: %v, %v", order, err)
+		}
+	})
+
+	t.Run("Test retry", func(t *testing.T) {
+		var calls int
+		if _, err := WithRetry(2, time.Millisecond)(countingChecker(3, &calls)).Check(ctx, e); err != nil || calls != 3 {
+			t.Fatalf("Unexpected result: %d calls, %v", calls, err)
+		}
+		calls = 0
+		if _, err := WithRetry(1, time.Millisecond)(countingChecker(3, &calls)).Check(ctx, e); err == nil || calls != 2 {
+			t.Fatalf("Unexpected result: %d calls, %v", calls, err)
+		}
+	})
+
+	t.Run("Test timeout", func(t *testing.T) {
+		var calls int
+		if _, err := WithTimeout(time.Nanosecond)(countingChecker(1, &calls)).Check(ctx, e); !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	})
+
+	t.Run("Test rate limit", func(t *testing.T) {
+		var calls int
+		limit := WithRateLimit(20 * time.Millisecond)
+		a, b := limit(countingChecker(1, &calls)), limit(countingChecker(1, &calls))
+		start := time.Now()
+		for _, c := range []Checker{a, b, a} {
+			if _, err := c.Check(ctx, e); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if elapsed := time.Since(start); elapsed < 40*time.Millisecond {
+			t.Fatalf("Checks are not limited: %v", elapsed)
+		}
+	})
+
+	t.Run("Test logging and metrics", func(t *testing.T) {
+		var calls int
+		var lines logLines
+		var m Metrics
+		c := Chain(countingChecker(2, &calls), WithLogging(&lines), WithMetrics(&m))
+		_, _ = c.Check(ctx, e)
+		_, _ = c.Check(ctx, e)
+		if len(lines) != 2 || !strings.HasPrefix(lines[0], "check of fake://db failed in ") || lines[1] != "check of fake://db succeeded in 1ms" {
+			t.Fatalf("Unexpected log lines: %q", lines)
+		}
+		if m.Checks.Load() != 2 || m.Failures.Load() != 1 || m.Latency.Load() < int64(time.Millisecond) {
+			t.Fatalf("Unexpected metrics: %d checks, %d failures, %d latency", m.Checks.Load(), m.Failures.Load(), m.Latency.Load())
+		}
+	})
+
+	t.Run("Test per endpoint", func(t *testing.T) {
+		addr, other := listen(t).Addr().String(), listen(t).Addr().String()
+		var all, db Metrics
+		w, err := NewWaiter(Options{
+			Endpoints:          []string{"tcp://" + addr, other},
+			Timeout:            time.Second,
+			Middleware:         []Middleware{WithMetrics(&all)},
+			EndpointMiddleware: map[string][]Middleware{"tcp://" + addr: {WithMetrics(&db)}},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Wait(ctx); err != nil || all.Checks.Load() != 2 || db.Checks.Load() != 1 {
+			t.Fatalf("Unexpected checks: %d of all, %d of db, %v", all.Checks.Load(), db.Checks.Load(), err)
+		}
+		_, err = NewWaiter(Options{Endpoints: []string{addr}, EndpointMiddleware: map[string][]Middleware{"db:5432": nil}})
+		if err == nil || err.Error() != "middleware of db:5432, which is not in endpoints" {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	})
+}
@@ -0,0 +1,11 @@
+//go:build minimal
+
+package tcpw
+
+import (
+	"context"
+	"net"
+)
+
+// traceConn does nothing in builds with 'minimal' tag, which leave out net/http/httptrace along with TLS it imports.
+func traceConn(context.Context, net.Conn) {}
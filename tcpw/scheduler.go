@@ -0,0 +1,146 @@
+package tcpw
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultConcurrency is the number of concurrent connection attempts of the wait,
+// unless Options.Concurrency or '-concurrency' of the command is given.
+const DefaultConcurrency = 512
+
+// wheelSlots is the number of ticks of the shortest interval in TimerWheel, so steps are late by 1/wheelSlots of it at most.
+const wheelSlots = 64
+
+// maxWheelSlots limits slots of TimerWheel of intervals far apart, which makes steps of the shortest one later.
+const maxWheelSlots = 1 << 16
+
+// TimerWheel keeps tasks until they are due, in slots of ticks of the longest interval after which they are due at most.
+// Adding and expiring tasks take constant time, however many of them there are.
+type TimerWheel struct {
+	mu     sync.Mutex
+	origin time.Time
+	tick   time.Duration
+	slots  [][]wheelTask
+	next   int64 // the tick of the slot to expire next
+}
+
+type wheelTask struct {
+	task int
+	tick int64 // when the task is due
+}
+
+func NewTimerWheel(shortest, longest time.Duration) *TimerWheel {
+	tick := max(shortest/wheelSlots, longest/maxWheelSlots, time.Millisecond)
+	return &TimerWheel{origin: time.Now(), tick: tick, slots: make([][]wheelTask, longest/tick+2)}
+}
+
+// ticks returns the number of ticks since the origin of the wheel till the time, rounded up.
+func (w *TimerWheel) ticks(t time.Time) int64 {
+	d := t.Sub(w.origin)
+	return int64((d + w.tick - 1) / w.tick)
+}
+
+// Add adds the task due at the time, which is the longest interval of the wheel later than now at most.
+func (w *TimerWheel) Add(task int, due time.Time) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	tick := max(w.ticks(due), w.next)
+	slot := tick % int64(len(w.slots))
+	w.slots[slot] = append(w.slots[slot], wheelTask{task, tick})
+}
+
+// Expire removes tasks due by now and appends them to tasks.
+func (w *TimerWheel) Expire(now time.Time, tasks []int) []int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	last := int64(now.Sub(w.origin) / w.tick)
+	// every slot is visited once, however long ago it was expired last time
+	for tick := max(w.next, last-int64(len(w.slots))+1); tick <= last; tick++ {
+		slot := w.slots[tick%int64(len(w.slots))]
+		kept := slot[:0]
+		for _, t := range slot {
+			if t.tick <= last {
+				tasks = append(tasks, t.task)
+			} else {
+				kept = append(kept, t)
+			}
+		}
+		clear(slot[len(kept):])
+		w.slots[tick%int64(len(w.slots))] = kept
+	}
+	w.next = max(w.next, last+1)
+	return tasks
+}
+
+// Schedule runs steps of tasks with at most the number of workers concurrently, until they are over or ctx is done.
+// The first steps run right away in order of tasks, each next one the interval of the task after the previous one started,
+// if it returned true. Meanwhile tasks wait in a timer wheel, so thousands of them take neither a goroutine nor a timer each.
+// Steps which started before ctx is done are waited for.
+func Schedule(ctx context.Context, tasks []int, workers int, interval func(task int) time.Duration, step func(task int) bool) {
+	if len(tasks) == 0 {
+		return
+	}
+	intervals := make(map[int]time.Duration, len(tasks))
+	shortest, longest := interval(tasks[0]), time.Duration(0)
+	for _, task := range tasks {
+		intervals[task] = interval(task)
+		shortest, longest = min(shortest, intervals[task]), max(longest, intervals[task])
+	}
+	wheel := NewTimerWheel(shortest, longest)
+	due := make(chan int)
+	var remaining atomic.Int64
+	remaining.Add(int64(len(tasks)))
+	over := make(chan struct{})
+	var wg sync.WaitGroup
+	for range min(workers, len(tasks)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for task := range due {
+				start := time.Now()
+				if !step(task) {
+					if remaining.Add(-1) == 0 {
+						close(over)
+					}
+				} else if ctx.Err() == nil {
+					wheel.Add(task, start.Add(intervals[task]))
+				}
+			}
+		}()
+	}
+	defer func() {
+		close(due)
+		wg.Wait()
+	}()
+	send := func(tasks []int) bool {
+		for _, task := range tasks {
+			select {
+			case due <- task:
+			case <-ctx.Done():
+				return false
+			}
+		}
+		return true
+	}
+	if !send(tasks) {
+		return
+	}
+	ticker := time.NewTicker(wheel.tick)
+	defer ticker.Stop()
+	var expired []int
+	for {
+		select {
+		case now := <-ticker.C:
+			if expired = wheel.Expire(now, expired[:0]); !send(expired) {
+				return
+			}
+		case <-over:
+			return
+		case <-ctx.Done():
+			return
+		}
+	}
+}
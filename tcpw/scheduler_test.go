@@ -0,0 +1,114 @@
+package tcpw
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestTimerWheel(t *testing.T) {
+	w := NewTimerWheel(time.Second, time.Second)
+	start := w.origin
+	w.Add(1, start.Add(500*time.Millisecond))
+	w.Add(2, start.Add(100*time.Millisecond))
+	w.Add(3, start.Add(time.Second))
+	w.Add(4, start.Add(-time.Second)) // already due
+	for _, tt := range []struct {
+		at   time.Duration
+		want []int
+	}{
+		{0, []int{4}},
+		{50 * time.Millisecond, nil},
+		{120 * time.Millisecond, []int{2}},
+		{time.Second, []int{1, 3}},
+	} {
+		if got := w.Expire(start.Add(tt.at), nil); !slices.Equal(got, tt.want) {
+			t.Fatalf("Unexpected tasks after %v: %v", tt.at, got)
+		}
+	}
+	// tasks are due later than expired slots, however long ago they were expired
+	now := start.Add(time.Minute)
+	w.Add(5, now.Add(100*time.Millisecond))
+	if got := w.Expire(now.Add(10*time.Second), nil); !slices.Equal(got, []int{5}) {
+		t.Fatalf("Unexpected tasks: %v", got)
+	}
+}
+
+func TestSchedule(t *testing.T) {
+	t.Run("Test workers", func(t *testing.T) {
+		const n, workers, steps = 100, 4, 3
+		interval := 20 * time.Millisecond
+		var mu sync.Mutex
+		started := make([][]time.Time, n)
+		var running, maxRunning atomic.Int32
+		tasks := make([]int, n)
+		for i := range tasks {
+			tasks[i] = i
+		}
+		Schedule(context.Background(), tasks, workers, func(int) time.Duration { return interval }, func(task int) bool {
+			if r := running.Add(1); r > maxRunning.Load() {
+				maxRunning.Store(r)
+			}
+			defer running.Add(-1)
+			time.Sleep(time.Millisecond)
+			mu.Lock()
+			defer mu.Unlock()
+			started[task] = append(started[task], time.Now())
+			return len(started[task]) < steps
+		})
+		if maxRunning.Load() > workers {
+			t.Fatalf("Too many concurrent steps: %d", maxRunning.Load())
+		}
+		for task, times := range started {
+			if len(times) != steps {
+				t.Fatalf("Unexpected steps of task %d: %d", task, len(times))
+			}
+			for i := 1; i < len(times); i++ {
+				if d := times[i].Sub(times[i-1]); d < interval-time.Millisecond {
+					t.Fatalf("Steps of task %d are too close: %v", task, d)
+				}
+			}
+		}
+	})
+
+	t.Run("Test done context", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+		var steps atomic.Int32
+		start := time.Now()
+		Schedule(ctx, []int{0, 1}, 1, func(int) time.Duration { return 10 * time.Millisecond }, func(int) bool {
+			steps.Add(1)
+			return true
+		})
+		if elapsed := time.Since(start); elapsed > time.Second || steps.Load() < 4 {
+			t.Fatalf("Unexpected schedule: %d steps in %v", steps.Load(), elapsed)
+		}
+	})
+
+	t.Run("Test intervals of tasks", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+		defer cancel()
+		intervals := []time.Duration{20 * time.Millisecond, 100 * time.Millisecond}
+		var mu sync.Mutex
+		started := make([][]time.Time, len(intervals))
+		Schedule(ctx, []int{0, 1}, 2, func(task int) time.Duration { return intervals[task] }, func(task int) bool {
+			mu.Lock()
+			defer mu.Unlock()
+			started[task] = append(started[task], time.Now())
+			return true
+		})
+		for task, times := range started {
+			for i := 1; i < len(times); i++ {
+				if d := times[i].Sub(times[i-1]); d < intervals[task]-time.Millisecond {
+					t.Fatalf("Steps of task %d are too close: %v", task, d)
+				}
+			}
+		}
+		if len(started[0]) < 2*len(started[1]) {
+			t.Fatalf("Unexpected steps: %d, %d", len(started[0]), len(started[1]))
+		}
+	})
+}
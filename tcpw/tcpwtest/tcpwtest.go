@@ -0,0 +1,66 @@
+// Package tcpwtest provides helpers for tests waiting for endpoints, e.g. of servers started by integration tests.
+package tcpwtest
+
+import (
+	"github.com/jackcvr/tcpw/tcpw"
+	"testing"
+	"time"
+)
+
+// Defaults of WaitFor: tests wait long enough for a local server to start, but not for a broken one.
+const (
+	DefaultTimeout  = 10 * time.Second
+	DefaultInterval = 100 * time.Millisecond
+)
+
+// Option changes the options of the wait of WaitFor.
+type Option func(*tcpw.Options)
+
+// Timeout of the whole wait, DefaultTimeout by default.
+func Timeout(d time.Duration) Option {
+	return func(o *tcpw.Options) {
+		o.Timeout = d
+	}
+}
+
+// Interval between connection attempts, DefaultInterval by default.
+func Interval(d time.Duration) Option {
+	return func(o *tcpw.Options) {
+		o.Interval = d
+	}
+}
+
+// Dialer connects to the endpoint instead of a net.Dialer.
+func Dialer(d tcpw.ContextDialer) Option {
+	return func(o *tcpw.Options) {
+		o.Dialer = d
+	}
+}
+
+// WaitFor blocks the test until the endpoint, in any form of tcpw.ParseEndpoint, is available,
+// and fails the test with t.Fatal if it is not in time or is invalid. Attempts are logged with t.Log.
+func WaitFor(t testing.TB, endpoint string, opts ...Option) {
+	t.Helper()
+	o := tcpw.Options{
+		Endpoints: []string{endpoint},
+		Timeout:   DefaultTimeout,
+		Interval:  DefaultInterval,
+		Hooks: tcpw.Hooks{
+			OnAttempt: func(a tcpw.Attempt) {
+				if a.Err != nil {
+					t.Logf("%s: attempt %d: %v", a.Endpoint, a.Number, a.Err)
+				}
+			},
+		},
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	w, err := tcpw.NewWaiter(o)
+	if err != nil {
+		t.Fatalf("tcpwtest: %v", err)
+	}
+	if _, err = w.Wait(t.Context()); err != nil {
+		t.Fatalf("tcpwtest: %v", err)
+	}
+}
@@ -0,0 +1,67 @@
+package tcpwtest
+
+import (
+	"fmt"
+	"net"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeT records the failure of WaitFor instead of failing the test.
+type fakeT struct {
+	*testing.T
+	failure string
+}
+
+func (t *fakeT) Fatalf(format string, args ...any) {
+	t.failure = fmt.Sprintf(format, args...)
+	runtime.Goexit()
+}
+
+// run calls WaitFor on its own goroutine, as t.Fatalf exits it.
+func run(t *testing.T, endpoint string, opts ...Option) string {
+	ft := &fakeT{T: t}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		WaitFor(ft, endpoint, opts...)
+	}()
+	<-done
+	return ft.failure
+}
+
+func TestWaitFor(t *testing.T) {
+	t.Run("Test endpoint available later", func(t *testing.T) {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		addr := l.Addr().String()
+		l.Close()
+		go func() {
+			time.Sleep(200 * time.Millisecond)
+			if l, err := net.Listen("tcp", addr); err == nil {
+				t.Cleanup(func() { l.Close() })
+			}
+		}()
+		start := time.Now()
+		if failure := run(t, addr, Interval(50*time.Millisecond)); failure != "" || time.Since(start) < 200*time.Millisecond {
+			t.Fatalf("Unexpected failure: %s", failure)
+		}
+	})
+
+	t.Run("Test timeout", func(t *testing.T) {
+		failure := run(t, "127.0.0.1:1", Timeout(200*time.Millisecond), Interval(50*time.Millisecond))
+		if !strings.HasPrefix(failure, "tcpwtest: 127.0.0.1:1: ") || !strings.Contains(failure, "deadline exceeded") {
+			t.Fatalf("Unexpected failure: %s", failure)
+		}
+	})
+
+	t.Run("Test invalid endpoint", func(t *testing.T) {
+		if failure := run(t, "ftp://localhost:21"); !strings.Contains(failure, "unsupported scheme") {
+			t.Fatalf("Unexpected failure: %s", failure)
+		}
+	})
+}
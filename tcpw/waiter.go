@@ -0,0 +1,278 @@
+// Package tcpw waits until TCP, UDP, TLS, HTTP(S) endpoints, unix sockets and paths are available,
+// the way the tcpw command does, for Go programs and tests which embed the wait instead of running the command.
+// Other protocols are checked by Checkers added with Register.
+package tcpw
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"slices"
+	"time"
+)
+
+// Options of a Waiter.
+type Options struct {
+	Endpoints   []string      // see ParseEndpoint
+	Timeout     time.Duration // of the whole wait, none if zero
+	Interval    time.Duration // between connection attempts to an endpoint, one second if zero
+	DialTimeout time.Duration // of a single connection attempt, Timeout if zero, like '-w' of the command
+	Concurrency int           // maximal number of concurrent connection attempts, DefaultConcurrency if zero
+	// Dialer connects to endpoints, e.g. through a tunnel or to a test fake, a net.Dialer if nil.
+	Dialer ContextDialer
+	// Transports are dialers of endpoints of the schemes, e.g. 'https', instead of Dialer.
+	Transports map[string]ContextDialer
+	// Logger prints results of connection attempts, like the command does, nothing if nil.
+	Logger Logger
+	// Middleware wraps checkers of all endpoints, the first one outermost, e.g. WithTimeout.
+	Middleware []Middleware
+	// EndpointMiddleware wraps checkers of some endpoints, by their values in Endpoints, inside Middleware.
+	EndpointMiddleware map[string][]Middleware
+	Hooks
+}
+
+// ContextDialer connects to the address on the named network, like net.Dialer.
+type ContextDialer interface {
+	DialContext(ctx context.Context, network, addr string) (net.Conn, error)
+}
+
+// Result is the outcome of the wait for an endpoint.
+type Result struct {
+	Endpoint string        // in the canonical form of ParseEndpoint
+	Err      error         // why the endpoint is not available, nil if it is
+	Attempts int           // connection attempts made
+	Latency  time.Duration // of the successful attempt
+	Elapsed  time.Duration // until the endpoint was available or the wait was over
+}
+
+// Results of the wait, in the order of the endpoints.
+type Results []Result
+
+// Failed returns results of endpoints which are not available.
+func (rs Results) Failed() Results {
+	var failed Results
+	for _, r := range rs {
+		if r.Err != nil {
+			failed = append(failed, r)
+		}
+	}
+	return failed
+}
+
+// Err returns errors of endpoints which are not available, or nil if all of them are.
+func (rs Results) Err() error {
+	var errs []error
+	for _, r := range rs.Failed() {
+		errs = append(errs, fmt.Errorf("%s: %w", r.Endpoint, r.Err))
+	}
+	return errors.Join(errs...)
+}
+
+// Waiter waits until all endpoints are available.
+type Waiter struct {
+	opts Options
+}
+
+// NewWaiter validates the options and returns a Waiter of them.
+func NewWaiter(opts Options) (*Waiter, error) {
+	if len(opts.Endpoints) == 0 {
+		return nil, errors.New("no endpoints to wait for")
+	}
+	if opts.Timeout < 0 || opts.Interval < 0 || opts.DialTimeout < 0 {
+		return nil, errors.New("durations must not be negative")
+	}
+	if opts.Concurrency < 0 {
+		return nil, errors.New("concurrency must not be negative")
+	}
+	if opts.Interval == 0 {
+		opts.Interval = time.Second
+	}
+	if opts.DialTimeout == 0 {
+		opts.DialTimeout = opts.Timeout
+	}
+	if opts.Concurrency == 0 {
+		opts.Concurrency = DefaultConcurrency
+	}
+	endpoints := make([]string, len(opts.Endpoints))
+	middleware := map[string][]Middleware{}
+	for i, value := range opts.Endpoints {
+		addr, err := ParseEndpoint(value)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", value, err)
+		}
+		endpoints[i] = addr
+		if m, ok := opts.EndpointMiddleware[value]; ok {
+			middleware[addr] = m
+		}
+	}
+	for value := range opts.EndpointMiddleware {
+		if !slices.Contains(opts.Endpoints, value) {
+			return nil, fmt.Errorf("middleware of %s, which is not in endpoints", value)
+		}
+	}
+	opts.Endpoints = endpoints
+	opts.EndpointMiddleware = middleware
+	return &Waiter{opts: opts}, nil
+}
+
+// Endpoints returns the endpoints to wait for, in the canonical form of ParseEndpoint.
+func (w *Waiter) Endpoints() []string {
+	return slices.Clone(w.opts.Endpoints)
+}
+
+// Wait connects to all endpoints every interval, at most Concurrency of them at once, until each of them is available,
+// the timeout passes or ctx is done. An attempt failed with a fatal error (see IsFatal) ends the wait for all of them.
+// The attempts are scheduled the way the command makes them, see Schedule. The error is that of Results.Err.
+func (w *Waiter) Wait(ctx context.Context) (Results, error) {
+	if w.opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, w.opts.Timeout)
+		defer cancel()
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	results := make(Results, len(w.opts.Endpoints))
+	awaiters := make([]*awaiter, len(w.opts.Endpoints))
+	tasks := make([]int, len(w.opts.Endpoints))
+	for i, addr := range w.opts.Endpoints {
+		awaiters[i] = w.newAwaiter(ctx, addr)
+		tasks[i] = i
+	}
+	over := make([]bool, len(tasks))
+	interval := func(int) time.Duration { return w.opts.Interval }
+	Schedule(ctx, tasks, w.opts.Concurrency, interval, func(i int) bool {
+		if !awaiters[i].step(ctx) {
+			return true
+		}
+		results[i] = awaiters[i].result
+		over[i] = true
+		if results[i].Err != nil {
+			cancel()
+		}
+		return false
+	})
+	// the wait for the rest of endpoints is over as well
+	for i, a := range awaiters {
+		if !over[i] {
+			results[i] = a.expire(ctx)
+		}
+	}
+	err := results.Err()
+	w.opts.finish(results, err)
+	return results, err
+}
+
+// dialer returns the function dialing addresses of endpoints of the scheme, limited by DialTimeout.
+func (w *Waiter) dialer(scheme string) DialFunc {
+	d, ok := w.opts.Transports[scheme]
+	if !ok {
+		d = w.opts.Dialer
+	}
+	if d == nil {
+		return (&net.Dialer{Timeout: w.opts.DialTimeout}).DialContext
+	}
+	if w.opts.DialTimeout == 0 {
+		return d.DialContext
+	}
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		ctx, cancel := context.WithTimeout(ctx, w.opts.DialTimeout)
+		defer cancel()
+		return d.DialContext(ctx, network, addr)
+	}
+}
+
+// awaiter is the wait for an endpoint, which makes a connection attempt per step.
+type awaiter struct {
+	opts    Options
+	e       Endpoint
+	checker Checker
+	start   time.Time
+	failure LastFailure
+	result  Result
+}
+
+func (w *Waiter) newAwaiter(ctx context.Context, addr string) *awaiter {
+	e := NewEndpoint(addr, w.dialer(Scheme(addr)))
+	a := &awaiter{
+		opts:    w.opts,
+		e:       e,
+		checker: Chain(CheckerFunc(Check), append(slices.Clone(w.opts.Middleware), w.opts.EndpointMiddleware[addr]...)...),
+		start:   time.Now(),
+		result:  Result{Endpoint: addr},
+	}
+	w.opts.state(addr, StateWaiting, nil)
+	w.opts.log(ctx, slog.LevelDebug, fmt.Sprintf("connecting to %s...", addr), "endpoint", addr)
+	return a
+}
+
+// step makes the next connection attempt and reports whether the wait for the endpoint is over:
+// it is available or failed with a fatal error.
+func (a *awaiter) step(ctx context.Context) bool {
+	addr, result := a.e.Addr, &a.result
+	result.Attempts++
+	attemptStart := time.Now()
+	attempt, err := a.checker.Check(ctx, a.e)
+	result.Elapsed = time.Since(a.start)
+	latency := attempt.Latency
+	if latency == 0 {
+		latency = time.Since(attemptStart)
+	}
+	a.opts.attempt(Attempt{Endpoint: addr, Number: result.Attempts, Latency: latency, Err: err})
+	attrs := []any{"endpoint", addr, "attempt", result.Attempts, "latency", latency}
+	if err == nil {
+		a.opts.log(ctx, slog.LevelInfo, fmt.Sprintf("successfully connected to %s in %v after %s",
+			addr, latency.Round(time.Microsecond), Plural(result.Attempts, "attempt")), attrs...)
+		result.Latency = latency
+		a.opts.state(addr, StateUp, nil)
+		a.opts.ready(*result)
+		return true
+	}
+	a.opts.log(ctx, slog.LevelDebug, fmt.Sprintf("attempt %d to connect to %s failed in %v",
+		result.Attempts, addr, latency.Round(time.Microsecond)), append(attrs, "error", err)...)
+	if IsFatal(err) {
+		result.Err = err
+		a.opts.state(addr, StateFailed, err)
+		return true
+	}
+	a.failure.Add(ctx, err)
+	return false
+}
+
+// expire ends the wait for the endpoint, which is not over, once ctx is done.
+func (a *awaiter) expire(ctx context.Context) Result {
+	a.result.Err = a.failure.Timeout(ctx)
+	a.result.Elapsed = time.Since(a.start)
+	a.opts.state(a.e.Addr, StateTimeout, a.result.Err)
+	return a.result
+}
+
+// LastFailure keeps the reason of the last failed connection attempt to an endpoint.
+type LastFailure struct {
+	err error
+}
+
+// Add keeps the error of a failed attempt. An attempt cut short by ctx fails for that reason,
+// not that of the endpoint, so it is kept only if no earlier attempt failed.
+func (f *LastFailure) Add(ctx context.Context, err error) {
+	if f.err == nil || !Expired(ctx) {
+		f.err = err
+	}
+}
+
+// Timeout returns the error of the wait which is over because ctx is done,
+// with the reason of the last failed attempt, if any, e.g. 'context deadline exceeded: connection refused'.
+func (f *LastFailure) Timeout(ctx context.Context) error {
+	if f.err == nil {
+		return ctx.Err()
+	}
+	return fmt.Errorf("%w: %w", ctx.Err(), f.err)
+}
+
+// Expired reports whether ctx is done or its deadline has passed,
+// which may be noticed by a dial before the context itself.
+func Expired(ctx context.Context) bool {
+	deadline, ok := ctx.Deadline()
+	return ctx.Err() != nil || (ok && !time.Now().Before(deadline))
+}
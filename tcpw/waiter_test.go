@@ -0,0 +1,165 @@
+package tcpw
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func listen(t *testing.T) net.Listener {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	return l
+}
+
+func freeAddr(t *testing.T) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	return l.Addr().String()
+}
+
+func TestNewWaiter(t *testing.T) {
+	for _, opts := range []Options{
+		{},
+		{Endpoints: []string{"ftp://localhost:21"}},
+		{Endpoints: []string{"127.0.0.1:80"}, Interval: -time.Second},
+		{Endpoints: []string{"127.0.0.1:80"}, Concurrency: -1},
+	} {
+		if _, err := NewWaiter(opts); err == nil {
+			t.Fatalf("Unexpected success of %+v", opts)
+		}
+	}
+	w, err := NewWaiter(Options{Endpoints: []string{"tcp://127.0.0.1:80", "udp://127.0.0.1:53"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w.opts.Endpoints[0] != "127.0.0.1:80" || w.opts.Interval != time.Second || w.opts.DialTimeout != 0 ||
+		w.opts.Concurrency != DefaultConcurrency {
+		t.Fatalf("Unexpected options: %+v", w.opts)
+	}
+}
+
+func TestWait(t *testing.T) {
+	t.Run("Test available endpoints", func(t *testing.T) {
+		up, down := listen(t).Addr().String(), freeAddr(t)
+		w, err := NewWaiter(Options{Endpoints: []string{up, down}, Timeout: 500 * time.Millisecond, Interval: 100 * time.Millisecond})
+		if err != nil {
+			t.Fatal(err)
+		}
+		results, err := w.Wait(context.Background())
+		if len(results) != 2 || results[0].Err != nil || results[0].Attempts != 1 || results[1].Err == nil {
+			t.Fatalf("Unexpected results: %+v", results)
+		}
+		if failed := results.Failed(); len(failed) != 1 || failed[0].Endpoint != down {
+			t.Fatalf("Unexpected failed results: %+v", failed)
+		}
+		if err == nil || !strings.HasPrefix(err.Error(), down+": ") {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	})
+
+	t.Run("Test endpoint available later", func(t *testing.T) {
+		addr := freeAddr(t)
+		go func() {
+			time.Sleep(250 * time.Millisecond)
+			l, err := net.Listen("tcp", addr)
+			if err == nil {
+				t.Cleanup(func() { l.Close() })
+			}
+		}()
+		w, err := NewWaiter(Options{Endpoints: []string{addr}, Timeout: 2 * time.Second, Interval: 100 * time.Millisecond})
+		if err != nil {
+			t.Fatal(err)
+		}
+		results, err := w.Wait(context.Background())
+		if err != nil || results[0].Attempts < 2 || results[0].Elapsed < 200*time.Millisecond {
+			t.Fatalf("Unexpected results: %+v, %v", results, err)
+		}
+	})
+
+	t.Run("Test timeout", func(t *testing.T) {
+		w, err := NewWaiter(Options{Endpoints: []string{freeAddr(t)}, Timeout: 300 * time.Millisecond, Interval: 100 * time.Millisecond})
+		if err != nil {
+			t.Fatal(err)
+		}
+		results, err := w.Wait(context.Background())
+		if !errors.Is(err, context.DeadlineExceeded) || !strings.Contains(err.Error(), "connection refused") || results[0].Attempts < 2 {
+			t.Fatalf("Unexpected results: %+v, %v", results, err)
+		}
+	})
+}
+
+func TestDialer(t *testing.T) {
+	var mu sync.Mutex
+	var dialed []string
+	fake := DialFunc(func(ctx context.Context, network, addr string) (net.Conn, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		dialed = append(dialed, network+" "+addr)
+		client, server := net.Pipe()
+		server.Close()
+		return client, nil
+	})
+	refused := DialFunc(func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if _, ok := ctx.Deadline(); !ok {
+			t.Error("No dial timeout")
+		}
+		return nil, errors.New("refused by transport")
+	})
+	w, err := NewWaiter(Options{
+		Endpoints:  []string{"10.3.2.1:5432", "unix:///run/app.sock", "udp://10.3.2.1:53"},
+		Timeout:    100 * time.Millisecond,
+		Dialer:     fake,
+		Transports: map[string]ContextDialer{"udp": refused},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	results, err := w.Wait(context.Background())
+	if err == nil || results[0].Err != nil || results[1].Err != nil || !strings.Contains(results[2].Err.Error(), "refused by transport") {
+		t.Fatalf("Unexpected results: %+v", results)
+	}
+	if !slices.Contains(dialed, "tcp 10.3.2.1:5432") || !slices.Contains(dialed, "unix /run/app.sock") {
+		t.Fatalf("Unexpected dials: %v", dialed)
+	}
+}
+
+func TestLogger(t *testing.T) {
+	var buf strings.Builder
+	addr := listen(t).Addr().String()
+	w, err := NewWaiter(Options{
+		Endpoints: []string{addr},
+		Logger:    slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = w.Wait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if out := buf.String(); !strings.Contains(out, "level=DEBUG msg=\"connecting to "+addr+"...\"") ||
+		!strings.Contains(out, "level=INFO msg=\"successfully connected to "+addr) || !strings.Contains(out, "attempt=1") {
+		t.Fatalf("Unexpected output: %s", out)
+	}
+}
+
+func TestPlural(t *testing.T) {
+	if got := Plural(1, "attempt"); got != "1 attempt" {
+		t.Fatalf("Unexpected: %s", got)
+	}
+	if got := Plural(12, "attempt"); got != "12 attempts" {
+		t.Fatalf("Unexpected: %s", got)
+	}
+}
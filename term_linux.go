@@ -0,0 +1,7 @@
+package main
+
+import "syscall"
+
+const ioctlGetTermios = syscall.TCGETS
+
+const ioctlSetTermios = syscall.TCSETS
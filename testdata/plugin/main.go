@@ -0,0 +1,19 @@
+// Package main is a Go plugin for tests of '-plugin', which checks 'echo://' endpoints.
+package main
+
+import (
+	"context"
+	"errors"
+	"github.com/jackcvr/tcpw/tcpw"
+)
+
+func init() {
+	tcpw.Register("echo", tcpw.CheckerFunc(func(ctx context.Context, e tcpw.Endpoint) (tcpw.Result, error) {
+		if e.Target != "up" {
+			return tcpw.Result{}, errors.New("echo: " + e.Target)
+		}
+		return tcpw.Result{}, nil
+	}))
+}
+
+func main() {}
@@ -0,0 +1,108 @@
+//go:build !minimal
+
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"log/slog"
+	"net/http/httptrace"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Trace returns ctx, which records the phases of the connection attempt made with it to p.
+func (p *Phases) Trace(ctx context.Context) context.Context {
+	var mu sync.Mutex
+	var dnsStart, connectStart, tlsStart time.Time
+	return httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) {
+			mu.Lock()
+			defer mu.Unlock()
+			dnsStart = time.Now()
+		},
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			mu.Lock()
+			defer mu.Unlock()
+			p.DNS = time.Since(dnsStart)
+		},
+		ConnectStart: func(string, string) {
+			mu.Lock()
+			defer mu.Unlock()
+			connectStart = time.Now()
+		},
+		ConnectDone: func(_, _ string, err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			if err == nil {
+				p.Connect = time.Since(connectStart)
+			}
+		},
+		TLSHandshakeStart: func() {
+			mu.Lock()
+			defer mu.Unlock()
+			tlsStart = time.Now()
+		},
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			p.TLS = time.Since(tlsStart)
+		},
+	})
+}
+
+// TraceDial returns ctx, which logs internals of the connection attempt made with it at 'trace' level
+// and protocol details at 'wire' level, if they are enabled.
+func TraceDial(ctx context.Context, logger *slog.Logger) context.Context {
+	if !logger.Enabled(ctx, LevelTrace) {
+		return ctx
+	}
+	logf := func(level slog.Level, format string, args ...any) {
+		logger.Log(ctx, level, fmt.Sprintf(format, args...))
+	}
+	return httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		DNSStart: func(info httptrace.DNSStartInfo) {
+			logf(LevelTrace, "resolving %s...", info.Host)
+		},
+		DNSDone: func(info httptrace.DNSDoneInfo) {
+			if info.Err != nil {
+				logf(LevelTrace, "resolving failed: %v", info.Err)
+				return
+			}
+			addrs := make([]string, len(info.Addrs))
+			for i, addr := range info.Addrs {
+				addrs[i] = addr.String()
+			}
+			logf(LevelTrace, "resolved to %s", strings.Join(addrs, ", "))
+		},
+		ConnectStart: func(network, addr string) {
+			logf(LevelTrace, "connecting to %s %s...", network, addr)
+		},
+		ConnectDone: func(network, addr string, err error) {
+			if err != nil {
+				logf(LevelTrace, "connecting to %s %s failed: %v", network, addr, err)
+				return
+			}
+			logf(LevelTrace, "connected to %s %s", network, addr)
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			logf(LevelWire, "connection %s -> %s", info.Conn.LocalAddr(), info.Conn.RemoteAddr())
+		},
+		TLSHandshakeStart: func() {
+			logf(LevelTrace, "TLS handshake...")
+		},
+		TLSHandshakeDone: func(state tls.ConnectionState, err error) {
+			if err != nil {
+				logf(LevelTrace, "TLS handshake failed: %v", err)
+				return
+			}
+			logf(LevelTrace, "TLS handshake done: %s, %s, server name %q",
+				tls.VersionName(state.Version), tls.CipherSuiteName(state.CipherSuite), state.ServerName)
+		},
+		WroteHeaderField: func(key string, value []string) {
+			logf(LevelWire, "> %s: %s", key, strings.Join(value, ", "))
+		},
+	})
+}
@@ -0,0 +1,82 @@
+//go:build !minimal
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestPhases(t *testing.T) {
+	t.Run("Test connect phase of TCP endpoint", func(t *testing.T) {
+		app := newApp()
+		r := app.Await(context.Background(), net.Dialer{}, startListener("").String())
+		if r.Err != nil {
+			t.Fatal(r.Err)
+		}
+		if r.Phases.Connect <= 0 || r.Phases.DNS != 0 || r.Phases.TLS != 0 {
+			t.Fatalf("Unexpected phases: %+v", r.Phases)
+		}
+	})
+
+	t.Run("Test DNS and connect phases of HTTP endpoint", func(t *testing.T) {
+		srv := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+		defer srv.Close()
+		app := newApp()
+		url := strings.Replace(srv.URL, "127.0.0.1", "localhost", 1)
+		r := app.Await(context.Background(), net.Dialer{}, url)
+		if r.Err != nil {
+			t.Fatal(r.Err)
+		}
+		if r.Phases.DNS <= 0 || r.Phases.Connect <= 0 {
+			t.Fatalf("Unexpected phases: %+v", r.Phases)
+		}
+		if s := r.Phases.String(); !strings.HasPrefix(s, "dns ") || !strings.Contains(s, ", connect ") {
+			t.Fatalf("Unexpected string: %s", s)
+		}
+	})
+
+	for _, tt := range []struct {
+		level    slog.Level
+		shown    []string
+		notShown []string
+	}{
+		{slog.LevelDebug, nil, []string{"resolving localhost", "< HTTP/1.1 204"}},
+		{LevelTrace, []string{"resolving localhost", "connected to tcp "}, []string{"> Host: ", "< HTTP/1.1 204"}},
+		{LevelWire, []string{"resolving localhost", "connection 127.0.0.1:", "> Host: ", "< HTTP/1.1 204 No Content"}, nil},
+	} {
+		t.Run("Test dial internals at level "+(&LogLevel{tt.level}).String(), func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(http.StatusNoContent)
+			}))
+			defer srv.Close()
+			var buf bytes.Buffer
+			log.SetOutput(&buf)
+			defer log.SetOutput(os.Stderr)
+			app := newApp()
+			app.logLevel.Level = tt.level
+			if r := app.Await(context.Background(), net.Dialer{}, strings.Replace(srv.URL, "127.0.0.1", "localhost", 1)); r.Err != nil {
+				t.Fatal(r.Err)
+			}
+			out := buf.String()
+			for _, s := range tt.shown {
+				if !strings.Contains(out, s) {
+					t.Fatalf("No %q in output: %s", s, out)
+				}
+			}
+			for _, s := range tt.notShown {
+				if strings.Contains(out, s) {
+					t.Fatalf("Unexpected %q in output: %s", s, out)
+				}
+			}
+		})
+	}
+}
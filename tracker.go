@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"github.com/jackcvr/tcpw/tcpw"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"time"
+)
+
+// EndpointState is the current state of an endpoint: 'waiting', 'up', 'failed' or 'timeout'.
+type EndpointState struct {
+	Endpoint string `json:"endpoint"`
+	State    string `json:"state"`
+	Attempts int    `json:"attempts"`
+	Error    string `json:"error,omitempty"`
+}
+
+// Tracker keeps the current state of endpoints and the command from events.
+// Methods of nil Tracker do nothing.
+type Tracker struct {
+	mu        sync.Mutex
+	start     time.Time
+	endpoints []*EndpointState
+	command   string
+}
+
+func NewTracker(endpoints []string) *Tracker {
+	t := &Tracker{start: time.Now()}
+	for _, addr := range endpoints {
+		t.endpoints = append(t.endpoints, &EndpointState{Endpoint: addr, State: "waiting"})
+	}
+	return t
+}
+
+func (t *Tracker) Update(e Event) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if e.Type == EventCommand {
+		t.command = e.State
+		return
+	}
+	for _, ep := range t.endpoints {
+		if ep.Endpoint != e.Endpoint {
+			continue
+		}
+		switch e.Type {
+		case EventAttempt:
+			ep.Attempts++
+			ep.Error = e.Error
+		case EventState:
+			ep.State = e.State
+		}
+	}
+}
+
+// Dump writes the current state to w with the time left of the timeout, if any, e.g. when asked with SIGUSR1.
+func (t *Tracker) Dump(w io.Writer, timeout time.Duration) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var b strings.Builder
+	elapsed := time.Since(t.start)
+	fmt.Fprintf(&b, "status after %v", elapsed.Round(time.Millisecond))
+	if timeout > 0 {
+		fmt.Fprintf(&b, ", %v left of %v timeout", max(timeout-elapsed, 0).Round(time.Millisecond), timeout)
+	}
+	b.WriteString(":\n")
+	for _, ep := range t.endpoints {
+		fmt.Fprintf(&b, "  %s: %s, %s", ep.Endpoint, ep.State, tcpw.Plural(ep.Attempts, "attempt"))
+		if ep.Error != "" {
+			fmt.Fprintf(&b, " (%s)", ep.Error)
+		}
+		b.WriteString("\n")
+	}
+	if t.command != "" {
+		fmt.Fprintf(&b, "  command: %s\n", t.command)
+	}
+	_, _ = io.WriteString(w, b.String())
+}
+
+// DumpOnSignal writes the current state to stderr on SIGUSR1 or SIGQUIT, without stopping,
+// until the returned function is called.
+func (app App) DumpOnSignal() func() {
+	if len(dumpSignals) == 0 {
+		return func() {}
+	}
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, dumpSignals...)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-ch:
+				app.tracker.Dump(os.Stderr, app.timeout-app.resume.Spent())
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() {
+		signal.Stop(ch)
+		close(done)
+	}
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTracker(t *testing.T) {
+	t.Run("Test state dump", func(t *testing.T) {
+		tracker := NewTracker([]string{"db", "cache"})
+		tracker.Update(Event{Type: EventAttempt, Endpoint: "db"})
+		tracker.Update(Event{Type: EventState, Endpoint: "db", State: "up"})
+		tracker.Update(Event{Type: EventAttempt, Endpoint: "cache", Error: "connection refused"})
+		tracker.Update(Event{Type: EventAttempt, Endpoint: "cache", Error: "connection refused"})
+		tracker.start = time.Now().Add(-time.Minute)
+		var b strings.Builder
+		tracker.Dump(&b, 3*time.Minute)
+		want := "status after 1m0s, 2m0s left of 3m0s timeout:\n" +
+			"  db: up, 1 attempt\n" +
+			"  cache: waiting, 2 attempts (connection refused)\n"
+		if b.String() != want {
+			t.Fatalf("Unexpected dump: %q", b.String())
+		}
+	})
+}
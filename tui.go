@@ -0,0 +1,293 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/jackcvr/tcpw/tcpw"
+	"io"
+	"net"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+const (
+	sparkLength = 20
+	sparkFailed = '×'
+)
+
+var sparkLevels = []rune("▁▂▃▄▅▆▇█")
+
+// DashboardRow is the state of an endpoint shown by 'tcpw tui'.
+type DashboardRow struct {
+	Addr     string
+	Label    string
+	Status   string
+	Attempts int
+	Latency  time.Duration   // of the last successful attempt
+	History  []time.Duration // latencies of the last attempts, negative for failed ones
+	Error    string          // of the last failed attempt
+	recheck  chan struct{}
+	cancel   context.CancelFunc
+}
+
+// Dashboard is a live table of endpoints of 'tcpw tui', which are probed every interval.
+type Dashboard struct {
+	mu       sync.Mutex
+	start    time.Time
+	rows     []*DashboardRow
+	selected int
+	color    bool
+}
+
+func NewDashboard(app App) *Dashboard {
+	d := &Dashboard{start: time.Now(), color: os.Getenv("NO_COLOR") == ""}
+	d.Sync(app)
+	return d
+}
+
+// Sync updates rows to the endpoints of the app: rows of removed endpoints are removed and their probes stopped,
+// and rows of new endpoints are added and returned to start probing them.
+func (d *Dashboard) Sync(app App) (added []*DashboardRow) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	rows := make([]*DashboardRow, 0, len(app.endpoints))
+	for _, addr := range app.endpoints {
+		i := slices.IndexFunc(d.rows, func(row *DashboardRow) bool { return row != nil && row.Addr == addr })
+		if i < 0 {
+			row := &DashboardRow{Addr: addr, Label: app.Label(addr), Status: "waiting", recheck: make(chan struct{}, 1)}
+			rows = append(rows, row)
+			added = append(added, row)
+			continue
+		}
+		d.rows[i].Label = app.Label(addr)
+		rows = append(rows, d.rows[i])
+		d.rows[i] = nil
+	}
+	for _, row := range d.rows {
+		if row != nil && row.cancel != nil {
+			row.cancel()
+		}
+	}
+	d.rows = rows
+	d.selected = max(min(d.selected, len(d.rows)-1), 0)
+	return added
+}
+
+// Add records the result of a connection attempt to the endpoint.
+func (d *Dashboard) Add(row *DashboardRow, latency time.Duration, err error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	row.Attempts++
+	if err != nil {
+		row.Status, row.Error = StatusDown, err.Error()
+		latency = -1
+	} else {
+		row.Status, row.Latency, row.Error = StatusUp, latency, ""
+	}
+	row.History = append(row.History, latency)
+	if len(row.History) > sparkLength {
+		row.History = row.History[len(row.History)-sparkLength:]
+	}
+}
+
+// Key handles a key press and reports whether the dashboard should be closed:
+// 'j'/'k' or arrows select an endpoint, 'r' re-checks it, 'R' re-checks all, 'd' removes it, 'q' quits.
+func (d *Dashboard) Key(key string) (quit bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	switch key {
+	case "q":
+		return true
+	case "j", "\033[B":
+		d.selected = max(min(d.selected+1, len(d.rows)-1), 0)
+	case "k", "\033[A":
+		d.selected = max(d.selected-1, 0)
+	case "r":
+		if d.selected < len(d.rows) {
+			d.rows[d.selected].Recheck()
+		}
+	case "R":
+		for _, row := range d.rows {
+			row.Recheck()
+		}
+	case "d":
+		if d.selected < len(d.rows) {
+			if cancel := d.rows[d.selected].cancel; cancel != nil {
+				cancel()
+			}
+			d.rows = append(d.rows[:d.selected], d.rows[d.selected+1:]...)
+			d.selected = max(min(d.selected, len(d.rows)-1), 0)
+		}
+	}
+	return false
+}
+
+// Recheck makes a connection attempt to the endpoint without waiting for the interval.
+func (row *DashboardRow) Recheck() {
+	select {
+	case row.recheck <- struct{}{}:
+	default:
+	}
+}
+
+// Sparkline returns latencies of the last attempts as bars relative to each other, '×' for failed ones.
+func (row *DashboardRow) Sparkline() string {
+	lowest, highest := time.Duration(-1), time.Duration(0)
+	for _, l := range row.History {
+		if l >= 0 && (lowest < 0 || l < lowest) {
+			lowest = l
+		}
+		highest = max(highest, l)
+	}
+	var b strings.Builder
+	for _, l := range row.History {
+		switch {
+		case l < 0:
+			b.WriteRune(sparkFailed)
+		case highest == lowest:
+			b.WriteRune(sparkLevels[0])
+		default:
+			b.WriteRune(sparkLevels[int(l-lowest)*(len(sparkLevels)-1)/int(highest-lowest)])
+		}
+	}
+	return b.String()
+}
+
+// Frame returns the text of the dashboard screen.
+func (d *Dashboard) Frame() string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var b strings.Builder
+	fmt.Fprintf(&b, "tcpw: %s, %v elapsed\n", tcpw.Plural(len(d.rows), "endpoint"), time.Since(d.start).Round(time.Second))
+	b.WriteString("j/k select, r re-check, R re-check all, d remove, q quit\n\n")
+	var table strings.Builder
+	w := tabwriter.NewWriter(&table, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "  ENDPOINT\tSTATE\tLATENCY\tATTEMPTS\tHISTORY\t")
+	for i, row := range d.rows {
+		cursor := " "
+		if i == d.selected {
+			cursor = ">"
+		}
+		latency := "-"
+		if row.Status == StatusUp {
+			latency = row.Latency.Round(time.Microsecond).String()
+		}
+		fmt.Fprintf(w, "%s %s\t%s\t%s\t%d\t%s\t\n", cursor, row.Label, row.Status, latency, row.Attempts, row.Sparkline())
+	}
+	_ = w.Flush()
+	lines := strings.SplitAfter(table.String(), "\n")
+	for i, row := range d.rows {
+		// colored after alignment, since escape sequences have no width
+		if color, ok := statusColors[row.Status]; ok && d.color {
+			line, start := lines[i+1], len(row.Label)+2
+			lines[i+1] = line[:start] + strings.Replace(line[start:], row.Status, color+row.Status+colorReset, 1)
+		}
+	}
+	b.WriteString(strings.Join(lines, ""))
+	if len(d.rows) > 0 {
+		if row := d.rows[d.selected]; row.Error != "" {
+			fmt.Fprintf(&b, "\n%s: %s\n", row.Label, row.Error)
+		}
+	}
+	return b.String()
+}
+
+// Probe makes a connection attempt to the endpoint every interval or on demand until ctx is done.
+func (d *Dashboard) Probe(ctx context.Context, app App, row *DashboardRow) {
+	dialer := net.Dialer{Timeout: app.interval}
+	if app.dialTimeout > 0 {
+		dialer.Timeout = app.dialTimeout
+	}
+	ticker := time.NewTicker(app.interval)
+	defer ticker.Stop()
+	for {
+		start := time.Now()
+		err := app.Dial(ctx, dialer, row.Addr)
+		if tcpw.Expired(ctx) {
+			return
+		}
+		d.Add(row, time.Since(start), err)
+		select {
+		case <-ticker.C:
+		case <-row.recheck:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// RunTUI runs 'tcpw tui' subcommand: a live dashboard of endpoints on the terminal until 'q' or Ctrl-C.
+func (app App) RunTUI() error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	d := NewDashboard(app)
+	probe := func(rows []*DashboardRow) {
+		for _, row := range rows {
+			var rowCtx context.Context
+			rowCtx, row.cancel = context.WithCancel(ctx)
+			go d.Probe(rowCtx, app, row)
+		}
+	}
+	probe(d.rows)
+
+	if restore, err := SetCbreakMode(os.Stdin); err == nil {
+		defer restore()
+	} else {
+		app.Debug("keys are read by line: %v", err)
+	}
+	keys := make(chan string)
+	go ReadKeys(os.Stdin, keys)
+	interrupts, stop := Interrupts()
+	defer stop()
+	hangups, stopHangups := app.Hangups()
+	defer stopHangups()
+
+	// alternate screen without cursor
+	fmt.Print("\033[?1049h\033[?25l")
+	defer fmt.Print("\033[?25h\033[?1049l")
+	ticker := time.NewTicker(250 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		fmt.Print("\033[H\033[2J" + d.Frame())
+		select {
+		case key, ok := <-keys:
+			if !ok || d.Key(key) {
+				return nil
+			}
+		case <-interrupts:
+			return nil
+		case <-hangups:
+			if app.ReloadOnHangup() {
+				probe(d.Sync(app))
+			}
+		case <-ticker.C:
+		}
+	}
+}
+
+// ReadKeys sends key presses read from r to the channel, arrows as their escape sequences,
+// and closes it at the end of input.
+func ReadKeys(r io.Reader, keys chan<- string) {
+	defer close(keys)
+	buf := make([]byte, 16)
+	for {
+		n, err := r.Read(buf)
+		if err != nil {
+			return
+		}
+		for s := string(buf[:n]); s != ""; {
+			key := s[:1]
+			if strings.HasPrefix(s, "\033[") && len(s) >= 3 {
+				key = s[:3]
+			}
+			s = s[len(key):]
+			if key != "\n" {
+				keys <- key
+			}
+		}
+	}
+}
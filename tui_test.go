@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDashboard(t *testing.T) {
+	t.Run("Test sparkline", func(t *testing.T) {
+		row := DashboardRow{History: []time.Duration{time.Millisecond, -1, 8 * time.Millisecond, 4500 * time.Microsecond}}
+		if got := row.Sparkline(); got != "▁×█▄" {
+			t.Fatalf("Unexpected sparkline: %s", got)
+		}
+	})
+
+	t.Run("Test probes and frame", func(t *testing.T) {
+		app := newApp()
+		app.interval = 50 * time.Millisecond
+		l := serve("localhost:0")
+		defer l.Close()
+		up := l.Addr().String()
+		down := getFreeTCPAddr().String()
+		app.endpoints = []string{up, down}
+		app.labels = Labels{up: "db"}
+		d := NewDashboard(app)
+		d.color = false
+		ctx, cancel := context.WithTimeout(context.Background(), 180*time.Millisecond)
+		defer cancel()
+		for _, row := range d.rows {
+			go d.Probe(ctx, app, row)
+		}
+		<-ctx.Done()
+		time.Sleep(10 * time.Millisecond)
+
+		frame := d.Frame()
+		for _, s := range []string{"tcpw: 2 endpoints", "> db ", "  " + down + " ", " up ", " down ", "×××"} {
+			if !strings.Contains(frame, s) {
+				t.Fatalf("No %q in frame:\n%s", s, frame)
+			}
+		}
+		d.Key("j")
+		frame = d.Frame()
+		for _, s := range []string{"> " + down + " ", down + ": dial tcp " + down + ": connect: connection refused"} {
+			if !strings.Contains(frame, s) {
+				t.Fatalf("No %q in frame:\n%s", s, frame)
+			}
+		}
+	})
+
+	t.Run("Test keys", func(t *testing.T) {
+		app := newApp()
+		app.endpoints = []string{"127.0.0.1:5432", "127.0.0.1:6379"}
+		d := NewDashboard(app)
+		if d.Key("j") || d.Key("j") || d.selected != 1 {
+			t.Fatalf("Wrong selection: %d", d.selected)
+		}
+		if d.Key("r"); len(d.rows[1].recheck) != 1 || len(d.rows[0].recheck) != 0 {
+			t.Fatal("Endpoint is not rechecked")
+		}
+		if d.Key("d"); len(d.rows) != 1 || d.rows[0].Addr != "127.0.0.1:5432" || d.selected != 0 {
+			t.Fatalf("Endpoint is not removed: %+v", d.rows)
+		}
+		if d.Key("d"); len(d.rows) != 0 || d.Key("j") || d.Key("r") {
+			t.Fatal("Unexpected quit")
+		}
+		if !d.Key("q") {
+			t.Fatal("Dashboard is not closed")
+		}
+	})
+
+	t.Run("Test reading keys", func(t *testing.T) {
+		keys := make(chan string)
+		go ReadKeys(strings.NewReader("j\033[Ar\n"), keys)
+		var got []string
+		for key := range keys {
+			got = append(got, key)
+		}
+		if strings.Join(got, ",") != "j,\033[A,r" {
+			t.Fatalf("Unexpected keys: %q", got)
+		}
+	})
+}
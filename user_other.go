@@ -0,0 +1,20 @@
+//go:build !unix
+
+package main
+
+import (
+	"errors"
+	"os/exec"
+)
+
+type User struct{}
+
+func (u *User) String() string {
+	return ""
+}
+
+func (u *User) Set(string) error {
+	return errors.New("not supported on this platform")
+}
+
+func (u User) Apply(*exec.Cmd) {}
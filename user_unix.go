@@ -0,0 +1,84 @@
+//go:build unix
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"os/user"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+type User struct {
+	*syscall.Credential
+}
+
+func (u *User) String() string {
+	if u == nil || u.Credential == nil {
+		return ""
+	}
+	return fmt.Sprintf("%d:%d", u.Uid, u.Gid)
+}
+
+func (u *User) Set(value string) error {
+	name, group, hasGroup := strings.Cut(value, ":")
+	uid, err := lookupID(name, func(name string) (string, error) {
+		usr, err := user.Lookup(name)
+		if err != nil {
+			return "", err
+		}
+		if !hasGroup {
+			group = usr.Gid
+		}
+		return usr.Uid, nil
+	})
+	if err != nil {
+		return err
+	}
+	if group == "" && !hasGroup {
+		if usr, err := user.LookupId(strconv.Itoa(int(uid))); err == nil {
+			group = usr.Gid
+		} else {
+			return errors.New("gid must be specified for unknown uid")
+		}
+	}
+	gid, err := lookupID(group, func(name string) (string, error) {
+		grp, err := user.LookupGroup(name)
+		if err != nil {
+			return "", err
+		}
+		return grp.Gid, nil
+	})
+	if err != nil {
+		return err
+	}
+	u.Credential = &syscall.Credential{Uid: uid, Gid: gid}
+	return nil
+}
+
+func (u User) Apply(cmd *exec.Cmd) {
+	if u.Credential != nil {
+		if cmd.SysProcAttr == nil {
+			cmd.SysProcAttr = &syscall.SysProcAttr{}
+		}
+		cmd.SysProcAttr.Credential = u.Credential
+	}
+}
+
+func lookupID(value string, lookup func(string) (string, error)) (uint32, error) {
+	if value == "" {
+		return 0, errors.New("empty user or group")
+	}
+	id, err := strconv.ParseUint(value, 10, 32)
+	if err == nil {
+		return uint32(id), nil
+	}
+	if value, err = lookup(value); err != nil {
+		return 0, err
+	}
+	id, err = strconv.ParseUint(value, 10, 32)
+	return uint32(id), err
+}
@@ -0,0 +1,45 @@
+//go:build unix
+
+package main
+
+import (
+	"os"
+	"strconv"
+	"testing"
+)
+
+func TestUserSet(t *testing.T) {
+	t.Run("Test numeric uid and gid", func(t *testing.T) {
+		var u User
+		if err := u.Set("1234:5678"); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if u.Uid != 1234 || u.Gid != 5678 {
+			t.Fatalf("Wrong credential: %v", u.String())
+		}
+	})
+
+	t.Run("Test current user without gid", func(t *testing.T) {
+		var u User
+		if err := u.Set(strconv.Itoa(os.Getuid())); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if int(u.Uid) != os.Getuid() || int(u.Gid) != os.Getgid() {
+			t.Fatalf("Wrong credential: %v", u.String())
+		}
+	})
+
+	t.Run("Test error: unknown user", func(t *testing.T) {
+		var u User
+		if err := u.Set("no-such-user-tcpw"); err == nil {
+			t.Fatal("Unexpected success")
+		}
+	})
+
+	t.Run("Test error: empty gid", func(t *testing.T) {
+		var u User
+		if err := u.Set("1234:"); err == nil {
+			t.Fatal("Unexpected success")
+		}
+	})
+}
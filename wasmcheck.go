@@ -0,0 +1,242 @@
+//go:build !minimal
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/jackcvr/tcpw/internal/wasm"
+	"github.com/jackcvr/tcpw/tcpw"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"time"
+)
+
+// wasmCheckType is the type of the function 'check' exported by modules of '-wasm-check'.
+var wasmCheckType = wasm.FuncType{Results: []wasm.ValType{wasm.I32}}
+
+// wasmStepsPerSecond is the step budget of a '-wasm-check' run per second of '-w',
+// so a module spinning in a loop fails about when a connection attempt would time out.
+const wasmStepsPerSecond = 1 << 24
+
+// WasmSteps returns how many instructions a '-wasm-check' run may execute: in proportion to '-w' if it is set,
+// or wasm.DefaultMaxSteps.
+func (app App) WasmSteps() int {
+	if app.dialTimeout <= 0 {
+		return wasm.DefaultMaxSteps
+	}
+	return max(1, int(app.dialTimeout.Seconds()*wasmStepsPerSecond))
+}
+
+// LoadWasm reads and decodes the module of '-wasm-check', which must export the function 'check'.
+func LoadWasm(path string) (*wasm.Module, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	m, err := wasm.Decode(b)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if ft, ok := m.ExportedFunc("check"); !ok || !ft.Equal(wasmCheckType) {
+		return nil, fmt.Errorf("%s: no exported function 'check' of type %s", path, wasmCheckType)
+	}
+	return m, nil
+}
+
+// WasmCheck runs the module of '-wasm-check' instead of connecting to the endpoint, which is available
+// once the exported function 'check' returns zero. The module is read again on every attempt, so it may be rebuilt while waiting.
+func (app App) WasmCheck(ctx context.Context, dial tcpw.DialFunc, addr string) error {
+	m, err := LoadWasm(app.wasmCheck)
+	if err != nil {
+		return err
+	}
+	wc := &wasmCheck{app: app, ctx: ctx, dial: dial, addr: addr}
+	defer wc.close()
+	inst, err := wasm.Instantiate(ctx, m, wc.imports(), app.WasmSteps())
+	if err != nil {
+		return fmt.Errorf("%s: %w", app.wasmCheck, err)
+	}
+	results, err := inst.Call(ctx, "check")
+	if err != nil {
+		return err
+	}
+	if code := int32(results[0]); code != 0 {
+		if wc.failure != "" {
+			return errors.New(wc.failure)
+		}
+		if wc.lastErr != nil {
+			return wc.lastErr
+		}
+		return fmt.Errorf("check returned %d", code)
+	}
+	return nil
+}
+
+// wasmCheck is the state of a single run of a '-wasm-check' module: its connections to the endpoint,
+// the message of fail() and the last error of dial(), read() or write().
+type wasmCheck struct {
+	app     App
+	ctx     context.Context
+	dial    tcpw.DialFunc
+	addr    string
+	conns   []*ScriptConn // nil once closed
+	failure string
+	lastErr error
+}
+
+func (wc *wasmCheck) close() {
+	for _, c := range wc.conns {
+		if c != nil {
+			_ = c.conn.Close()
+		}
+	}
+}
+
+// imports are the host functions of module 'tcpw'. Pointers and lengths are in the memory exported by the module as 'memory'.
+// Functions returning i32 return -1 on errors, which are reported by the check unless it calls fail().
+func (wc *wasmCheck) imports() wasm.Imports {
+	i32 := wasm.I32
+	fn := func(params []wasm.ValType, results []wasm.ValType, f func(inst *wasm.Instance, args []uint64) (int32, error)) wasm.HostFunc {
+		return wasm.HostFunc{
+			Type: wasm.FuncType{Params: params, Results: results},
+			Fn: func(inst *wasm.Instance, args []uint64) ([]uint64, error) {
+				n, err := f(inst, args)
+				if len(results) == 0 {
+					return nil, err
+				}
+				return []uint64{uint64(uint32(n))}, err
+			},
+		}
+	}
+	return wasm.Imports{"tcpw": {
+		// endpoint(ptr, cap) writes the endpoint being checked and returns its length, which may be above cap
+		"endpoint": fn([]wasm.ValType{i32, i32}, []wasm.ValType{i32}, func(inst *wasm.Instance, args []uint64) (int32, error) {
+			buf, err := wasmMemory(inst, args[0], args[1])
+			if err != nil {
+				return 0, err
+			}
+			copy(buf, wc.addr)
+			return int32(len(wc.addr)), nil
+		}),
+		// dial() connects to the endpoint and returns the connection
+		"dial": fn(nil, []wasm.ValType{i32}, func(inst *wasm.Instance, args []uint64) (int32, error) {
+			c, err := wc.connect()
+			if err != nil {
+				wc.lastErr = err
+				return -1, nil
+			}
+			wc.conns = append(wc.conns, c)
+			return int32(len(wc.conns) - 1), nil
+		}),
+		// read(conn, ptr, len) returns the number of bytes read, zero once the connection is closed
+		"read": fn([]wasm.ValType{i32, i32, i32}, []wasm.ValType{i32}, func(inst *wasm.Instance, args []uint64) (int32, error) {
+			c, buf, err := wc.conn(inst, args)
+			if err != nil {
+				return 0, err
+			}
+			var n int
+			err = wc.io(c, func() error {
+				n, err = c.r.Read(buf)
+				return err
+			})
+			if n > 0 {
+				wc.app.Wire("< %q", buf[:n])
+			} else if err != nil && !errors.Is(err, io.EOF) {
+				wc.lastErr = err
+				return -1, nil
+			}
+			return int32(n), nil
+		}),
+		// write(conn, ptr, len) returns the number of bytes written
+		"write": fn([]wasm.ValType{i32, i32, i32}, []wasm.ValType{i32}, func(inst *wasm.Instance, args []uint64) (int32, error) {
+			c, buf, err := wc.conn(inst, args)
+			if err != nil {
+				return 0, err
+			}
+			wc.app.Wire("> %q", buf)
+			var n int
+			if err = wc.io(c, func() error {
+				n, err = c.conn.Write(buf)
+				return err
+			}); err != nil {
+				wc.lastErr = err
+				return -1, nil
+			}
+			return int32(n), nil
+		}),
+		"close": fn([]wasm.ValType{i32}, []wasm.ValType{i32}, func(inst *wasm.Instance, args []uint64) (int32, error) {
+			if fd := args[0]; fd < uint64(len(wc.conns)) && wc.conns[fd] != nil {
+				err := wc.conns[fd].conn.Close()
+				wc.conns[fd] = nil
+				if err != nil {
+					wc.lastErr = err
+					return -1, nil
+				}
+				return 0, nil
+			}
+			return 0, fmt.Errorf("unknown connection %d", int32(args[0]))
+		}),
+		// log(ptr, len) logs the message at debug level
+		"log": fn([]wasm.ValType{i32, i32}, nil, func(inst *wasm.Instance, args []uint64) (int32, error) {
+			msg, err := wasmMemory(inst, args[0], args[1])
+			if err == nil {
+				wc.app.Debug("%s: %s", wc.app.wasmCheck, msg)
+			}
+			return 0, err
+		}),
+		// fail(ptr, len) sets the error of the check, once it returns non-zero
+		"fail": fn([]wasm.ValType{i32, i32}, nil, func(inst *wasm.Instance, args []uint64) (int32, error) {
+			msg, err := wasmMemory(inst, args[0], args[1])
+			wc.failure = string(msg)
+			return 0, err
+		}),
+	}}
+}
+
+// connect connects to the endpoint on the network of its scheme: TCP, UDP and unix sockets, or TLS of 'tls' and 'https' endpoints.
+func (wc *wasmCheck) connect() (*ScriptConn, error) {
+	switch scheme := tcpw.Scheme(wc.addr); scheme {
+	case "tcp", "http", "udp", "tls", "https":
+		network := map[string]string{"http": "tcp", "https": "tls"}[scheme]
+		if network == "" {
+			network = scheme
+		}
+		return wc.app.dialScript(wc.ctx, wc.dial, network, net.JoinHostPort(tcpw.HostPort(wc.addr)))
+	case "unix":
+		return wc.app.dialScript(wc.ctx, wc.dial, "unix", strings.TrimPrefix(wc.addr, "unix://"))
+	default:
+		return nil, fmt.Errorf("dial: unsupported endpoint '%s'", wc.addr)
+	}
+}
+
+// conn returns the connection and the buffer of read() and write() arguments.
+func (wc *wasmCheck) conn(inst *wasm.Instance, args []uint64) (*ScriptConn, []byte, error) {
+	fd := args[0]
+	if fd >= uint64(len(wc.conns)) || wc.conns[fd] == nil {
+		return nil, nil, fmt.Errorf("unknown connection %d", int32(fd))
+	}
+	buf, err := wasmMemory(inst, args[1], args[2])
+	return wc.conns[fd], buf, err
+}
+
+// io runs the read or the write, which is interrupted once the attempt is canceled.
+func (wc *wasmCheck) io(c *ScriptConn, fn func() error) error {
+	stop := context.AfterFunc(wc.ctx, func() {
+		_ = c.conn.SetDeadline(time.Now())
+	})
+	defer stop()
+	return fn()
+}
+
+// wasmMemory returns the bytes of the memory exported by the module at the pointer.
+func wasmMemory(inst *wasm.Instance, ptr, n uint64) ([]byte, error) {
+	mem := inst.Memory()
+	if end := ptr + n; end > uint64(len(mem)) {
+		return nil, fmt.Errorf("out of bounds memory access at %d", uint32(ptr))
+	}
+	return mem[ptr : ptr+n], nil
+}
@@ -0,0 +1,122 @@
+//go:build !minimal
+
+package main
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// wasmSection encodes a section of a module, whose contents are shorter than 128 bytes.
+func wasmSection(id byte, contents ...byte) []byte {
+	return append([]byte{id, byte(len(contents))}, contents...)
+}
+
+// pingModule sends PING to the endpoint and fails unless the reply starts with '+'.
+var pingModule = strings.Join([]string{
+	"\x00asm\x01\x00\x00\x00",
+	string(wasmSection(1, 3, 0x60, 0, 1, 0x7f, 0x60, 3, 0x7f, 0x7f, 0x7f, 1, 0x7f, 0x60, 2, 0x7f, 0x7f, 0)),
+	string(wasmSection(2, append(append(append(
+		append([]byte{4}, "\x04tcpw\x04dial\x00\x00"...),
+		"\x04tcpw\x05write\x00\x01"...),
+		"\x04tcpw\x04read\x00\x01"...),
+		"\x04tcpw\x04fail\x00\x02"...)...)),
+	string(wasmSection(3, 1, 0)),
+	string(wasmSection(5, 1, 0, 1)),
+	string(wasmSection(7, append([]byte{2}, "\x05check\x00\x04\x06memory\x02\x00"...)...)),
+	string(wasmSection(10, 1, 59, 1, 1, 0x7f,
+		// c = dial(); if c < 0 { return 1 }
+		0x10, 0x00, 0x22, 0x00, 0x41, 0x00, 0x48, 0x04, 0x40, 0x41, 0x01, 0x0f, 0x0b,
+		// write(c, "PING\r\n")
+		0x20, 0x00, 0x41, 0x00, 0x41, 0x06, 0x10, 0x01, 0x1a,
+		// read(c, 64, 16)
+		0x20, 0x00, 0x41, 0xc0, 0x00, 0x41, 0x10, 0x10, 0x02, 0x1a,
+		// if mem[64] != '+' { fail("unexpected reply"); return 2 }
+		0x41, 0xc0, 0x00, 0x2d, 0x00, 0x00, 0x41, 0x2b, 0x47, 0x04, 0x40, 0x41, 0x10, 0x41, 0x10, 0x10, 0x03, 0x41, 0x02, 0x0f, 0x0b,
+		0x41, 0x00, 0x0b)),
+	string(wasmSection(11, append([]byte{2, 0, 0x41, 0x00, 0x0b, 6}, "PING\r\n\x00\x41\x10\x0b\x10unexpected reply"...)...)),
+}, "")
+
+func TestWasmCheck(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "ping.wasm")
+	if err := os.WriteFile(path, []byte(pingModule), 0644); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	t.Run("Test check", func(t *testing.T) {
+		addr := serveRedis(t)
+		l := serve("127.0.0.1:0")
+		defer l.Close()
+		var app App
+		if err := app.Parse("tcpw", []string{"-wasm-check", path, "-a", addr, "-a", l.Addr().String()}); err != nil {
+			t.Fatal(err)
+		}
+		if err := app.Check(); err != nil {
+			t.Fatal(err)
+		}
+		if err := app.Dial(ctx, net.Dialer{}, app.endpoints[0]); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if err := app.Dial(ctx, net.Dialer{}, app.endpoints[1]); err == nil || err.Error() != "unexpected reply" {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		err := app.Dial(ctx, net.Dialer{}, getFreeTCPAddr().String())
+		if err == nil || !strings.HasPrefix(err.Error(), "dial tcp") || !strings.HasSuffix(err.Error(), "connection refused") {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if err := app.Dial(ctx, net.Dialer{}, "path:///tmp"); err == nil || err.Error() != "dial: unsupported endpoint 'path:///tmp'" {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	})
+
+	t.Run("Test invalid module", func(t *testing.T) {
+		noCheck := filepath.Join(dir, "empty.wasm")
+		if err := os.WriteFile(noCheck, []byte("\x00asm\x01\x00\x00\x00"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		// check returns i64
+		badCode := filepath.Join(dir, "bad.wasm")
+		if err := os.WriteFile(badCode, []byte("\x00asm\x01\x00\x00\x00"+string(wasmSection(1, 1, 0x60, 0, 1, 0x7f))+
+			string(wasmSection(3, 1, 0))+string(wasmSection(7, append([]byte{1}, "\x05check\x00\x00"...)...))+
+			string(wasmSection(10, 1, 4, 0, 0x42, 0x00, 0x0b))), 0644); err != nil {
+			t.Fatal(err)
+		}
+		for args, want := range map[string]string{
+			"-wasm-check " + noCheck:                   noCheck + ": no exported function 'check' of type [] -> [i32]",
+			"-wasm-check " + badCode:                   badCode + ": invalid module: offset 0x26: type mismatch: expected i32, got i64",
+			"-wasm-check " + path + " -check-cmd true": "'-wasm-check' can not be combined with '-check-cmd'",
+		} {
+			app := newApp()
+			if err := app.Parse("tcpw", append(strings.Fields(args), "-a", "localhost:80")); err != nil {
+				t.Fatal(err)
+			}
+			if err := app.Check(); err == nil || err.Error() != want {
+				t.Fatalf("Unexpected error of %s: %v", args, err)
+			}
+		}
+	})
+}
+
+func TestWasmSteps(t *testing.T) {
+	for args, want := range map[string]int{
+		"":        1 << 24,
+		"-w 2s":   1 << 25,
+		"-w 10ms": 167772,
+	} {
+		app := newApp()
+		if err := app.Parse("tcpw", append(strings.Fields(args), "-a", "localhost:80")); err != nil {
+			t.Fatal(err)
+		}
+		if steps := app.WasmSteps(); steps != want {
+			t.Fatalf("Unexpected steps of %q: %d", args, steps)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"text/template"
+	"time"
+)
+
+const DefaultWebhookBody = `{"status":{{json .Status}},"endpoints":{{json .Endpoints}},"failed":{{json .Failed}},` +
+	`"duration_ms":{{.DurationMs}},"error":{{json .Error}}}`
+
+// WebhookData holds values for the webhook body template.
+type WebhookData struct {
+	Status     string
+	Endpoints  []string
+	Failed     []string
+	DurationMs int64
+	Error      string
+}
+
+func NewWebhookData(results []EndpointResult, elapsed time.Duration, err error) WebhookData {
+	data := WebhookData{
+		Status:     Status(err),
+		Endpoints:  []string{},
+		Failed:     []string{},
+		DurationMs: elapsed.Milliseconds(),
+	}
+	for _, r := range results {
+		data.Endpoints = append(data.Endpoints, r.Name())
+		if !r.Ready {
+			data.Failed = append(data.Failed, r.Name())
+		}
+	}
+	if err != nil {
+		data.Error = err.Error()
+	}
+	return data
+}
+
+// Status returns 'success', 'timeout' or 'failure' depending on the error of Connect.
+func Status(err error) string {
+	if err == nil {
+		return "success"
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return "timeout"
+	}
+	return "failure"
+}
+
+func ParseWebhookBody(body string) (*template.Template, error) {
+	return template.New("webhook").Funcs(template.FuncMap{
+		"json": func(v any) (string, error) {
+			b, err := json.Marshal(v)
+			return string(b), err
+		},
+	}).Parse(body)
+}
+
+// Webhook sends the result of the wait to the '-webhook' URL.
+func (app App) Webhook(results []EndpointResult, elapsed time.Duration, connErr error) error {
+	tmpl, err := ParseWebhookBody(app.webhookBody)
+	if err != nil {
+		return err
+	}
+	var body strings.Builder
+	if err = tmpl.Execute(&body, NewWebhookData(results, elapsed, connErr)); err != nil {
+		return err
+	}
+	return PostJSON(app.webhookMethod, app.webhook, body.String())
+}
@@ -0,0 +1,44 @@
+//go:build !minimal
+
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestWebhook(t *testing.T) {
+	bodies := make(chan []byte, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		bodies <- body
+	}))
+	t.Cleanup(srv.Close)
+
+	app := newApp()
+	app.timeout = 100 * time.Millisecond
+	addr := getFreeTCPAddr().String()
+	app.endpoints = []string{addr}
+	app.webhook = srv.URL
+	app.webhookMethod = http.MethodPost
+	app.webhookBody = DefaultWebhookBody
+	if err := app.Run(); err == nil {
+		t.Fatal("Connection succeeded on fail test")
+	}
+
+	var data WebhookData
+	if err := json.Unmarshal(<-bodies, &data); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if data.Status != "timeout" || len(data.Failed) != 1 || data.Failed[0] != addr {
+		t.Fatalf("Wrong webhook data: %+v", data)
+	}
+}
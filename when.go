@@ -0,0 +1,287 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/jackcvr/tcpw/tcpw"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// ErrConditionNotMet is the error of the wait, which ended without the '-when' condition met
+// and without failures of endpoints to blame, e.g. because an endpoint negated with '!' is available.
+var ErrConditionNotMet = errors.New("condition is not met")
+
+// errConditionMet cancels the wait for the rest of endpoints once the '-when' condition is met.
+var errConditionMet = errors.New("condition is met")
+
+// Condition is the value of '-when' flag: a boolean expression over labels of endpoints
+// with '&&', '||', '!' operators and parentheses, e.g. 'db && (cache1 || cache2) && !legacy'.
+// A label is true once its endpoint is available and false once it failed or timed out,
+// so 'db && !legacy' is met once 'db' is available and 'legacy' is not in time.
+type Condition struct {
+	src  string
+	root condNode
+}
+
+type condNode interface {
+	// eval returns the value of the node and whether it is known, see Condition.Eval.
+	eval(value func(label string) (bool, bool)) (bool, bool)
+}
+
+type (
+	condLabel string
+	condNot   struct{ x condNode }
+	condAnd   struct{ x, y condNode }
+	condOr    struct{ x, y condNode }
+)
+
+func (n condLabel) eval(value func(string) (bool, bool)) (bool, bool) {
+	return value(string(n))
+}
+
+func (n condNot) eval(value func(string) (bool, bool)) (bool, bool) {
+	v, ok := n.x.eval(value)
+	return ok && !v, ok
+}
+
+func (n condAnd) eval(value func(string) (bool, bool)) (bool, bool) {
+	x, xok := n.x.eval(value)
+	y, yok := n.y.eval(value)
+	if (xok && !x) || (yok && !y) {
+		return false, true
+	}
+	return xok && yok && x && y, xok && yok
+}
+
+func (n condOr) eval(value func(string) (bool, bool)) (bool, bool) {
+	x, xok := n.x.eval(value)
+	y, yok := n.y.eval(value)
+	if (xok && x) || (yok && y) {
+		return true, true
+	}
+	return false, xok && yok
+}
+
+// ParseCondition parses the '-when' condition.
+func ParseCondition(src string) (*Condition, error) {
+	p := condParser{src: src}
+	p.next()
+	root, err := p.or()
+	if err == nil && p.tok != "" {
+		err = p.unexpected()
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &Condition{src: src, root: root}, nil
+}
+
+func (c *Condition) String() string {
+	return c.src
+}
+
+// Labels returns the labels used in the condition in order of appearance, without duplicates.
+func (c *Condition) Labels() []string {
+	var labels []string
+	var walk func(n condNode)
+	walk = func(n condNode) {
+		switch n := n.(type) {
+		case condLabel:
+			if !slices.Contains(labels, string(n)) {
+				labels = append(labels, string(n))
+			}
+		case condNot:
+			walk(n.x)
+		case condAnd:
+			walk(n.x)
+			walk(n.y)
+		case condOr:
+			walk(n.x)
+			walk(n.y)
+		}
+	}
+	walk(c.root)
+	return labels
+}
+
+// Eval evaluates the condition with values of labels, which are unknown if ok is false,
+// e.g. of endpoints which are still awaited. The result is unknown if it depends on unknown values,
+// e.g. 'db || cache' is known to be true once 'db' is, while 'db && cache' is not.
+func (c *Condition) Eval(value func(label string) (v, ok bool)) (v, ok bool) {
+	return c.root.eval(value)
+}
+
+// Check returns an error if the condition uses labels of none of the endpoints.
+func (c *Condition) Check(labels []string) error {
+	for _, label := range c.Labels() {
+		if !slices.Contains(labels, label) {
+			return fmt.Errorf("unknown label '%s' in '-when' condition, expected %s", label, tcpw.QuoteList(labels))
+		}
+	}
+	return nil
+}
+
+type condParser struct {
+	src string
+	pos int    // of tok
+	end int    // of tok
+	tok string // empty at the end of src
+}
+
+func (p *condParser) next() {
+	p.pos = p.end
+	for p.pos < len(p.src) && (p.src[p.pos] == ' ' || p.src[p.pos] == '\t') {
+		p.pos++
+	}
+	p.end = p.pos
+	switch {
+	case p.end == len(p.src):
+	case strings.HasPrefix(p.src[p.end:], "&&"), strings.HasPrefix(p.src[p.end:], "||"):
+		p.end += 2
+	case strings.ContainsRune("!()", rune(p.src[p.end])):
+		p.end++
+	default:
+		for p.end < len(p.src) && labelRe.MatchString(p.src[p.end:p.end+1]) {
+			p.end++
+		}
+		if p.end == p.pos {
+			p.end++
+		}
+	}
+	p.tok = p.src[p.pos:p.end]
+}
+
+func (p *condParser) unexpected() error {
+	if p.tok == "" {
+		return fmt.Errorf("unexpected end of '-when' condition '%s'", p.src)
+	}
+	return fmt.Errorf("unexpected '%s' at position %d of '-when' condition '%s'", p.tok, p.pos+1, p.src)
+}
+
+func (p *condParser) or() (condNode, error) {
+	x, err := p.and()
+	for err == nil && p.tok == "||" {
+		p.next()
+		var y condNode
+		if y, err = p.and(); err == nil {
+			x = condOr{x, y}
+		}
+	}
+	return x, err
+}
+
+func (p *condParser) and() (condNode, error) {
+	x, err := p.unary()
+	for err == nil && p.tok == "&&" {
+		p.next()
+		var y condNode
+		if y, err = p.unary(); err == nil {
+			x = condAnd{x, y}
+		}
+	}
+	return x, err
+}
+
+func (p *condParser) unary() (condNode, error) {
+	switch {
+	case p.tok == "!":
+		p.next()
+		x, err := p.unary()
+		return condNot{x}, err
+	case p.tok == "(":
+		p.next()
+		x, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if p.tok != ")" {
+			return nil, p.unexpected()
+		}
+		p.next()
+		return x, nil
+	case p.tok != "" && labelRe.MatchString(p.tok):
+		label := condLabel(p.tok)
+		p.next()
+		return label, nil
+	default:
+		return nil, p.unexpected()
+	}
+}
+
+// Verdict decides the wait with the '-when' condition as endpoints are done.
+// A label is available once all endpoints with it are.
+type Verdict struct {
+	cond    *Condition
+	mu      sync.Mutex
+	pending map[string]int  // number of endpoints with the label, which are not done yet
+	failed  map[string]bool // whether any endpoint with the label is not available
+}
+
+func NewVerdict(cond *Condition, labels []string) *Verdict {
+	v := &Verdict{cond: cond, pending: map[string]int{}, failed: map[string]bool{}}
+	for _, label := range labels {
+		v.pending[label]++
+	}
+	return v
+}
+
+// Decide returns errConditionMet if the condition is met, even while endpoints are still pending,
+// or ErrConditionNotMet if it can not be met anymore.
+func (v *Verdict) Decide() error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	met, ok := v.cond.Eval(func(label string) (bool, bool) {
+		return !v.failed[label], v.pending[label] == 0 || v.failed[label]
+	})
+	switch {
+	case !ok:
+		return nil
+	case met:
+		return errConditionMet
+	default:
+		return ErrConditionNotMet
+	}
+}
+
+// Done records the result of an endpoint with the label and decides the wait, see Decide.
+func (v *Verdict) Done(label string, ready bool) error {
+	v.mu.Lock()
+	v.pending[label]--
+	if !ready {
+		v.failed[label] = true
+	}
+	v.mu.Unlock()
+	return v.Decide()
+}
+
+// WaitErr returns the error of the wait, see WaitErr. With the '-when' condition,
+// only errors of commands executed with '-each' matter once it is met,
+// otherwise the error of the endpoint in the condition which failed first, or ErrConditionNotMet.
+func (app App) WaitErr(results []EndpointResult) error {
+	if app.condition == nil {
+		return WaitErr(results)
+	}
+	labels := make([]string, len(results))
+	for i, r := range results {
+		labels[i] = r.Name()
+	}
+	v := NewVerdict(app.condition, labels)
+	for _, r := range results {
+		_ = v.Done(r.Name(), r.Ready)
+	}
+	if v.Decide() == errConditionMet {
+		// the rest of endpoints were canceled once the condition was met
+		return WaitErr(slices.DeleteFunc(slices.Clone(results), func(r EndpointResult) bool { return !r.Executed }))
+	}
+	// endpoints which are not in the condition are not to blame
+	err := WaitErr(slices.DeleteFunc(slices.Clone(results), func(r EndpointResult) bool {
+		return !slices.Contains(app.condition.Labels(), r.Name())
+	}))
+	if err == nil || errors.Is(err, context.Canceled) {
+		return fmt.Errorf("%w: %s", ErrConditionNotMet, app.condition)
+	}
+	return err
+}
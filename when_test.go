@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCondition(t *testing.T) {
+	t.Run("Test eval", func(t *testing.T) {
+		cond, err := ParseCondition("db && (cache1 || cache2) && !legacy")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if labels := cond.Labels(); len(labels) != 4 || labels[3] != "legacy" {
+			t.Fatalf("Unexpected labels: %v", labels)
+		}
+		for _, test := range []struct {
+			values    map[string]bool // unknown if missing
+			want, had bool
+		}{
+			{map[string]bool{"db": true, "cache2": true, "legacy": false}, true, true},
+			{map[string]bool{"db": true, "cache1": false, "cache2": false}, false, true},
+			{map[string]bool{"db": true, "cache1": true}, false, false},
+			{map[string]bool{"legacy": true}, false, true},
+		} {
+			v, ok := cond.Eval(func(label string) (bool, bool) {
+				v, ok := test.values[label]
+				return v, ok
+			})
+			if v != test.want || ok != test.had {
+				t.Fatalf("Unexpected result with %v: %v, %v", test.values, v, ok)
+			}
+		}
+	})
+
+	t.Run("Test errors", func(t *testing.T) {
+		for src, want := range map[string]string{
+			"db &&":        "unexpected end of '-when' condition 'db &&'",
+			"(db || cache": "unexpected end of '-when' condition '(db || cache'",
+			"db cache":     "unexpected 'cache' at position 4 of '-when' condition 'db cache'",
+			"db & cache":   "unexpected '&' at position 4 of '-when' condition 'db & cache'",
+		} {
+			if _, err := ParseCondition(src); err == nil || err.Error() != want {
+				t.Fatalf("Unexpected error of %q: %v", src, err)
+			}
+		}
+		cond, _ := ParseCondition("db || redis")
+		if err := cond.Check([]string{"db", "cache"}); err == nil || err.Error() != "unknown label 'redis' in '-when' condition, expected 'db' or 'cache'" {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	})
+
+	t.Run("Test wait", func(t *testing.T) {
+		app := newApp()
+		app.timeout = 5 * time.Second
+		l := serve("localhost:0")
+		defer l.Close()
+		up := l.Addr().String()
+		down := getFreeTCPAddr().String()
+		app.endpoints = []string{up, down, badAddr}
+		app.labels = Labels{up: "db", down: "cache", badAddr: "legacy"}
+		for src, want := range map[string]string{
+			"db && (cache || !legacy)": "",
+			"db && legacy":             badAddrError,
+			"!db || legacy":            badAddrError,
+			"!db":                      "condition is not met: !db",
+			"db && !legacy":            "",
+		} {
+			app.condition, _ = ParseCondition(src)
+			start := time.Now()
+			err := app.WaitErr(app.Connect(context.Background()))
+			if time.Since(start) > time.Second {
+				t.Fatalf("Condition %q is not decided before the timeout", src)
+			}
+			if (want == "" && err != nil) || (want != "" && (err == nil || !strings.Contains(err.Error(), want))) {
+				t.Fatalf("Unexpected error of %q: %v", src, err)
+			}
+		}
+	})
+}